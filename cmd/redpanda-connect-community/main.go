@@ -16,6 +16,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 
@@ -31,9 +33,18 @@ var (
 	BinaryName string = "redpanda-connect"
 )
 
+var (
+	// extraCLIOpts are added to the options of the CLI by files that are only
+	// built with specific tags.
+	extraCLIOpts []service.CLIOptFunc
+
+	// exitHooks are called with the error that the CLI exited with, if any,
+	// before the process exits.
+	exitHooks []func(err error)
+)
+
 func main() {
-	service.RunCLI(
-		context.Background(),
+	opts := []service.CLIOptFunc{
 		service.CLIOptSetVersion(Version, DateBuilt),
 		service.CLIOptSetBinaryName(BinaryName),
 		service.CLIOptSetProductName("Redpanda Connect"),
@@ -54,5 +65,16 @@ func main() {
 			"/etc/benthos.yaml",
 		),
 		service.CLIOptSetDocumentationURL("https://docs.redpanda.com/redpanda-connect"),
-	)
+	}
+
+	exitCode, err := service.RunCLIToCode(context.Background(), append(opts, extraCLIOpts...)...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	for _, fn := range exitHooks {
+		fn(err)
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
@@ -0,0 +1,58 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+//go:build x_rcl_topic_logger
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/rs/xid"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/kafka/enterprise"
+)
+
+// The topic logger is licensed under the Redpanda Community License, and is
+// therefore only linked into the community distribution when it is built with
+// the x_rcl_topic_logger tag. It sends logs and status updates to the Redpanda
+// topics configured within the `redpanda` section of the config.
+func init() {
+	rpLogger := enterprise.NewTopicLogger(xid.New().String())
+
+	extraCLIOpts = append(extraCLIOpts,
+		service.CLIOptSetMainSchemaFrom(func() *service.ConfigSchema {
+			return service.GlobalEnvironment().
+				FullConfigSchema(Version, DateBuilt).
+				Field(service.NewObjectField("redpanda", enterprise.TopicLoggerFields()...))
+		}),
+		service.CLIOptOnLoggerInit(func(l *service.Logger) {
+			rpLogger.SetFallbackLogger(l)
+		}),
+		service.CLIOptAddTeeLogger(slog.New(rpLogger)),
+		service.CLIOptOnConfigParse(func(pConf *service.ParsedConfig) error {
+			return rpLogger.InitOutputFromParsed(pConf.Namespace("redpanda"))
+		}),
+		service.CLIOptOnStreamStart(func(s *service.RunningStreamSummary) error {
+			rpLogger.SetStreamSummary(s)
+			return nil
+		}),
+	)
+
+	exitHooks = append(exitHooks, func(err error) {
+		rpLogger.TriggerEventStopped(err)
+
+		// Pending logs are flushed unless the cluster is unreachable.
+		closeCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+		_ = rpLogger.Close(closeCtx)
+		done()
+	})
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/redpanda-data/connect/v4/public/schema"
 
+	_ "github.com/redpanda-data/connect/v4/internal/secrets"
 	_ "github.com/redpanda-data/connect/v4/public/components/all"
 
 	_ "embed"
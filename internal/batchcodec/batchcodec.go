@@ -0,0 +1,152 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batchcodec implements the binary format used for storing batches of
+// messages, including their metadata, by persisted buffers.
+package batchcodec
+
+import (
+	"errors"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// ErrFailedParse is returned when the data being read is not a valid
+// serialized batch.
+var ErrFailedParse = errors.New("the data appears to be corrupt")
+
+func appendUint32(buffer []byte, i uint32) []byte {
+	return append(buffer,
+		byte(i>>24),
+		byte(i>>16),
+		byte(i>>8),
+		byte(i))
+}
+
+func readUint32(b []byte) (i uint32, remaining []byte, err error) {
+	if len(b) < 4 {
+		return 0, nil, ErrFailedParse
+	}
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), b[4:], nil
+}
+
+// Append serializes a batch of messages, including their metadata, and
+// appends it to a buffer.
+func Append(buffer []byte, batch service.MessageBatch) ([]byte, error) {
+	// First value indicates the marshal version, which starts at 0.
+	buffer = appendUint32(buffer, 0)
+
+	// Second value indicates the number of messages in the batch.
+	buffer = appendUint32(buffer, uint32(len(batch)))
+
+	for _, msg := range batch {
+		var err error
+		if buffer, err = appendMessageV0(buffer, msg); err != nil {
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
+
+func appendMessageV0(buffer []byte, msg *service.Message) ([]byte, error) {
+	metaObj := map[string]any{}
+	_ = msg.MetaWalkMut(func(key string, value any) error {
+		metaObj[key] = value
+		return nil
+	})
+
+	metaBytes, err := msgpack.Marshal(metaObj)
+	if err != nil {
+		return nil, err
+	}
+
+	// First value indicates length of serialized metadata.
+	buffer = appendUint32(buffer, uint32(len(metaBytes)))
+	// Followed by metadata.
+	buffer = append(buffer, metaBytes...)
+
+	msgBytes, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Second value indicates length of content.
+	buffer = appendUint32(buffer, uint32(len(msgBytes)))
+	// Followed by content.
+	buffer = append(buffer, msgBytes...)
+	return buffer, nil
+}
+
+// Read deserializes a batch of messages from the beginning of a buffer and
+// returns the remaining bytes.
+func Read(b []byte) (service.MessageBatch, []byte, error) {
+	var ver uint32
+	var err error
+	if ver, b, err = readUint32(b); err != nil {
+		return nil, nil, err
+	}
+	// Only supported version thus far.
+	if ver != 0 {
+		return nil, nil, ErrFailedParse
+	}
+	return readBatchV0(b)
+}
+
+func readBatchV0(b []byte) (service.MessageBatch, []byte, error) {
+	var parts uint32
+	var err error
+	if parts, b, err = readUint32(b); err != nil {
+		return nil, nil, err
+	}
+
+	batch := make(service.MessageBatch, parts)
+	for i := uint32(0); i < parts; i++ {
+		if batch[i], b, err = readMessageV0(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return batch, b, nil
+}
+
+func readMessageV0(b []byte) (*service.Message, []byte, error) {
+	var contentLen uint32
+	var err error
+
+	// Metadata bytes.
+	if contentLen, b, err = readUint32(b); err != nil {
+		return nil, nil, err
+	}
+	metaBytes := b[:contentLen]
+	b = b[contentLen:]
+
+	// Content bytes.
+	if contentLen, b, err = readUint32(b); err != nil {
+		return nil, nil, err
+	}
+	contentBytes := b[:contentLen]
+	b = b[contentLen:]
+
+	msg := service.NewMessage(contentBytes)
+
+	metaObj := map[string]any{}
+	if err := msgpack.Unmarshal(metaBytes, &metaObj); err != nil {
+		return nil, nil, err
+	}
+	for k, v := range metaObj {
+		msg.MetaSetMut(k, v)
+	}
+	return msg, b, nil
+}
@@ -0,0 +1,401 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"gopkg.in/yaml.v3"
+)
+
+var policyComponentTypes = []string{
+	"buffer", "cache", "input", "metrics", "output", "processor", "rate_limit", "scanner", "tracer",
+}
+
+// connectorFieldConstraint restricts the values of a field within the config of
+// a component, either to a set of allowed values or away from a set of denied
+// values.
+type connectorFieldConstraint struct {
+	Path  string `yaml:"path"`
+	Allow []any  `yaml:"allow"`
+	Deny  []any  `yaml:"deny"`
+}
+
+// connectorPolicy applies to components of the given types (or all types when
+// empty) with names matching any of a list of glob patterns. A policy without
+// fields either allows or denies the matching components, whereas a policy
+// with fields constrains their config.
+type connectorPolicy struct {
+	Name        string                     `yaml:"name"`
+	Description string                     `yaml:"description"`
+	Types       []string                   `yaml:"types"`
+	Components  []string                   `yaml:"components"`
+	Action      string                     `yaml:"action"`
+	Fields      []connectorFieldConstraint `yaml:"fields"`
+}
+
+func (p *connectorPolicy) validate() error {
+	if p.Name == "" {
+		return errors.New("policies must have a name")
+	}
+	for _, t := range p.Types {
+		if !slices.Contains(policyComponentTypes, t) {
+			return fmt.Errorf("policy %v: unrecognised component type %q, expected one of %v", p.Name, t, policyComponentTypes)
+		}
+	}
+	if len(p.Components) == 0 {
+		return fmt.Errorf("policy %v: at least one component name or pattern must be listed", p.Name)
+	}
+	for _, c := range p.Components {
+		if _, err := path.Match(c, ""); err != nil {
+			return fmt.Errorf("policy %v: invalid component pattern %q: %w", p.Name, c, err)
+		}
+	}
+	switch {
+	case len(p.Fields) > 0 && p.Action != "":
+		return fmt.Errorf("policy %v: must have either an action or fields, not both", p.Name)
+	case len(p.Fields) == 0 && p.Action != "allow" && p.Action != "deny":
+		return fmt.Errorf("policy %v: action must be either allow or deny", p.Name)
+	}
+	for _, f := range p.Fields {
+		if f.Path == "" {
+			return fmt.Errorf("policy %v: field constraints must have a path", p.Name)
+		}
+	}
+	return nil
+}
+
+func (p *connectorPolicy) matches(componentType, name string) bool {
+	if len(p.Types) > 0 && !slices.Contains(p.Types, componentType) {
+		return false
+	}
+	for _, c := range p.Components {
+		if ok, _ := path.Match(c, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *connectorPolicy) violation(w *service.WalkedComponent, reason string) string {
+	msg := fmt.Sprintf("%v `%v` at `%v` %v by policy `%v`", w.ComponentType, w.Name, w.Path, reason, p.Name)
+	if p.Description != "" {
+		msg += ": " + p.Description
+	}
+	return msg
+}
+
+// ConnectorPolicies is a set of policies that restrict which components can be
+// used within a config and how they can be configured.
+type ConnectorPolicies struct {
+	policies []connectorPolicy
+}
+
+// ReadConnectorPolicies attempts to read the policies of a connectors list
+// file, returning nil if the file does not exist. When withLists is true the
+// flat allow and deny lists of the file are also enforced as policies, which
+// is necessary for files that were not applied to the environment at startup
+// with ApplyConnectorsList.
+func ReadConnectorPolicies(path string, withLists bool) (*ConnectorPolicies, error) {
+	cListBytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read connector list file: %w", err)
+	}
+
+	var cList connectorsList
+	if err := yaml.Unmarshal(cListBytes, &cList); err != nil {
+		return nil, fmt.Errorf("failed to parse connector list file: %w", err)
+	}
+	if withLists {
+		if len(cList.Allow) > 0 && len(cList.Deny) > 0 {
+			return nil, errors.New("connector list must only contain deny or allow items, not both")
+		}
+		if len(cList.Allow) > 0 {
+			cList.Policies = append(cList.Policies, connectorPolicy{Name: "allow", Components: cList.Allow, Action: "allow"})
+		}
+		if len(cList.Deny) > 0 {
+			cList.Policies = append(cList.Policies, connectorPolicy{Name: "deny", Components: cList.Deny, Action: "deny"})
+		}
+	}
+	if len(cList.Policies) == 0 {
+		return nil, nil
+	}
+
+	for i := range cList.Policies {
+		if err := cList.Policies[i].validate(); err != nil {
+			return nil, fmt.Errorf("connector list file: %w", err)
+		}
+	}
+	return &ConnectorPolicies{policies: cList.Policies}, nil
+}
+
+// Check walks the components of a parsed config and returns an error
+// describing each policy that they violate.
+//
+// A component is rejected when it matches a deny policy, or when allow
+// policies exist for its type and it matches none of them. Field constraints
+// are only checked for fields that are present in the config.
+func (c *ConnectorPolicies) Check(schema *service.ConfigSchema, conf any) error {
+	violations, err := c.violations(schema, conf)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("config violates connector policies:\n  - %v", strings.Join(violations, "\n  - "))
+}
+
+// CheckYAML parses a YAML config, replacing environment variable references
+// using lookup when it is non-nil, and checks it against the policies in the
+// same way as Check.
+func (c *ConnectorPolicies) CheckYAML(ctx context.Context, schema *service.ConfigSchema, data []byte, lookup func(context.Context, string) (string, bool)) error {
+	conf, err := parsePolicyYAML(ctx, data, lookup)
+	if err != nil {
+		return err
+	}
+	return c.Check(schema, conf)
+}
+
+func (c *ConnectorPolicies) violations(schema *service.ConfigSchema, conf any) ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	var violations []string
+	if err := schema.NewStreamConfigWalker().WalkComponentsAny(conf, func(w *service.WalkedComponent) error {
+		var allowed, hasAllow bool
+		for i := range c.policies {
+			p := &c.policies[i]
+			if p.Action == "allow" && (len(p.Types) == 0 || slices.Contains(p.Types, w.ComponentType)) {
+				hasAllow = true
+			}
+			if !p.matches(w.ComponentType, w.Name) {
+				continue
+			}
+			switch p.Action {
+			case "allow":
+				allowed = true
+			case "deny":
+				violations = append(violations, p.violation(w, "is denied"))
+			default:
+				fieldViolations, err := checkFieldConstraints(w, p.Fields)
+				if err != nil {
+					return err
+				}
+				for _, v := range fieldViolations {
+					violations = append(violations, p.violation(w, v))
+				}
+			}
+		}
+		if hasAllow && !allowed {
+			violations = append(violations, fmt.Sprintf("%v `%v` at `%v` is not allowed by any policy", w.ComponentType, w.Name, w.Path))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+var policyEnvVarRegex = regexp.MustCompile(`\${[0-9A-Za-z_.]+(:((\${[^}]+})|[^}])*)?}`)
+
+// parsePolicyYAML parses a config into generic values that can be walked for
+// components. Environment variable references are replaced in the same way as
+// when a config is read by the CLI, but missing variables are replaced with an
+// empty string rather than rejected, which is left to the CLI.
+func parsePolicyYAML(ctx context.Context, data []byte, lookup func(context.Context, string) (string, bool)) (map[string]any, error) {
+	if lookup != nil {
+		data = policyEnvVarRegex.ReplaceAllFunc(data, func(ref []byte) []byte {
+			name, defaultValue, hasDefault := strings.Cut(string(ref[2:len(ref)-1]), ":")
+			value, _ := lookup(ctx, name)
+			if value == "" && hasDefault {
+				value = defaultValue
+			}
+			return []byte(strings.ReplaceAll(value, "\n", "\\n"))
+		})
+	}
+
+	var conf map[string]any
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// policyConfigPaths expands a list of config paths in the way that the lint
+// and streams commands do, where a directory is walked for YAML files, glob
+// patterns are expanded and a path ending with `/...` is walked recursively.
+func policyConfigPaths(targets []string) ([]string, error) {
+	var paths []string
+	for _, target := range targets {
+		if dir, ok := strings.CutSuffix(target, "..."); ok {
+			target = dir
+			if target == "" {
+				target = "."
+			}
+		}
+		matches, err := filepath.Glob(target)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("config path %v does not exist", target)
+		}
+		for _, m := range matches {
+			if err := filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if p == m && !d.IsDir() {
+					paths = append(paths, p)
+				} else if !d.IsDir() && (path.Ext(p) == ".yaml" || path.Ext(p) == ".yml") {
+					paths = append(paths, p)
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return paths, nil
+}
+
+// lintConnectorPolicies checks the configs targeted by the arguments of a lint
+// command against the policies of a connector list file, writing each
+// violation to w in the form of a lint error. The lint command is unaware of
+// the --connector-list flag, which is removed from the returned arguments.
+func lintConnectorPolicies(w io.Writer, schema *service.ConfigSchema, args []string) (lintArgs []string, failed bool, err error) {
+	policiesPath, withLists := connectorListPath, false
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			targets = append(targets, arg)
+			lintArgs = append(lintArgs, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch name {
+		case "connector-list", "r", "resources", "e", "env-file", "t", "templates":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, false, fmt.Errorf("flag %v requires a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+		}
+		switch name {
+		case "connector-list":
+			policiesPath, withLists = value, value != connectorListPath
+			continue
+		case "r", "resources":
+			targets = append(targets, value)
+		}
+		lintArgs = append(lintArgs, arg)
+		if !hasValue && value != "" {
+			lintArgs = append(lintArgs, value)
+		}
+	}
+
+	policies, err := ReadConnectorPolicies(policiesPath, withLists)
+	if err != nil || policies == nil {
+		return lintArgs, false, err
+	}
+
+	paths, err := policyConfigPaths(targets)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, false, err
+		}
+		conf, err := parsePolicyYAML(context.Background(), data, nil)
+		if err != nil {
+			// Parsing errors are reported by the lint command itself.
+			continue
+		}
+		violations, err := policies.violations(schema, conf)
+		if err != nil {
+			continue
+		}
+		for _, v := range violations {
+			failed = true
+			fmt.Fprintf(w, "%v: %v\n", p, v)
+		}
+	}
+	return lintArgs, failed, nil
+}
+
+func checkFieldConstraints(w *service.WalkedComponent, constraints []connectorFieldConstraint) ([]string, error) {
+	v, err := w.ConfigAny()
+	if err != nil {
+		return nil, err
+	}
+	// Components are walked as an object containing the plugin config under
+	// the plugin name alongside common fields such as the label.
+	if obj, ok := v.(map[string]any); ok {
+		if pluginConf, exists := obj[w.Name]; exists {
+			v = pluginConf
+		}
+	}
+
+	var violations []string
+	for _, f := range constraints {
+		value, exists := fieldAtPath(v, f.Path)
+		if !exists {
+			continue
+		}
+		if slices.ContainsFunc(f.Deny, func(d any) bool { return valuesEqual(d, value) }) {
+			violations = append(violations, fmt.Sprintf("sets `%v` to the denied value `%v`", f.Path, value))
+			continue
+		}
+		if len(f.Allow) > 0 && !slices.ContainsFunc(f.Allow, func(a any) bool { return valuesEqual(a, value) }) {
+			violations = append(violations, fmt.Sprintf("sets `%v` to `%v`, which is not one of the allowed values %v", f.Path, value, f.Allow))
+		}
+	}
+	return violations, nil
+}
+
+func fieldAtPath(v any, dotPath string) (any, bool) {
+	for _, p := range strings.Split(dotPath, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[p]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// valuesEqual compares scalar values by their string form, so that a policy
+// of `true` matches both a boolean and a string config value.
+func valuesEqual(a, b any) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
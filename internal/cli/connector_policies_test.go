@@ -0,0 +1,239 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package cli_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redpanda-data/connect/v4/internal/cli"
+)
+
+func policyTestSchema(t testing.TB) *service.ConfigSchema {
+	t.Helper()
+	s := service.NewEmptyEnvironment()
+	spec := service.NewConfigSpec().Fields(
+		service.NewStringField("driver").Default(""),
+		service.NewObjectField("tls", service.NewBoolField("skip_cert_verify").Default(false)).Optional(),
+	)
+	for _, n := range []string{"http_client", "sql_insert", "sql_select", "stdin"} {
+		require.NoError(t, s.RegisterInput(n, spec, nil))
+		require.NoError(t, s.RegisterOutput(n, spec, nil))
+	}
+	require.NoError(t, s.RegisterProcessor("mapping", service.NewConfigSpec().Field(service.NewStringField("")), nil))
+	return s.CoreConfigSchema("", "")
+}
+
+// parseTestConfig mirrors a parsed config, where the components of the config
+// are YAML nodes.
+func parseTestConfig(t testing.TB, config string) map[string]any {
+	t.Helper()
+	var nodes map[string]yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(config), &nodes))
+	conf := map[string]any{}
+	for k, v := range nodes {
+		conf[k] = &v
+	}
+	return conf
+}
+
+const testPolicies = `
+policies:
+  - name: no-http-client-input
+    description: Inputs must not poll external HTTP services.
+    types: [ input ]
+    components: [ http_client ]
+    action: deny
+  - name: verify-tls
+    components: [ "*" ]
+    fields:
+      - path: tls.skip_cert_verify
+        deny: [ true ]
+  - name: postgres-only
+    components: [ "sql_*" ]
+    fields:
+      - path: driver
+        allow: [ postgres ]
+`
+
+func TestConnectorPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyPath := path.Join(tmpDir, "connector_list.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(testPolicies), 0o666))
+
+	policies, err := cli.ReadConnectorPolicies(policyPath, false)
+	require.NoError(t, err)
+	require.NotNil(t, policies)
+
+	for _, testCase := range []struct {
+		name                string
+		config              string
+		expectedErrContains []string
+	}{
+		{
+			name: "http client output is allowed",
+			config: `
+input:
+  stdin: {}
+output:
+  http_client:
+    tls:
+      skip_cert_verify: false
+`,
+		},
+		{
+			name: "http client input is denied",
+			config: `
+input:
+  http_client: {}
+output:
+  stdin: {}
+`,
+			expectedErrContains: []string{
+				"input `http_client` at `input` is denied by policy `no-http-client-input`: Inputs must not poll external HTTP services.",
+			},
+		},
+		{
+			name: "field constraints",
+			config: `
+input:
+  sql_select:
+    driver: mysql
+pipeline:
+  processors:
+    - mapping: root = this
+output:
+  http_client:
+    tls:
+      skip_cert_verify: true
+`,
+			expectedErrContains: []string{
+				"input `sql_select` at `input` sets `driver` to `mysql`, which is not one of the allowed values [postgres] by policy `postgres-only`",
+				"output `http_client` at `output` sets `tls.skip_cert_verify` to the denied value `true` by policy `verify-tls`",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := policies.Check(policyTestSchema(t), parseTestConfig(t, testCase.config))
+			if len(testCase.expectedErrContains) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, exp := range testCase.expectedErrContains {
+				assert.Contains(t, err.Error(), exp)
+			}
+		})
+	}
+}
+
+func TestConnectorPoliciesAllow(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyPath := path.Join(tmpDir, "connector_list.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(`
+policies:
+  - name: outputs
+    types: [ output ]
+    components: [ http_client ]
+    action: allow
+`), 0o666))
+
+	policies, err := cli.ReadConnectorPolicies(policyPath, false)
+	require.NoError(t, err)
+
+	err = policies.Check(policyTestSchema(t), parseTestConfig(t, `
+input:
+  sql_select: {}
+output:
+  stdin: {}
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "output `stdin` at `output` is not allowed by any policy")
+	assert.NotContains(t, err.Error(), "sql_select")
+}
+
+func TestConnectorPoliciesInvalid(t *testing.T) {
+	for _, testCase := range []struct {
+		name                string
+		input               string
+		expectedErrContains string
+	}{
+		{
+			name: "unknown type",
+			input: `
+policies:
+  - name: foo
+    types: [ nope ]
+    components: [ a ]
+    action: deny
+`,
+			expectedErrContains: `unrecognised component type "nope"`,
+		},
+		{
+			name: "action and fields",
+			input: `
+policies:
+  - name: foo
+    components: [ a ]
+    action: deny
+    fields: [ { path: b, deny: [ c ] } ]
+`,
+			expectedErrContains: "must have either an action or fields",
+		},
+		{
+			name: "missing action",
+			input: `
+policies:
+  - name: foo
+    components: [ a ]
+`,
+			expectedErrContains: "action must be either allow or deny",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			policyPath := path.Join(t.TempDir(), "connector_list.yaml")
+			require.NoError(t, os.WriteFile(policyPath, []byte(testCase.input), 0o666))
+
+			_, err := cli.ReadConnectorPolicies(policyPath, false)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.expectedErrContains)
+		})
+	}
+
+	policies, err := cli.ReadConnectorPolicies(path.Join(t.TempDir(), "missing.yaml"), false)
+	require.NoError(t, err)
+	assert.Nil(t, policies)
+}
+
+func TestConnectorPoliciesWithLists(t *testing.T) {
+	policyPath := path.Join(t.TempDir(), "connector_list.yaml")
+	require.NoError(t, os.WriteFile(policyPath, []byte(`deny: [ http_client ]`), 0o666))
+
+	policies, err := cli.ReadConnectorPolicies(policyPath, false)
+	require.NoError(t, err)
+	assert.Nil(t, policies)
+
+	policies, err = cli.ReadConnectorPolicies(policyPath, true)
+	require.NoError(t, err)
+	err = policies.Check(policyTestSchema(t), parseTestConfig(t, `
+input:
+  http_client: {}
+output:
+  http_client: {}
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "input `http_client` at `input` is denied by policy `deny`")
+	assert.Contains(t, err.Error(), "output `http_client` at `output` is denied by policy `deny`")
+}
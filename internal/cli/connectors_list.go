@@ -18,8 +18,9 @@ import (
 )
 
 type connectorsList struct {
-	Allow []string `yaml:"allow"`
-	Deny  []string `yaml:"deny"`
+	Allow    []string          `yaml:"allow"`
+	Deny     []string          `yaml:"deny"`
+	Policies []connectorPolicy `yaml:"policies"`
 }
 
 // ApplyConnectorsList attempts to read a path (if the file exists) and modifies
@@ -10,15 +10,20 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 	"github.com/rs/xid"
 	"github.com/urfave/cli/v2"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/kafka/enterprise"
+	"github.com/redpanda-data/connect/v4/internal/protohealth"
 	"github.com/redpanda-data/connect/v4/internal/secrets"
 	"github.com/redpanda-data/connect/v4/internal/telemetry"
 )
@@ -29,8 +34,20 @@ const connectorListPath = "/etc/redpanda/connector_list.yaml"
 // all of the enterprise functionality of Redpanda Connect. This has been
 // abstracted into a separate package so that multiple distributions (classic
 // versus cloud) can reference the same code.
-func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.ConfigSchema, opts ...service.CLIOptFunc) {
+//
+// When a status is provided it is updated with the state of the running
+// stream, which may be nil when the distribution does not serve it.
+func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.ConfigSchema, status *protohealth.Status, opts ...service.CLIOptFunc) {
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		if err := secretsCommand(binaryName).Run(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	instanceID := xid.New().String()
+	status.SetInstanceID(instanceID)
 
 	rpLogger := enterprise.NewTopicLogger(instanceID)
 	var fbLogger *service.Logger
@@ -45,7 +62,16 @@ func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.Co
 		return "", false
 	}
 
-	var disableTelemetry bool
+	var connectorPolicies *ConnectorPolicies
+	var telemetryOpts telemetry.ExportOptions
+	var telemetryOnce sync.Once
+
+	// When secrets are refreshed and found to have changed the pipeline is
+	// gracefully stopped, as it would be by a SIGTERM, and the process is then
+	// replaced with a new instance of itself that reads the new values.
+	var refresher *secrets.Refresher
+	var refresherOnce sync.Once
+	var reload atomic.Bool
 
 	opts = append(opts,
 		service.CLIOptSetVersion(version, dateBuilt),
@@ -79,16 +105,50 @@ func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.Co
 			}
 			rpLogger.SetFallbackLogger(l)
 		}),
-		service.CLIOptAddTeeLogger(slog.New(rpLogger)),
+		service.CLIOptAddTeeLogger(slog.New(status.LogHandler(rpLogger))),
 		service.CLIOptOnConfigParse(func(pConf *service.ParsedConfig) error {
+			if connectorPolicies != nil {
+				conf, err := pConf.FieldAny()
+				if err != nil {
+					return err
+				}
+				if err := connectorPolicies.Check(schema, conf); err != nil {
+					return err
+				}
+			}
+
 			// Kick off telemetry exporter.
-			if !disableTelemetry {
-				telemetry.ActivateExporter(instanceID, version, fbLogger, schema, pConf)
+			telemetryOnce.Do(func() {
+				telemetry.ActivateExporter(instanceID, version, fbLogger, schema, pConf, telemetryOpts)
+			})
+			rpConf := pConf.Namespace("redpanda")
+			pipelineID, err := rpConf.FieldString("pipeline_id")
+			if err != nil {
+				return err
 			}
-			return rpLogger.InitOutputFromParsed(pConf.Namespace("redpanda"))
+			status.SetConfigParsed(pipelineID)
+			return rpLogger.InitOutputFromParsed(rpConf)
 		}),
 		service.CLIOptOnStreamStart(func(s *service.RunningStreamSummary) error {
 			rpLogger.SetStreamSummary(s)
+			status.SetStreamSummary(s)
+
+			// Secrets are only polled once the stream has started, at which
+			// point a graceful stop is handled by the CLI.
+			if refresher != nil {
+				refresherOnce.Do(func() {
+					go refresher.Run(context.Background(), func(changed []string) {
+						if reload.Swap(true) {
+							return
+						}
+						logger := slog.New(rpLogger).With("changed", len(changed))
+						logger.Info("Secrets have changed, reloading the pipeline")
+						if err := triggerGracefulStop(); err != nil {
+							logger.With("error", err).Error("Failed to stop the pipeline for a reload")
+						}
+					})
+				})
+			}
 			return nil
 		}),
 
@@ -99,19 +159,72 @@ func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.Co
 				Usage: "Attempt to load secrets from a provided URN. If more than one entry is specified they will be attempted in order until a value is found. Environment variable lookups are specified with the URN `env:`, which by default is the only entry. In order to disable all secret lookups specify a single entry of `none:`.",
 				Value: cli.NewStringSlice("env:"),
 			},
+			&cli.DurationFlag{
+				Name:  "secrets-refresh-interval",
+				Usage: "When set, secrets are cached for this duration and then polled for changes. If any secret referenced by the config has changed then the pipeline is gracefully stopped and the process restarts itself in place with the new values. Not supported on Windows.",
+			},
+			&cli.StringFlag{
+				Name:  "connector-list",
+				Usage: "The path of a connectors list file containing allow and deny lists, and policies that restrict the components that configs are allowed to use and how they are configured.",
+				Value: connectorListPath,
+			},
 			&cli.BoolFlag{
 				Name:  "disable-telemetry",
 				Usage: "Disable anonymous telemetry from being emitted by this Connect instance.",
 			},
+			&cli.StringFlag{
+				Name:  "telemetry-dump",
+				Usage: "Write each telemetry payload as a line of JSON to a file, or to stdout with `-`. The payload is written when the config is loaded and each time it is exported.",
+			},
+			&cli.StringFlag{
+				Name:  "telemetry-otlp-endpoint",
+				Usage: "The URL of an OTLP/HTTP collector to send telemetry payloads to as metrics, e.g. `http://localhost:4318`. This is independent of --disable-telemetry.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "telemetry-otlp-header",
+				Usage: "A `key=value` header to add to requests sent to the OTLP collector. Can be specified multiple times.",
+			},
 		}, func(c *cli.Context) error {
-			disableTelemetry = c.Bool("disable-telemetry")
+			telemetryOpts = telemetry.ExportOptions{
+				DisableRedpanda: c.Bool("disable-telemetry"),
+				DumpPath:        c.String("telemetry-dump"),
+				OTLPEndpoint:    c.String("telemetry-otlp-endpoint"),
+			}
+			for _, h := range c.StringSlice("telemetry-otlp-header") {
+				k, v, ok := strings.Cut(h, "=")
+				if !ok {
+					return fmt.Errorf("telemetry OTLP header %q must be in the form key=value", h)
+				}
+				if telemetryOpts.OTLPHeaders == nil {
+					telemetryOpts.OTLPHeaders = map[string]string{}
+				}
+				telemetryOpts.OTLPHeaders[k] = v
+			}
+
+			var err error
+			// The allow and deny lists of the default connector list file have
+			// already been applied to the environment.
+			policiesPath := c.String("connector-list")
+			if connectorPolicies, err = ReadConnectorPolicies(policiesPath, policiesPath != connectorListPath); err != nil {
+				return err
+			}
 
 			if secretsURNs := c.StringSlice("secrets"); len(secretsURNs) > 0 {
-				var err error
 				if secretLookupFn, err = secrets.ParseLookupURNs(c.Context, slog.New(rpLogger), secretsURNs...); err != nil {
 					return err
 				}
 			}
+			if interval := c.Duration("secrets-refresh-interval"); interval > 0 {
+				if !reloadSupported {
+					return errors.New("the --secrets-refresh-interval flag is not supported on this platform")
+				}
+				refresher = secrets.NewRefresher(slog.New(rpLogger), secretLookupFn, interval)
+				secretLookupFn = refresher.Lookup
+			}
+
+			if connectorPolicies != nil {
+				return checkRunPolicies(c, schema, connectorPolicies, secretLookupFn)
+			}
 			return nil
 		}),
 		service.CLIOptSetEnvVarLookup(func(ctx context.Context, key string) (string, bool) {
@@ -119,7 +232,27 @@ func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.Co
 		}),
 	)
 
+	// The lint command is not aware of connector policies, and so configs are
+	// checked against them beforehand.
+	var lintFailed bool
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		var lintArgs []string
+		if lintArgs, lintFailed, err = lintConnectorPolicies(os.Stderr, schema, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, service.CLIOptSetArgs(append([]string{os.Args[0], "lint"}, lintArgs...)...))
+	}
+
 	exitCode, err := service.RunCLIToCode(context.Background(), opts...)
+	if lintFailed && exitCode == 0 {
+		exitCode = 1
+	}
+	if err == nil && exitCode == 0 && reload.Load() {
+		_ = rpLogger.Close(context.Background())
+		err = fmt.Errorf("failed to restart for a reload: %w", reexec())
+		exitCode = 1
+	}
 	if err != nil {
 		if fbLogger != nil {
 			fbLogger.Error(err.Error())
@@ -128,9 +261,43 @@ func InitEnterpriseCLI(binaryName, version, dateBuilt string, schema *service.Co
 		}
 	}
 	rpLogger.TriggerEventStopped(err)
+	status.SetStopped(err)
 
 	_ = rpLogger.Close(context.Background())
 	if exitCode != 0 {
 		os.Exit(exitCode)
 	}
 }
+
+// checkRunPolicies checks the configs of a run or streams command that are not
+// provided to the config parse hook against connector policies. Configs that
+// can be changed after they have been checked, by the config watcher or the
+// streams API, are rejected as the policies could not be enforced.
+func checkRunPolicies(c *cli.Context, schema *service.ConfigSchema, policies *ConnectorPolicies, lookup func(context.Context, string) (string, bool)) error {
+	if c.Bool("watcher") {
+		return errors.New("the --watcher flag cannot be used with connector policies")
+	}
+
+	targets := c.StringSlice("resources")
+	if c.Command != nil && c.Command.Name == "streams" {
+		if !c.Bool("no-api") {
+			return errors.New("streams mode must be run with the --no-api flag when connector policies are set")
+		}
+		targets = append(targets, c.Args().Slice()...)
+	}
+
+	paths, err := policyConfigPaths(targets)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := policies.CheckYAML(c.Context, schema, data, lookup); err != nil {
+			return fmt.Errorf("%v: %w", p, err)
+		}
+	}
+	return nil
+}
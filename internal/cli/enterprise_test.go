@@ -0,0 +1,199 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/redpanda-data/connect/v4/public/components/io"
+	_ "github.com/redpanda-data/connect/v4/public/components/pure"
+	"github.com/redpanda-data/connect/v4/public/schema"
+)
+
+// testCLIArgsEnv, when set, causes the test binary to run the CLI with its
+// newline separated arguments instead of the tests. The environment is kept when
+// the process replaces itself, and so the CLI is run again after a reload.
+const testCLIArgsEnv = "CONNECT_TEST_CLI_ARGS"
+
+func TestMain(m *testing.M) {
+	if args := os.Getenv(testCLIArgsEnv); args != "" {
+		os.Args = append([]string{os.Args[0]}, strings.Split(args, "\n")...)
+		InitEnterpriseCLI("redpanda-connect", "v0.0.0", "", schema.Standard("v0.0.0", ""), nil)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestCLI runs the CLI with the provided arguments until it exits, or until
+// stop returns true at which point it is interrupted, and returns its combined
+// output and exit code.
+func runTestCLI(t *testing.T, stop func() bool, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), testCLIArgsEnv+"="+strings.Join(args, "\n"))
+	var logs bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	require.NoError(t, cmd.Start())
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	var err error
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(30 * time.Second)
+waitLoop:
+	for {
+		select {
+		case err = <-exited:
+			break waitLoop
+		case <-ticker.C:
+			if stop != nil && stop() {
+				require.NoError(t, cmd.Process.Signal(os.Interrupt))
+				stop = nil
+			}
+		case <-timeout:
+			_ = cmd.Process.Kill()
+			t.Fatalf("timed out waiting for the CLI to exit: %s", logs.String())
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return logs.String(), exitErr.ExitCode()
+	}
+	require.NoError(t, err)
+	return logs.String(), 0
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+}
+
+const testCLIPolicies = `
+policies:
+  - name: no-stdin
+    description: Configs must not read from stdin.
+    types: [ input ]
+    components: [ stdin ]
+    action: deny
+`
+
+const testCLIAllowedConfig = `
+input:
+  generate:
+    count: 1
+    mapping: 'root = "hello"'
+output:
+  drop: {}
+`
+
+const testCLIDeniedConfig = `
+input:
+  stdin: {}
+output:
+  drop: {}
+`
+
+func TestConnectorPoliciesLint(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"connector_list.yaml":    testCLIPolicies,
+		"allowed.yaml":           testCLIAllowedConfig,
+		"nested/denied.yaml":     testCLIDeniedConfig,
+		"resources/denied.yaml":  "input_resources:\n  - label: foo\n    stdin: {}\n",
+		"resources/ignored.json": "{}",
+	})
+	policiesPath := filepath.Join(dir, "connector_list.yaml")
+
+	out, code := runTestCLI(t, nil, "lint", "--connector-list", policiesPath, filepath.Join(dir, "allowed.yaml"))
+	assert.Equal(t, 0, code, out)
+
+	out, code = runTestCLI(t, nil, "lint", "--connector-list="+policiesPath, filepath.Join(dir, "allowed.yaml"), dir+"/nested/...")
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, filepath.Join(dir, "nested/denied.yaml")+": input `stdin` at `input` is denied by policy `no-stdin`")
+	assert.NotContains(t, out, "allowed.yaml")
+
+	out, code = runTestCLI(t, nil, "lint", "-r", filepath.Join(dir, "resources/denied.yaml"), "--connector-list", policiesPath, filepath.Join(dir, "allowed.yaml"))
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, filepath.Join(dir, "resources/denied.yaml")+": input `stdin` at `input_resources.0` is denied by policy `no-stdin`")
+
+	// Without policies the denied config is linted as normal.
+	out, code = runTestCLI(t, nil, "lint", "--connector-list", filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "nested/denied.yaml"))
+	assert.Equal(t, 0, code, out)
+}
+
+func TestConnectorPoliciesStreams(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"connector_list.yaml":   testCLIPolicies,
+		"streams/allowed.yaml":  testCLIAllowedConfig,
+		"streams/denied.yaml":   "input:\n  ${INPUT_TYPE:stdin}: {}\noutput:\n  drop: {}\n",
+		"resources/denied.yaml": "input_resources:\n  - label: foo\n    stdin: {}\n",
+	})
+	policiesPath := filepath.Join(dir, "connector_list.yaml")
+
+	out, code := runTestCLI(t, nil, "streams", "--disable-telemetry", "--connector-list", policiesPath, filepath.Join(dir, "streams/allowed.yaml"))
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, "streams mode must be run with the --no-api flag")
+
+	out, code = runTestCLI(t, nil, "streams", "--disable-telemetry", "--connector-list", policiesPath, "--no-api", filepath.Join(dir, "streams"))
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, filepath.Join(dir, "streams/denied.yaml")+": config violates connector policies")
+	assert.Contains(t, out, "input `stdin` at `input` is denied by policy `no-stdin`")
+
+	out, code = runTestCLI(t, nil, "streams", "--disable-telemetry", "--connector-list", policiesPath, "--no-api", "-r", filepath.Join(dir, "resources/denied.yaml"), filepath.Join(dir, "streams/allowed.yaml"))
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, filepath.Join(dir, "resources/denied.yaml")+": config violates connector policies")
+
+	// Environment variables are replaced before checking the config, and
+	// streams that comply with the policies are run.
+	outPath := filepath.Join(dir, "out.txt")
+	t.Setenv("INPUT_TYPE", "generate")
+	writeTestFiles(t, dir, map[string]string{
+		"streams/denied.yaml": "input:\n  ${INPUT_TYPE:stdin}:\n    count: 1\n    mapping: 'root = \"hello\"'\noutput:\n  file:\n    path: " + outPath + "\n",
+	})
+	out, code = runTestCLI(t, func() bool {
+		b, _ := os.ReadFile(outPath)
+		return strings.Contains(string(b), "hello")
+	}, "streams", "--disable-telemetry", "--connector-list", policiesPath, "--no-api", filepath.Join(dir, "streams"))
+	assert.Equal(t, 0, code, out)
+}
+
+func TestConnectorPoliciesWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"connector_list.yaml": testCLIPolicies,
+		"config.yaml":         testCLIAllowedConfig,
+	})
+
+	out, code := runTestCLI(t, nil, "run", "--disable-telemetry", "--connector-list", filepath.Join(dir, "connector_list.yaml"), "-w", filepath.Join(dir, "config.yaml"))
+	assert.Equal(t, 1, code, out)
+	assert.Contains(t, out, "the --watcher flag cannot be used with connector policies")
+
+	out, code = runTestCLI(t, nil, "run", "--disable-telemetry", "--connector-list", filepath.Join(dir, "connector_list.yaml"), filepath.Join(dir, "config.yaml"))
+	assert.Equal(t, 0, code, out)
+}
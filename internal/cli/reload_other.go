@@ -0,0 +1,25 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+//go:build !unix
+
+package cli
+
+import "errors"
+
+const reloadSupported = false
+
+var errReloadNotSupported = errors.New("reloading the pipeline when secrets change is not supported on this platform")
+
+func triggerGracefulStop() error {
+	return errReloadNotSupported
+}
+
+func reexec() error {
+	return errReloadNotSupported
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+//go:build unix
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	return port
+}
+
+func TestSecretsRefreshReload(t *testing.T) {
+	dir := t.TempDir()
+	secretsDir := filepath.Join(dir, "secrets")
+	require.NoError(t, os.Mkdir(secretsDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(secretsDir, "GREETING"), []byte("foo"), 0o600))
+
+	outPath := filepath.Join(dir, "out.txt")
+	port := freePort(t)
+	confPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(confPath, []byte(fmt.Sprintf(`
+http:
+  address: 127.0.0.1:%v
+input:
+  generate:
+    interval: 20ms
+    mapping: 'root = "${GREETING}"'
+output:
+  file:
+    path: %v
+    codec: lines
+shutdown_timeout: 5s
+`, port, outPath)), 0o600))
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), testCLIArgsEnv+"="+strings.Join([]string{
+		"run",
+		"--disable-telemetry",
+		"--connector-list", filepath.Join(dir, "connector_list.yaml"),
+		"--secrets", "file://" + secretsDir,
+		"--secrets-refresh-interval", "100ms",
+		confPath,
+	}, "\n"))
+	var logs bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	require.NoError(t, cmd.Start())
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		if t.Failed() {
+			t.Log(logs.String())
+		}
+	})
+
+	outputContains := func(s string) func() bool {
+		return func() bool {
+			b, _ := os.ReadFile(outPath)
+			return strings.Contains(string(b), s+"\n")
+		}
+	}
+	require.Eventually(t, outputContains("foo"), 10*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(secretsDir, "GREETING"), []byte("bar"), 0o600))
+	require.Eventually(t, outputContains("bar"), 20*time.Second, 20*time.Millisecond)
+
+	// The reloaded process serves the HTTP API on the same address, which
+	// requires the previous instance to have released it.
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%v/ping", port))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 20*time.Millisecond)
+
+	// The process is still the one started by the test, and shuts down
+	// cleanly.
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	select {
+	case err := <-exited:
+		require.NoError(t, err)
+	case <-time.After(20 * time.Second):
+		t.Fatal("timed out waiting for the process to exit")
+	}
+
+	b, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Equal(t, "foo", lines[0])
+	assert.Equal(t, "bar", lines[len(lines)-1])
+
+	// Every message produced before the reload was delivered before any of
+	// those produced after it.
+	seenBar := false
+	for _, l := range lines {
+		if l == "bar" {
+			seenBar = true
+		} else {
+			assert.False(t, seenBar, "found %v after bar", l)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+//go:build unix
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+const reloadSupported = true
+
+// triggerGracefulStop stops the running pipeline in the same way as a SIGTERM
+// would, which drains it within the configured shutdown timeout.
+func triggerGracefulStop() error {
+	return syscall.Kill(os.Getpid(), syscall.SIGTERM)
+}
+
+// reexec replaces the current process with a new instance of the same binary,
+// arguments and environment. It only returns when this fails.
+func reexec() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/urfave/cli/v2"
+
+	"github.com/redpanda-data/connect/v4/internal/secrets"
+)
+
+var ageStringRegex = regexp.MustCompile(regexp.QuoteMeta(secrets.AgePrefix) + `[A-Za-z0-9+/]+=*`)
+
+func recipientsFlag() *cli.StringSliceFlag {
+	return &cli.StringSliceFlag{
+		Name:     "recipient",
+		Aliases:  []string{"r"},
+		Usage:    "An age public key, or the path of a file of public keys, to encrypt for. Can be specified multiple times.",
+		Required: true,
+	}
+}
+
+// secretsCommand returns a CLI for encrypting secrets that are then embedded
+// within configs, either as `age:` prefixed strings or SOPS encrypted files.
+func secretsCommand(binaryName string) *cli.App {
+	return &cli.App{
+		Name:  binaryName + " secrets",
+		Usage: "Encrypt and re-key secrets that are committed alongside configs",
+		Commands: []*cli.Command{
+			{
+				Name:  "encrypt",
+				Usage: "Encrypt a value, or with --sops a YAML file, for a set of age recipients",
+				Description: `
+Without --sops the value provided as an argument, or read from stdin, is
+encrypted and printed as an age: prefixed string, which can be decrypted within
+a config with ${! secret("age:...") }.
+
+With --sops the YAML file provided as an argument is encrypted in the SOPS
+format and printed, which can then be read with --secrets sops://<path>.`[1:],
+				ArgsUsage: "[value|file]",
+				Flags: []cli.Flag{
+					recipientsFlag(),
+					&cli.BoolFlag{
+						Name:  "sops",
+						Usage: "Encrypt the values of a YAML file in the SOPS format.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					recipients, err := secrets.ParseAgeRecipients(c.StringSlice("recipient"))
+					if err != nil {
+						return err
+					}
+					if c.Bool("sops") {
+						if c.NArg() != 1 {
+							return errors.New("expected a single file path argument")
+						}
+						data, err := os.ReadFile(c.Args().First())
+						if err != nil {
+							return err
+						}
+						enc, err := secrets.EncryptSOPS(data, recipients...)
+						if err != nil {
+							return err
+						}
+						_, err = c.App.Writer.Write(enc)
+						return err
+					}
+
+					var value []byte
+					switch c.NArg() {
+					case 0:
+						if value, err = io.ReadAll(c.App.Reader); err != nil {
+							return err
+						}
+						value = []byte(strings.TrimSuffix(string(value), "\n"))
+					case 1:
+						value = []byte(c.Args().First())
+					default:
+						return errors.New("expected at most one value argument")
+					}
+					enc, err := secrets.EncryptAgeString(value, recipients...)
+					if err != nil {
+						return err
+					}
+					_, err = fmt.Fprintln(c.App.Writer, enc)
+					return err
+				},
+			},
+			{
+				Name:  "rekey",
+				Usage: "Re-encrypt the secrets of files in place for a new set of age recipients",
+				Description: `
+For SOPS encrypted files the data key is re-encrypted for the recipients, for
+all other files each age: prefixed string is decrypted and encrypted again for
+the recipients. Identities are read from the --identity file, or otherwise the
+SOPS_AGE_KEY and SOPS_AGE_KEY_FILE environment variables.`[1:],
+				ArgsUsage: "file...",
+				Flags: []cli.Flag{
+					recipientsFlag(),
+					&cli.StringFlag{
+						Name:    "identity",
+						Aliases: []string{"i"},
+						Usage:   "The path of a file of age identities used to decrypt the current secrets.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("expected at least one file path argument")
+					}
+					recipients, err := secrets.ParseAgeRecipients(c.StringSlice("recipient"))
+					if err != nil {
+						return err
+					}
+					identities, err := secrets.LoadAgeIdentities(c.String("identity"))
+					if err != nil {
+						return err
+					}
+					for _, path := range c.Args().Slice() {
+						if err := rekeyFile(path, identities, recipients); err != nil {
+							return fmt.Errorf("%v: %w", path, err)
+						}
+						fmt.Fprintf(c.App.ErrWriter, "Re-keyed %v\n", path)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func rekeyFile(path string, identities []age.Identity, recipients []age.Recipient) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := secrets.RekeySOPS(data, identities, recipients)
+	if errors.Is(err, secrets.ErrNotSOPSDocument) {
+		// Not a SOPS document, so re-encrypt any age strings within it.
+		var rerr error
+		out = ageStringRegex.ReplaceAllFunc(data, func(enc []byte) []byte {
+			if rerr != nil {
+				return enc
+			}
+			var plaintext []byte
+			if plaintext, rerr = secrets.DecryptAgeString(string(enc), identities...); rerr != nil {
+				return enc
+			}
+			var reenc string
+			reenc, rerr = secrets.EncryptAgeString(plaintext, recipients...)
+			return []byte(reenc)
+		})
+		if rerr != nil {
+			return rerr
+		}
+	} else if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, info.Mode().Perm())
+}
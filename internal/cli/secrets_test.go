@@ -0,0 +1,65 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/connect/v4/internal/secrets"
+)
+
+func TestSecretsEncryptAndRekey(t *testing.T) {
+	oldID, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	newID, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	app := secretsCommand("connect")
+	app.Writer, app.ErrWriter = &out, &bytes.Buffer{}
+	require.NoError(t, app.Run([]string{"secrets", "encrypt", "-r", oldID.Recipient().String(), "hunter2"}))
+	enc := strings.TrimSpace(out.String())
+	require.True(t, strings.HasPrefix(enc, secrets.AgePrefix))
+
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(confPath, []byte(`password: ${! secret("`+enc+`") }`+"\n"), 0o600))
+
+	sopsPath := filepath.Join(dir, "secrets.yaml")
+	require.NoError(t, os.WriteFile(sopsPath, []byte("api_key: foo\n"), 0o600))
+	out.Reset()
+	require.NoError(t, app.Run([]string{"secrets", "encrypt", "--sops", "-r", oldID.Recipient().String(), sopsPath}))
+	require.NoError(t, os.WriteFile(sopsPath, out.Bytes(), 0o600))
+
+	keyPath := filepath.Join(dir, "keys.txt")
+	require.NoError(t, os.WriteFile(keyPath, []byte(oldID.String()), 0o600))
+	require.NoError(t, app.Run([]string{"secrets", "rekey", "-i", keyPath, "-r", newID.Recipient().String(), confPath, sopsPath}))
+
+	conf, err := os.ReadFile(confPath)
+	require.NoError(t, err)
+	rekeyed := ageStringRegex.Find(conf)
+	require.NotNil(t, rekeyed)
+	assert.NotEqual(t, enc, string(rekeyed))
+	plaintext, err := secrets.DecryptAgeString(string(rekeyed), newID)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", string(plaintext))
+
+	sopsDoc, err := os.ReadFile(sopsPath)
+	require.NoError(t, err)
+	_, err = secrets.DecryptSOPS(sopsDoc, newID)
+	require.NoError(t, err)
+}
@@ -0,0 +1,533 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package anthropic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
+)
+
+const (
+	acpFieldBaseURL        = "base_url"
+	acpFieldAPIKey         = "api_key"
+	acpFieldModel          = "model"
+	acpFieldUserPrompt     = "prompt"
+	acpFieldSystemPrompt   = "system_prompt"
+	acpFieldImage          = "image"
+	acpFieldDocument       = "document"
+	acpFieldMaxTokens      = "max_tokens"
+	acpFieldTemp           = "temperature"
+	acpFieldTopP           = "top_p"
+	acpFieldTopK           = "top_k"
+	acpFieldStop           = "stop"
+	acpFieldResponseFormat = "response_format"
+	acpFieldJSONSchema     = "json_schema"
+
+	// The name of the tool that the model is forced to call in order to
+	// produce structured output.
+	structuredOutputTool = "structured_output"
+)
+
+func init() {
+	err := service.RegisterProcessor(
+		"anthropic_chat",
+		chatProcessorConfig(),
+		makeChatProcessor,
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func chatProcessorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Categories("AI").
+		Summary("Generates responses to messages in a chat conversation, using the Anthropic API.").
+		Description(`
+This processor sends the contents of user prompts to the Anthropic Messages API, which generates responses. By default, the processor submits the entire payload of each message as a string, unless you use the `+"`"+acpFieldUserPrompt+"`"+` configuration field to customize it.
+
+To learn more about the Messages API, see the https://docs.anthropic.com/en/api/messages[Anthropic API documentation^].
+
+== Structured output
+
+When the `+"`"+acpFieldResponseFormat+"`"+` is `+"`json`"+` or `+"`json_schema`"+`, the model is forced to respond by calling a tool whose input is the JSON schema, and the input of that tool call becomes the message payload.
+
+== Metadata
+
+The following metadata is added to each processed message:
+
+- anthropic_stop_reason
+- anthropic_input_tokens
+- anthropic_output_tokens
+`).
+		Version("4.42.0").
+		Fields(
+			service.NewStringField(acpFieldBaseURL).
+				Description("The base URL to use for API requests.").
+				Default("https://api.anthropic.com"),
+			service.NewStringField(acpFieldAPIKey).
+				Secret().
+				Description("The API key for the Anthropic API."),
+			service.NewStringField(acpFieldModel).
+				Description("The name of the Anthropic model to use.").
+				Examples("claude-3-5-sonnet-latest", "claude-3-5-haiku-latest", "claude-3-opus-latest"),
+			service.NewInterpolatedStringField(acpFieldUserPrompt).
+				Description("The user prompt you want to generate a response for. By default, the processor submits the entire payload as a string.").
+				Optional(),
+			service.NewInterpolatedStringField(acpFieldSystemPrompt).
+				Description("The system prompt to submit along with the user prompt.").
+				Optional(),
+			service.NewBloblangField(acpFieldImage).
+				Description("An image to send along with the prompt. The mapping result must be a byte array.").
+				Example(`root = this.image.decode("base64") # decode base64 encoded image`).
+				Optional(),
+			service.NewBloblangField(acpFieldDocument).
+				Description("A PDF document to send along with the prompt. The mapping result must be a byte array.").
+				Example(`root = content()`).
+				Optional(),
+			service.NewIntField(acpFieldMaxTokens).
+				Description("The maximum number of tokens that can be generated in the response.").
+				Default(1024).
+				LintRule(`root = if this < 1 { [ "field must be greater than or equal to 1" ] }`),
+			service.NewFloatField(acpFieldTemp).
+				Optional().
+				Description("The amount of randomness injected into the response, between 0 and 1. Use a temperature closer to 0 for analytical or multiple choice tasks, and closer to 1 for creative tasks.").
+				LintRule(`root = if this > 1 || this < 0 { [ "field must be between 0 and 1" ] }`),
+			service.NewStringEnumField(acpFieldResponseFormat, "text", "json", "json_schema").
+				Default("text").
+				Description("Specify the model's output format. If `json_schema` is specified, then additionally a `json_schema` must be configured."),
+			service.NewStringField(acpFieldJSONSchema).
+				Optional().
+				Description("The JSON schema to use when responding in `json_schema` format."),
+			service.NewFloatField(acpFieldTopP).
+				Optional().
+				Advanced().
+				Description("Use nucleus sampling, where the model considers the results of the tokens with top_p probability mass. You should either alter temperature or top_p, but not both.").
+				LintRule(`root = if this > 1 || this < 0 { [ "field must be between 0 and 1" ] }`),
+			service.NewIntField(acpFieldTopK).
+				Optional().
+				Advanced().
+				Description("Only sample from the top K options for each subsequent token, which removes long tail low probability responses."),
+			service.NewStringListField(acpFieldStop).
+				Optional().
+				Advanced().
+				Description("Custom sequences that cause the model to stop generating further tokens."),
+			llm.CacheField(),
+			llm.RateLimitField(),
+			llm.SchemaValidationField(),
+		).LintRule(`
+      root = match {
+        this.`+llm.FieldSchemaValidation+`.enabled.or(false) && this.response_format != "json_schema" => ["`+"`"+llm.FieldSchemaValidation+"`"+` requires a `+"`"+acpFieldResponseFormat+"`"+` of `+"`json_schema`"+`"]
+        this.response_format == "json_schema" && !this.exists("`+acpFieldJSONSchema+`") => ["`+"`"+acpFieldJSONSchema+"`"+` must be set when responding in `+"`json_schema`"+` format"]
+      }
+    `).
+		Example(
+			"Extract structured data from invoices",
+			"This example sends PDF invoices read from a bucket to Claude, and extracts the invoice number and total as JSON.",
+			`
+input:
+  aws_s3:
+    bucket: invoices
+pipeline:
+  processors:
+    - anthropic_chat:
+        model: claude-3-5-sonnet-latest
+        api_key: "${ANTHROPIC_API_KEY}"
+        prompt: "Extract the invoice number and total from the attached invoice."
+        document: "root = content()"
+        response_format: json_schema
+        json_schema: |
+          {
+            "type": "object",
+            "properties": {
+              "invoice_number": {"type": "string"},
+              "total": {"type": "number"}
+            },
+            "required": ["invoice_number", "total"]
+          }
+output:
+  stdout: {}
+`)
+}
+
+func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+	baseURL, err := conf.FieldString(acpFieldBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := conf.FieldString(acpFieldAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	p := &chatProcessor{
+		client: newHTTPClient(baseURL, apiKey),
+		usage:  llm.NewUsageMetrics(mgr),
+	}
+	if p.model, err = conf.FieldString(acpFieldModel); err != nil {
+		return nil, err
+	}
+	if conf.Contains(acpFieldUserPrompt) {
+		if p.userPrompt, err = conf.FieldInterpolatedString(acpFieldUserPrompt); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(acpFieldSystemPrompt) {
+		if p.systemPrompt, err = conf.FieldInterpolatedString(acpFieldSystemPrompt); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(acpFieldImage) {
+		if p.image, err = conf.FieldBloblang(acpFieldImage); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(acpFieldDocument) {
+		if p.document, err = conf.FieldBloblang(acpFieldDocument); err != nil {
+			return nil, err
+		}
+	}
+	if p.maxTokens, err = conf.FieldInt(acpFieldMaxTokens); err != nil {
+		return nil, err
+	}
+	if conf.Contains(acpFieldTemp) {
+		v, err := conf.FieldFloat(acpFieldTemp)
+		if err != nil {
+			return nil, err
+		}
+		p.temperature = &v
+	}
+	if conf.Contains(acpFieldTopP) {
+		v, err := conf.FieldFloat(acpFieldTopP)
+		if err != nil {
+			return nil, err
+		}
+		p.topP = &v
+	}
+	if conf.Contains(acpFieldTopK) {
+		v, err := conf.FieldInt(acpFieldTopK)
+		if err != nil {
+			return nil, err
+		}
+		p.topK = &v
+	}
+	if conf.Contains(acpFieldStop) {
+		if p.stop, err = conf.FieldStringList(acpFieldStop); err != nil {
+			return nil, err
+		}
+	}
+	v, err := conf.FieldString(acpFieldResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	switch v {
+	case "text":
+	case "json":
+		p.schema = json.RawMessage(`{"type":"object"}`)
+	case "json_schema":
+		if !conf.Contains(acpFieldJSONSchema) {
+			return nil, fmt.Errorf("using %s %q, but did not specify %s", acpFieldResponseFormat, v, acpFieldJSONSchema)
+		}
+		s, err := conf.FieldString(acpFieldJSONSchema)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid %s: not valid JSON", acpFieldJSONSchema)
+		}
+		p.schema = json.RawMessage(s)
+	default:
+		return nil, fmt.Errorf("unknown %s: %q", acpFieldResponseFormat, v)
+	}
+	if p.cache, err = llm.NewResponseCacheFromParsed(conf, mgr); err != nil {
+		return nil, err
+	}
+	if p.limiter, err = llm.NewTokenLimiterFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if p.extractor, err = llm.NewExtractorFromParsed(conf); err != nil {
+		return nil, err
+	}
+	if p.extractor != nil && v != "json_schema" {
+		return nil, fmt.Errorf("%s requires %s %q", llm.FieldSchemaValidation, acpFieldResponseFormat, "json_schema")
+	}
+	return p, nil
+}
+
+type chatProcessor struct {
+	client client
+	model  string
+
+	userPrompt   *service.InterpolatedString
+	systemPrompt *service.InterpolatedString
+	image        *bloblang.Executor
+	document     *bloblang.Executor
+	maxTokens    int
+	temperature  *float64
+	topP         *float64
+	topK         *int
+	stop         []string
+	schema       json.RawMessage
+
+	cache     *llm.ResponseCache
+	limiter   *llm.TokenLimiter
+	usage     *llm.UsageMetrics
+	extractor *llm.Extractor
+}
+
+func (p *chatProcessor) buildRequest(msg *service.Message) (*messageRequest, error) {
+	req := &messageRequest{
+		Model:         p.model,
+		MaxTokens:     p.maxTokens,
+		Temperature:   p.temperature,
+		TopP:          p.topP,
+		TopK:          p.topK,
+		StopSequences: p.stop,
+	}
+	if p.systemPrompt != nil {
+		s, err := p.systemPrompt.TryString(msg)
+		if err != nil {
+			return nil, fmt.Errorf("%s interpolation error: %w", acpFieldSystemPrompt, err)
+		}
+		req.System = s
+	}
+	var content []contentBlock
+	if p.image != nil {
+		b, err := p.attachment(msg, p.image, acpFieldImage)
+		if err != nil {
+			return nil, err
+		}
+		mimeType := http.DetectContentType(b)
+		switch mimeType {
+		case "image/jpeg", "image/png", "image/gif", "image/webp":
+		default:
+			return nil, fmt.Errorf("invalid %s data, detected mime type: %s", acpFieldImage, mimeType)
+		}
+		content = append(content, contentBlock{
+			Type:   "image",
+			Source: &blockSource{Type: "base64", MediaType: mimeType, Data: base64.StdEncoding.EncodeToString(b)},
+		})
+	}
+	if p.document != nil {
+		b, err := p.attachment(msg, p.document, acpFieldDocument)
+		if err != nil {
+			return nil, err
+		}
+		if mimeType := http.DetectContentType(b); mimeType != "application/pdf" {
+			return nil, fmt.Errorf("invalid %s data, detected mime type: %s", acpFieldDocument, mimeType)
+		}
+		content = append(content, contentBlock{
+			Type:   "document",
+			Source: &blockSource{Type: "base64", MediaType: "application/pdf", Data: base64.StdEncoding.EncodeToString(b)},
+		})
+	}
+	if p.userPrompt != nil {
+		s, err := p.userPrompt.TryString(msg)
+		if err != nil {
+			return nil, fmt.Errorf("%s interpolation error: %w", acpFieldUserPrompt, err)
+		}
+		content = append(content, contentBlock{Type: "text", Text: s})
+	} else {
+		b, err := msg.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, contentBlock{Type: "text", Text: string(b)})
+	}
+	req.Messages = []message{{Role: "user", Content: content}}
+	if p.schema != nil {
+		req.Tools = []tool{{
+			Name:        structuredOutputTool,
+			Description: "Respond with structured output that matches the input schema.",
+			InputSchema: p.schema,
+		}}
+		req.ToolChoice = &toolChoice{Type: "tool", Name: structuredOutputTool}
+	}
+	return req, nil
+}
+
+func (p *chatProcessor) attachment(msg *service.Message, exec *bloblang.Executor, field string) ([]byte, error) {
+	v, err := msg.BloblangQuery(exec)
+	if err != nil {
+		return nil, fmt.Errorf("%s execution error: %w", field, err)
+	}
+	b, err := v.AsBytes()
+	if err != nil {
+		return nil, fmt.Errorf("%s conversion error: %w", field, err)
+	}
+	return b, nil
+}
+
+func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	req, err := p.buildRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	key, cached, ok := p.cache.Lookup(ctx, req)
+	if ok {
+		// Entries that cannot be decoded are treated as misses and replaced.
+		var entry cacheEntry
+		if err := json.Unmarshal(cached, &entry); err == nil {
+			msg = msg.Copy()
+			msg.SetBytes(entry.Content)
+			setResponseMetadata(msg, entry.StopReason, entry.InputTokens, entry.OutputTokens)
+			return service.MessageBatch{msg}, nil
+		}
+	}
+	resp, err := p.complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	content, err := responseContent(resp)
+	if err != nil {
+		return nil, err
+	}
+	if content, resp, err = p.extract(ctx, req, resp, content); err != nil {
+		return nil, err
+	}
+	if p.cache != nil {
+		entry, err := json.Marshal(cacheEntry{
+			Content:      content,
+			StopReason:   resp.StopReason,
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.cache.Store(ctx, key, entry)
+	}
+	msg = msg.Copy()
+	msg.SetBytes(content)
+	setResponseMetadata(msg, resp.StopReason, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+	return service.MessageBatch{msg}, nil
+}
+
+// cacheEntry is a response stored in the response cache, which includes the
+// metadata of the response so that it can be restored upon a cache hit.
+type cacheEntry struct {
+	Content      []byte `json:"content"`
+	StopReason   string `json:"stop_reason"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+func setResponseMetadata(msg *service.Message, stopReason string, inputTokens, outputTokens int) {
+	msg.MetaSetMut("anthropic_stop_reason", stopReason)
+	msg.MetaSetMut("anthropic_input_tokens", inputTokens)
+	msg.MetaSetMut("anthropic_output_tokens", outputTokens)
+}
+
+func (p *chatProcessor) complete(ctx context.Context, req *messageRequest) (*messageResponse, error) {
+	estimate := req.MaxTokens + llm.EstimateTokens(req.System)
+	for _, m := range req.Messages {
+		for _, c := range m.Content {
+			estimate += llm.EstimateTokens(c.Text) + llm.EstimateTokens(c.Content) + llm.EstimateTokens(string(c.Input))
+		}
+	}
+	if err := p.limiter.Acquire(ctx, estimate); err != nil {
+		return nil, err
+	}
+	resp, headers, err := p.client.CreateMessage(ctx, req)
+	p.limiter.ObserveHeaders(rateLimitHeaders(headers, time.Now()))
+	if err != nil {
+		p.limiter.Release(estimate, 0)
+		return nil, err
+	}
+	p.limiter.Release(estimate, resp.Usage.InputTokens+resp.Usage.OutputTokens)
+	p.usage.Record(p.model, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+	return resp, nil
+}
+
+// responseContent returns the payload of a response, which is either the
+// input of the structured output tool call or the text of the response.
+func responseContent(resp *messageResponse) ([]byte, error) {
+	var text strings.Builder
+	for _, c := range resp.Content {
+		switch c.Type {
+		case "tool_use":
+			if c.Name == structuredOutputTool {
+				return c.Input, nil
+			}
+		case "text":
+			text.WriteString(c.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, errors.New("response did not contain any content")
+	}
+	return []byte(text.String()), nil
+}
+
+// extract validates structured output against the JSON schema when schema
+// validation is enabled, re-prompting the model with the validation errors as
+// the result of the tool call if it's invalid.
+func (p *chatProcessor) extract(ctx context.Context, req *messageRequest, resp *messageResponse, content []byte) ([]byte, *messageResponse, error) {
+	if p.extractor == nil {
+		return content, resp, nil
+	}
+	completion, err := p.extractor.Extract(ctx, p.schema, func(ctx context.Context, followUp []llm.Turn) (string, error) {
+		if len(followUp) == 0 {
+			return string(content), nil
+		}
+		retry := *req
+		retry.Messages = slices.Clone(req.Messages)
+		for i := 0; i+1 < len(followUp); i += 2 {
+			id := "toolu_retry_" + strconv.Itoa(i/2)
+			retry.Messages = append(retry.Messages,
+				message{Role: "assistant", Content: []contentBlock{{
+					Type:  "tool_use",
+					ID:    id,
+					Name:  structuredOutputTool,
+					Input: toolInput(followUp[i].Content),
+				}}},
+				message{Role: "user", Content: []contentBlock{{
+					Type:      "tool_result",
+					ToolUseID: id,
+					Content:   followUp[i+1].Content,
+					IsError:   true,
+				}}},
+			)
+		}
+		var err error
+		if resp, err = p.complete(ctx, &retry); err != nil {
+			return "", err
+		}
+		b, err := responseContent(resp)
+		return string(b), err
+	})
+	return []byte(completion), resp, err
+}
+
+// toolInput returns a previous completion as the input of a tool call, which
+// must be a JSON object even when the completion wasn't.
+func toolInput(completion string) json.RawMessage {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(completion), &obj); err != nil {
+		b, _ := json.Marshal(map[string]string{"invalid_output": completion})
+		return b
+	}
+	return json.RawMessage(completion)
+}
+
+func (p *chatProcessor) Close(ctx context.Context) error {
+	return nil
+}
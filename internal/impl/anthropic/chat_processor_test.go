@@ -0,0 +1,183 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockMessagesServer replies to each request to the Messages API with the
+// next of its responses, recording the requests it receives.
+type mockMessagesServer struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []messageRequest
+}
+
+func (m *mockMessagesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.Header.Get("X-Api-Key") != "foo" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		return
+	}
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.requests = append(m.requests, req)
+	_, _ = w.Write([]byte(m.responses[len(m.requests)-1]))
+}
+
+func newTestProcessor(t *testing.T, mock *mockMessagesServer, yaml string) service.Processor {
+	t.Helper()
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	conf, err := chatProcessorConfig().ParseYAML(fmt.Sprintf(`
+base_url: %s
+api_key: foo
+model: claude-3-5-sonnet-latest
+%s`, srv.URL, yaml), nil)
+	require.NoError(t, err)
+	proc, err := makeChatProcessor(conf, service.MockResources())
+	require.NoError(t, err)
+	return proc
+}
+
+func TestChatText(t *testing.T) {
+	mock := &mockMessagesServer{responses: []string{
+		`{"id":"msg_1","content":[{"type":"text","text":"Hello there"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}`,
+	}}
+	proc := newTestProcessor(t, mock, `
+prompt: "Say hello to ${! json(\"name\") }"
+system_prompt: "Be brief"
+`)
+	out, err := proc.Process(context.Background(), service.NewMessage([]byte(`{"name":"bob"}`)))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+
+	b, err := out[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", string(b))
+	v, _ := out[0].MetaGetMut("anthropic_output_tokens")
+	assert.Equal(t, 3, v)
+	v, _ = out[0].MetaGetMut("anthropic_stop_reason")
+	assert.Equal(t, "end_turn", v)
+
+	require.Len(t, mock.requests, 1)
+	req := mock.requests[0]
+	assert.Equal(t, "Be brief", req.System)
+	assert.Equal(t, 1024, req.MaxTokens)
+	require.Len(t, req.Messages, 1)
+	assert.Equal(t, []contentBlock{{Type: "text", Text: "Say hello to bob"}}, req.Messages[0].Content)
+}
+
+func TestChatCacheMetadata(t *testing.T) {
+	mock := &mockMessagesServer{responses: []string{
+		`{"id":"msg_1","content":[{"type":"text","text":"Hello there"}],"stop_reason":"max_tokens","usage":{"input_tokens":12,"output_tokens":3}}`,
+	}}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	conf, err := chatProcessorConfig().ParseYAML(fmt.Sprintf(`
+base_url: %s
+api_key: foo
+model: claude-3-5-sonnet-latest
+cache:
+  resource: llm_cache
+`, srv.URL), nil)
+	require.NoError(t, err)
+	proc, err := makeChatProcessor(conf, service.MockResources(service.MockResourcesOptAddCache("llm_cache")))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		out, err := proc.Process(context.Background(), service.NewMessage([]byte("hello")))
+		require.NoError(t, err)
+		require.Len(t, out, 1)
+
+		b, err := out[0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "Hello there", string(b))
+		v, _ := out[0].MetaGetMut("anthropic_stop_reason")
+		assert.Equal(t, "max_tokens", v)
+		v, _ = out[0].MetaGetMut("anthropic_input_tokens")
+		assert.Equal(t, 12, v)
+		v, _ = out[0].MetaGetMut("anthropic_output_tokens")
+		assert.Equal(t, 3, v)
+	}
+	assert.Len(t, mock.requests, 1)
+}
+
+func TestChatJSONSchemaRetry(t *testing.T) {
+	mock := &mockMessagesServer{responses: []string{
+		`{"content":[{"type":"tool_use","id":"toolu_1","name":"structured_output","input":{"total":"lots"}}],"stop_reason":"tool_use"}`,
+		`{"content":[{"type":"tool_use","id":"toolu_2","name":"structured_output","input":{"total":42}}],"stop_reason":"tool_use"}`,
+	}}
+	proc := newTestProcessor(t, mock, `
+response_format: json_schema
+json_schema: '{"type":"object","properties":{"total":{"type":"number"}},"required":["total"]}'
+schema_validation:
+  enabled: true
+  max_retries: 1
+`)
+	out, err := proc.Process(context.Background(), service.NewMessage([]byte("what is the total?")))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	b, err := out[0].AsBytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":42}`, string(b))
+
+	require.Len(t, mock.requests, 2)
+	first := mock.requests[0]
+	require.Len(t, first.Tools, 1)
+	assert.Equal(t, &toolChoice{Type: "tool", Name: structuredOutputTool}, first.ToolChoice)
+
+	retry := mock.requests[1].Messages
+	require.Len(t, retry, 3)
+	assert.Equal(t, "tool_use", retry[1].Content[0].Type)
+	assert.JSONEq(t, `{"total":"lots"}`, string(retry[1].Content[0].Input))
+	assert.Equal(t, "tool_result", retry[2].Content[0].Type)
+	assert.Equal(t, retry[1].Content[0].ID, retry[2].Content[0].ToolUseID)
+	assert.True(t, retry[2].Content[0].IsError)
+}
+
+func TestChatAPIError(t *testing.T) {
+	srv := httptest.NewServer(&mockMessagesServer{})
+	t.Cleanup(srv.Close)
+	p := &chatProcessor{client: newHTTPClient(srv.URL, "bar"), model: "claude-3-5-sonnet-latest", maxTokens: 10}
+	_, err := p.Process(context.Background(), service.NewMessage([]byte("hello")))
+	var aerr *apiError
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, http.StatusUnauthorized, aerr.StatusCode)
+	assert.Equal(t, "authentication_error", aerr.Type)
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Anthropic-Ratelimit-Tokens-Remaining", "0")
+	h.Set("Anthropic-Ratelimit-Tokens-Reset", "2024-10-01T12:00:30Z")
+	out := rateLimitHeaders(h, now)
+	assert.Equal(t, "0", out.Get("x-ratelimit-remaining-tokens"))
+	assert.Equal(t, "30s", out.Get("x-ratelimit-reset-tokens"))
+
+	assert.Nil(t, rateLimitHeaders(http.Header{}, now))
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const apiVersion = "2023-06-01"
+
+type messageRequest struct {
+	Model         string      `json:"model"`
+	MaxTokens     int         `json:"max_tokens"`
+	System        string      `json:"system,omitempty"`
+	Messages      []message   `json:"messages"`
+	Temperature   *float64    `json:"temperature,omitempty"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	TopK          *int        `json:"top_k,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Tools         []tool      `json:"tools,omitempty"`
+	ToolChoice    *toolChoice `json:"tool_choice,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+	// Set for text blocks
+	Text string `json:"text,omitempty"`
+	// Set for image and document blocks
+	Source *blockSource `json:"source,omitempty"`
+	// Set for tool use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Set for tool result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type blockSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type messageResponse struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type apiError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("anthropic API error (status code %d, type %s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// client is the subset of the Anthropic Messages API used by the processors,
+// which is an interface so that it can be mocked in tests.
+type client interface {
+	CreateMessage(ctx context.Context, req *messageRequest) (*messageResponse, http.Header, error)
+}
+
+type httpClient struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+var _ client = (*httpClient)(nil)
+
+func newHTTPClient(baseURL, apiKey string) *httpClient {
+	return &httpClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{},
+	}
+}
+
+func (c *httpClient) CreateMessage(ctx context.Context, req *messageRequest) (*messageResponse, http.Header, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("X-Api-Key", c.apiKey)
+	hreq.Header.Set("Anthropic-Version", apiVersion)
+	hresp, err := c.http.Do(hreq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer hresp.Body.Close()
+	respBody, err := io.ReadAll(hresp.Body)
+	if err != nil {
+		return nil, hresp.Header, err
+	}
+	if hresp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &e); err != nil || e.Error.Message == "" {
+			e.Error.Message = string(respBody)
+		}
+		return nil, hresp.Header, &apiError{StatusCode: hresp.StatusCode, Type: e.Error.Type, Message: e.Error.Message}
+	}
+	var resp messageResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, hresp.Header, fmt.Errorf("unable to parse response: %w", err)
+	}
+	return &resp, hresp.Header, nil
+}
+
+// rateLimitHeaders converts the token rate limit headers of an Anthropic
+// response into the `x-ratelimit-*` form understood by llm.TokenLimiter.
+func rateLimitHeaders(h http.Header, now time.Time) http.Header {
+	remaining := h.Get("Anthropic-Ratelimit-Tokens-Remaining")
+	reset, err := time.Parse(time.RFC3339, h.Get("Anthropic-Ratelimit-Tokens-Reset"))
+	if remaining == "" || err != nil {
+		return nil
+	}
+	out := http.Header{}
+	out.Set("x-ratelimit-remaining-tokens", remaining)
+	out.Set("x-ratelimit-reset-tokens", max(reset.Sub(now), 0).String())
+	return out
+}
@@ -10,12 +10,16 @@ package enterprise
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/aws"
@@ -23,13 +27,22 @@ import (
 )
 
 const (
-	bedcpFieldModel        = "model"
-	bedcpFieldUserPrompt   = "prompt"
-	bedcpFieldSystemPrompt = "system_prompt"
-	bedcpFieldMaxTokens    = "max_tokens"
-	bedcpFieldStop         = "stop"
-	bedcpFieldTemp         = "temperature"
-	bedcpFieldTopP         = "top_p"
+	bedcpFieldModel          = "model"
+	bedcpFieldUserPrompt     = "prompt"
+	bedcpFieldSystemPrompt   = "system_prompt"
+	bedcpFieldImage          = "image"
+	bedcpFieldDocument       = "document"
+	bedcpFieldDocumentFormat = "document_format"
+	bedcpFieldMaxTokens      = "max_tokens"
+	bedcpFieldStop           = "stop"
+	bedcpFieldTemp           = "temperature"
+	bedcpFieldTopP           = "top_p"
+	bedcpFieldResponseFormat = "response_format"
+	bedcpFieldJSONSchema     = "json_schema"
+
+	// The name of the tool that the model is forced to call in order to
+	// produce structured output.
+	bedcpStructuredOutputTool = "structured_output"
 )
 
 func init() {
@@ -43,19 +56,46 @@ func newBedrockChatConfigSpec() *service.ConfigSpec {
 	return service.NewConfigSpec().
 		Summary("Generates responses to messages in a chat conversation, using the AWS Bedrock API.").
 		Description(`This processor sends prompts to your chosen large language model (LLM) and generates text from the responses, using the AWS Bedrock API.
-For more information, see the https://docs.aws.amazon.com/bedrock/latest/userguide[AWS Bedrock documentation^].`).
+For more information, see the https://docs.aws.amazon.com/bedrock/latest/userguide[AWS Bedrock documentation^].
+
+== Structured output
+
+When the ` + "`" + bedcpFieldResponseFormat + "`" + ` is ` + "`json`" + ` or ` + "`json_schema`" + `, the model is forced to respond by calling a tool whose input is the JSON schema, and the input of that tool call becomes the message payload. This requires a model that supports forced tool use, such as Anthropic Claude 3 and Mistral Large models.
+
+== Metadata
+
+The following metadata is added to each processed message:
+
+- bedrock_stop_reason
+- bedrock_input_tokens
+- bedrock_output_tokens`).
 		Categories("AI").
 		Version("4.34.0").
 		Fields(config.SessionFields()...).
 		Field(service.NewStringField(bedcpFieldModel).
 			Examples("amazon.titan-text-express-v1", "anthropic.claude-3-5-sonnet-20240620-v1:0", "cohere.command-text-v14", "meta.llama3-1-70b-instruct-v1:0", "mistral.mistral-large-2402-v1:0").
 			Description("The model ID to use. For a full list see the https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids.html[AWS Bedrock documentation^].")).
-		Field(service.NewStringField(bedcpFieldUserPrompt).
+		Field(service.NewInterpolatedStringField(bedcpFieldUserPrompt).
 			Description("The prompt you want to generate a response for. By default, the processor submits the entire payload as a string.").
 			Optional()).
-		Field(service.NewStringField(bedcpFieldSystemPrompt).
+		Field(service.NewInterpolatedStringField(bedcpFieldSystemPrompt).
 			Optional().
 			Description("The system prompt to submit to the AWS Bedrock LLM.")).
+		Field(service.NewBloblangField(bedcpFieldImage).
+			Description("An image to send along with the prompt. The mapping result must be a byte array of a PNG, JPEG, GIF or WEBP image.").
+			Version("4.42.0").
+			Example(`root = this.image.decode("base64") # decode base64 encoded image`).
+			Optional()).
+		Field(service.NewBloblangField(bedcpFieldDocument).
+			Description("A document to send along with the prompt. The mapping result must be a byte array.").
+			Version("4.42.0").
+			Example(`root = content()`).
+			Optional()).
+		Field(service.NewStringEnumField(bedcpFieldDocumentFormat, "pdf", "csv", "doc", "docx", "xls", "xlsx", "html", "txt", "md").
+			Description("The format of the `" + bedcpFieldDocument + "`.").
+			Version("4.42.0").
+			Default("pdf").
+			Advanced()).
 		Field(service.NewIntField(bedcpFieldMaxTokens).
 			Optional().
 			Description("The maximum number of tokens to allow in the generated response.").
@@ -72,7 +112,16 @@ For more information, see the https://docs.aws.amazon.com/bedrock/latest/usergui
 			Optional().
 			Advanced().
 			Description("The percentage of most-likely candidates that the model considers for the next token. For example, if you choose a value of 0.8, the model selects from the top 80% of the probability distribution of tokens that could be next in the sequence. ").
-			LintRule(`root = if this < 0 || this > 1 { ["field must be between 0.0-1.0"] }`))
+			LintRule(`root = if this < 0 || this > 1 { ["field must be between 0.0-1.0"] }`)).
+		Field(service.NewStringEnumField(bedcpFieldResponseFormat, "text", "json", "json_schema").
+			Description("Specify the model's output format. If `json_schema` is specified, then additionally a `json_schema` must be configured.").
+			Version("4.42.0").
+			Default("text")).
+		Field(service.NewStringField(bedcpFieldJSONSchema).
+			Description("The JSON schema to use when responding in `json_schema` format.").
+			Version("4.42.0").
+			Optional()).
+		LintRule(`root = if this.response_format == "json_schema" && !this.exists("` + bedcpFieldJSONSchema + `") { ["` + "`" + bedcpFieldJSONSchema + "`" + ` must be set when responding in ` + "`json_schema`" + ` format"] }`)
 }
 
 func newBedrockChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
@@ -134,35 +183,148 @@ func newBedrockChatProcessor(conf *service.ParsedConfig, mgr *service.Resources)
 		tp := float32(v)
 		p.topP = &tp
 	}
+	if conf.Contains(bedcpFieldImage) {
+		if p.image, err = conf.FieldBloblang(bedcpFieldImage); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(bedcpFieldDocument) {
+		if p.document, err = conf.FieldBloblang(bedcpFieldDocument); err != nil {
+			return nil, err
+		}
+		format, err := conf.FieldString(bedcpFieldDocumentFormat)
+		if err != nil {
+			return nil, err
+		}
+		p.documentFormat = bedrocktypes.DocumentFormat(format)
+	}
+	format, err := conf.FieldString(bedcpFieldResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "text":
+	case "json":
+		p.schema = map[string]any{"type": "object"}
+	case "json_schema":
+		if !conf.Contains(bedcpFieldJSONSchema) {
+			return nil, fmt.Errorf("using %s %q, but did not specify %s", bedcpFieldResponseFormat, format, bedcpFieldJSONSchema)
+		}
+		raw, err := conf.FieldString(bedcpFieldJSONSchema)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &p.schema); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", bedcpFieldJSONSchema, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown %s: %q", bedcpFieldResponseFormat, format)
+	}
 	return p, nil
 }
 
+type bedrockChatClient interface {
+	Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+}
+
 type bedrockChatProcessor struct {
-	client *bedrockruntime.Client
+	client bedrockChatClient
 	model  string
 
-	userPrompt   *service.InterpolatedString
-	systemPrompt *service.InterpolatedString
-	maxTokens    *int32
-	stop         []string
-	temp         *float32
-	topP         *float32
+	userPrompt     *service.InterpolatedString
+	systemPrompt   *service.InterpolatedString
+	image          *bloblang.Executor
+	document       *bloblang.Executor
+	documentFormat bedrocktypes.DocumentFormat
+	maxTokens      *int32
+	stop           []string
+	temp           *float32
+	topP           *float32
+	schema         any
 }
 
 func (b *bedrockChatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	input, err := b.buildInput(msg)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Converse(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	respOut, ok := resp.Output.(*bedrocktypes.ConverseOutputMemberMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected output: %T", resp)
+	}
+	out := msg.Copy()
+	if err := b.setContent(out, respOut.Value.Content); err != nil {
+		return nil, err
+	}
+	out.MetaSetMut("bedrock_stop_reason", string(resp.StopReason))
+	if resp.Usage != nil {
+		if resp.Usage.InputTokens != nil {
+			out.MetaSetMut("bedrock_input_tokens", int64(*resp.Usage.InputTokens))
+		}
+		if resp.Usage.OutputTokens != nil {
+			out.MetaSetMut("bedrock_output_tokens", int64(*resp.Usage.OutputTokens))
+		}
+	}
+	return service.MessageBatch{out}, nil
+}
+
+func (b *bedrockChatProcessor) buildInput(msg *service.Message) (*bedrockruntime.ConverseInput, error) {
 	prompt, err := b.computePrompt(msg)
 	if err != nil {
 		return nil, err
 	}
+	var content []bedrocktypes.ContentBlock
+	if b.image != nil {
+		img, err := b.attachment(msg, b.image, bedcpFieldImage)
+		if err != nil {
+			return nil, err
+		}
+		var format bedrocktypes.ImageFormat
+		switch mimeType := http.DetectContentType(img); mimeType {
+		case "image/png":
+			format = bedrocktypes.ImageFormatPng
+		case "image/jpeg":
+			format = bedrocktypes.ImageFormatJpeg
+		case "image/gif":
+			format = bedrocktypes.ImageFormatGif
+		case "image/webp":
+			format = bedrocktypes.ImageFormatWebp
+		default:
+			return nil, fmt.Errorf("invalid %s data, detected mime type: %s", bedcpFieldImage, mimeType)
+		}
+		content = append(content, &bedrocktypes.ContentBlockMemberImage{
+			Value: bedrocktypes.ImageBlock{
+				Format: format,
+				Source: &bedrocktypes.ImageSourceMemberBytes{Value: img},
+			},
+		})
+	}
+	if b.document != nil {
+		doc, err := b.attachment(msg, b.document, bedcpFieldDocument)
+		if err != nil {
+			return nil, err
+		}
+		name := "document"
+		content = append(content, &bedrocktypes.ContentBlockMemberDocument{
+			Value: bedrocktypes.DocumentBlock{
+				Format: b.documentFormat,
+				Name:   &name,
+				Source: &bedrocktypes.DocumentSourceMemberBytes{Value: doc},
+			},
+		})
+	}
+	content = append(content, &bedrocktypes.ContentBlockMemberText{
+		Value: prompt,
+	})
 	input := &bedrockruntime.ConverseInput{
 		Messages: []bedrocktypes.Message{
 			{
-				Role: bedrocktypes.ConversationRoleUser,
-				Content: []bedrocktypes.ContentBlock{
-					&bedrocktypes.ContentBlockMemberText{
-						Value: prompt,
-					},
-				},
+				Role:    bedrocktypes.ConversationRoleUser,
+				Content: content,
 			},
 		},
 		ModelId: &b.model,
@@ -182,26 +344,64 @@ func (b *bedrockChatProcessor) Process(ctx context.Context, msg *service.Message
 			&bedrocktypes.SystemContentBlockMemberText{Value: prompt},
 		}
 	}
-	resp, err := b.client.Converse(ctx, input)
+	if b.schema != nil {
+		name, desc := bedcpStructuredOutputTool, "Respond with structured output that matches the input schema."
+		input.ToolConfig = &bedrocktypes.ToolConfiguration{
+			Tools: []bedrocktypes.Tool{
+				&bedrocktypes.ToolMemberToolSpec{
+					Value: bedrocktypes.ToolSpecification{
+						Name:        &name,
+						Description: &desc,
+						InputSchema: &bedrocktypes.ToolInputSchemaMemberJson{
+							Value: document.NewLazyDocument(b.schema),
+						},
+					},
+				},
+			},
+			ToolChoice: &bedrocktypes.ToolChoiceMemberTool{
+				Value: bedrocktypes.SpecificToolChoice{Name: &name},
+			},
+		}
+	}
+	return input, nil
+}
+
+func (b *bedrockChatProcessor) attachment(msg *service.Message, exec *bloblang.Executor, field string) ([]byte, error) {
+	v, err := msg.BloblangQuery(exec)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s execution error: %w", field, err)
 	}
-	respOut, ok := resp.Output.(*bedrocktypes.ConverseOutputMemberMessage)
-	if !ok {
-		return nil, fmt.Errorf("unexpected output: %T", resp)
+	buf, err := v.AsBytes()
+	if err != nil {
+		return nil, fmt.Errorf("%s conversion error: %w", field, err)
+	}
+	return buf, nil
+}
+
+func (b *bedrockChatProcessor) setContent(out *service.Message, content []bedrocktypes.ContentBlock) error {
+	if b.schema != nil {
+		for _, c := range content {
+			if c, ok := c.(*bedrocktypes.ContentBlockMemberToolUse); ok && c.Value.Input != nil {
+				buf, err := c.Value.Input.MarshalSmithyDocument()
+				if err != nil {
+					return fmt.Errorf("unable to serialize structured output: %w", err)
+				}
+				out.SetBytes(buf)
+				return nil
+			}
+		}
+		return errors.New("response did not contain structured output")
 	}
-	content := respOut.Value.Content
 	if len(content) != 1 {
-		return nil, fmt.Errorf("unexpected number of response content: %d", len(content))
+		return fmt.Errorf("unexpected number of response content: %d", len(content))
 	}
-	out := msg.Copy()
 	switch c := content[0].(type) {
 	case *bedrocktypes.ContentBlockMemberText:
 		out.SetStructured(c.Value)
 	default:
-		return nil, fmt.Errorf("unsupported response content type: %T", content[0])
+		return fmt.Errorf("unsupported response content type: %T", content[0])
 	}
-	return service.MessageBatch{out}, nil
+	return nil
 }
 
 func (b *bedrockChatProcessor) computePrompt(msg *service.Message) (string, error) {
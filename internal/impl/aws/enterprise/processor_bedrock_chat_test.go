@@ -0,0 +1,99 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBedrockChatClient struct {
+	input  *bedrockruntime.ConverseInput
+	output *bedrockruntime.ConverseOutput
+}
+
+func (m *mockBedrockChatClient) Converse(_ context.Context, input *bedrockruntime.ConverseInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	m.input = input
+	return m.output, nil
+}
+
+func TestBedrockChatInterpolatedPromptAndDocument(t *testing.T) {
+	prompt, err := service.NewInterpolatedString(`Summarize the report for ${! @customer }`)
+	require.NoError(t, err)
+	doc, err := bloblang.Parse(`root = content()`)
+	require.NoError(t, err)
+	client := &mockBedrockChatClient{output: &bedrockruntime.ConverseOutput{
+		Output: &bedrocktypes.ConverseOutputMemberMessage{Value: bedrocktypes.Message{
+			Content: []bedrocktypes.ContentBlock{&bedrocktypes.ContentBlockMemberText{Value: "A summary"}},
+		}},
+		StopReason: bedrocktypes.StopReasonEndTurn,
+		Usage:      &bedrocktypes.TokenUsage{InputTokens: aws.Int32(50), OutputTokens: aws.Int32(5)},
+	}}
+	p := &bedrockChatProcessor{
+		client:         client,
+		model:          "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		userPrompt:     prompt,
+		document:       doc,
+		documentFormat: bedrocktypes.DocumentFormatTxt,
+	}
+	msg := service.NewMessage([]byte("quarterly numbers"))
+	msg.MetaSetMut("customer", "acme")
+	out, err := p.Process(context.Background(), msg)
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+
+	content := client.input.Messages[0].Content
+	require.Len(t, content, 2)
+	docBlock, ok := content[0].(*bedrocktypes.ContentBlockMemberDocument)
+	require.True(t, ok)
+	assert.Equal(t, bedrocktypes.DocumentFormatTxt, docBlock.Value.Format)
+	assert.Equal(t, &bedrocktypes.ContentBlockMemberText{Value: "Summarize the report for acme"}, content[1])
+
+	v, _ := out[0].MetaGetMut("bedrock_input_tokens")
+	assert.Equal(t, int64(50), v)
+	v, _ = out[0].MetaGetMut("bedrock_stop_reason")
+	assert.Equal(t, "end_turn", v)
+}
+
+func TestBedrockChatStructuredOutput(t *testing.T) {
+	client := &mockBedrockChatClient{output: &bedrockruntime.ConverseOutput{
+		Output: &bedrocktypes.ConverseOutputMemberMessage{Value: bedrocktypes.Message{
+			Content: []bedrocktypes.ContentBlock{&bedrocktypes.ContentBlockMemberToolUse{Value: bedrocktypes.ToolUseBlock{
+				Name:  aws.String(bedcpStructuredOutputTool),
+				Input: document.NewLazyDocument(map[string]any{"total": 42}),
+			}}},
+		}},
+		StopReason: bedrocktypes.StopReasonToolUse,
+	}}
+	p := &bedrockChatProcessor{
+		client: client,
+		model:  "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		schema: map[string]any{"type": "object"},
+	}
+	out, err := p.Process(context.Background(), service.NewMessage([]byte("what is the total?")))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	b, err := out[0].AsBytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":42}`, string(b))
+
+	require.NotNil(t, client.input.ToolConfig)
+	choice, ok := client.input.ToolConfig.ToolChoice.(*bedrocktypes.ToolChoiceMemberTool)
+	require.True(t, ok)
+	assert.Equal(t, bedcpStructuredOutputTool, *choice.Value.Name)
+}
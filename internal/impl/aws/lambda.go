@@ -29,54 +29,37 @@ var handler *serverless.Handler
 
 // RunLambda executes Benthos as an AWS Lambda function. Configuration can be
 // stored within the environment variable CONNECT_CONFIG.
+//
+// When the environment variable CONNECT_LAMBDA_EVENT_BATCHES is set to true the
+// records of SQS, Kinesis and S3 events are processed as a batch of messages,
+// and failed SQS and Kinesis records are reported as batch item failures.
+// Failures are determined from the messages written to sync_response outputs,
+// which is the default output, and configs with an output that does not
+// include one are rejected. When CONNECT_LAMBDA_S3_FETCH_OBJECTS is also set to
+// true the objects referenced by S3 events are fetched and used as the message
+// contents.
 func RunLambda() {
-	// A list of default config paths to check for if not explicitly defined
-	defaultPaths := []string{
-		"./redpanda-connect.yaml",
-		"/redpanda-connect.yaml",
-		"/etc/redpanda-connect/config.yaml",
-		"/etc/redpanda-connect.yaml",
-
-		"./connect.yaml",
-		"/connect.yaml",
-		"/etc/connect/config.yaml",
-		"/etc/connect.yaml",
-
-		"./benthos.yaml",
-		"./config.yaml",
-		"/benthos.yaml",
-		"/etc/benthos/config.yaml",
-		"/etc/benthos.yaml",
-	}
-	if path := os.Getenv("BENTHOS_CONFIG_PATH"); path != "" {
-		defaultPaths = append([]string{path}, defaultPaths...)
-	}
-	if path := os.Getenv("CONNECT_CONFIG_PATH"); path != "" {
-		defaultPaths = append([]string{path}, defaultPaths...)
-	}
-
-	confStr := os.Getenv("BENTHOS_CONFIG")
-	if confStr == "" {
-		confStr = os.Getenv("CONNECT_CONFIG")
-	}
-
-	if confStr == "" {
-		// Iterate default config paths
-		for _, path := range defaultPaths {
-			if confBytes, err := os.ReadFile(path); err == nil {
-				confStr = string(confBytes)
-				break
-			}
-		}
-	}
-
-	var err error
-	if handler, err = serverless.NewHandler(confStr); err != nil {
+	eventBatches, err := parseEnvBool("CONNECT_LAMBDA_EVENT_BATCHES", os.Getenv("CONNECT_LAMBDA_EVENT_BATCHES"))
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Initialisation error: %v\n", err)
 		os.Exit(1)
 	}
 
-	lambda.Start(handler.Handle)
+	if !eventBatches {
+		if handler, err = serverless.NewHandler(serverless.ReadConfig()); err != nil {
+			fmt.Fprintf(os.Stderr, "Initialisation error: %v\n", err)
+			os.Exit(1)
+		}
+		lambda.Start(handler.Handle)
+	} else {
+		eventHandler, err := newLambdaEventHandler(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Initialisation error: %v\n", err)
+			os.Exit(1)
+		}
+		handler = eventHandler.h
+		lambda.Start(eventHandler.Handle)
+	}
 
 	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
 	defer done()
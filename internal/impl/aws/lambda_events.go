@@ -0,0 +1,303 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/serverless"
+)
+
+// s3ObjectGetter is the subset of the S3 client API used for fetching the
+// objects referenced by S3 event notifications.
+type s3ObjectGetter interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// lambdaEventHandler recognises SQS, Kinesis and S3 event source payloads and
+// injects their records into a pipeline as a batch of messages, falling back to
+// a single structured message for any other event.
+type lambdaEventHandler struct {
+	h  *serverless.Handler
+	s3 s3ObjectGetter
+}
+
+func (l *lambdaEventHandler) Handle(ctx context.Context, payload json.RawMessage) (any, error) {
+	var probe struct {
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(payload, &probe); err == nil && len(probe.Records) > 0 {
+		switch probe.Records[0].EventSource {
+		case "aws:sqs":
+			var event events.SQSEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("failed to parse SQS event: %w", err)
+			}
+			return l.handleSQS(ctx, event)
+		case "aws:kinesis":
+			var event events.KinesisEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("failed to parse Kinesis event: %w", err)
+			}
+			return l.handleKinesis(ctx, event)
+		case "aws:s3":
+			var event events.S3Event
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("failed to parse S3 event: %w", err)
+			}
+			return l.handleS3(ctx, event)
+		}
+	}
+
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, err
+	}
+	return l.h.Handle(ctx, v)
+}
+
+// failedItems processes a batch and returns the identifiers, obtained from the
+// metadata key idKey, of all results that failed processing. When a failure
+// cannot be attributed to a specific message all identifiers are returned.
+func (l *lambdaEventHandler) failedItems(ctx context.Context, batch service.MessageBatch, idKey string, ids []string) []string {
+	results, err := l.h.HandleBatch(ctx, batch)
+	if err != nil {
+		return ids
+	}
+
+	failed := map[string]struct{}{}
+	for _, b := range results {
+		for _, m := range b {
+			if m.GetError() == nil {
+				continue
+			}
+			id, exists := m.MetaGet(idKey)
+			if !exists {
+				return ids
+			}
+			failed[id] = struct{}{}
+		}
+	}
+
+	failedIDs := []string{}
+	for _, id := range ids {
+		if _, exists := failed[id]; exists {
+			failedIDs = append(failedIDs, id)
+		}
+	}
+	return failedIDs
+}
+
+func (l *lambdaEventHandler) handleSQS(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	batch := make(service.MessageBatch, len(event.Records))
+	ids := make([]string, len(event.Records))
+	for i, r := range event.Records {
+		ids[i] = r.MessageId
+
+		msg := service.NewMessage([]byte(r.Body))
+		msg.MetaSetMut("sqs_message_id", r.MessageId)
+		msg.MetaSetMut("sqs_receipt_handle", r.ReceiptHandle)
+		msg.MetaSetMut("sqs_event_source_arn", r.EventSourceARN)
+		if rCountStr, exists := r.Attributes["ApproximateReceiveCount"]; exists {
+			msg.MetaSetMut("sqs_approximate_receive_count", rCountStr)
+		}
+		for k, v := range r.MessageAttributes {
+			if v.StringValue != nil {
+				msg.MetaSetMut(k, *v.StringValue)
+			}
+		}
+		batch[i] = msg
+	}
+
+	failedIDs := l.failedItems(ctx, batch, "sqs_message_id", ids)
+
+	// Messages of a FIFO queue that follow a failed message must also be
+	// reported as failed in order to preserve their ordering.
+	if len(failedIDs) > 0 && len(event.Records) > 0 && strings.HasSuffix(event.Records[0].EventSourceARN, ".fifo") {
+		for i, id := range ids {
+			if id == failedIDs[0] {
+				failedIDs = ids[i:]
+				break
+			}
+		}
+	}
+
+	res := events.SQSEventResponse{
+		BatchItemFailures: make([]events.SQSBatchItemFailure, len(failedIDs)),
+	}
+	for i, id := range failedIDs {
+		res.BatchItemFailures[i].ItemIdentifier = id
+	}
+	return res, nil
+}
+
+func (l *lambdaEventHandler) handleKinesis(ctx context.Context, event events.KinesisEvent) (events.KinesisEventResponse, error) {
+	batch := make(service.MessageBatch, len(event.Records))
+	ids := make([]string, len(event.Records))
+	for i, r := range event.Records {
+		ids[i] = r.Kinesis.SequenceNumber
+
+		msg := service.NewMessage(r.Kinesis.Data)
+		if _, streamName, found := strings.Cut(r.EventSourceArn, ":stream/"); found {
+			msg.MetaSetMut("kinesis_stream", streamName)
+		}
+		if shardID, _, found := strings.Cut(r.EventID, ":"); found {
+			msg.MetaSetMut("kinesis_shard", shardID)
+		}
+		msg.MetaSetMut("kinesis_partition_key", r.Kinesis.PartitionKey)
+		msg.MetaSetMut("kinesis_sequence_number", r.Kinesis.SequenceNumber)
+		batch[i] = msg
+	}
+
+	failedIDs := l.failedItems(ctx, batch, "kinesis_sequence_number", ids)
+
+	res := events.KinesisEventResponse{
+		BatchItemFailures: make([]events.KinesisBatchItemFailure, len(failedIDs)),
+	}
+	for i, id := range failedIDs {
+		res.BatchItemFailures[i].ItemIdentifier = id
+	}
+	return res, nil
+}
+
+func (l *lambdaEventHandler) handleS3(ctx context.Context, event events.S3Event) (any, error) {
+	batch := make(service.MessageBatch, len(event.Records))
+	for i, r := range event.Records {
+		var msg *service.Message
+		if l.s3 != nil {
+			var err error
+			if msg, err = l.fetchS3Object(ctx, r); err != nil {
+				return nil, err
+			}
+		} else {
+			recordBytes, err := json.Marshal(r)
+			if err != nil {
+				return nil, err
+			}
+			msg = service.NewMessage(recordBytes)
+		}
+		msg.MetaSetMut("s3_bucket", r.S3.Bucket.Name)
+		msg.MetaSetMut("s3_key", r.S3.Object.URLDecodedKey)
+		msg.MetaSetMut("s3_event_name", r.EventName)
+		batch[i] = msg
+	}
+
+	results, err := l.h.HandleBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, b := range results {
+		for _, m := range b {
+			if merr := m.GetError(); merr != nil {
+				errs = append(errs, merr)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("processing failed due to: %w", errors.Join(errs...))
+	}
+	return serverless.StructuredResults(results)
+}
+
+func (l *lambdaEventHandler) fetchS3Object(ctx context.Context, r events.S3EventRecord) (*service.Message, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.S3.Bucket.Name),
+		Key:    aws.String(r.S3.Object.URLDecodedKey),
+	}
+	if r.S3.Object.VersionID != "" {
+		input.VersionId = aws.String(r.S3.Object.VersionID)
+	}
+
+	obj, err := l.s3.GetObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object '%v' from bucket '%v': %w", r.S3.Object.URLDecodedKey, r.S3.Bucket.Name, err)
+	}
+	defer obj.Body.Close()
+
+	body, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object '%v' from bucket '%v': %w", r.S3.Object.URLDecodedKey, r.S3.Bucket.Name, err)
+	}
+
+	msg := service.NewMessage(body)
+	if obj.LastModified != nil {
+		msg.MetaSetMut("s3_last_modified", obj.LastModified.Format(time.RFC3339))
+		msg.MetaSetMut("s3_last_modified_unix", obj.LastModified.Unix())
+	}
+	if obj.ContentType != nil {
+		msg.MetaSetMut("s3_content_type", *obj.ContentType)
+	}
+	if obj.ContentEncoding != nil {
+		msg.MetaSetMut("s3_content_encoding", *obj.ContentEncoding)
+	}
+	if obj.VersionId != nil {
+		msg.MetaSetMut("s3_version_id", *obj.VersionId)
+	}
+	for k, v := range obj.Metadata {
+		msg.MetaSetMut(k, v)
+	}
+	return msg, nil
+}
+
+// parseEnvBool parses an optional boolean environment variable value.
+func parseEnvBool(name, v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %v: %w", name, err)
+	}
+	return b, nil
+}
+
+func newLambdaEventHandler(ctx context.Context) (*lambdaEventHandler, error) {
+	fetchObjects, err := parseEnvBool("CONNECT_LAMBDA_S3_FETCH_OBJECTS", os.Getenv("CONNECT_LAMBDA_S3_FETCH_OBJECTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &lambdaEventHandler{}
+	if fetchObjects {
+		awsConf, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		l.s3 = s3.NewFromConfig(awsConf)
+	}
+
+	if l.h, err = serverless.NewBatchHandler(serverless.ReadConfig()); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/connect/v4/internal/serverless"
+)
+
+const testLambdaEventsConfig = `
+pipeline:
+  processors:
+    - mapping: |
+        root = if content().string().contains("bad") { throw("nope") } else { content().string().uppercase() }
+logger:
+  level: NONE
+`
+
+func testLambdaEventHandler(t *testing.T, s3Getter s3ObjectGetter) *lambdaEventHandler {
+	t.Helper()
+
+	h, err := serverless.NewBatchHandler(testLambdaEventsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+		_ = h.Close(ctx)
+	})
+	return &lambdaEventHandler{h: h, s3: s3Getter}
+}
+
+func handleTestLambdaEvent(t *testing.T, l *lambdaEventHandler, event any) (any, error) {
+	t.Helper()
+
+	eventBytes, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	return l.Handle(ctx, eventBytes)
+}
+
+func TestLambdaEventsSQS(t *testing.T) {
+	l := testLambdaEventHandler(t, nil)
+
+	sqsEvent := func(arn string) events.SQSEvent {
+		var e events.SQSEvent
+		for _, r := range [][2]string{{"a", "foo"}, {"b", "bad"}, {"c", "bar"}, {"d", "bad"}} {
+			e.Records = append(e.Records, events.SQSMessage{
+				MessageId:      r[0],
+				Body:           r[1],
+				EventSource:    "aws:sqs",
+				EventSourceARN: arn,
+			})
+		}
+		return e
+	}
+
+	res, err := handleTestLambdaEvent(t, l, sqsEvent("arn:aws:sqs:us-east-1:123456789012:queue"))
+	require.NoError(t, err)
+	assert.Equal(t, events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{
+			{ItemIdentifier: "b"},
+			{ItemIdentifier: "d"},
+		},
+	}, res)
+
+	res, err = handleTestLambdaEvent(t, l, sqsEvent("arn:aws:sqs:us-east-1:123456789012:queue.fifo"))
+	require.NoError(t, err)
+	assert.Equal(t, events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{
+			{ItemIdentifier: "b"},
+			{ItemIdentifier: "c"},
+			{ItemIdentifier: "d"},
+		},
+	}, res)
+}
+
+func TestLambdaEventsKinesis(t *testing.T) {
+	l := testLambdaEventHandler(t, nil)
+
+	var e events.KinesisEvent
+	for _, r := range [][2]string{{"1", "foo"}, {"2", "bad"}, {"3", "bar"}} {
+		e.Records = append(e.Records, events.KinesisEventRecord{
+			EventID:        "shardId-000000000000:" + r[0],
+			EventSource:    "aws:kinesis",
+			EventSourceArn: "arn:aws:kinesis:us-east-1:123456789012:stream/foo",
+			Kinesis: events.KinesisRecord{
+				Data:           []byte(r[1]),
+				PartitionKey:   "key",
+				SequenceNumber: r[0],
+			},
+		})
+	}
+
+	res, err := handleTestLambdaEvent(t, l, e)
+	require.NoError(t, err)
+	assert.Equal(t, events.KinesisEventResponse{
+		BatchItemFailures: []events.KinesisBatchItemFailure{
+			{ItemIdentifier: "2"},
+		},
+	}, res)
+}
+
+type mockS3Getter struct {
+	objects map[string]string
+}
+
+func (m *mockS3Getter) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{
+		Body:        io.NopCloser(bytes.NewReader([]byte(m.objects[*params.Bucket+"/"+*params.Key]))),
+		ContentType: aws.String("text/plain"),
+	}, nil
+}
+
+func TestLambdaEventsS3FetchObjects(t *testing.T) {
+	l := testLambdaEventHandler(t, &mockS3Getter{
+		objects: map[string]string{
+			"foo/a b.json": `"hello"`,
+			"foo/c.json":   `"world"`,
+		},
+	})
+
+	var e events.S3Event
+	for _, key := range []string{"a+b.json", "c.json"} {
+		var r events.S3EventRecord
+		r.EventSource = "aws:s3"
+		r.S3.Bucket.Name = "foo"
+		r.S3.Object.Key = key
+		e.Records = append(e.Records, r)
+	}
+
+	res, err := handleTestLambdaEvent(t, l, e)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"HELLO", "WORLD"}, res)
+}
+
+func TestLambdaEventsFallback(t *testing.T) {
+	l := testLambdaEventHandler(t, nil)
+
+	res, err := handleTestLambdaEvent(t, l, map[string]any{"foo": "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"FOO": "BAR"}, res)
+
+	_, err = handleTestLambdaEvent(t, l, "bad")
+	require.ErrorContains(t, err, "nope")
+}
@@ -0,0 +1,256 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var errUnsupportedConversion = errors.New("unsupported conversion")
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// columnValues returns a slice of the values of a column within a batch of
+// objects, converted to the scan type of the column.
+func columnValues(t reflect.Type, name string, objects []map[string]any) (any, error) {
+	values := reflect.MakeSlice(reflect.SliceOf(t), len(objects), len(objects))
+	for i, obj := range objects {
+		v, err := convertValue(obj[name], t)
+		if err != nil {
+			return nil, fmt.Errorf("row %v: %w", i, err)
+		}
+		values.Index(i).Set(v)
+	}
+	return values.Interface(), nil
+}
+
+// convertValue converts a structured value to a type, where nil values are
+// converted to the zero value of the type.
+func convertValue(v any, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(t), nil
+	}
+	if t == timeType {
+		ts, err := toTime(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(ts), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := convertValue(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a boolean, got %T", v)
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := toInt64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res := reflect.New(t).Elem()
+		if res.OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("value %v overflows %v", i, t)
+		}
+		res.SetInt(i)
+		return res, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := toUint64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res := reflect.New(t).Elem()
+		if res.OverflowUint(u) {
+			return reflect.Value{}, fmt.Errorf("value %v overflows %v", u, t)
+		}
+		res.SetUint(u)
+		return res, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res := reflect.New(t).Elem()
+		if t.Kind() == reflect.Float32 && !math.IsInf(f, 0) && res.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("value %v overflows %v", f, t)
+		}
+		res.SetFloat(f)
+		return res, nil
+	case reflect.String:
+		switch s := v.(type) {
+		case string:
+			return reflect.ValueOf(s).Convert(t), nil
+		case []byte:
+			return reflect.ValueOf(string(s)).Convert(t), nil
+		case json.Number:
+			return reflect.ValueOf(s.String()).Convert(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("expected a string, got %T", v)
+	case reflect.Slice:
+		if b, ok := v.([]byte); ok && t.Elem().Kind() == reflect.Uint8 {
+			return reflect.ValueOf(b).Convert(t), nil
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected an array, got %T", v)
+		}
+		res := reflect.MakeSlice(t, len(arr), len(arr))
+		for i, e := range arr {
+			ev, err := convertValue(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %v: %w", i, err)
+			}
+			res.Index(i).Set(ev)
+		}
+		return res, nil
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected an object, got %T", v)
+		}
+		res := reflect.MakeMapWithSize(t, len(obj))
+		for k, e := range obj {
+			kv, err := convertValue(k, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %v: %w", k, err)
+			}
+			ev, err := convertValue(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %v: %w", k, err)
+			}
+			res.SetMapIndex(kv, ev)
+		}
+		return res, nil
+	}
+
+	if rv := reflect.ValueOf(v); rv.Type().AssignableTo(t) {
+		return rv, nil
+	}
+	return reflect.Value{}, fmt.Errorf("%w from %T to %v", errUnsupportedConversion, v, t)
+}
+
+func toInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v overflows int64", n)
+		}
+		return int64(n), nil
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		return strconv.ParseInt(n.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+func toUint64(v any) (uint64, error) {
+	switch n := v.(type) {
+	case uint32:
+		return uint64(n), nil
+	case uint64:
+		return n, nil
+	case float64:
+		if n != math.Trunc(n) || n < 0 || n >= math.MaxUint64 {
+			return 0, fmt.Errorf("value %v is not an unsigned integer", n)
+		}
+		return uint64(n), nil
+	case json.Number:
+		return strconv.ParseUint(n.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(n, 10, 64)
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("value %v is negative", i)
+	}
+	return uint64(i), nil
+}
+
+func toFloat64(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	return float64(i), nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if ts, err := time.Parse(layout, t); err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("failed to parse timestamp %q", t)
+	}
+	secs, err := toFloat64(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a timestamp, got %T", v)
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
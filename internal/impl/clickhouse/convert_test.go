@@ -0,0 +1,136 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnValues(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		chType      string
+		values      []any
+		expected    any
+		errContains string
+	}{
+		{
+			name:     "integers",
+			chType:   "Int32",
+			values:   []any{int64(1), json.Number("2"), float64(3), nil},
+			expected: []int32{1, 2, 3, 0},
+		},
+		{
+			name:        "integer overflow",
+			chType:      "UInt8",
+			values:      []any{int64(256)},
+			errContains: "overflows",
+		},
+		{
+			name:        "negative unsigned",
+			chType:      "UInt64",
+			values:      []any{int64(-1)},
+			errContains: "negative",
+		},
+		{
+			name:     "nullable",
+			chType:   "Nullable(Float64)",
+			values:   []any{1.5, nil},
+			expected: []*float64{ptr(1.5), nil},
+		},
+		{
+			name:     "low cardinality",
+			chType:   "LowCardinality(String)",
+			values:   []any{"foo", []byte("bar")},
+			expected: []string{"foo", "bar"},
+		},
+		{
+			name:     "timestamps",
+			chType:   "DateTime64(3)",
+			values:   []any{ts, "2024-05-06T07:08:09Z", json.Number("1714979289")},
+			expected: []time.Time{ts, ts, ts},
+		},
+		{
+			name:     "arrays",
+			chType:   "Array(Nullable(Int64))",
+			values:   []any{[]any{int64(1), nil}, nil},
+			expected: [][]*int64{{ptr(int64(1)), nil}, nil},
+		},
+		{
+			name:   "maps",
+			chType: "Map(String, Array(UInt16))",
+			values: []any{map[string]any{"a": []any{int64(1), int64(2)}}},
+			expected: []map[string][]uint16{
+				{"a": {1, 2}},
+			},
+		},
+		{
+			name:        "wrong type",
+			chType:      "String",
+			values:      []any{map[string]any{}},
+			errContains: "expected a string",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			col, err := column.Type(test.chType).Column("foo", time.UTC)
+			require.NoError(t, err)
+
+			objects := make([]map[string]any, len(test.values))
+			for i, v := range test.values {
+				objects[i] = map[string]any{"foo": v}
+			}
+
+			values, err := columnValues(col.ScanType(), "foo", objects)
+			if test.errContains != "" {
+				require.ErrorContains(t, err, test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, values)
+
+			// The driver accepts the converted values.
+			_, err = col.Append(values)
+			require.NoError(t, err)
+			assert.Equal(t, len(test.values), col.Rows())
+		})
+	}
+}
+
+func TestColumnValuesUnsupported(t *testing.T) {
+	col, err := column.Type("UUID").Column("foo", time.UTC)
+	require.NoError(t, err)
+
+	_, err = columnValues(col.ScanType(), "foo", []map[string]any{
+		{"foo": "7b3a9c2e-8f0d-4b1a-9e6c-2d5f4a8b1c3e"},
+	})
+	require.ErrorIs(t, err, errUnsupportedConversion)
+
+	// Unsupported conversions are left to the driver.
+	require.NoError(t, col.AppendRow("7b3a9c2e-8f0d-4b1a-9e6c-2d5f4a8b1c3e"))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/redpanda-data/benthos/v4/public/service/integration"
+)
+
+const integrationTableSchema = `(
+  id UInt64,
+  name String,
+  score Nullable(Float64),
+  ts DateTime64(3),
+  tags Array(LowCardinality(String)),
+  attrs Map(String, String),
+  uid UUID
+) ENGINE = MergeTree ORDER BY id`
+
+func TestIntegrationClickHouseOutput(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "clickhouse/clickhouse-server:24.8",
+			ExposedPorts: []string{"9000/tcp"},
+			Env:          map[string]string{"CLICKHOUSE_PASSWORD": "password"},
+			WaitingFor:   wait.ForListeningPort("9000/tcp").WithStartupTimeout(time.Minute * 3),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("Failed to terminate container: %s", err)
+		}
+	})
+
+	host, err := container.Host(ctx)
+	require.NoError(t, err)
+	port, err := container.MappedPort(ctx, "9000/tcp")
+	require.NoError(t, err)
+	dsn := fmt.Sprintf("clickhouse://default:password@%v:%v/default", host, port.Port())
+
+	opts, err := ch.ParseDSN(dsn)
+	require.NoError(t, err)
+	var conn driver.Conn
+	require.Eventually(t, func() bool {
+		c, err := ch.Open(opts)
+		if err != nil {
+			return false
+		}
+		if err := c.Ping(ctx); err != nil {
+			_ = c.Close()
+			return false
+		}
+		conn = c
+		return true
+	}, time.Minute, time.Second)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	writeBatch := func(t *testing.T, extraConf string, docs ...string) error {
+		t.Helper()
+
+		conf, err := outputConfig().ParseYAML(fmt.Sprintf("dsn: %v\n", dsn)+extraConf, nil)
+		require.NoError(t, err)
+
+		o, err := newOutputFromConfig(conf, service.MockResources())
+		require.NoError(t, err)
+		require.NoError(t, o.Connect(ctx))
+		t.Cleanup(func() {
+			require.NoError(t, o.Close(ctx))
+		})
+
+		var batch service.MessageBatch
+		for _, doc := range docs {
+			batch = append(batch, service.NewMessage([]byte(doc)))
+		}
+		return o.WriteBatch(ctx, batch)
+	}
+
+	type row struct {
+		ID    uint64
+		Name  string
+		Score *float64
+		TS    time.Time
+		Tags  []string
+		Attrs map[string]string
+		UID   string
+	}
+
+	readRows := func(t *testing.T, table string) (rows []row) {
+		t.Helper()
+
+		res, err := conn.Query(ctx, "SELECT id, name, score, ts, tags, attrs, toString(uid) FROM "+table+" ORDER BY id")
+		require.NoError(t, err)
+		defer res.Close()
+
+		for res.Next() {
+			var r row
+			require.NoError(t, res.Scan(&r.ID, &r.Name, &r.Score, &r.TS, &r.Tags, &r.Attrs, &r.UID))
+			r.TS = r.TS.UTC()
+			if len(r.Tags) == 0 {
+				r.Tags = nil
+			}
+			if len(r.Attrs) == 0 {
+				r.Attrs = nil
+			}
+			rows = append(rows, r)
+		}
+		require.NoError(t, res.Err())
+		return
+	}
+
+	score := 1.5
+
+	t.Run("columnar append and row fallback", func(t *testing.T) {
+		require.NoError(t, conn.Exec(ctx, "CREATE TABLE events "+integrationTableSchema))
+
+		// The uid column is of a type that values are not converted to, and is
+		// therefore appended one row at a time.
+		require.NoError(t, writeBatch(t, `
+table: events
+mapping: |
+  root = this
+  root.name = this.name.or("unknown")
+`,
+			`{"id":1,"name":"foo","score":1.5,"ts":"2024-01-02T03:04:05.678Z","tags":["a","b"],"attrs":{"k":"v"},"uid":"7e1a1f43-4a39-4cd2-9e4e-0ef0e7dd4b3c","ignored":true}`,
+			`{"id":2,"ts":1704164645,"score":null}`,
+		))
+
+		assert.Equal(t, []row{
+			{
+				ID:    1,
+				Name:  "foo",
+				Score: &score,
+				TS:    time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC),
+				Tags:  []string{"a", "b"},
+				Attrs: map[string]string{"k": "v"},
+				UID:   "7e1a1f43-4a39-4cd2-9e4e-0ef0e7dd4b3c",
+			},
+			{
+				ID:   2,
+				Name: "unknown",
+				TS:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				UID:  "00000000-0000-0000-0000-000000000000",
+			},
+		}, readRows(t, "events"))
+
+		// Values that cannot be converted to the type of their column fail the
+		// whole batch.
+		require.Error(t, writeBatch(t, "table: events\n", `{"id":3,"name":{"not":"a string"}}`))
+		assert.Len(t, readRows(t, "events"), 2)
+	})
+
+	t.Run("async insert", func(t *testing.T) {
+		require.NoError(t, conn.Exec(ctx, "CREATE TABLE events_async "+integrationTableSchema))
+
+		var docs []string
+		for i := 1; i <= 10; i++ {
+			docs = append(docs, fmt.Sprintf(`{"id":%v,"name":"foo%v","ts":"2024-01-02T03:04:05Z"}`, i, i))
+		}
+
+		// Waiting for the async insert means the rows are readable once the
+		// batch is written.
+		require.NoError(t, writeBatch(t, `
+table: events_async
+columns: [ id, name, ts ]
+async_insert:
+  enabled: true
+  wait: true
+`, docs...))
+
+		rows := readRows(t, "events_async")
+		require.Len(t, rows, 10)
+		for i, r := range rows {
+			assert.Equal(t, uint64(i+1), r.ID)
+			assert.Equal(t, fmt.Sprintf("foo%v", i+1), r.Name)
+			assert.Nil(t, r.Score)
+		}
+	})
+}
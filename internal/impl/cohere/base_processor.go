@@ -11,8 +11,11 @@ package cohere
 import (
 	"context"
 
+	cohereapi "github.com/cohere-ai/cohere-go/v2"
 	cohere "github.com/cohere-ai/cohere-go/v2/client"
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -38,13 +41,17 @@ func baseConfigFieldsWithModels(modelExamples ...any) []*service.ConfigField {
 type baseProcessor struct {
 	client *cohere.Client
 	model  string
+
+	cache   *llm.ResponseCache
+	limiter *llm.TokenLimiter
+	usage   *llm.UsageMetrics
 }
 
 func (b *baseProcessor) Close(ctx context.Context) error {
 	return nil
 }
 
-func newBaseProcessor(conf *service.ParsedConfig) (*baseProcessor, error) {
+func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (*baseProcessor, error) {
 	bu, err := conf.FieldString(cpFieldBaseURL)
 	if err != nil {
 		return nil, err
@@ -61,5 +68,35 @@ func newBaseProcessor(conf *service.ParsedConfig) (*baseProcessor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &baseProcessor{c, m}, nil
+	cache, err := llm.NewResponseCacheFromParsed(conf, mgr)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := llm.NewTokenLimiterFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &baseProcessor{
+		client:  c,
+		model:   m,
+		cache:   cache,
+		limiter: limiter,
+		usage:   llm.NewUsageMetrics(mgr),
+	}, nil
+}
+
+// recordUsage reports the tokens consumed by a request as returned in the
+// response metadata, falling back to the estimate when it's missing.
+func (b *baseProcessor) recordUsage(meta *cohereapi.ApiMeta, estimate int) {
+	prompt, completion := estimate, 0
+	if meta != nil && meta.Tokens != nil {
+		if meta.Tokens.InputTokens != nil {
+			prompt = int(*meta.Tokens.InputTokens)
+		}
+		if meta.Tokens.OutputTokens != nil {
+			completion = int(*meta.Tokens.OutputTokens)
+		}
+	}
+	b.limiter.Release(estimate, prompt+completion)
+	b.usage.Record(b.model, prompt, completion)
 }
@@ -10,6 +10,7 @@ package cohere
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"slices"
@@ -19,6 +20,7 @@ import (
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/confluent/sr"
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -135,8 +137,12 @@ We generally recommend altering this or temperature but not both.`).
 				Optional().
 				Advanced().
 				Description("Up to 4 sequences where the API will stop generating further tokens."),
+			llm.CacheField(),
+			llm.RateLimitField(),
+			llm.SchemaValidationField(),
 		).LintRule(`
       root = match {
+        this.` + llm.FieldSchemaValidation + `.enabled.or(false) && this.response_format != "json_schema" => ["` + "`" + llm.FieldSchemaValidation + "`" + ` requires a ` + "`" + ccpFieldResponseFormat + "`" + ` of ` + "`json_schema`" + `"]
         this.exists("` + ccpFieldJSONSchema + `") && this.exists("` + ccpFieldSchemaRegistry + `") => ["cannot set both ` + "`" + ccpFieldJSONSchema + "`" + ` and ` + "`" + ccpFieldSchemaRegistry + "`" + `"]
         this.response_format == "json_schema" && !this.exists("` + ccpFieldJSONSchema + `") && !this.exists("` + ccpFieldSchemaRegistry + `") => ["schema must be specified using either ` + "`" + ccpFieldJSONSchema + "`" + ` or ` + "`" + ccpFieldSchemaRegistry + "`" + `"]
       }
@@ -144,7 +150,7 @@ We generally recommend altering this or temperature but not both.`).
 }
 
 func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +257,14 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 	default:
 		return nil, fmt.Errorf("unknown %s: %q", ccpFieldResponseFormat, v)
 	}
-	return &chatProcessor{b, up, sp, maxTokens, temp, topP, frequencyPenalty, presencePenalty, seed, stop, responseFormat, schemaProvider}, nil
+	extractor, err := llm.NewExtractorFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	if extractor != nil && schemaProvider == nil {
+		return nil, fmt.Errorf("%s requires %s %q", llm.FieldSchemaValidation, ccpFieldResponseFormat, "json_schema")
+	}
+	return &chatProcessor{b, up, sp, maxTokens, temp, topP, frequencyPenalty, presencePenalty, seed, stop, responseFormat, schemaProvider, extractor}, nil
 }
 
 func newFixedSchemaProvider(conf *service.ParsedConfig) (jsonSchemaProvider, error) {
@@ -307,6 +320,7 @@ type chatProcessor struct {
 	stop             []string
 	responseFormat   cohere.ResponseFormat
 	schemaProvider   jsonSchemaProvider
+	extractor        *llm.Extractor
 }
 
 func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
@@ -347,11 +361,83 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 		}
 		body.Message = string(b)
 	}
-	resp, err := p.client.Chat(ctx, &body)
+	key, cached, ok := p.cache.Lookup(ctx, &body)
+	if ok {
+		msg = msg.Copy()
+		msg.SetBytes(cached)
+		return service.MessageBatch{msg}, nil
+	}
+	text, err := p.complete(ctx, &body)
 	if err != nil {
 		return nil, err
 	}
+	if text, err = p.extract(ctx, body, text); err != nil {
+		return nil, err
+	}
+	p.cache.Store(ctx, key, []byte(text))
 	msg = msg.Copy()
-	msg.SetBytes([]byte(resp.Text))
+	msg.SetBytes([]byte(text))
 	return service.MessageBatch{msg}, nil
 }
+
+func (p *chatProcessor) complete(ctx context.Context, body *cohere.ChatRequest) (string, error) {
+	estimate := llm.EstimateTokens(body.Message)
+	for _, m := range body.ChatHistory {
+		switch {
+		case m.User != nil:
+			estimate += llm.EstimateTokens(m.User.Message)
+		case m.Chatbot != nil:
+			estimate += llm.EstimateTokens(m.Chatbot.Message)
+		}
+	}
+	if body.Preamble != nil {
+		estimate += llm.EstimateTokens(*body.Preamble)
+	}
+	if body.MaxTokens != nil {
+		estimate += *body.MaxTokens
+	}
+	if err := p.limiter.Acquire(ctx, estimate); err != nil {
+		return "", err
+	}
+	resp, err := p.client.Chat(ctx, body)
+	if err != nil {
+		p.limiter.Release(estimate, 0)
+		return "", err
+	}
+	p.recordUsage(resp.Meta, estimate)
+	return resp.Text, nil
+}
+
+// extract validates a response against the JSON schema of the request when
+// schema validation is enabled, re-prompting the model if it's invalid.
+func (p *chatProcessor) extract(ctx context.Context, body cohere.ChatRequest, text string) (string, error) {
+	if p.extractor == nil || body.ResponseFormat == nil || body.ResponseFormat.JsonObject == nil || body.ResponseFormat.JsonObject.Schema == nil {
+		return text, nil
+	}
+	schema, err := json.Marshal(body.ResponseFormat.JsonObject.Schema)
+	if err != nil {
+		return "", fmt.Errorf("unable to serialize JSON schema: %w", err)
+	}
+	return p.extractor.Extract(ctx, schema, func(ctx context.Context, followUp []llm.Turn) (string, error) {
+		if len(followUp) == 0 {
+			return text, nil
+		}
+		// The original message and all but the last follow up become history,
+		// as the Cohere API takes the latest user message separately.
+		req := body
+		req.ChatHistory = append(slices.Clone(body.ChatHistory), &cohere.Message{
+			Role: "USER",
+			User: &cohere.ChatMessage{Message: body.Message},
+		})
+		for _, t := range followUp[:len(followUp)-1] {
+			m := &cohere.ChatMessage{Message: t.Content}
+			if t.Role == llm.RoleAssistant {
+				req.ChatHistory = append(req.ChatHistory, &cohere.Message{Role: "CHATBOT", Chatbot: m})
+			} else {
+				req.ChatHistory = append(req.ChatHistory, &cohere.Message{Role: "USER", User: m})
+			}
+		}
+		req.Message = followUp[len(followUp)-1].Content
+		return p.complete(ctx, &req)
+	})
+}
@@ -10,12 +10,15 @@ package cohere
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
 	cohere "github.com/cohere-ai/cohere-go/v2"
 	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -63,6 +66,8 @@ To learn more about vector embeddings, see the https://docs.cohere.com/docs/embe
 			}).
 				Description("Specifies the type of input passed to the model.").
 				Default("search_document"),
+			llm.CacheField(),
+			llm.RateLimitField(),
 		).
 		Example(
 			"Store embedding vectors in Qdrant",
@@ -87,7 +92,7 @@ output:
 }
 
 func makeEmbeddingsProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -141,13 +146,29 @@ func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message)
 		}
 		body.Texts = append(body.Texts, string(b))
 	}
+	key, cached, ok := p.cache.Lookup(ctx, &body)
+	if ok {
+		var data []any
+		if err := json.Unmarshal(cached, &data); err == nil {
+			msg = msg.Copy()
+			msg.SetStructuredMut(data)
+			return service.MessageBatch{msg}, nil
+		}
+	}
+	estimate := llm.EstimateTokens(body.Texts[0])
+	if err := p.limiter.Acquire(ctx, estimate); err != nil {
+		return nil, err
+	}
 	resp, err := p.client.Embed(ctx, &body)
 	if err != nil {
+		p.limiter.Release(estimate, 0)
 		return nil, err
 	}
 	if resp.EmbeddingsFloats == nil {
+		p.recordUsage(nil, estimate)
 		return nil, errors.New("expected embeddings output")
 	}
+	p.recordUsage(resp.EmbeddingsFloats.Meta, estimate)
 	if len(resp.EmbeddingsFloats.Embeddings) != 1 {
 		return nil, fmt.Errorf("expected a single embeddings response, got: %d", len(resp.EmbeddingsFloats.Embeddings))
 	}
@@ -156,6 +177,9 @@ func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message)
 	for i, f := range embd {
 		data[i] = f
 	}
+	if b, err := json.Marshal(embd); err == nil {
+		p.cache.Store(ctx, key, b)
+	}
 	msg = msg.Copy()
 	msg.SetStructuredMut(data)
 	return service.MessageBatch{msg}, nil
@@ -4,7 +4,7 @@
 // License (the "License"); you may not use this file except in compliance with
 // the License. You may obtain a copy of the License at
 //
-// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
 
 package enterprise
 
@@ -14,10 +14,12 @@ import (
 	"log/slog"
 	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 
@@ -32,6 +34,13 @@ const (
 	sharedGlobalRedpandaClientKey = "__redpanda_global"
 )
 
+const (
+	logsFormatLegacy   = "legacy"
+	logsFormatJSON     = "json"
+	logsFormatProtobuf = "protobuf"
+	logsFormatOTLP     = "otlp"
+)
+
 // TopicLoggerFields returns the topic logger config fields.
 func TopicLoggerFields() []*service.ConfigField {
 	return slices.Concat(
@@ -46,6 +55,27 @@ func TopicLoggerFields() []*service.ConfigField {
 				Example("__redpanda.connect.logs"),
 			service.NewStringEnumField("logs_level", "debug", "info", "warn", "error").
 				Default("info"),
+			service.NewStringAnnotatedEnumField("logs_format", map[string]string{
+				logsFormatLegacy:   "A JSON object containing the message, level, time, instance and pipeline IDs, with each log field added as a top level key.",
+				logsFormatJSON:     "A JSON object with a stable schema, where log fields are nested within an `attributes` object so that they cannot collide with the other keys.",
+				logsFormatProtobuf: "A protobuf encoded `LogEvent` message of the `redpanda.api.connect.v1alpha1` package.",
+				logsFormatOTLP:     "A protobuf encoded OTLP `LogsData` message containing a single log record.",
+			}).
+				Description("The encoding of log records sent to the logs topic. When tracing is enabled the trace and span IDs active when a record was logged are included.").
+				Default(logsFormatLegacy).
+				Advanced(),
+			service.NewIntField("logs_batch_size").
+				Description("The maximum number of log records sent to the logs topic in a single batch.").
+				Default(50).
+				Advanced(),
+			service.NewDurationField("logs_batch_period").
+				Description("The maximum period to wait before sending a batch of log records that is not yet full.").
+				Default("1s").
+				Advanced(),
+			service.NewIntField("logs_max_pending").
+				Description("The maximum number of log records waiting to be sent to the logs topic. When this is exceeded new log records are dropped rather than blocking, and the `redpanda_logs_dropped` counter metric is incremented.").
+				Default(100).
+				Advanced(),
 			service.NewStringField("status_topic").
 				Description("A topic to send status updates to.").
 				Default("").
@@ -62,8 +92,7 @@ func TopicLoggerFields() []*service.ConfigField {
 // topic. The writing is done by a regular output, but this type is necessary in
 // order to allow hot swapping of log components during start up.
 type TopicLogger struct {
-	id         string
-	pipelineID string
+	id string
 
 	fallbackLogger *atomic.Pointer[service.Logger]
 	o              *atomic.Pointer[service.OwnedOutput]
@@ -74,8 +103,20 @@ type TopicLogger struct {
 	streamStatus           *atomic.Pointer[service.RunningStreamSummary]
 	streamStatusPollTicker *time.Ticker
 
+	// The config is shared with the handlers returned by WithAttrs, which
+	// may be created before it is parsed.
+	confMut *sync.RWMutex
+	conf    *topicLoggerConf
+}
+
+// topicLoggerConf is the config of a topic logger, which is set once the
+// redpanda section of a config has been parsed.
+type topicLoggerConf struct {
+	pipelineID  string
 	logsTopic   string
+	logsFormat  string
 	statusTopic string
+	dropped     *service.MetricCounter
 }
 
 // NewTopicLogger constructs a new topic logger.
@@ -88,6 +129,8 @@ func NewTopicLogger(id string) *TopicLogger {
 		pendingWrites:          &atomic.Int64{},
 		streamStatus:           &atomic.Pointer[service.RunningStreamSummary]{},
 		streamStatusPollTicker: time.NewTicker(statusTickerDuration),
+		confMut:                &sync.RWMutex{},
+		conf:                   &topicLoggerConf{},
 	}
 	go t.statusEventLoop()
 	return t
@@ -98,6 +141,12 @@ func (l *TopicLogger) SetFallbackLogger(fLogger *service.Logger) {
 	l.fallbackLogger.Store(fLogger)
 }
 
+func (l *TopicLogger) loadConf() topicLoggerConf {
+	l.confMut.RLock()
+	defer l.confMut.RUnlock()
+	return *l.conf
+}
+
 // InitOutputFromParsed initialises the underlying output from the input config.
 func (l *TopicLogger) InitOutputFromParsed(pConf *service.ParsedConfig) error {
 	w, err := newTopicLoggerWriterFromConfig(pConf, l.fallbackLogger.Load())
@@ -108,18 +157,43 @@ func (l *TopicLogger) InitOutputFromParsed(pConf *service.ParsedConfig) error {
 		return nil
 	}
 
-	if l.pipelineID, err = pConf.FieldString("pipeline_id"); err != nil {
+	var conf topicLoggerConf
+	if conf.pipelineID, err = pConf.FieldString("pipeline_id"); err != nil {
+		return err
+	}
+
+	if conf.logsTopic, err = pConf.FieldString("logs_topic"); err != nil {
+		return err
+	}
+
+	if conf.logsFormat, err = pConf.FieldString("logs_format"); err != nil {
 		return err
 	}
 
-	if l.logsTopic, err = pConf.FieldString("logs_topic"); err != nil {
+	if conf.statusTopic, err = pConf.FieldString("status_topic"); err != nil {
 		return err
 	}
 
-	if l.statusTopic, err = pConf.FieldString("status_topic"); err != nil {
+	batchSize, err := pConf.FieldInt("logs_batch_size")
+	if err != nil {
 		return err
 	}
 
+	batchPeriod, err := pConf.FieldDuration("logs_batch_period")
+	if err != nil {
+		return err
+	}
+
+	maxPending, err := pConf.FieldInt("logs_max_pending")
+	if err != nil {
+		return err
+	}
+	if maxPending < 1 {
+		return fmt.Errorf("logs_max_pending must be at least 1, got %v", maxPending)
+	}
+
+	conf.dropped = pConf.Resources().Metrics().NewCounter("redpanda_logs_dropped")
+
 	lvlStr, err := pConf.FieldString("logs_level")
 	if err != nil {
 		return err
@@ -138,6 +212,9 @@ func (l *TopicLogger) InitOutputFromParsed(pConf *service.ParsedConfig) error {
 	default:
 		return fmt.Errorf("log level not recognized: %v", lvlStr)
 	}
+	l.confMut.Lock()
+	*l.conf = conf
+	l.confMut.Unlock()
 	l.level.Store(&lvl)
 
 	res := service.MockResources(service.MockResourcesOptUseLogger(l.fallbackLogger.Load()))
@@ -147,16 +224,24 @@ func (l *TopicLogger) InitOutputFromParsed(pConf *service.ParsedConfig) error {
 	}
 
 	batchPol, err := (service.BatchPolicy{
-		Count:  50,
-		Period: "1s",
+		Count:  batchSize,
+		Period: batchPeriod.String(),
 	}).NewBatcher(service.MockResources())
 	if err != nil {
 		return err
 	}
 
 	tmpO = tmpO.BatchedWith(batchPol)
-	if err := tmpO.PrimeBuffered(100); err == nil {
-		l.o.Store(tmpO)
+	if err := tmpO.PrimeBuffered(maxPending); err == nil {
+		// The config is parsed again when the pipeline is reloaded, in which
+		// case the writer of the previous config is replaced.
+		if prev := l.o.Swap(tmpO); prev != nil {
+			go func() {
+				ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+				defer done()
+				_ = prev.Close(ctx)
+			}()
+		}
 		l.TriggerEventConfigParsed()
 	} else {
 		l.fallbackLogger.Load().With("error", err.Error()).Warn("failed to initialise topic logs writer")
@@ -173,9 +258,34 @@ func (l *TopicLogger) Enabled(ctx context.Context, atLevel slog.Level) bool {
 	return atLevel >= *lvl
 }
 
+// newLogRecord returns the log record of a slog record, including the IDs of
+// the trace and span active within ctx, if any.
+func (l *TopicLogger) newLogRecord(ctx context.Context, r slog.Record, pipelineID string) logRecord {
+	rec := logRecord{
+		time:       r.Time,
+		level:      r.Level,
+		message:    r.Message,
+		instanceID: l.id,
+		pipelineID: pipelineID,
+		attrs:      make(map[string]string, len(l.attrs)+r.NumAttrs()),
+	}
+	for _, a := range l.attrs {
+		rec.attrs[a.Key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value.String()
+		return true
+	})
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.traceID, rec.spanID = sc.TraceID(), sc.SpanID()
+	}
+	return rec
+}
+
 // Handle invokes the logger for the input record.
 func (l *TopicLogger) Handle(ctx context.Context, r slog.Record) error {
-	if l.logsTopic == "" {
+	conf := l.loadConf()
+	if conf.logsTopic == "" {
 		return nil
 	}
 
@@ -184,25 +294,13 @@ func (l *TopicLogger) Handle(ctx context.Context, r slog.Record) error {
 		return nil
 	}
 
-	msg := service.NewMessage(nil)
-
-	v := map[string]any{
-		"message":     r.Message,
-		"level":       r.Level.String(),
-		"time":        r.Time.Format(time.RFC3339Nano),
-		"instance_id": l.id,
-		"pipeline_id": l.pipelineID,
-	}
-	for _, a := range l.attrs {
-		v[a.Key] = a.Value.String()
+	rec := l.newLogRecord(ctx, r, conf.pipelineID)
+	msg, err := rec.encode(conf.logsFormat)
+	if err != nil {
+		return err
 	}
-	r.Attrs(func(a slog.Attr) bool {
-		v[a.Key] = a.Value.String()
-		return true
-	})
-	msg.SetStructured(v)
-	msg.MetaSetMut(topicMetaKey, l.logsTopic)
-	msg.MetaSetMut(keyMetaKey, l.pipelineID)
+	msg.MetaSetMut(topicMetaKey, conf.logsTopic)
+	msg.MetaSetMut(keyMetaKey, conf.pipelineID)
 
 	tmpO := l.o.Load()
 	if tmpO == nil {
@@ -212,9 +310,13 @@ func (l *TopicLogger) Handle(ctx context.Context, r slog.Record) error {
 	l.pendingWrites.Add(1)
 	if err := tmpO.WriteBatchNonBlocking(service.MessageBatch{msg}, func(ctx context.Context, err error) error {
 		l.pendingWrites.Add(-1)
+		if err != nil {
+			conf.dropped.Incr(1)
+		}
 		return nil
 	}); err != nil {
 		l.pendingWrites.Add(-1)
+		conf.dropped.Incr(1)
 	}
 	return nil
 }
@@ -0,0 +1,177 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package enterprise
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/redpanda-data/connect/v4/internal/protoconnect"
+)
+
+// logRecord is a log captured by the topic logger, which is encoded in one of
+// the supported logs formats before being sent.
+type logRecord struct {
+	time       time.Time
+	level      slog.Level
+	message    string
+	instanceID string
+	pipelineID string
+	attrs      map[string]string
+	traceID    trace.TraceID
+	spanID     trace.SpanID
+}
+
+// jsonLogEvent is the stable schema of the json logs format.
+type jsonLogEvent struct {
+	Time       string            `json:"time"`
+	Level      string            `json:"level"`
+	Message    string            `json:"message"`
+	InstanceID string            `json:"instance_id"`
+	PipelineID string            `json:"pipeline_id"`
+	TraceID    string            `json:"trace_id,omitempty"`
+	SpanID     string            `json:"span_id,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+func (r *logRecord) encode(format string) (*service.Message, error) {
+	msg := service.NewMessage(nil)
+	switch format {
+	case logsFormatLegacy, "":
+		v := map[string]any{
+			"message":     r.message,
+			"level":       r.level.String(),
+			"time":        r.time.Format(time.RFC3339Nano),
+			"instance_id": r.instanceID,
+			"pipeline_id": r.pipelineID,
+		}
+		if r.traceID.IsValid() {
+			v["trace_id"] = r.traceID.String()
+			v["span_id"] = r.spanID.String()
+		}
+		// Log fields are added as top level keys and may therefore replace
+		// any of the above.
+		for k, a := range r.attrs {
+			v[k] = a
+		}
+		msg.SetStructured(v)
+	case logsFormatJSON:
+		e := jsonLogEvent{
+			Time:       r.time.Format(time.RFC3339Nano),
+			Level:      r.level.String(),
+			Message:    r.message,
+			InstanceID: r.instanceID,
+			PipelineID: r.pipelineID,
+			Attributes: r.attrs,
+		}
+		if r.traceID.IsValid() {
+			e.TraceID, e.SpanID = r.traceID.String(), r.spanID.String()
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		msg.SetBytes(b)
+	case logsFormatProtobuf:
+		e := &protoconnect.LogEvent{
+			PipelineId:        r.pipelineID,
+			InstanceId:        r.instanceID,
+			TimestampUnixNano: r.time.UnixNano(),
+			Level:             r.level.String(),
+			Message:           r.message,
+			Attributes:        r.attrs,
+		}
+		if r.traceID.IsValid() {
+			traceID, spanID := r.traceID.String(), r.spanID.String()
+			e.TraceId, e.SpanId = &traceID, &spanID
+		}
+		b, err := proto.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		msg.SetBytes(b)
+	case logsFormatOTLP:
+		b, err := proto.Marshal(r.otlpLogsData())
+		if err != nil {
+			return nil, err
+		}
+		msg.SetBytes(b)
+	default:
+		return nil, fmt.Errorf("logs format not recognized: %v", format)
+	}
+	return msg, nil
+}
+
+func otlpStringKV(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   k,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func otlpSeverity(lvl slog.Level) logspb.SeverityNumber {
+	switch {
+	case lvl >= slog.LevelError:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case lvl >= slog.LevelWarn:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case lvl >= slog.LevelInfo:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case lvl >= slog.LevelDebug:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+}
+
+func (r *logRecord) otlpLogsData() *logspb.LogsData {
+	lr := &logspb.LogRecord{
+		TimeUnixNano:         uint64(r.time.UnixNano()),
+		ObservedTimeUnixNano: uint64(r.time.UnixNano()),
+		SeverityNumber:       otlpSeverity(r.level),
+		SeverityText:         r.level.String(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: r.message}},
+	}
+	keys := make([]string, 0, len(r.attrs))
+	for k := range r.attrs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		lr.Attributes = append(lr.Attributes, otlpStringKV(k, r.attrs[k]))
+	}
+	if r.traceID.IsValid() {
+		lr.TraceId = r.traceID[:]
+		lr.SpanId = r.spanID[:]
+	}
+
+	return &logspb.LogsData{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{
+					otlpStringKV("service.name", "redpanda-connect"),
+					otlpStringKV("service.instance.id", r.instanceID),
+					otlpStringKV("redpanda.pipeline_id", r.pipelineID),
+				},
+			},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				LogRecords: []*logspb.LogRecord{lr},
+			}},
+		}},
+	}
+}
@@ -4,14 +4,12 @@
 // License (the "License"); you may not use this file except in compliance with
 // the License. You may obtain a copy of the License at
 //
-// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
 
 package enterprise
 
 import (
-	"bytes"
 	"context"
-	"strings"
 	"time"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -25,7 +23,7 @@ import (
 // to run it.
 func (l *TopicLogger) TriggerEventConfigParsed() {
 	l.sendStatusEvent(&protoconnect.StatusEvent{
-		PipelineId: l.pipelineID,
+		PipelineId: l.loadConf().pipelineID,
 		InstanceId: l.id,
 		Type:       protoconnect.StatusEvent_TYPE_INITIALIZING,
 		Timestamp:  time.Now().Unix(),
@@ -51,7 +49,7 @@ func (l *TopicLogger) TriggerEventStopped(err error) {
 		}
 	}
 	l.sendStatusEvent(&protoconnect.StatusEvent{
-		PipelineId: l.pipelineID,
+		PipelineId: l.loadConf().pipelineID,
 		InstanceId: l.id,
 		Type:       protoconnect.StatusEvent_TYPE_EXITING,
 		Timestamp:  time.Now().Unix(),
@@ -60,7 +58,8 @@ func (l *TopicLogger) TriggerEventStopped(err error) {
 }
 
 func (l *TopicLogger) sendStatusEvent(e *protoconnect.StatusEvent) {
-	if l.statusTopic == "" {
+	conf := l.loadConf()
+	if conf.statusTopic == "" {
 		return
 	}
 
@@ -72,8 +71,8 @@ func (l *TopicLogger) sendStatusEvent(e *protoconnect.StatusEvent) {
 
 	msg := service.NewMessage(nil)
 	msg.SetBytes(data)
-	msg.MetaSetMut(topicMetaKey, l.statusTopic)
-	msg.MetaSetMut(keyMetaKey, l.pipelineID)
+	msg.MetaSetMut(topicMetaKey, conf.statusTopic)
+	msg.MetaSetMut(keyMetaKey, conf.pipelineID)
 
 	tmpO := l.o.Load()
 	if tmpO == nil {
@@ -84,20 +83,6 @@ func (l *TopicLogger) sendStatusEvent(e *protoconnect.StatusEvent) {
 	}) // TODO: Log errors (occasionally)
 }
 
-// Convert a slice to a dot path following https://docs.redpanda.com/redpanda-connect/configuration/field_paths/
-func sliceToDotPath(path []string) string {
-	var b bytes.Buffer
-	for i, s := range path {
-		s = strings.ReplaceAll(s, "~", "~0")
-		s = strings.ReplaceAll(s, ".", "~1")
-		b.WriteString(s)
-		if i < len(path)-1 {
-			b.WriteRune('.')
-		}
-	}
-	return b.String()
-}
-
 func (l *TopicLogger) statusEventLoop() {
 	for {
 		_, open := <-l.streamStatusPollTicker.C
@@ -111,7 +96,7 @@ func (l *TopicLogger) statusEventLoop() {
 		}
 
 		e := &protoconnect.StatusEvent{
-			PipelineId: l.pipelineID,
+			PipelineId: l.loadConf().pipelineID,
 			InstanceId: l.id,
 			Timestamp:  time.Now().Unix(),
 			Type:       protoconnect.StatusEvent_TYPE_CONNECTION_HEALTHY,
@@ -122,7 +107,7 @@ func (l *TopicLogger) statusEventLoop() {
 			if !c.Active() {
 				e.Type = protoconnect.StatusEvent_TYPE_CONNECTION_ERROR
 				cErr := &protoconnect.ConnectionError{
-					Path: sliceToDotPath(c.Path()),
+					Path: protoconnect.SliceToDotPath(c.Path()),
 				}
 				if l := c.Label(); l != "" {
 					cErr.Label = &l
@@ -0,0 +1,238 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/redpanda-data/connect/v4/internal/protoconnect"
+)
+
+func testLogRecord(t *testing.T) logRecord {
+	t.Helper()
+
+	traceID, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("0102030405060708")
+	require.NoError(t, err)
+
+	return logRecord{
+		time:       time.Unix(10, 5).UTC(),
+		level:      slog.LevelWarn,
+		message:    "hello world",
+		instanceID: "foo",
+		pipelineID: "bar",
+		attrs:      map[string]string{"path": "root.input", "message": "collides"},
+		traceID:    traceID,
+		spanID:     spanID,
+	}
+}
+
+func TestTopicLoggerEncodeLegacy(t *testing.T) {
+	rec := testLogRecord(t)
+	msg, err := rec.encode(logsFormatLegacy)
+	require.NoError(t, err)
+
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "time": "1970-01-01T00:00:10.000000005Z",
+  "level": "WARN",
+  "message": "collides",
+  "instance_id": "foo",
+  "pipeline_id": "bar",
+  "path": "root.input",
+  "trace_id": "0102030405060708090a0b0c0d0e0f10",
+  "span_id": "0102030405060708"
+}`, string(b))
+}
+
+func TestTopicLoggerEncodeJSON(t *testing.T) {
+	rec := testLogRecord(t)
+	msg, err := rec.encode(logsFormatJSON)
+	require.NoError(t, err)
+
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "time": "1970-01-01T00:00:10.000000005Z",
+  "level": "WARN",
+  "message": "hello world",
+  "instance_id": "foo",
+  "pipeline_id": "bar",
+  "trace_id": "0102030405060708090a0b0c0d0e0f10",
+  "span_id": "0102030405060708",
+  "attributes": {"path": "root.input", "message": "collides"}
+}`, string(b))
+
+	rec.traceID, rec.spanID = trace.TraceID{}, trace.SpanID{}
+	msg, err = rec.encode(logsFormatJSON)
+	require.NoError(t, err)
+
+	b, err = msg.AsBytes()
+	require.NoError(t, err)
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(b, &v))
+	assert.NotContains(t, v, "trace_id")
+	assert.NotContains(t, v, "span_id")
+}
+
+func TestTopicLoggerEncodeProtobuf(t *testing.T) {
+	rec := testLogRecord(t)
+	msg, err := rec.encode(logsFormatProtobuf)
+	require.NoError(t, err)
+
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+
+	var e protoconnect.LogEvent
+	require.NoError(t, proto.Unmarshal(b, &e))
+	assert.Equal(t, "bar", e.PipelineId)
+	assert.Equal(t, "foo", e.InstanceId)
+	assert.Equal(t, int64(10_000_000_005), e.TimestampUnixNano)
+	assert.Equal(t, "WARN", e.Level)
+	assert.Equal(t, "hello world", e.Message)
+	assert.Equal(t, map[string]string{"path": "root.input", "message": "collides"}, e.Attributes)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", e.GetTraceId())
+	assert.Equal(t, "0102030405060708", e.GetSpanId())
+}
+
+func TestTopicLoggerEncodeOTLP(t *testing.T) {
+	rec := testLogRecord(t)
+	msg, err := rec.encode(logsFormatOTLP)
+	require.NoError(t, err)
+
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+
+	var d logspb.LogsData
+	require.NoError(t, proto.Unmarshal(b, &d))
+	require.Len(t, d.ResourceLogs, 1)
+
+	resAttrs := map[string]string{}
+	for _, kv := range d.ResourceLogs[0].Resource.Attributes {
+		resAttrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, map[string]string{
+		"service.name":         "redpanda-connect",
+		"service.instance.id":  "foo",
+		"redpanda.pipeline_id": "bar",
+	}, resAttrs)
+
+	require.Len(t, d.ResourceLogs[0].ScopeLogs, 1)
+	require.Len(t, d.ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+	lr := d.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+
+	assert.Equal(t, uint64(10_000_000_005), lr.TimeUnixNano)
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, lr.SeverityNumber)
+	assert.Equal(t, "WARN", lr.SeverityText)
+	assert.Equal(t, "hello world", lr.Body.GetStringValue())
+	assert.Equal(t, rec.traceID[:], lr.TraceId)
+	assert.Equal(t, rec.spanID[:], lr.SpanId)
+
+	require.Len(t, lr.Attributes, 2)
+	assert.Equal(t, "message", lr.Attributes[0].Key)
+	assert.Equal(t, "path", lr.Attributes[1].Key)
+}
+
+func TestTopicLoggerEncodeUnknown(t *testing.T) {
+	rec := testLogRecord(t)
+	_, err := rec.encode("nope")
+	require.Error(t, err)
+}
+
+func TestTopicLoggerWithAttrsConfig(t *testing.T) {
+	pConf, err := service.NewConfigSpec().Fields(TopicLoggerFields()...).ParseYAML(`
+seed_brokers: [ localhost:1 ]
+pipeline_id: foo
+logs_topic: __logs
+status_topic: __status
+`, nil)
+	require.NoError(t, err)
+
+	l := NewTopicLogger("bar")
+	l.SetFallbackLogger(service.MockResources().Logger())
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer done()
+		_ = l.Close(ctx)
+	})
+
+	// Handlers derived before the config is parsed, as they are when the
+	// logger is created by the CLI, are used concurrently with parsing.
+	h := l.WithAttrs([]slog.Attr{slog.String("path", "root")}).(*TopicLogger)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0))
+		}
+	}()
+	require.NoError(t, l.InitOutputFromParsed(pConf))
+	wg.Wait()
+
+	conf := h.loadConf()
+	assert.Equal(t, "foo", conf.pipelineID)
+	assert.Equal(t, "__logs", conf.logsTopic)
+	assert.Equal(t, logsFormatLegacy, conf.logsFormat)
+	assert.Equal(t, "__status", conf.statusTopic)
+	assert.NotNil(t, conf.dropped)
+}
+
+func TestTopicLoggerRecordTraceIDs(t *testing.T) {
+	l := NewTopicLogger("foo")
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer done()
+		_ = l.Close(ctx)
+	})
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0)
+
+	// Without an active span the IDs are left empty.
+	rec := l.newLogRecord(context.Background(), r, "bar")
+	assert.False(t, rec.traceID.IsValid())
+	assert.False(t, rec.spanID.IsValid())
+
+	tp := sdktrace.NewTracerProvider()
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	rec = l.newLogRecord(ctx, r, "bar")
+	assert.Equal(t, span.SpanContext().TraceID(), rec.traceID)
+	assert.Equal(t, span.SpanContext().SpanID(), rec.spanID)
+
+	msg, err := rec.encode(logsFormatJSON)
+	require.NoError(t, err)
+	b, err := msg.AsBytes()
+	require.NoError(t, err)
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(b, &v))
+	assert.Equal(t, span.SpanContext().TraceID().String(), v["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), v["span_id"])
+}
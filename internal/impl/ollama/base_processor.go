@@ -22,10 +22,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/dustin/go-humanize"
 	"github.com/ollama/ollama/api"
 	"github.com/redpanda-data/benthos/v4/public/service"
 
+	"github.com/redpanda-data/connect/v4/internal/asyncroutine"
+	"github.com/redpanda-data/connect/v4/internal/llm"
 	"github.com/redpanda-data/connect/v4/internal/singleton"
 )
 
@@ -34,6 +35,7 @@ const (
 	bopFieldModel          = "model"
 	bopFieldCacheDirectory = "cache_directory"
 	bopFieldDownloadURL    = "download_url"
+	bopFieldKeepAlive      = "keep_alive"
 
 	bopFieldRunner = "runner"
 	// Runner fields
@@ -73,6 +75,11 @@ func commonFields() []*service.ConfigField {
 				Advanced().
 				Description("Lock the model in memory, preventing it from being swapped out when memory-mapped. This option can improve performance but reduces some of the advantages of memory-mapping because it uses more RAM to run and can slow down load times as the model loads into RAM."),
 		).Optional().Description(`Options for the model runner that are used when the model is first loaded into memory.`),
+		service.NewDurationField(bopFieldKeepAlive).
+			Description("How long a model stays loaded in memory after a request. Idle models are unloaded once this period has passed without requests, which frees up memory for other models. A value of `0s` unloads a model immediately after each request, and a negative value keeps models loaded indefinitely. By default the server decides, which is 5 minutes unless configured otherwise.").
+			Example("10m").
+			Example("-1s").
+			Optional(),
 		service.NewStringField(bopFieldServerAddress).
 			Description("The address of the Ollama server to use. Leave the field blank and the processor starts and runs a local Ollama server or specify the address of your own local or remote server.").
 			Example("http://127.0.0.1:11434").
@@ -245,11 +252,20 @@ func (co *commandOutput) Write(b []byte) (int, error) {
 }
 
 type baseOllamaProcessor struct {
-	model  string
-	opts   map[string]any
-	ticket singleton.Ticket
-	client *api.Client
-	logger *service.Logger
+	model     *service.InterpolatedString
+	keepAlive *api.Duration
+	opts      map[string]any
+	ticket    singleton.Ticket
+	client    *api.Client
+	logger    *service.Logger
+	metrics   *asyncroutine.Periodic
+
+	pullsMu sync.Mutex
+	pulls   map[string]*modelPull
+
+	cache   *llm.ResponseCache
+	limiter *llm.TokenLimiter
+	usage   *llm.UsageMetrics
 }
 
 type key int
@@ -268,14 +284,31 @@ type runOllamaConfig struct {
 func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *baseOllamaProcessor, err error) {
 	p = &baseOllamaProcessor{}
 	p.logger = mgr.Logger()
-	p.model, err = conf.FieldString(bopFieldModel)
+	p.model, err = conf.FieldInterpolatedString(bopFieldModel)
 	if err != nil {
 		return
 	}
+	if conf.Contains(bopFieldKeepAlive) {
+		var d time.Duration
+		d, err = conf.FieldDuration(bopFieldKeepAlive)
+		if err != nil {
+			return
+		}
+		p.keepAlive = &api.Duration{Duration: d}
+	}
 	p.opts, err = extractOptions(conf)
 	if err != nil {
 		return
 	}
+	p.cache, err = llm.NewResponseCacheFromParsed(conf, mgr)
+	if err != nil {
+		return
+	}
+	p.limiter, err = llm.NewTokenLimiterFromParsed(conf)
+	if err != nil {
+		return
+	}
+	p.usage = llm.NewUsageMetrics(mgr)
 	if conf.Contains(bopFieldServerAddress) {
 		var a string
 		a, err = conf.FieldString(bopFieldServerAddress)
@@ -337,11 +370,14 @@ func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *ba
 	if err = p.waitForServer(context.Background()); err != nil {
 		return
 	}
-	p.logger.Infof("Pulling %q", p.model)
-	if err = p.pullModel(context.Background()); err != nil {
-		return
+	// Models that are routed to dynamically are pulled when they're first used.
+	if model, static := p.model.Static(); static {
+		if err = p.ensureModel(context.Background(), model); err != nil {
+			return
+		}
 	}
-	p.logger.Infof("Finished pulling %q", p.model)
+	p.metrics = asyncroutine.NewPeriodicWithContext(serverMetricsInterval, newServerMetrics(p.client, mgr).update)
+	p.metrics.Start()
 	return
 }
 
@@ -360,17 +396,10 @@ func (o *baseOllamaProcessor) waitForServer(ctx context.Context) error {
 	}
 }
 
-func (o *baseOllamaProcessor) pullModel(ctx context.Context) error {
-	pr := api.PullRequest{
-		Model: o.model,
-	}
-	return o.client.Pull(ctx, &pr, func(resp api.ProgressResponse) error {
-		o.logger.Tracef("Pulling %q: %s [%s/%s]", o.model, resp.Status, humanize.Bytes(uint64(resp.Completed)), humanize.Bytes(uint64(resp.Total)))
-		return nil
-	})
-}
-
 func (o *baseOllamaProcessor) Close(ctx context.Context) error {
+	if o.metrics != nil {
+		o.metrics.Stop()
+	}
 	if ollamaProcess == nil {
 		return nil
 	}
@@ -17,6 +17,8 @@ import (
 	"github.com/ollama/ollama/api"
 	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -60,9 +62,9 @@ By default, the processor starts and runs a locally installed Ollama server. Alt
 For more information, see the https://github.com/ollama/ollama/tree/main/docs[Ollama documentation^].`).
 		Version("4.32.0").
 		Fields(
-			service.NewStringField(bopFieldModel).
-				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website].").
-				Examples("llama3.1", "gemma2", "qwen2", "phi3"),
+			service.NewInterpolatedStringField(bopFieldModel).
+				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website]. This field supports interpolation functions so that messages can be routed to different models, in which case each model is pulled the first time it's used.").
+				Examples("llama3.1", "gemma2", "qwen2", "phi3", `${! @model }`),
 			service.NewInterpolatedStringField(ocpFieldUserPrompt).
 				Description("The prompt you want to generate a response for. By default, the processor submits the entire payload as a string.").
 				Optional(),
@@ -125,6 +127,8 @@ For more information, see the https://github.com/ollama/ollama/tree/main/docs[Ol
 			service.NewBoolField(ocpFieldEmitPromptMetadata).
 				Default(false).
 				Description(`If enabled the prompt is saved as @prompt metadata on the output message. If system_prompt is used it's also saved as @system_prompt`),
+			llm.CacheField(),
+			llm.RateLimitField(),
 		).Fields(commonFields()...).
 		Example(
 			"Use Llava to analyze an image",
@@ -230,7 +234,11 @@ func (o *ollamaCompletionProcessor) Process(ctx context.Context, msg *service.Me
 			return nil, fmt.Errorf("unable to convert `%s` result to a byte array: %w", ocpFieldImage, err)
 		}
 	}
-	g, err := o.generateCompletion(ctx, sp, up, image)
+	model, err := o.resolveModel(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	g, err := o.generateCompletion(ctx, model, sp, up, image)
 	if err != nil {
 		return nil, err
 	}
@@ -259,9 +267,10 @@ func (o *ollamaCompletionProcessor) computePrompt(msg *service.Message) (string,
 	return string(b), nil
 }
 
-func (o *ollamaCompletionProcessor) generateCompletion(ctx context.Context, systemPrompt, userPrompt string, image []byte) (string, error) {
+func (o *ollamaCompletionProcessor) generateCompletion(ctx context.Context, model, systemPrompt, userPrompt string, image []byte) (string, error) {
 	var req api.ChatRequest
-	req.Model = o.model
+	req.Model = model
+	req.KeepAlive = o.keepAlive
 	req.Options = o.opts
 	req.Format = o.format
 	if systemPrompt != "" {
@@ -281,12 +290,29 @@ func (o *ollamaCompletionProcessor) generateCompletion(ctx context.Context, syst
 	})
 	shouldStream := false
 	req.Stream = &shouldStream
+	key, cached, ok := o.cache.Lookup(ctx, &req)
+	if ok {
+		return string(cached), nil
+	}
+	estimate := llm.EstimateTokens(systemPrompt) + llm.EstimateTokens(userPrompt)
+	if err := o.limiter.Acquire(ctx, estimate); err != nil {
+		return "", err
+	}
 	var g string
+	var metrics api.Metrics
 	err := o.client.Chat(ctx, &req, func(resp api.ChatResponse) error {
 		g = resp.Message.Content
+		metrics = resp.Metrics
 		return nil
 	})
-	return g, err
+	if err != nil {
+		o.limiter.Release(estimate, 0)
+		return "", err
+	}
+	o.limiter.Release(estimate, metrics.PromptEvalCount+metrics.EvalCount)
+	o.usage.Record(model, metrics.PromptEvalCount, metrics.EvalCount)
+	o.cache.Store(ctx, key, []byte(g))
+	return g, nil
 }
 
 func (o *ollamaCompletionProcessor) Close(ctx context.Context) error {
@@ -27,10 +27,12 @@ func createCompletionProcessorForTest(t *testing.T, addr string) *ollamaCompleti
 	t.Helper()
 	url, err := url.Parse(addr)
 	assert.NoError(t, err)
+	// use smallest model possible to make it cheaper
+	model, err := service.NewInterpolatedString("tinyllama")
+	assert.NoError(t, err)
 	return &ollamaCompletionProcessor{
 		baseOllamaProcessor: &baseOllamaProcessor{
-			// use smallest model possible to make it cheaper
-			model:  "tinyllama",
+			model:  model,
 			client: api.NewClient(url, http.DefaultClient),
 		},
 		userPrompt:   nil,
@@ -52,7 +54,7 @@ func TestOllamaCompletionIntegration(t *testing.T) {
 	addr, err := ollamaContainer.ConnectionString(ctx)
 	assert.NoError(t, err)
 	proc := createCompletionProcessorForTest(t, addr)
-	err = proc.pullModel(context.Background())
+	err = proc.pullModel(context.Background(), "tinyllama")
 	assert.NoError(t, err)
 	msg := service.NewMessage([]byte("In one word what color is snow?"))
 	batch, err := proc.Process(ctx, msg)
@@ -10,11 +10,14 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"unicode/utf8"
 
 	"github.com/ollama/ollama/api"
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -43,12 +46,14 @@ By default, the processor starts and runs a locally installed Ollama server. Alt
 For more information, see the https://github.com/ollama/ollama/tree/main/docs[Ollama documentation^].`).
 		Version("4.32.0").
 		Fields(
-			service.NewStringField(bopFieldModel).
-				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website].").
+			service.NewInterpolatedStringField(bopFieldModel).
+				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website]. This field supports interpolation functions so that messages can be routed to different models, in which case each model is pulled the first time it's used.").
 				Examples("nomic-embed-text", "mxbai-embed-large", "snowflake-artic-embed", "all-minilm"),
 			service.NewInterpolatedStringField(oepFieldText).
 				Description("The text you want to create vector embeddings for. By default, the processor submits the entire payload as a string.").
 				Optional(),
+			llm.CacheField(),
+			llm.RateLimitField(),
 		).Fields(commonFields()...).
 		Example(
 			"Store embedding vectors in Qdrant",
@@ -125,7 +130,11 @@ func (o *ollamaEmbeddingProcessor) Process(ctx context.Context, msg *service.Mes
 	if err != nil {
 		return nil, err
 	}
-	e, err := o.generateEmbedding(ctx, p)
+	model, err := o.resolveModel(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	e, err := o.generateEmbedding(ctx, model, p)
 	if err != nil {
 		return nil, err
 	}
@@ -152,15 +161,33 @@ func (o *ollamaEmbeddingProcessor) computeText(msg *service.Message) (string, er
 	return string(b), nil
 }
 
-func (o *ollamaEmbeddingProcessor) generateEmbedding(ctx context.Context, text string) ([]float64, error) {
+func (o *ollamaEmbeddingProcessor) generateEmbedding(ctx context.Context, model, text string) ([]float64, error) {
 	var req api.EmbeddingRequest
-	req.Model = o.model
+	req.Model = model
+	req.KeepAlive = o.keepAlive
 	req.Prompt = text
 	req.Options = o.opts
+	key, cached, ok := o.cache.Lookup(ctx, &req)
+	if ok {
+		var e []float64
+		if err := json.Unmarshal(cached, &e); err == nil {
+			return e, nil
+		}
+	}
+	// The embeddings API doesn't report usage, so only the estimate is known.
+	estimate := llm.EstimateTokens(text)
+	if err := o.limiter.Acquire(ctx, estimate); err != nil {
+		return nil, err
+	}
 	resp, err := o.client.Embeddings(ctx, &req)
 	if err != nil {
+		o.limiter.Release(estimate, 0)
 		return nil, err
 	}
+	o.usage.Record(model, estimate, 0)
+	if b, err := json.Marshal(resp.Embedding); err == nil {
+		o.cache.Store(ctx, key, b)
+	}
 	return resp.Embedding, nil
 }
 
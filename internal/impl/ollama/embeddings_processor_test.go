@@ -26,10 +26,12 @@ func createEmbeddingsProcessorForTest(t *testing.T, addr string) *ollamaEmbeddin
 	t.Helper()
 	url, err := url.Parse(addr)
 	assert.NoError(t, err)
+	// use smallest model possible to make it cheaper
+	model, err := service.NewInterpolatedString("all-minilm")
+	assert.NoError(t, err)
 	return &ollamaEmbeddingProcessor{
 		baseOllamaProcessor: &baseOllamaProcessor{
-			// use smallest model possible to make it cheaper
-			model:  "all-minilm",
+			model:  model,
 			client: api.NewClient(url, http.DefaultClient),
 		},
 		text: nil,
@@ -50,7 +52,7 @@ func TestOllamaEmbeddingsIntegration(t *testing.T) {
 	addr, err := ollamaContainer.ConnectionString(ctx)
 	assert.NoError(t, err)
 	proc := createEmbeddingsProcessorForTest(t, addr)
-	err = proc.pullModel(context.Background())
+	err = proc.pullModel(context.Background(), "all-minilm")
 	assert.NoError(t, err)
 	msg := service.NewMessage([]byte("Redpanda is the fastest and best streaming platform"))
 	batch, err := proc.Process(ctx, msg)
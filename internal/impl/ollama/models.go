@@ -0,0 +1,164 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ollama/ollama/api"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	// How often to report the health and memory usage of the server.
+	serverMetricsInterval = 15 * time.Second
+	// How often to log the progress of pulling a model.
+	pullProgressInterval = 5 * time.Second
+)
+
+// modelPull tracks pulling a model so that concurrent messages routed to the
+// same model only pull it once.
+type modelPull struct {
+	done chan struct{}
+	err  error
+}
+
+// resolveModel returns the model that a message should be sent to, pulling
+// the model if it's not yet available on the server.
+func (o *baseOllamaProcessor) resolveModel(ctx context.Context, msg *service.Message) (string, error) {
+	model, err := o.model.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("%s interpolation error: %w", bopFieldModel, err)
+	}
+	if model == "" {
+		return "", fmt.Errorf("%s interpolation resulted in an empty string", bopFieldModel)
+	}
+	if err := o.ensureModel(ctx, model); err != nil {
+		return "", err
+	}
+	return model, nil
+}
+
+func (o *baseOllamaProcessor) ensureModel(ctx context.Context, model string) error {
+	o.pullsMu.Lock()
+	if o.pulls == nil {
+		o.pulls = map[string]*modelPull{}
+	}
+	p, exists := o.pulls[model]
+	if !exists {
+		p = &modelPull{done: make(chan struct{})}
+		o.pulls[model] = p
+	}
+	o.pullsMu.Unlock()
+
+	if exists {
+		select {
+		case <-p.done:
+			return p.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p.err = o.pullModel(ctx, model)
+	if p.err != nil {
+		// Allow the pull to be retried by the next message.
+		o.pullsMu.Lock()
+		delete(o.pulls, model)
+		o.pullsMu.Unlock()
+	}
+	close(p.done)
+	return p.err
+}
+
+func (o *baseOllamaProcessor) pullModel(ctx context.Context, model string) error {
+	if _, err := o.client.Show(ctx, &api.ShowRequest{Model: model}); err == nil {
+		return nil // The model is already available locally
+	}
+	o.logger.Infof("Pulling %q", model)
+	var lastStatus string
+	var lastLogged time.Time
+	pr := api.PullRequest{
+		Model: model,
+	}
+	err := o.client.Pull(ctx, &pr, func(resp api.ProgressResponse) error {
+		if resp.Status == lastStatus && time.Since(lastLogged) < pullProgressInterval {
+			o.logger.Tracef("Pulling %q: %s [%s/%s]", model, resp.Status, humanize.Bytes(uint64(resp.Completed)), humanize.Bytes(uint64(resp.Total)))
+			return nil
+		}
+		lastStatus, lastLogged = resp.Status, time.Now()
+		if resp.Total > 0 {
+			o.logger.Infof("Pulling %q: %s [%s/%s]", model, resp.Status, humanize.Bytes(uint64(resp.Completed)), humanize.Bytes(uint64(resp.Total)))
+		} else {
+			o.logger.Infof("Pulling %q: %s", model, resp.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to pull model %q: %w", model, err)
+	}
+	o.logger.Infof("Finished pulling %q", model)
+	return nil
+}
+
+// serverMetrics reports the health of the ollama server and the memory used
+// by each of the models that it has loaded.
+type serverMetrics struct {
+	client *api.Client
+	logger *service.Logger
+
+	healthy     *service.MetricGauge
+	modelMemory *service.MetricGauge
+	modelVRAM   *service.MetricGauge
+	loaded      map[string]struct{}
+}
+
+func newServerMetrics(client *api.Client, mgr *service.Resources) *serverMetrics {
+	return &serverMetrics{
+		client:      client,
+		logger:      mgr.Logger(),
+		healthy:     mgr.Metrics().NewGauge("ollama_server_healthy"),
+		modelMemory: mgr.Metrics().NewGauge("ollama_model_memory_bytes", "model"),
+		modelVRAM:   mgr.Metrics().NewGauge("ollama_model_vram_bytes", "model"),
+		loaded:      map[string]struct{}{},
+	}
+}
+
+func (s *serverMetrics) update(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, serverMetricsInterval)
+	defer cancel()
+	if err := s.client.Heartbeat(ctx); err != nil {
+		s.logger.Debugf("Ollama server health check failed: %v", err)
+		s.healthy.Set(0)
+		return
+	}
+	s.healthy.Set(1)
+	running, err := s.client.ListRunning(ctx)
+	if err != nil {
+		s.logger.Debugf("Failed to list running ollama models: %v", err)
+		return
+	}
+	loaded := make(map[string]struct{}, len(running.Models))
+	for _, m := range running.Models {
+		loaded[m.Name] = struct{}{}
+		s.modelMemory.Set(m.Size, m.Name)
+		s.modelVRAM.Set(m.SizeVRAM, m.Name)
+	}
+	// Models that have since been unloaded no longer use any memory.
+	for name := range s.loaded {
+		if _, ok := loaded[name]; !ok {
+			s.modelMemory.Set(0, name)
+			s.modelVRAM.Set(0, name)
+		}
+	}
+	s.loaded = loaded
+}
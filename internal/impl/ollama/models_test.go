@@ -0,0 +1,70 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package ollama
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveModelPullsOnce(t *testing.T) {
+	var pulls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+		case "/api/pull":
+			pulls.Add(1)
+			_, _ = w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"status":"success"}` + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	model, err := service.NewInterpolatedString(`${! @model }`)
+	require.NoError(t, err)
+	p := &baseOllamaProcessor{
+		model:  model,
+		client: api.NewClient(u, http.DefaultClient),
+		logger: service.MockResources().Logger(),
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := service.NewMessage(nil)
+			msg.MetaSetMut("model", "llama3.1")
+			m, err := p.resolveModel(context.Background(), msg)
+			assert.NoError(t, err)
+			assert.Equal(t, "llama3.1", m)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), pulls.Load())
+
+	msg := service.NewMessage(nil)
+	msg.MetaSetMut("model", "")
+	_, err = p.resolveModel(context.Background(), msg)
+	require.Error(t, err)
+}
@@ -117,14 +117,18 @@ func (o *ollamaModerationProcessor) Process(ctx context.Context, msg *service.Me
 	if err != nil {
 		return nil, fmt.Errorf("interpolation error for %s: %w", ompFieldAssistantResponse, err)
 	}
-	g, err := o.generateCompletion(ctx, p, r)
+	model, err := o.resolveModel(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	g, err := o.generateCompletion(ctx, model, p, r)
 	if err != nil {
 		return nil, err
 	}
 	m := msg.Copy()
 	lines := strings.Split(g, "\n")
 	if len(lines) == 0 {
-		return nil, fmt.Errorf("unable to extract a moderation response from %s", o.model)
+		return nil, fmt.Errorf("unable to extract a moderation response from %s", model)
 	}
 	var safe string
 	switch lines[0] {
@@ -133,7 +137,7 @@ func (o *ollamaModerationProcessor) Process(ctx context.Context, msg *service.Me
 	case "No", "safe":
 		safe = "yes"
 	default:
-		return nil, fmt.Errorf("unexpected moderation response from %s: %q", o.model, lines[0])
+		return nil, fmt.Errorf("unexpected moderation response from %s: %q", model, lines[0])
 	}
 	m.MetaSet("safe", safe)
 	if len(lines) > 1 {
@@ -142,9 +146,10 @@ func (o *ollamaModerationProcessor) Process(ctx context.Context, msg *service.Me
 	return service.MessageBatch{m}, nil
 }
 
-func (o *ollamaModerationProcessor) generateCompletion(ctx context.Context, prompt, response string) (string, error) {
+func (o *ollamaModerationProcessor) generateCompletion(ctx context.Context, model, prompt, response string) (string, error) {
 	var req api.ChatRequest
-	req.Model = o.model
+	req.Model = model
+	req.KeepAlive = o.keepAlive
 	req.Options = o.opts
 	req.Messages = append(req.Messages, api.Message{
 		Role:    "user",
@@ -31,9 +31,11 @@ func createModerationProcessorForTest(t *testing.T, model, addr, prompt, respons
 	require.NoError(t, err)
 	r, err := service.NewInterpolatedString(response)
 	require.NoError(t, err)
+	m, err := service.NewInterpolatedString(model)
+	require.NoError(t, err)
 	return &ollamaModerationProcessor{
 		baseOllamaProcessor: &baseOllamaProcessor{
-			model:  model,
+			model:  m,
 			client: api.NewClient(url, http.DefaultClient),
 		},
 		prompt:   p,
@@ -60,7 +62,7 @@ func TestOllamaModerationIntegration(t *testing.T) {
 	require.NoError(t, err)
 	for _, model := range []string{"llama-guard3:1b", "shieldgemma:2b"} {
 		proc := createModerationProcessorForTest(t, model, addr, "How can I adopt my own llama?", "${!content()}")
-		err = proc.pullModel(ctx)
+		err = proc.pullModel(ctx, model)
 		require.NoError(t, err)
 		msg := service.NewMessage([]byte("Go to the zoo and steal one!"))
 		batch, err := proc.Process(ctx, msg)
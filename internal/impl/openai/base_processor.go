@@ -13,6 +13,8 @@ import (
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 	oai "github.com/sashabaranov/go-openai"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -38,13 +40,18 @@ func baseConfigFieldsWithModels(modelExamples ...any) []*service.ConfigField {
 type baseProcessor struct {
 	client client
 	model  string
+
+	cache   *llm.ResponseCache
+	limiter *llm.TokenLimiter
+	usage   *llm.UsageMetrics
+	batch   *batchAPI
 }
 
 func (b *baseProcessor) Close(ctx context.Context) error {
 	return nil
 }
 
-func newBaseProcessor(conf *service.ParsedConfig) (*baseProcessor, error) {
+func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (*baseProcessor, error) {
 	sa, err := conf.FieldString(opFieldServerAddress)
 	if err != nil {
 		return nil, err
@@ -60,5 +67,24 @@ func newBaseProcessor(conf *service.ParsedConfig) (*baseProcessor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &baseProcessor{c, m}, nil
+	cache, err := llm.NewResponseCacheFromParsed(conf, mgr)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := llm.NewTokenLimiterFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := newBatchAPIFromParsed(conf, mgr)
+	if err != nil {
+		return nil, err
+	}
+	return &baseProcessor{
+		client:  c,
+		model:   m,
+		cache:   cache,
+		limiter: limiter,
+		usage:   llm.NewUsageMetrics(mgr),
+		batch:   batch,
+	}, nil
 }
@@ -0,0 +1,234 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	oai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	opFieldBatchAPI                 = "batch_api"
+	opFieldBatchAPIEnabled          = "enabled"
+	opFieldBatchAPIPollInterval     = "poll_interval"
+	opFieldBatchAPICompletionWindow = "completion_window"
+)
+
+func batchAPIField() *service.ConfigField {
+	return service.NewObjectField(opFieldBatchAPI,
+		service.NewBoolField(opFieldBatchAPIEnabled).
+			Description("Whether to submit message batches to the Batch API.").
+			Default(false),
+		service.NewDurationField(opFieldBatchAPIPollInterval).
+			Description("How often to poll the status of a submitted batch.").
+			Default("1m"),
+		service.NewStringEnumField(opFieldBatchAPICompletionWindow, "24h").
+			Description("The time frame within which the batch should be processed.").
+			Default("24h"),
+	).
+		Description("Submit message batches to the https://platform.openai.com/docs/guides/batch[OpenAI Batch API^] instead of making a request per message. Each message batch is uploaded as a JSONL batch file, and the processor then polls until the batch has finished before mapping the results back to the original messages. Messages are not acknowledged until the batch has completed, which can take up to the completion window, so this mode is best suited to bulk workloads such as backfills. The size of each batch is controlled with a batching policy on the input.").
+		Optional().
+		Advanced()
+}
+
+type batchAPI struct {
+	pollInterval     time.Duration
+	completionWindow string
+	logger           *service.Logger
+}
+
+func newBatchAPIFromParsed(conf *service.ParsedConfig, mgr *service.Resources) (*batchAPI, error) {
+	conf = conf.Namespace(opFieldBatchAPI)
+	if enabled, err := conf.FieldBool(opFieldBatchAPIEnabled); err != nil || !enabled {
+		return nil, err
+	}
+	pollInterval, err := conf.FieldDuration(opFieldBatchAPIPollInterval)
+	if err != nil {
+		return nil, err
+	}
+	completionWindow, err := conf.FieldString(opFieldBatchAPICompletionWindow)
+	if err != nil {
+		return nil, err
+	}
+	return &batchAPI{
+		pollInterval:     pollInterval,
+		completionWindow: completionWindow,
+		logger:           mgr.Logger(),
+	}, nil
+}
+
+// batchResult is a single line of the output or error file of a batch.
+type batchResult struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// err returns the error of a request within a batch, if it failed.
+func (r *batchResult) err() error {
+	if r.Error != nil {
+		return fmt.Errorf("batch request failed with code %s: %s", r.Error.Code, r.Error.Message)
+	}
+	if r.Response == nil {
+		return errors.New("batch request did not have a response")
+	}
+	if r.Response.StatusCode != 200 {
+		var body struct {
+			Error oai.APIError `json:"error"`
+		}
+		if err := json.Unmarshal(r.Response.Body, &body); err == nil && body.Error.Message != "" {
+			return fmt.Errorf("batch request failed with status code %d: %s", r.Response.StatusCode, body.Error.Message)
+		}
+		return fmt.Errorf("batch request failed with status code %d", r.Response.StatusCode)
+	}
+	return nil
+}
+
+// run submits a batch of requests, blocking until the batch is finished, and
+// returns the result of each request keyed by its custom ID.
+func (b *batchAPI) run(ctx context.Context, c client, endpoint oai.BatchEndpoint, lines []oai.BatchLineItem) (map[string]batchResult, error) {
+	resp, err := c.CreateBatchWithUploadFile(ctx, oai.CreateBatchWithUploadFileRequest{
+		Endpoint:         endpoint,
+		CompletionWindow: b.completionWindow,
+		UploadBatchFileRequest: oai.UploadBatchFileRequest{
+			Lines: lines,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create batch: %w", err)
+	}
+	batch := resp.Batch
+	defer b.deleteFile(c, batch.InputFileID)
+	b.logger.Debugf("Created batch %s with %d requests", batch.ID, len(lines))
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for !batchFinished(batch.Status) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// Don't leave the batch running, as its results would never be used.
+			cctx, done := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := c.CancelBatch(cctx, batch.ID); err != nil {
+				b.logger.Warnf("Failed to cancel batch %s: %v", batch.ID, err)
+			}
+			done()
+			return nil, ctx.Err()
+		}
+		resp, err := c.RetrieveBatch(ctx, batch.ID)
+		if err != nil {
+			b.logger.Warnf("Failed to retrieve status of batch %s: %v", batch.ID, err)
+			continue
+		}
+		batch = resp.Batch
+		b.logger.Tracef(
+			"Batch %s is %s: %d/%d completed, %d failed",
+			batch.ID, batch.Status, batch.RequestCounts.Completed, batch.RequestCounts.Total, batch.RequestCounts.Failed,
+		)
+	}
+
+	switch batch.Status {
+	case "completed", "expired":
+		// Expired batches can still have results for some of the requests.
+	case "failed":
+		if batch.Errors != nil && len(batch.Errors.Data) > 0 {
+			return nil, fmt.Errorf("batch %s failed: %s", batch.ID, batch.Errors.Data[0].Message)
+		}
+		return nil, fmt.Errorf("batch %s failed", batch.ID)
+	default:
+		return nil, fmt.Errorf("batch %s was %s", batch.ID, batch.Status)
+	}
+
+	results := make(map[string]batchResult, len(lines))
+	for _, fileID := range []*string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		if err := b.readResults(ctx, c, *fileID, results); err != nil {
+			return nil, err
+		}
+		b.deleteFile(c, *fileID)
+	}
+	return results, nil
+}
+
+func (b *batchAPI) readResults(ctx context.Context, c client, fileID string, results map[string]batchResult) error {
+	content, err := c.GetFileContent(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("unable to download batch results file %s: %w", fileID, err)
+	}
+	defer content.Close()
+	scanner := bufio.NewScanner(content)
+	// Chat completions and embeddings can easily exceed the default line limit
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r batchResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("unable to parse batch results file %s: %w", fileID, err)
+		}
+		results[r.CustomID] = r
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read batch results file %s: %w", fileID, err)
+	}
+	return nil
+}
+
+func (b *batchAPI) deleteFile(c client, fileID string) {
+	ctx, done := context.WithTimeout(context.Background(), 30*time.Second)
+	defer done()
+	if err := c.DeleteFile(ctx, fileID); err != nil {
+		b.logger.Debugf("Failed to delete batch file %s: %v", fileID, err)
+	}
+}
+
+func batchFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+func batchCustomID(index int) string {
+	return "msg-" + strconv.Itoa(index)
+}
+
+// processEach applies a per message processor over a batch, which is how the
+// batch capable processors behave without the batch API.
+func processEach(ctx context.Context, p service.Processor, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	out := make(service.MessageBatch, 0, len(batch))
+	for _, msg := range batch {
+		res, err := p.Process(ctx, msg)
+		if err != nil {
+			msg.SetError(err)
+			out = append(out, msg)
+			continue
+		}
+		out = append(out, res...)
+	}
+	return []service.MessageBatch{out}, nil
+}
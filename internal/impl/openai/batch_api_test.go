@@ -0,0 +1,175 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	oai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockBatchServer implements enough of the files and batches endpoints of the
+// OpenAI API to run a batch, replying to each chat completion with the
+// uppercased prompt and failing prompts that contain "fail".
+type mockBatchServer struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	deleted []string
+	polls   int
+}
+
+func (m *mockBatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeJSON := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/files":
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		m.files["file-input"] = b
+		writeJSON(oai.File{ID: "file-input", Purpose: "batch"})
+	case r.Method == http.MethodPost && r.URL.Path == "/batches":
+		writeJSON(oai.Batch{ID: "batch-1", Status: "validating", InputFileID: "file-input"})
+	case r.Method == http.MethodGet && r.URL.Path == "/batches/batch-1":
+		m.polls++
+		if m.polls < 2 {
+			writeJSON(oai.Batch{ID: "batch-1", Status: "in_progress", InputFileID: "file-input"})
+			return
+		}
+		output, errs := m.runBatch()
+		m.files["file-output"] = output
+		m.files["file-errors"] = errs
+		outputID, errorsID := "file-output", "file-errors"
+		writeJSON(oai.Batch{
+			ID:           "batch-1",
+			Status:       "completed",
+			InputFileID:  "file-input",
+			OutputFileID: &outputID,
+			ErrorFileID:  &errorsID,
+		})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/content"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/files/"), "/content")
+		_, _ = w.Write(m.files[id])
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/files/"):
+		m.deleted = append(m.deleted, strings.TrimPrefix(r.URL.Path, "/files/"))
+		writeJSON(map[string]any{"deleted": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *mockBatchServer) runBatch() (output, errs []byte) {
+	scanner := bufio.NewScanner(strings.NewReader(string(m.files["file-input"])))
+	for scanner.Scan() {
+		var req oai.BatchChatCompletionRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			panic(err)
+		}
+		prompt := req.Body.Messages[len(req.Body.Messages)-1].Content
+		if strings.Contains(prompt, "fail") {
+			errs = fmt.Appendf(errs, `{"custom_id":%q,"response":{"status_code":400,"body":{"error":{"message":"bad prompt"}}}}`+"\n", req.CustomID)
+			continue
+		}
+		body, _ := json.Marshal(oai.ChatCompletionResponse{
+			Model: req.Body.Model,
+			Choices: []oai.ChatCompletionChoice{{
+				Message: oai.ChatCompletionMessage{Role: "assistant", Content: strings.ToUpper(prompt)},
+			}},
+		})
+		output = fmt.Appendf(output, `{"custom_id":%q,"response":{"status_code":200,"body":%s}}`+"\n", req.CustomID, body)
+	}
+	return
+}
+
+func TestChatBatchAPI(t *testing.T) {
+	mock := &mockBatchServer{files: map[string][]byte{}}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	conf, err := chatProcessorConfig().ParseYAML(fmt.Sprintf(`
+server_address: %s
+api_key: foo
+model: gpt-4o
+batch_api:
+  enabled: true
+  poll_interval: 1ms
+`, srv.URL), nil)
+	require.NoError(t, err)
+	proc, err := makeChatProcessor(conf, service.MockResources())
+	require.NoError(t, err)
+
+	batch := service.MessageBatch{
+		service.NewMessage([]byte("hello")),
+		service.NewMessage([]byte("please fail")),
+		service.NewMessage([]byte("world")),
+	}
+	out, err := proc.ProcessBatch(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	require.Len(t, out[0], 3)
+
+	b, err := out[0][0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", string(b))
+	assert.ErrorContains(t, out[0][1].GetError(), "bad prompt")
+	b, err = out[0][2].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "WORLD", string(b))
+
+	assert.ElementsMatch(t, []string{"file-input", "file-output", "file-errors"}, mock.deleted)
+}
+
+func TestChatBatchAPIDisabled(t *testing.T) {
+	conf, err := chatProcessorConfig().ParseYAML(`
+api_key: foo
+model: gpt-4o
+`, nil)
+	require.NoError(t, err)
+	proc, err := makeChatProcessor(conf, service.MockResources())
+	require.NoError(t, err)
+	assert.Nil(t, proc.(*chatProcessor).batch)
+}
+
+func TestChatWithoutBatchAPI(t *testing.T) {
+	p := &chatProcessor{
+		baseProcessor: &baseProcessor{
+			client: &mockChatClient{},
+			model:  "gpt-4o",
+		},
+	}
+	out, err := p.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte("hello")),
+		service.NewMessage([]byte("world")),
+	})
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	require.Len(t, out[0], 2)
+	for _, msg := range out[0] {
+		require.NoError(t, msg.GetError())
+	}
+}
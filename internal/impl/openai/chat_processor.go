@@ -11,6 +11,8 @@ package openai
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -23,6 +25,7 @@ import (
 	oai "github.com/sashabaranov/go-openai"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/confluent/sr"
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -53,7 +56,7 @@ const (
 )
 
 func init() {
-	err := service.RegisterProcessor(
+	err := service.RegisterBatchProcessor(
 		"openai_chat_completion",
 		chatProcessorConfig(),
 		makeChatProcessor,
@@ -160,8 +163,13 @@ We generally recommend altering this or temperature but not both.`).
 				Optional().
 				Advanced().
 				Description("Up to 4 sequences where the API will stop generating further tokens."),
+			llm.CacheField(),
+			llm.RateLimitField(),
+			batchAPIField(),
+			llm.SchemaValidationField(),
 		).LintRule(`
       root = match {
+        this.`+llm.FieldSchemaValidation+`.enabled.or(false) && this.response_format != "json_schema" => ["`+"`"+llm.FieldSchemaValidation+"`"+` requires a `+"`"+ocpFieldResponseFormat+"`"+` of `+"`json_schema`"+`"]
         this.exists("`+ocpFieldJSONSchema+`") && this.exists("`+ocpFieldSchemaRegistry+`") => ["cannot set both `+"`"+ocpFieldJSONSchema+"`"+` and `+"`"+ocpFieldSchemaRegistry+"`"+`"]
         this.response_format == "json_schema" && !this.exists("`+ocpFieldJSONSchema+`") && !this.exists("`+ocpFieldSchemaRegistry+`") => ["schema must be specified using either `+"`"+ocpFieldJSONSchema+"`"+` or `+"`"+ocpFieldSchemaRegistry+"`"+`"]
       }
@@ -190,8 +198,8 @@ output:
 `)
 }
 
-func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -313,6 +321,13 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 	default:
 		return nil, fmt.Errorf("unknown %s: %q", ocpFieldResponseFormat, v)
 	}
+	extractor, err := llm.NewExtractorFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	if extractor != nil && schemaProvider == nil {
+		return nil, fmt.Errorf("%s requires %s %q", llm.FieldSchemaValidation, ocpFieldResponseFormat, "json_schema")
+	}
 	return &chatProcessor{
 		b,
 		up,
@@ -328,6 +343,7 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 		stop,
 		responseFormat,
 		schemaProvider,
+		extractor,
 	}, nil
 }
 
@@ -401,10 +417,10 @@ type chatProcessor struct {
 	stop             []string
 	responseFormat   oai.ChatCompletionResponseFormatType
 	schemaProvider   jsonSchemaProvider
+	extractor        *llm.Extractor
 }
 
-func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
-	var body oai.ChatCompletionRequest
+func (p *chatProcessor) buildRequest(ctx context.Context, msg *service.Message) (body oai.ChatCompletionRequest, err error) {
 	body.Model = p.model
 	if p.maxTokens != nil {
 		body.MaxTokens = *p.maxTokens
@@ -427,7 +443,7 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 		if p.schemaProvider != nil {
 			s, err := p.schemaProvider.GetJSONSchema(ctx)
 			if err != nil {
-				return nil, err
+				return body, err
 			}
 			body.ResponseFormat.JSONSchema = s
 		}
@@ -436,14 +452,14 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 	if p.user != nil {
 		u, err := p.user.TryString(msg)
 		if err != nil {
-			return nil, fmt.Errorf("%s interpolation error: %w", ocpFieldUser, err)
+			return body, fmt.Errorf("%s interpolation error: %w", ocpFieldUser, err)
 		}
 		body.User = u
 	}
 	if p.systemPrompt != nil {
 		s, err := p.systemPrompt.TryString(msg)
 		if err != nil {
-			return nil, fmt.Errorf("%s interpolation error: %w", ocpFieldSystemPrompt, err)
+			return body, fmt.Errorf("%s interpolation error: %w", ocpFieldSystemPrompt, err)
 		}
 		body.Messages = append(body.Messages, oai.ChatCompletionMessage{
 			Role:    "system",
@@ -456,13 +472,13 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 	if p.userPrompt != nil {
 		s, err := p.userPrompt.TryString(msg)
 		if err != nil {
-			return nil, fmt.Errorf("%s interpolation error: %w", ocpFieldUserPrompt, err)
+			return body, fmt.Errorf("%s interpolation error: %w", ocpFieldUserPrompt, err)
 		}
 		chatMsg.Content = s
 	} else {
 		b, err := msg.AsBytes()
 		if err != nil {
-			return nil, err
+			return body, err
 		}
 		chatMsg.Content = string(b)
 	}
@@ -470,15 +486,15 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 	if p.image != nil {
 		i, err := msg.BloblangQuery(p.image)
 		if err != nil {
-			return nil, fmt.Errorf("%s execution error: %w", ocpFieldImage, err)
+			return body, fmt.Errorf("%s execution error: %w", ocpFieldImage, err)
 		}
 		b, err := i.AsBytes()
 		if err != nil {
-			return nil, fmt.Errorf("%s conversion error: %w", ocpFieldImage, err)
+			return body, fmt.Errorf("%s conversion error: %w", ocpFieldImage, err)
 		}
 		mimeType := http.DetectContentType(b)
 		if !strings.HasPrefix(mimeType, "image/") {
-			return nil, fmt.Errorf("invalid %s data, detected mime type: %s", ocpFieldImage, mimeType)
+			return body, fmt.Errorf("invalid %s data, detected mime type: %s", ocpFieldImage, mimeType)
 		}
 		body.Messages = append(body.Messages, oai.ChatCompletionMessage{
 			Role: "user",
@@ -490,14 +506,156 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 			}},
 		})
 	}
+	return body, nil
+}
+
+func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	body, err := p.buildRequest(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	key, cached, ok := p.cache.Lookup(ctx, body)
+	if ok {
+		msg = msg.Copy()
+		msg.SetBytes(cached)
+		return service.MessageBatch{msg}, nil
+	}
+	content, err := p.complete(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	if content, err = p.extract(ctx, body, content); err != nil {
+		return nil, err
+	}
+	p.cache.Store(ctx, key, content)
+	msg = msg.Copy()
+	msg.SetBytes(content)
+	return service.MessageBatch{msg}, nil
+}
+
+func (p *chatProcessor) complete(ctx context.Context, body oai.ChatCompletionRequest) ([]byte, error) {
+	estimate := body.MaxTokens
+	for _, m := range body.Messages {
+		estimate += llm.EstimateTokens(m.Content)
+	}
+	if err := p.limiter.Acquire(ctx, estimate); err != nil {
+		return nil, err
+	}
 	resp, err := p.client.CreateChatCompletion(ctx, body)
 	if err != nil {
+		p.limiter.Release(estimate, 0)
 		return nil, err
 	}
+	p.limiter.ObserveHeaders(resp.Header())
+	p.limiter.Release(estimate, resp.Usage.TotalTokens)
+	return p.completionContent(resp)
+}
+
+// extract validates a completion against the JSON schema of the request when
+// schema validation is enabled, re-prompting the model if it's invalid.
+func (p *chatProcessor) extract(ctx context.Context, body oai.ChatCompletionRequest, content []byte) ([]byte, error) {
+	if p.extractor == nil || body.ResponseFormat == nil || body.ResponseFormat.JSONSchema == nil {
+		return content, nil
+	}
+	schema, err := json.Marshal(body.ResponseFormat.JSONSchema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize JSON schema: %w", err)
+	}
+	completion, err := p.extractor.Extract(ctx, schema, func(ctx context.Context, followUp []llm.Turn) (string, error) {
+		if len(followUp) == 0 {
+			return string(content), nil
+		}
+		req := body
+		req.Messages = slices.Clone(body.Messages)
+		for _, t := range followUp {
+			req.Messages = append(req.Messages, oai.ChatCompletionMessage{
+				Role:    string(t.Role),
+				Content: t.Content,
+			})
+		}
+		b, err := p.complete(ctx, req)
+		return string(b), err
+	})
+	return []byte(completion), err
+}
+
+func (p *chatProcessor) completionContent(resp oai.ChatCompletionResponse) ([]byte, error) {
+	p.usage.Record(p.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	if len(resp.Choices) != 1 {
 		return nil, fmt.Errorf("invalid number of choices in response: %d", len(resp.Choices))
 	}
-	msg = msg.Copy()
-	msg.SetBytes([]byte(resp.Choices[0].Message.Content))
-	return service.MessageBatch{msg}, nil
+	return []byte(resp.Choices[0].Message.Content), nil
+}
+
+func (p *chatProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	if p.batch == nil {
+		return processEach(ctx, p, batch)
+	}
+	keys := make([]string, len(batch))
+	bodies := make([]oai.ChatCompletionRequest, len(batch))
+	lines := make([]oai.BatchLineItem, 0, len(batch))
+	out := make(service.MessageBatch, len(batch))
+	for i, msg := range batch {
+		body, err := p.buildRequest(ctx, msg)
+		if err != nil {
+			msg.SetError(err)
+			out[i] = msg
+			continue
+		}
+		bodies[i] = body
+		key, cached, ok := p.cache.Lookup(ctx, body)
+		if ok {
+			out[i] = msg.Copy()
+			out[i].SetBytes(cached)
+			continue
+		}
+		keys[i] = key
+		lines = append(lines, oai.BatchChatCompletionRequest{
+			CustomID: batchCustomID(i),
+			Body:     body,
+			Method:   "POST",
+			URL:      oai.BatchEndpointChatCompletions,
+		})
+	}
+	if len(lines) == 0 {
+		return []service.MessageBatch{out}, nil
+	}
+	results, err := p.batch.run(ctx, p.client, oai.BatchEndpointChatCompletions, lines)
+	if err != nil {
+		return nil, err
+	}
+	for i, msg := range batch {
+		if out[i] != nil {
+			continue
+		}
+		out[i] = msg
+		r, ok := results[batchCustomID(i)]
+		if !ok {
+			msg.SetError(errors.New("no result found within batch"))
+			continue
+		}
+		if err := r.err(); err != nil {
+			msg.SetError(err)
+			continue
+		}
+		var resp oai.ChatCompletionResponse
+		if err := json.Unmarshal(r.Response.Body, &resp); err != nil {
+			msg.SetError(fmt.Errorf("unable to parse batch response: %w", err))
+			continue
+		}
+		content, err := p.completionContent(resp)
+		if err != nil {
+			msg.SetError(err)
+			continue
+		}
+		// Invalid results are re-prompted synchronously rather than with another batch.
+		if content, err = p.extract(ctx, bodies[i], content); err != nil {
+			msg.SetError(err)
+			continue
+		}
+		p.cache.Store(ctx, keys[i], content)
+		out[i] = msg.Copy()
+		out[i].SetBytes(content)
+	}
+	return []service.MessageBatch{out}, nil
 }
@@ -10,13 +10,18 @@ package openai
 
 import (
 	"context"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-faker/faker/v4"
 	"github.com/redpanda-data/benthos/v4/public/service"
 	oai "github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 type mockChatClient struct {
@@ -66,3 +71,187 @@ func TestChatInterpolationError(t *testing.T) {
 	_, err = p.Process(context.Background(), input)
 	assert.Error(t, err)
 }
+
+type mockExtractionClient struct {
+	stubClient
+	responses []string
+	requests  []oai.ChatCompletionRequest
+}
+
+func (m *mockExtractionClient) CreateChatCompletion(ctx context.Context, body oai.ChatCompletionRequest) (resp oai.ChatCompletionResponse, err error) {
+	m.requests = append(m.requests, body)
+	resp.Choices = []oai.ChatCompletionChoice{
+		{
+			Message: oai.ChatCompletionMessage{
+				Role:    "assistant",
+				Content: m.responses[len(m.requests)-1],
+			},
+		},
+	}
+	return
+}
+
+func TestChatSchemaValidationRetry(t *testing.T) {
+	schema, err := newFixedSchema("person", "", `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`)
+	require.NoError(t, err)
+	client := &mockExtractionClient{responses: []string{`{}`, `{"name":"bob"}`}}
+	p := chatProcessor{
+		baseProcessor: &baseProcessor{
+			client: client,
+			model:  "gpt-4o",
+		},
+		responseFormat: oai.ChatCompletionResponseFormatTypeJSONSchema,
+		schemaProvider: schema,
+		extractor:      llm.NewExtractor(1),
+	}
+	output, err := p.Process(context.Background(), service.NewMessage([]byte("who?")))
+	require.NoError(t, err)
+	require.Len(t, output, 1)
+	b, err := output[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"bob"}`, string(b))
+
+	require.Len(t, client.requests, 2)
+	retry := client.requests[1].Messages
+	require.Len(t, retry, 3)
+	assert.Equal(t, "assistant", retry[1].Role)
+	assert.Equal(t, `{}`, retry[1].Content)
+	assert.Equal(t, "user", retry[2].Role)
+}
+
+type countingChatClient struct {
+	stubClient
+	mu       sync.Mutex
+	requests int
+	header   http.Header
+}
+
+func (m *countingChatClient) CreateChatCompletion(ctx context.Context, body oai.ChatCompletionRequest) (resp oai.ChatCompletionResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	resp.SetHeader(m.header)
+	resp.Usage.TotalTokens = 5
+	resp.Choices = []oai.ChatCompletionChoice{
+		{
+			Message: oai.ChatCompletionMessage{
+				Role:    "assistant",
+				Content: "reply " + body.Messages[len(body.Messages)-1].Content,
+			},
+		},
+	}
+	return
+}
+
+func testResponseCache(t *testing.T) *llm.ResponseCache {
+	t.Helper()
+	conf, err := service.NewConfigSpec().Field(llm.CacheField()).ParseYAML(`
+cache:
+  resource: llm_cache
+`, nil)
+	require.NoError(t, err)
+	c, err := llm.NewResponseCacheFromParsed(conf, service.MockResources(service.MockResourcesOptAddCache("llm_cache")))
+	require.NoError(t, err)
+	return c
+}
+
+func batchContents(t *testing.T, batch service.MessageBatch) []string {
+	t.Helper()
+	var contents []string
+	for _, msg := range batch {
+		if err := msg.GetError(); err != nil {
+			contents = append(contents, "error: "+err.Error())
+			continue
+		}
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+func TestChatProcessBatchCache(t *testing.T) {
+	client := &countingChatClient{}
+	p := &chatProcessor{
+		baseProcessor: &baseProcessor{
+			client: client,
+			model:  "gpt-4o",
+			cache:  testResponseCache(t),
+		},
+	}
+
+	ctx := context.Background()
+	batches, err := p.ProcessBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("foo")),
+		service.NewMessage([]byte("bar")),
+		service.NewMessage([]byte("foo")),
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, []string{"reply foo", "reply bar", "reply foo"}, batchContents(t, batches[0]))
+	assert.Equal(t, 2, client.requests)
+
+	batches, err = p.ProcessBatch(ctx, service.MessageBatch{service.NewMessage([]byte("bar"))})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply bar"}, batchContents(t, batches[0]))
+	assert.Equal(t, 2, client.requests)
+
+	// A different model must not be served responses of another.
+	p.model = "gpt-4o-mini"
+	batches, err = p.ProcessBatch(ctx, service.MessageBatch{service.NewMessage([]byte("bar"))})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply bar"}, batchContents(t, batches[0]))
+	assert.Equal(t, 3, client.requests)
+}
+
+func TestChatProcessBatchRateLimit(t *testing.T) {
+	client := &countingChatClient{}
+	p := &chatProcessor{
+		baseProcessor: &baseProcessor{
+			client:  client,
+			model:   "gpt-4o",
+			limiter: llm.NewTokenLimiter(8, time.Hour),
+		},
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+
+	// The first request is always allowed, and consumes the whole budget, so
+	// the second blocks until the context is cancelled.
+	batches, err := p.ProcessBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("the first message")),
+		service.NewMessage([]byte("the second message")),
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, []string{"reply the first message", "error: context deadline exceeded"}, batchContents(t, batches[0]))
+	assert.Equal(t, 1, client.requests)
+}
+
+func TestChatProcessBatchRateLimitHeaders(t *testing.T) {
+	client := &countingChatClient{header: http.Header{
+		"X-Ratelimit-Remaining-Tokens": []string{"0"},
+		"X-Ratelimit-Reset-Tokens":     []string{"1h"},
+	}}
+	p := &chatProcessor{
+		baseProcessor: &baseProcessor{
+			client:  client,
+			model:   "gpt-4o",
+			limiter: llm.NewTokenLimiter(1000000, time.Minute),
+		},
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer done()
+
+	// The provider reports that its budget is exhausted, which pauses
+	// requests despite the local budget.
+	batches, err := p.ProcessBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("foo")),
+		service.NewMessage([]byte("bar")),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reply foo", "error: context deadline exceeded"}, batchContents(t, batches[0]))
+	assert.Equal(t, 1, client.requests)
+}
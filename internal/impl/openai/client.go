@@ -22,4 +22,9 @@ type client interface {
 	CreateTranscription(ctx context.Context, body oai.AudioRequest) (oai.AudioResponse, error)
 	CreateTranslation(ctx context.Context, body oai.AudioRequest) (oai.AudioResponse, error)
 	CreateImage(ctx context.Context, body oai.ImageRequest) (oai.ImageResponse, error)
+	CreateBatchWithUploadFile(ctx context.Context, body oai.CreateBatchWithUploadFileRequest) (oai.BatchResponse, error)
+	RetrieveBatch(ctx context.Context, batchID string) (oai.BatchResponse, error)
+	CancelBatch(ctx context.Context, batchID string) (oai.BatchResponse, error)
+	GetFileContent(ctx context.Context, fileID string) (oai.RawResponse, error)
+	DeleteFile(ctx context.Context, fileID string) error
 }
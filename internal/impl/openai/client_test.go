@@ -46,3 +46,27 @@ func (*stubClient) CreateImage(ctx context.Context, body oai.ImageRequest) (r oa
 	err = errors.New("unimplemented")
 	return
 }
+
+func (*stubClient) CreateBatchWithUploadFile(ctx context.Context, body oai.CreateBatchWithUploadFileRequest) (r oai.BatchResponse, err error) {
+	err = errors.New("unimplemented")
+	return
+}
+
+func (*stubClient) RetrieveBatch(ctx context.Context, batchID string) (r oai.BatchResponse, err error) {
+	err = errors.New("unimplemented")
+	return
+}
+
+func (*stubClient) CancelBatch(ctx context.Context, batchID string) (r oai.BatchResponse, err error) {
+	err = errors.New("unimplemented")
+	return
+}
+
+func (*stubClient) GetFileContent(ctx context.Context, fileID string) (r oai.RawResponse, err error) {
+	err = errors.New("unimplemented")
+	return
+}
+
+func (*stubClient) DeleteFile(ctx context.Context, fileID string) error {
+	return errors.New("unimplemented")
+}
@@ -10,11 +10,15 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 	oai "github.com/sashabaranov/go-openai"
+
+	"github.com/redpanda-data/connect/v4/internal/llm"
 )
 
 const (
@@ -23,7 +27,7 @@ const (
 )
 
 func init() {
-	err := service.RegisterProcessor(
+	err := service.RegisterBatchProcessor(
 		"openai_embeddings",
 		embeddingProcessorConfig(),
 		makeEmbeddingsProcessor,
@@ -56,6 +60,9 @@ To learn more about vector embeddings, see the https://platform.openai.com/docs/
 			service.NewIntField(oepFieldDims).
 				Description("The number of dimensions the resulting output embeddings should have. Only supported in `text-embedding-3` and later models.").
 				Optional(),
+			llm.CacheField(),
+			llm.RateLimitField(),
+			batchAPIField(),
 		).
 		Example(
 			"Store embedding vectors in Pinecone",
@@ -79,8 +86,8 @@ output:
     vector_mapping: "root = this"`)
 }
 
-func makeEmbeddingsProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+func makeEmbeddingsProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -109,8 +116,7 @@ type embeddingsProcessor struct {
 	dimensions *int
 }
 
-func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
-	var body oai.EmbeddingRequestStrings
+func (p *embeddingsProcessor) buildRequest(msg *service.Message) (body oai.EmbeddingRequestStrings, err error) {
 	body.Model = oai.EmbeddingModel(p.model)
 	if p.dimensions != nil {
 		body.Dimensions = *p.dimensions
@@ -118,24 +124,59 @@ func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message)
 	if p.text != nil {
 		s, err := msg.BloblangQuery(p.text)
 		if err != nil {
-			return nil, fmt.Errorf("%s execution error: %w", oepFieldTextMapping, err)
+			return body, fmt.Errorf("%s execution error: %w", oepFieldTextMapping, err)
 		}
 		r, err := s.AsBytes()
 		if err != nil {
-			return nil, fmt.Errorf("%s extraction error: %w", oepFieldTextMapping, err)
+			return body, fmt.Errorf("%s extraction error: %w", oepFieldTextMapping, err)
 		}
 		body.Input = append(body.Input, string(r))
 	} else {
 		b, err := msg.AsBytes()
 		if err != nil {
-			return nil, err
+			return body, err
 		}
 		body.Input = append(body.Input, string(b))
 	}
+	return body, nil
+}
+
+func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	body, err := p.buildRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	key, cached, ok := p.cache.Lookup(ctx, body)
+	if ok {
+		var data []any
+		if err := json.Unmarshal(cached, &data); err == nil {
+			msg = msg.Copy()
+			msg.SetStructuredMut(data)
+			return service.MessageBatch{msg}, nil
+		}
+	}
+	estimate := llm.EstimateTokens(body.Input[0])
+	if err := p.limiter.Acquire(ctx, estimate); err != nil {
+		return nil, err
+	}
 	resp, err := p.client.CreateEmbeddings(ctx, body)
+	if err != nil {
+		p.limiter.Release(estimate, 0)
+		return nil, err
+	}
+	p.limiter.ObserveHeaders(resp.Header())
+	p.limiter.Release(estimate, resp.Usage.TotalTokens)
+	data, err := p.embeddingsData(ctx, key, resp)
 	if err != nil {
 		return nil, err
 	}
+	msg = msg.Copy()
+	msg.SetStructuredMut(data)
+	return service.MessageBatch{msg}, nil
+}
+
+func (p *embeddingsProcessor) embeddingsData(ctx context.Context, cacheKey string, resp oai.EmbeddingResponse) ([]any, error) {
+	p.usage.Record(p.model, resp.Usage.PromptTokens, 0)
 	if len(resp.Data) != 1 {
 		return nil, fmt.Errorf("expected a single embeddings response, got: %d", len(resp.Data))
 	}
@@ -144,7 +185,76 @@ func (p *embeddingsProcessor) Process(ctx context.Context, msg *service.Message)
 	for i, f := range embd.Embedding {
 		data[i] = f
 	}
-	msg = msg.Copy()
-	msg.SetStructuredMut(data)
-	return service.MessageBatch{msg}, nil
+	if b, err := json.Marshal(embd.Embedding); err == nil {
+		p.cache.Store(ctx, cacheKey, b)
+	}
+	return data, nil
+}
+
+func (p *embeddingsProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	if p.batch == nil {
+		return processEach(ctx, p, batch)
+	}
+	keys := make([]string, len(batch))
+	lines := make([]oai.BatchLineItem, 0, len(batch))
+	out := make(service.MessageBatch, len(batch))
+	for i, msg := range batch {
+		body, err := p.buildRequest(msg)
+		if err != nil {
+			msg.SetError(err)
+			out[i] = msg
+			continue
+		}
+		key, cached, ok := p.cache.Lookup(ctx, body)
+		if ok {
+			var data []any
+			if err := json.Unmarshal(cached, &data); err == nil {
+				out[i] = msg.Copy()
+				out[i].SetStructuredMut(data)
+				continue
+			}
+		}
+		keys[i] = key
+		lines = append(lines, oai.BatchEmbeddingRequest{
+			CustomID: batchCustomID(i),
+			Body:     body.Convert(),
+			Method:   "POST",
+			URL:      oai.BatchEndpointEmbeddings,
+		})
+	}
+	if len(lines) == 0 {
+		return []service.MessageBatch{out}, nil
+	}
+	results, err := p.batch.run(ctx, p.client, oai.BatchEndpointEmbeddings, lines)
+	if err != nil {
+		return nil, err
+	}
+	for i, msg := range batch {
+		if out[i] != nil {
+			continue
+		}
+		out[i] = msg
+		r, ok := results[batchCustomID(i)]
+		if !ok {
+			msg.SetError(errors.New("no result found within batch"))
+			continue
+		}
+		if err := r.err(); err != nil {
+			msg.SetError(err)
+			continue
+		}
+		var resp oai.EmbeddingResponse
+		if err := json.Unmarshal(r.Response.Body, &resp); err != nil {
+			msg.SetError(fmt.Errorf("unable to parse batch response: %w", err))
+			continue
+		}
+		data, err := p.embeddingsData(ctx, keys[i], resp)
+		if err != nil {
+			msg.SetError(err)
+			continue
+		}
+		out[i] = msg.Copy()
+		out[i].SetStructuredMut(data)
+	}
+	return []service.MessageBatch{out}, nil
 }
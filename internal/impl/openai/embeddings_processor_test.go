@@ -76,3 +76,50 @@ func TestEmbeddingInterpolationError(t *testing.T) {
 	_, err = p.Process(context.Background(), input)
 	assert.Error(t, err)
 }
+
+type countingEmbeddingsClient struct {
+	mockEmbeddingsClient
+	requests int
+}
+
+func (m *countingEmbeddingsClient) CreateEmbeddings(ctx context.Context, body oai.EmbeddingRequestConverter) (oai.EmbeddingResponse, error) {
+	m.requests++
+	return m.mockEmbeddingsClient.CreateEmbeddings(ctx, body)
+}
+
+func TestEmbeddingProcessBatchCache(t *testing.T) {
+	text, err := bloblang.GlobalEnvironment().Parse(`content().string()`)
+	require.NoError(t, err)
+
+	client := &countingEmbeddingsClient{}
+	p := &embeddingsProcessor{
+		baseProcessor: &baseProcessor{
+			client: client,
+			model:  "text-embedding-ada-002",
+			cache:  testResponseCache(t),
+		},
+		text: text,
+	}
+
+	batches, err := p.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte("ab")),
+		service.NewMessage([]byte("cd")),
+		service.NewMessage([]byte("ab")),
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 3)
+	assert.Equal(t, 2, client.requests)
+
+	var results []any
+	for _, msg := range batches[0] {
+		require.NoError(t, msg.GetError())
+		v, err := msg.AsStructured()
+		require.NoError(t, err)
+		results = append(results, v)
+	}
+	// Cache hits are decoded from JSON, and so are compared by value.
+	assert.Equal(t, []any{float32('a'), float32('b')}, results[0])
+	assert.Equal(t, []any{float32('c'), float32('d')}, results[1])
+	assert.Equal(t, []any{float64('a'), float64('b')}, results[2])
+}
@@ -75,7 +75,7 @@ To learn more about image generation, see the https://platform.openai.com/docs/g
 }
 
 func makeImageProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
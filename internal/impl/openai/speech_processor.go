@@ -66,7 +66,7 @@ To learn more about turning text into spoken audio, see the https://platform.ope
 }
 
 func makeSpeechProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +65,7 @@ To learn more about audio transcription, see the: https://platform.openai.com/do
 }
 
 func makeTranscriptionProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +60,7 @@ To learn more about translation, see the https://platform.openai.com/docs/guides
 }
 
 func makeTranslationProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-	b, err := newBaseProcessor(conf)
+	b, err := newBaseProcessor(conf, mgr)
 	if err != nil {
 		return nil, err
 	}
@@ -26,9 +26,10 @@ import (
 
 	"github.com/Masterminds/squirrel"
 	"github.com/cenkalti/backoff/v4"
-	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/batchcodec"
 )
 
 // SQLiteBufferConfig returns a config spec for an SQLite buffer.
@@ -47,6 +48,16 @@ Messages are not acknowledged at the input level until they have been added to t
 == Batching
 
 Messages that are logically batched at the point where they are added to the buffer will continue to be associated with that batch when they are consumed. This buffer is also more efficient when storing messages within batches, and therefore it is recommended to use batching at the input level in high-throughput use cases even if they are not required for processing.
+
+== Limits
+
+By default the database grows without limit. The fields `+"`max_messages` and `max_size_bytes`"+` limit the number of messages and the size of their stored content, and the `+"`limit_policy`"+` determines what happens to writes that would exceed a limit. The size of the database file itself may be larger than the stored content as SQLite does not shrink files when rows are deleted.
+
+Messages stored for longer than the `+"`ttl`"+` are deleted without being delivered, unless they are currently being delivered.
+
+== Metrics
+
+This buffer emits the gauges `+"`buffer_sqlite_messages`, `buffer_sqlite_bytes` and `buffer_sqlite_oldest_message_age_ms`"+`, and the counters `+"`buffer_sqlite_dropped` and `buffer_sqlite_expired`"+`, which count messages removed by the `+"`drop_oldest`"+` policy and the `+"`ttl`"+` respectively. The counter `+"`buffer_sqlite_maintenance_errors`"+` counts failures to expire messages or to measure the age of the oldest message, which are also logged.
 `).
 		Field(service.NewStringField("path").
 			Description(`The path of the database file, which will be created if it does not already exist.`)).
@@ -56,6 +67,31 @@ Messages that are logically batched at the point where they are added to the buf
 		Field(service.NewProcessorListField("post_processors").
 			Description("An optional list of processors to apply to messages after they are consumed from the buffer. These processors are useful for undoing any compression, archiving, etc that may have been done by your `pre_processors`.").
 			Optional()).
+		Field(service.NewIntField("max_messages").
+			Description("The maximum number of messages to store, where `0` means no limit.").
+			Default(0).
+			Advanced().
+			Version("4.42.0")).
+		Field(service.NewIntField("max_size_bytes").
+			Description("The maximum total size in bytes of the content of stored messages, after `pre_processors` are applied, where `0` means no limit.").
+			Default(0).
+			Advanced().
+			Version("4.42.0")).
+		Field(service.NewStringAnnotatedEnumField("limit_policy", map[string]string{
+			"block":       "Block writes until enough messages have been delivered, which applies back pressure to the input.",
+			"drop_oldest": "Delete the oldest messages that are not currently being delivered in order to make room.",
+			"reject":      "Reject the write, which causes the input to nack the messages.",
+		}).
+			Description("What to do when writing a batch would exceed `max_messages` or `max_size_bytes`. A batch is always accepted when the buffer is empty, even when it exceeds a limit by itself.").
+			Default("block").
+			Advanced().
+			Version("4.42.0")).
+		Field(service.NewDurationField("ttl").
+			Description("An optional duration after which stored messages expire and are deleted without being delivered.").
+			Example("24h").
+			Optional().
+			Advanced().
+			Version("4.42.0")).
 		Example("Batching for optimization", "Batching at the input level greatly increases the throughput of this buffer. If logical batches aren't needed for processing add a xref:components:processors/split.adoc[`split` processor] to the `post_processors`.", `
 input:
   batched:
@@ -109,7 +145,44 @@ func NewSQLiteBufferFromConfig(conf *service.ParsedConfig, res *service.Resource
 		}
 	}
 
-	return newSQLiteBuffer(path, preProcs, postProcs)
+	var opts sqliteBufferOptions
+	if opts.maxMessages, err = conf.FieldInt("max_messages"); err != nil {
+		return nil, err
+	}
+	if opts.maxBytes, err = conf.FieldInt("max_size_bytes"); err != nil {
+		return nil, err
+	}
+	if opts.limitPolicy, err = conf.FieldString("limit_policy"); err != nil {
+		return nil, err
+	}
+	if conf.Contains("ttl") {
+		if opts.ttl, err = conf.FieldDuration("ttl"); err != nil {
+			return nil, err
+		}
+	}
+
+	return newSQLiteBuffer(path, opts, res.Logger(), res.Metrics(), preProcs, postProcs)
+}
+
+type sqliteBufferOptions struct {
+	maxMessages int
+	maxBytes    int
+	limitPolicy string
+	ttl         time.Duration
+}
+
+// errBufferFull is returned by WriteBatch when a limit is reached and the
+// limit policy is to reject writes.
+var errBufferFull = errors.New("buffer is full")
+
+// sqliteBufferMaintenanceInterval is how often expired messages are deleted
+// and the metrics of the buffer are updated.
+var sqliteBufferMaintenanceInterval = time.Second
+
+// rowStats is the number of messages and bytes stored by a row.
+type rowStats struct {
+	messages int64
+	bytes    int64
 }
 
 //------------------------------------------------------------------------------
@@ -120,15 +193,32 @@ type SQLiteBuffer struct {
 	preProcs  []*service.OwnedProcessor
 	postProcs []*service.OwnedProcessor
 
+	opts sqliteBufferOptions
+
 	pending     []ackableBatch
 	cond        *sync.Cond
 	nextIndex   int
 	requeueFrom int
 	endOfInput  bool
 	closed      bool
+
+	// The total number of messages and bytes stored, and the stats of rows
+	// currently being delivered, which must not be dropped or expired.
+	stored   rowStats
+	inFlight map[int]rowStats
+
+	mMessages  *service.MetricGauge
+	mBytes     *service.MetricGauge
+	mOldestAge *service.MetricGauge
+	mDropped   *service.MetricCounter
+	mExpired   *service.MetricCounter
+	mErrors    *service.MetricCounter
+
+	log       *service.Logger
+	closeChan chan struct{}
 }
 
-func newSQLiteBuffer(path string, preProcs, postProcs []*service.OwnedProcessor) (*SQLiteBuffer, error) {
+func newSQLiteBuffer(path string, opts sqliteBufferOptions, log *service.Logger, metrics *service.Metrics, preProcs, postProcs []*service.OwnedProcessor) (*SQLiteBuffer, error) {
 	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, err
@@ -138,20 +228,82 @@ func newSQLiteBuffer(path string, preProcs, postProcs []*service.OwnedProcessor)
 PRAGMA synchronous = 0;
 
 CREATE TABLE IF NOT EXISTS messages (
-  id       INTEGER PRIMARY KEY AUTOINCREMENT,
-  content  TEXT NOT NULL,
-  requeue  INTEGER NOT NULL
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  content    TEXT NOT NULL,
+  requeue    INTEGER NOT NULL,
+  created    INTEGER NOT NULL DEFAULT 0,
+  msg_count  INTEGER NOT NULL DEFAULT 1
 )
 `); err != nil {
 		return nil, err
 	}
+	if err = migrateSQLiteBuffer(db); err != nil {
+		return nil, err
+	}
 
-	return &SQLiteBuffer{
+	m := &SQLiteBuffer{
 		db:        db,
 		preProcs:  preProcs,
 		postProcs: postProcs,
+		opts:      opts,
 		cond:      sync.NewCond(&sync.Mutex{}),
-	}, nil
+		inFlight:  map[int]rowStats{},
+
+		mMessages:  metrics.NewGauge("buffer_sqlite_messages"),
+		mBytes:     metrics.NewGauge("buffer_sqlite_bytes"),
+		mOldestAge: metrics.NewGauge("buffer_sqlite_oldest_message_age_ms"),
+		mDropped:   metrics.NewCounter("buffer_sqlite_dropped"),
+		mExpired:   metrics.NewCounter("buffer_sqlite_expired"),
+		mErrors:    metrics.NewCounter("buffer_sqlite_maintenance_errors"),
+
+		log:       log,
+		closeChan: make(chan struct{}),
+	}
+
+	if err := db.QueryRow(`SELECT COALESCE(SUM(msg_count), 0), COALESCE(SUM(LENGTH(content)), 0) FROM messages`).
+		Scan(&m.stored.messages, &m.stored.bytes); err != nil {
+		return nil, err
+	}
+
+	go m.maintenanceLoop()
+	return m, nil
+}
+
+// migrateSQLiteBuffer adds the columns introduced after the messages table was
+// first created to databases created by older versions.
+func migrateSQLiteBuffer(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('messages')`)
+	if err != nil {
+		return err
+	}
+	columns := map[string]struct{}{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		columns[name] = struct{}{}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, c := range []string{
+		"created INTEGER NOT NULL DEFAULT 0",
+		"msg_count INTEGER NOT NULL DEFAULT 1",
+	} {
+		name, _, _ := strings.Cut(c, " ")
+		if _, exists := columns[name]; exists {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE messages ADD COLUMN " + c); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS messages_created ON messages (created)`)
+	return err
 }
 
 //------------------------------------------------------------------------------
@@ -161,19 +313,30 @@ func (m *SQLiteBuffer) tryGetBatch(ctx context.Context) (service.MessageBatch, i
 	var index int
 	var requeueFrom int
 	var contentBytes []byte
+	var msgCount int64
 
-	if err := queryRowRetries(ctx, squirrel.Select("id", "content", "requeue").
-		From("messages").
-		Where(squirrel.Or{
+	where := squirrel.And{
+		squirrel.Or{
 			squirrel.GtOrEq{"id": m.nextIndex},
 			squirrel.And{
 				squirrel.Gt{"requeue": m.requeueFrom},
 				squirrel.NotEq{"requeue": maxRequeue},
 			},
-		}).
+		},
+	}
+	if m.opts.ttl > 0 {
+		where = append(where, squirrel.Or{
+			squirrel.Eq{"created": 0},
+			squirrel.GtOrEq{"created": time.Now().Add(-m.opts.ttl).UnixNano()},
+		})
+	}
+
+	if err := queryRowRetries(ctx, squirrel.Select("id", "content", "requeue", "msg_count").
+		From("messages").
+		Where(where).
 		OrderBy("requeue, id").
 		Limit(1).
-		RunWith(m.db), &index, &contentBytes, &requeueFrom); err != nil {
+		RunWith(m.db), &index, &contentBytes, &requeueFrom, &msgCount); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = nil
 		}
@@ -184,8 +347,9 @@ func (m *SQLiteBuffer) tryGetBatch(ctx context.Context) (service.MessageBatch, i
 		m.requeueFrom = requeueFrom
 	}
 	m.nextIndex = index + 1
+	m.inFlight[index] = rowStats{messages: msgCount, bytes: int64(len(contentBytes))}
 
-	batch, _, err := readBatch(contentBytes)
+	batch, _, err := batchcodec.Read(contentBytes)
 	return batch, index, err
 }
 
@@ -197,6 +361,7 @@ func (m *SQLiteBuffer) requeue(ctx context.Context, index int) error {
 		Set("requeue", time.Now().UnixNano()).
 		Where(squirrel.Eq{"id": index}).
 		RunWith(m.db))
+	delete(m.inFlight, index)
 	m.cond.Broadcast()
 	return err
 }
@@ -212,10 +377,14 @@ func (m *SQLiteBuffer) toAckableBatches(batches []service.MessageBatch, index in
 		defer m.cond.L.Unlock()
 		if err != nil {
 			ackErr = m.requeue(ctx, index)
-		} else {
-			_, ackErr = execRetries(ctx, squirrel.Delete("messages").
-				Where(squirrel.Eq{"id": index}).
-				RunWith(m.db))
+		} else if _, ackErr = execRetries(ctx, squirrel.Delete("messages").
+			Where(squirrel.Eq{"id": index}).
+			RunWith(m.db)); ackErr == nil {
+			stats := m.inFlight[index]
+			delete(m.inFlight, index)
+			m.stored.messages -= stats.messages
+			m.stored.bytes -= stats.bytes
+			m.cond.Broadcast()
 		}
 		return
 	}
@@ -322,18 +491,29 @@ func (m *SQLiteBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageB
 		msgBatches = tmpResBatch
 	}
 
-	builder := squirrel.Insert("messages").Columns("content", "requeue")
+	var written rowStats
+	created := time.Now().UnixNano()
+	builder := squirrel.Insert("messages").Columns("content", "requeue", "created", "msg_count")
 	for _, batch := range msgBatches {
-		contentBytes, err := appendBatchV0(nil, batch)
+		contentBytes, err := batchcodec.Append(nil, batch)
 		if err != nil {
 			return err
 		}
-		builder = builder.Values(contentBytes, maxRequeue)
+		builder = builder.Values(contentBytes, maxRequeue, created, len(batch))
+		written.messages += int64(len(batch))
+		written.bytes += int64(len(contentBytes))
+	}
+
+	if err := m.waitForSpace(ctx, written); err != nil {
+		return err
 	}
 
 	if _, err := execRetries(ctx, builder.RunWith(m.db)); err != nil {
 		return err
 	}
+	m.stored.messages += written.messages
+	m.stored.bytes += written.bytes
+
 	if err := aFn(ctx, nil); err != nil {
 		return err
 	}
@@ -342,6 +522,214 @@ func (m *SQLiteBuffer) WriteBatch(ctx context.Context, msgBatch service.MessageB
 	return nil
 }
 
+// fits returns whether rows with the given stats can be written without
+// exceeding the limits of the buffer. Rows are always accepted when the buffer
+// is empty.
+func (m *SQLiteBuffer) fits(stats rowStats) bool {
+	if m.stored.messages == 0 {
+		return true
+	}
+	if m.opts.maxMessages > 0 && m.stored.messages+stats.messages > int64(m.opts.maxMessages) {
+		return false
+	}
+	if m.opts.maxBytes > 0 && m.stored.bytes+stats.bytes > int64(m.opts.maxBytes) {
+		return false
+	}
+	return true
+}
+
+// waitForSpace applies the limit policy until rows with the given stats fit
+// within the limits of the buffer. Must be called whilst holding the lock.
+func (m *SQLiteBuffer) waitForSpace(ctx context.Context, stats rowStats) error {
+	if m.fits(stats) {
+		return nil
+	}
+	if m.opts.limitPolicy == "reject" {
+		return errBufferFull
+	}
+
+	ctx, done := context.WithCancel(ctx)
+	defer done()
+
+	go func() {
+		<-ctx.Done()
+		m.cond.Broadcast()
+	}()
+
+	for !m.fits(stats) {
+		if m.closed {
+			return service.ErrEndOfBuffer
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if m.opts.limitPolicy == "drop_oldest" {
+			dropped, err := m.dropOldest(ctx, stats)
+			if err != nil {
+				return err
+			}
+			if dropped {
+				continue
+			}
+			// Every stored message is being delivered, so wait for some of
+			// them to be acknowledged instead.
+		}
+		m.cond.Wait()
+	}
+	return nil
+}
+
+// dropOldest deletes the oldest rows that are not being delivered until rows
+// with the given stats fit, and returns whether any rows were deleted.
+func (m *SQLiteBuffer) dropOldest(ctx context.Context, stats rowStats) (bool, error) {
+	rows, err := squirrel.Select("id", "msg_count", "LENGTH(content)").
+		From("messages").
+		OrderBy("id").
+		RunWith(m.db).
+		QueryContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var ids []int
+	var dropped rowStats
+	remaining := m.stored
+	for rows.Next() {
+		var id int
+		var row rowStats
+		if err := rows.Scan(&id, &row.messages, &row.bytes); err != nil {
+			_ = rows.Close()
+			return false, err
+		}
+		if _, exists := m.inFlight[id]; exists {
+			continue
+		}
+		ids = append(ids, id)
+		dropped.messages += row.messages
+		dropped.bytes += row.bytes
+
+		m.stored = rowStats{messages: remaining.messages - dropped.messages, bytes: remaining.bytes - dropped.bytes}
+		fits := m.fits(stats)
+		m.stored = remaining
+		if fits {
+			break
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return false, err
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	if err := m.deleteRows(ctx, ids, dropped); err != nil {
+		return false, err
+	}
+	m.mDropped.Incr(dropped.messages)
+	return true, nil
+}
+
+func (m *SQLiteBuffer) deleteRows(ctx context.Context, ids []int, stats rowStats) error {
+	if _, err := execRetries(ctx, squirrel.Delete("messages").
+		Where(squirrel.Eq{"id": ids}).
+		RunWith(m.db)); err != nil {
+		return err
+	}
+	m.stored.messages -= stats.messages
+	m.stored.bytes -= stats.bytes
+	return nil
+}
+
+// expire deletes rows older than the TTL that are not being delivered. Must be
+// called whilst holding the lock.
+func (m *SQLiteBuffer) expire(ctx context.Context) error {
+	rows, err := squirrel.Select("id", "msg_count", "LENGTH(content)").
+		From("messages").
+		Where(squirrel.And{
+			squirrel.Gt{"created": 0},
+			squirrel.Lt{"created": time.Now().Add(-m.opts.ttl).UnixNano()},
+		}).
+		RunWith(m.db).
+		QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	var expired rowStats
+	for rows.Next() {
+		var id int
+		var row rowStats
+		if err := rows.Scan(&id, &row.messages, &row.bytes); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if _, exists := m.inFlight[id]; exists {
+			continue
+		}
+		ids = append(ids, id)
+		expired.messages += row.messages
+		expired.bytes += row.bytes
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := m.deleteRows(ctx, ids, expired); err != nil {
+		return err
+	}
+	m.mExpired.Incr(expired.messages)
+	m.cond.Broadcast()
+	return nil
+}
+
+// maintenanceLoop periodically expires messages and updates metrics until the
+// buffer is closed.
+func (m *SQLiteBuffer) maintenanceLoop() {
+	ticker := time.NewTicker(sqliteBufferMaintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.closeChan:
+			return
+		}
+
+		m.cond.L.Lock()
+		if m.closed {
+			m.cond.L.Unlock()
+			return
+		}
+
+		ctx := context.Background()
+		if m.opts.ttl > 0 {
+			if err := m.expire(ctx); err != nil {
+				m.log.Errorf("Failed to delete expired messages: %v", err)
+				m.mErrors.Incr(1)
+			}
+		}
+
+		var oldest sql.NullInt64
+		if err := m.db.QueryRowContext(ctx, `SELECT MIN(created) FROM messages WHERE created > 0`).Scan(&oldest); err != nil {
+			m.log.Errorf("Failed to query the age of the oldest message: %v", err)
+			m.mErrors.Incr(1)
+		}
+
+		m.mMessages.Set(m.stored.messages)
+		m.mBytes.Set(m.stored.bytes)
+		if oldest.Valid {
+			m.mOldestAge.Set(time.Since(time.Unix(0, oldest.Int64)).Milliseconds())
+		} else {
+			m.mOldestAge.Set(0)
+		}
+		m.cond.L.Unlock()
+	}
+}
+
 // EndOfInput signals to the buffer that the input is finished and therefore
 // once the DB is drained it should close.
 func (m *SQLiteBuffer) EndOfInput() {
@@ -357,8 +745,12 @@ func (m *SQLiteBuffer) EndOfInput() {
 // Close the underlying DB connection.
 func (m *SQLiteBuffer) Close(ctx context.Context) error {
 	m.cond.L.Lock()
+	if !m.closed {
+		close(m.closeChan)
+	}
 	m.closed = true
 	err := m.db.Close()
+	m.cond.Broadcast()
 	m.cond.L.Unlock()
 	return err
 }
@@ -424,124 +816,3 @@ func queryRowRetries(ctx context.Context, r retryable, v ...interface{}) (err er
 		}
 	}
 }
-
-var errFailedParse = errors.New("the data appears to be corrupt")
-
-func appendUint32(buffer []byte, i uint32) []byte {
-	return append(buffer,
-		byte(i>>24),
-		byte(i>>16),
-		byte(i>>8),
-		byte(i))
-}
-
-func readUint32(b []byte) (i uint32, remaining []byte, err error) {
-	if len(b) < 4 {
-		return 0, nil, errFailedParse
-	}
-	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), b[4:], nil
-}
-
-func appendBatchV0(buffer []byte, batch service.MessageBatch) ([]byte, error) {
-	// First value indicates the marshal version, which starts at 0.
-	buffer = appendUint32(buffer, 0)
-
-	// Second value indicates the number of messages in the batch.
-	buffer = appendUint32(buffer, uint32(len(batch)))
-
-	for _, msg := range batch {
-		var err error
-		if buffer, err = appendMessageV0(buffer, msg); err != nil {
-			return nil, err
-		}
-	}
-	return buffer, nil
-}
-
-func appendMessageV0(buffer []byte, msg *service.Message) ([]byte, error) {
-	metaObj := map[string]any{}
-	_ = msg.MetaWalkMut(func(key string, value any) error {
-		metaObj[key] = value
-		return nil
-	})
-
-	metaBytes, err := msgpack.Marshal(metaObj)
-	if err != nil {
-		return nil, err
-	}
-
-	// First value indicates length of serialized metadata.
-	buffer = appendUint32(buffer, uint32(len(metaBytes)))
-	// Followed by metadata.
-	buffer = append(buffer, metaBytes...)
-
-	msgBytes, err := msg.AsBytes()
-	if err != nil {
-		return nil, err
-	}
-
-	// Second value indicates length of content.
-	buffer = appendUint32(buffer, uint32(len(msgBytes)))
-	// Followed by content.
-	buffer = append(buffer, msgBytes...)
-	return buffer, nil
-}
-
-func readBatch(b []byte) (service.MessageBatch, []byte, error) {
-	var ver uint32
-	var err error
-	if ver, b, err = readUint32(b); err != nil {
-		return nil, nil, err
-	}
-	// Only supported version thus far.
-	if ver != 0 {
-		return nil, nil, errFailedParse
-	}
-	return readBatchV0(b)
-}
-
-func readBatchV0(b []byte) (service.MessageBatch, []byte, error) {
-	var parts uint32
-	var err error
-	if parts, b, err = readUint32(b); err != nil {
-		return nil, nil, err
-	}
-
-	batch := make(service.MessageBatch, parts)
-	for i := uint32(0); i < parts; i++ {
-		if batch[i], b, err = readMessageV0(b); err != nil {
-			return nil, nil, err
-		}
-	}
-	return batch, b, nil
-}
-
-func readMessageV0(b []byte) (*service.Message, []byte, error) {
-	var contentLen uint32
-	var err error
-
-	// Metadata bytes.
-	if contentLen, b, err = readUint32(b); err != nil {
-		return nil, nil, err
-	}
-	metaBytes := b[:contentLen]
-	b = b[contentLen:]
-
-	// Content bytes.
-	if contentLen, b, err = readUint32(b); err != nil {
-		return nil, nil, err
-	}
-	contentBytes := b[:contentLen]
-	b = b[contentLen:]
-
-	msg := service.NewMessage(contentBytes)
-
-	metaObj := map[string]any{}
-	if err := msgpack.Unmarshal(metaBytes, &metaObj); err != nil {
-		return nil, nil, err
-	}
-	for k, v := range metaObj {
-		msg.MetaSetMut(k, v)
-	}
-	return msg, b, nil
-}
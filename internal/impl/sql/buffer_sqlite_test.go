@@ -15,9 +15,12 @@
 package sql_test
 
 import (
+	"bytes"
 	"context"
+	gosql "database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -461,6 +464,271 @@ path: "%v"
 	require.NoError(t, block.Close(ctx))
 }
 
+func TestBufferSQLiteLimitReject(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := context.Background()
+	block := memBufFromConf(t, fmt.Sprintf(`
+path: "%v"
+max_messages: 2
+limit_policy: reject
+`, filepath.Join(tmpDir, "foo.db")))
+	defer block.Close(ctx)
+
+	noopAck := func(ctx context.Context, err error) error { return nil }
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("1"))}, noopAck))
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("2"))}, noopAck))
+	require.Error(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("3"))}, noopAck))
+
+	m, ackFunc, err := block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	msgEqualStr(t, "1", m[0])
+	require.NoError(t, ackFunc(ctx, nil))
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("3"))}, noopAck))
+
+	for _, exp := range []string{"2", "3"} {
+		m, ackFunc, err := block.ReadBatch(ctx)
+		require.NoError(t, err)
+		require.Len(t, m, 1)
+		msgEqualStr(t, exp, m[0])
+		require.NoError(t, ackFunc(ctx, nil))
+	}
+}
+
+func TestBufferSQLiteLimitBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := context.Background()
+	block := memBufFromConf(t, fmt.Sprintf(`
+path: "%v"
+max_size_bytes: 100
+`, filepath.Join(tmpDir, "foo.db")))
+	defer block.Close(ctx)
+
+	noopAck := func(ctx context.Context, err error) error { return nil }
+	payload := strings.Repeat("a", 60)
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte(payload))}, noopAck))
+
+	tCtx, done := context.WithTimeout(ctx, time.Millisecond*50)
+	err := block.WriteBatch(tCtx, service.MessageBatch{service.NewMessage([]byte(payload))}, noopAck)
+	done()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte(payload))}, noopAck)
+	}()
+
+	select {
+	case err := <-writeErr:
+		t.Fatalf("write should be blocked: %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	m, ackFunc, err := block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	require.NoError(t, ackFunc(ctx, nil))
+
+	select {
+	case err := <-writeErr:
+		require.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for blocked write")
+	}
+
+	m, ackFunc, err = block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	msgEqualStr(t, payload, m[0])
+	require.NoError(t, ackFunc(ctx, nil))
+}
+
+func TestBufferSQLiteLimitDropOldest(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := context.Background()
+	block := memBufFromConf(t, fmt.Sprintf(`
+path: "%v"
+max_messages: 3
+limit_policy: drop_oldest
+`, filepath.Join(tmpDir, "foo.db")))
+	defer block.Close(ctx)
+
+	noopAck := func(ctx context.Context, err error) error { return nil }
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{
+			service.NewMessage(fmt.Appendf(nil, "%v", i)),
+		}, noopAck))
+	}
+
+	// The first message is being delivered and therefore must not be dropped.
+	m, ackFunc, err := block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	msgEqualStr(t, "0", m[0])
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("3")),
+		service.NewMessage([]byte("4")),
+	}, noopAck))
+
+	require.NoError(t, ackFunc(ctx, nil))
+
+	m, ackFunc, err = block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 2)
+	msgEqualStr(t, "3", m[0])
+	msgEqualStr(t, "4", m[1])
+	require.NoError(t, ackFunc(ctx, nil))
+
+	block.EndOfInput()
+
+	_, _, err = block.ReadBatch(ctx)
+	assert.Equal(t, service.ErrEndOfBuffer, err)
+}
+
+func TestBufferSQLiteTTL(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := context.Background()
+	conf := fmt.Sprintf(`
+path: "%v"
+ttl: 100ms
+`, filepath.Join(tmpDir, "foo.db"))
+	block := memBufFromConf(t, conf)
+
+	noopAck := func(ctx context.Context, err error) error { return nil }
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("old"))}, noopAck))
+	time.Sleep(time.Millisecond * 150)
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte("new"))}, noopAck))
+
+	m, ackFunc, err := block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	msgEqualStr(t, "new", m[0])
+	require.NoError(t, ackFunc(ctx, nil))
+
+	// The expired message is not delivered after a restart either.
+	require.NoError(t, block.Close(ctx))
+	block = memBufFromConf(t, conf)
+	defer block.Close(ctx)
+
+	block.EndOfInput()
+
+	tCtx, done := context.WithTimeout(ctx, time.Second*5)
+	defer done()
+	_, _, err = block.ReadBatch(tCtx)
+	assert.Equal(t, service.ErrEndOfBuffer, err)
+}
+
+func TestBufferSQLiteMaintenanceErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "foo.db")
+
+	var logs syncBuffer
+	builder := service.NewStreamBuilder()
+	builder.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	require.NoError(t, builder.SetYAML(fmt.Sprintf(`
+input:
+  generate:
+    interval: 1h
+    mapping: 'root = "hello world"'
+buffer:
+  sqlite:
+    path: "%v"
+    ttl: 100ms
+output:
+  drop: {}
+`, path)))
+
+	strm, err := builder.Build()
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- strm.Run(ctx)
+	}()
+
+	// Removing the table from beneath the buffer causes expiring messages to
+	// fail, which must be logged rather than swallowed.
+	assert.Eventually(t, func() bool {
+		db, err := gosql.Open("sqlite", path)
+		require.NoError(t, err)
+		defer db.Close()
+		_, err = db.Exec(`DROP TABLE messages`)
+		return err == nil
+	}, time.Second*5, time.Millisecond*50)
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "Failed to delete expired messages")
+	}, time.Second*5, time.Millisecond*50)
+	assert.Contains(t, logs.String(), "Failed to query the age of the oldest message")
+
+	require.NoError(t, strm.StopWithin(time.Second*5))
+	<-runErr
+}
+
+type syncBuffer struct {
+	mut sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.String()
+}
+
+func TestBufferSQLiteMigration(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "foo.db")
+
+	db, err := gosql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+CREATE TABLE messages (
+  id       INTEGER PRIMARY KEY AUTOINCREMENT,
+  content  TEXT NOT NULL,
+  requeue  INTEGER NOT NULL
+)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	ctx := context.Background()
+	block := memBufFromConf(t, fmt.Sprintf(`
+path: "%v"
+max_messages: 10
+ttl: 1h
+`, path))
+	defer block.Close(ctx)
+
+	require.NoError(t, block.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("hello world")),
+	}, func(ctx context.Context, err error) error { return nil }))
+
+	m, ackFunc, err := block.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, m, 1)
+	msgEqualStr(t, "hello world", m[0])
+	require.NoError(t, ackFunc(ctx, nil))
+}
+
 func BenchmarkBufferSQLiteWrites(b *testing.B) {
 	tmpDir := b.TempDir()
 
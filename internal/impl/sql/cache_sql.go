@@ -18,7 +18,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/squirrel"
@@ -29,11 +31,23 @@ import (
 )
 
 const (
-	cacheKeyColumnField   = "key_column"
-	cacheValueColumnField = "value_column"
-	cacheSetSuffixField   = "set_suffix"
+	cacheKeyColumnField       = "key_column"
+	cacheValueColumnField     = "value_column"
+	cacheSetSuffixField       = "set_suffix"
+	cacheExpiresColumnField   = "expires_column"
+	cacheDefaultTTLField      = "default_ttl"
+	cacheCleanupIntervalField = "cleanup_interval"
 )
 
+// cacheSetMultiMaxRows is the maximum number of rows written by a single
+// insert statement of a multi-set, which keeps the number of parameters below
+// the limits of all drivers.
+const cacheSetMultiMaxRows = 500
+
+// cacheGetMultiMaxKeys is the maximum number of keys read by a single select
+// statement of combined get operations.
+const cacheGetMultiMaxKeys = 500
+
 func sqlCacheConfig() *service.ConfigSpec {
 	spec := service.NewConfigSpec().
 		Categories("Services").
@@ -46,7 +60,7 @@ Cache operations are translated into SQL statements as follows:
 
 == Get
 
-All ` + "`get`" + ` operations are performed with a traditional ` + "`select`" + ` statement.
+All ` + "`get`" + ` operations are performed with a traditional ` + "`select`" + ` statement. Get operations that are issued whilst another is in progress, for example by the processing threads of a ` + "`cached`" + ` processor, are combined into a single ` + "`select`" + ` statement of their keys, except with the ` + "`gocosmos`" + ` driver. When the keys returned differ from those requested, for example with a case insensitive collation or a padded ` + "`CHAR`" + ` column, the keys left unmatched are read with individual ` + "`select`" + ` statements.
 
 == Delete
 
@@ -61,6 +75,14 @@ This will behave as an ` + "`add`" + ` operation by default, and so ideally need
 == Add
 
 The ` + "`add`" + ` operation is performed with a traditional ` + "`insert`" + ` statement.
+
+== Batched Set
+
+Components that set many cache items at once, such as the ` + "`cache`" + ` output, do so with a single ` + "`insert`" + ` statement of multiple rows, including the ` + "`set_suffix`" + `. When multiple items within a batch share a key only the last is written. The ` + "`oracle`" + ` and ` + "`gocosmos`" + ` drivers do not support inserting multiple rows and therefore set each item individually.
+
+== TTL
+
+When an ` + "`expires_column`" + ` is specified the TTL of each item, or the ` + "`default_ttl`" + ` when an item has none, determines the time at which it expires, which is stored in that column. Expired items are not returned by ` + "`get`" + ` operations, are replaced by ` + "`add`" + ` operations, and are periodically deleted according to ` + "`cleanup_interval`" + `. Items without a TTL are stored with a NULL expiry and never expire.
 `).
 		Field(driverField).
 		Field(dsnField).
@@ -80,7 +102,22 @@ The ` + "`add`" + ` operation is performed with a traditional ` + "`insert`" + `
 				"ON DUPLICATE KEY UPDATE bar=VALUES(bar)",
 				"ON CONFLICT (foo) DO UPDATE SET bar=excluded.bar",
 				"ON CONFLICT (foo) DO NOTHING",
-			))
+			)).
+		Field(service.NewStringField(cacheExpiresColumnField).
+			Description("An optional timestamp column that allows NULL values, which is used for storing the time at which cache items expire. When set the TTL of cache items is honoured. When the `" + cacheSetSuffixField + "` updates existing rows it should also update this column.").
+			Example("expires_at").
+			Optional().
+			Version("4.42.0")).
+		Field(service.NewDurationField(cacheDefaultTTLField).
+			Description("An optional TTL applied to items that are set without one. Requires an `" + cacheExpiresColumnField + "`.").
+			Example("5m").
+			Optional().
+			Version("4.42.0")).
+		Field(service.NewDurationField(cacheCleanupIntervalField).
+			Description("How often expired items are deleted from the table when an `" + cacheExpiresColumnField + "` is specified. Set to `0s` in order to disable the deletion of expired items, for example when several caches share a table.").
+			Default("1m").
+			Advanced().
+			Version("4.42.0"))
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -104,12 +141,21 @@ type sqlCache struct {
 	dsn    string
 	db     *sql.DB
 
-	keyColumn string
+	keyColumn     string
+	expiresColumn string
+	defaultTTL    *time.Duration
+
+	selectBuilder   squirrel.SelectBuilder
+	getMultiBuilder squirrel.SelectBuilder
+	insertBuilder   squirrel.InsertBuilder
+	upsertBuilder   squirrel.InsertBuilder
+	deleteBuilder   squirrel.DeleteBuilder
 
-	selectBuilder squirrel.SelectBuilder
-	insertBuilder squirrel.InsertBuilder
-	upsertBuilder squirrel.InsertBuilder
-	deleteBuilder squirrel.DeleteBuilder
+	// Get operations waiting for the one in progress to complete, which are
+	// then performed together.
+	getMut     sync.Mutex
+	getPending *cacheGetBatch
+	getRunning bool
 
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
@@ -145,18 +191,45 @@ func newSQLCacheFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (
 		return nil, err
 	}
 
+	columns := []string{s.keyColumn, valueColumn}
+	if conf.Contains(cacheExpiresColumnField) {
+		if s.expiresColumn, err = conf.FieldString(cacheExpiresColumnField); err != nil {
+			return nil, err
+		}
+		columns = append(columns, s.expiresColumn)
+	}
+
+	if conf.Contains(cacheDefaultTTLField) {
+		if s.expiresColumn == "" {
+			return nil, fmt.Errorf("field %v requires an %v", cacheDefaultTTLField, cacheExpiresColumnField)
+		}
+		ttl, err := conf.FieldDuration(cacheDefaultTTLField)
+		if err != nil {
+			return nil, err
+		}
+		s.defaultTTL = &ttl
+	}
+
+	cleanupInterval, err := conf.FieldDuration(cacheCleanupIntervalField)
+	if err != nil {
+		return nil, err
+	}
+
 	s.selectBuilder = squirrel.Select(valueColumn).From(tableStr)
-	s.insertBuilder = squirrel.Insert(tableStr).Columns(s.keyColumn, valueColumn)
-	s.upsertBuilder = squirrel.Insert(tableStr).Columns(s.keyColumn, valueColumn)
+	s.getMultiBuilder = squirrel.Select(s.keyColumn, valueColumn).From(tableStr)
+	s.insertBuilder = squirrel.Insert(tableStr).Columns(columns...)
+	s.upsertBuilder = squirrel.Insert(tableStr).Columns(columns...)
 	s.deleteBuilder = squirrel.Delete(tableStr)
 
 	if s.driver == "postgres" || s.driver == "clickhouse" {
 		s.selectBuilder = s.selectBuilder.PlaceholderFormat(squirrel.Dollar)
+		s.getMultiBuilder = s.getMultiBuilder.PlaceholderFormat(squirrel.Dollar)
 		s.insertBuilder = s.insertBuilder.PlaceholderFormat(squirrel.Dollar)
 		s.upsertBuilder = s.upsertBuilder.PlaceholderFormat(squirrel.Dollar)
 		s.deleteBuilder = s.deleteBuilder.PlaceholderFormat(squirrel.Dollar)
 	} else if s.driver == "oracle" || s.driver == "gocosmos" {
 		s.selectBuilder = s.selectBuilder.PlaceholderFormat(squirrel.Colon)
+		s.getMultiBuilder = s.getMultiBuilder.PlaceholderFormat(squirrel.Colon)
 		s.insertBuilder = s.insertBuilder.PlaceholderFormat(squirrel.Colon)
 		s.upsertBuilder = s.upsertBuilder.PlaceholderFormat(squirrel.Colon)
 		s.deleteBuilder = s.deleteBuilder.PlaceholderFormat(squirrel.Colon)
@@ -181,16 +254,204 @@ func newSQLCacheFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (
 	connSettings.apply(context.Background(), s.db, s.logger)
 
 	go func() {
-		<-s.shutSig.HardStopChan()
+		if s.expiresColumn != "" && cleanupInterval > 0 {
+			s.cleanupLoop(cleanupInterval)
+		} else {
+			<-s.shutSig.HardStopChan()
+		}
 		_ = s.db.Close()
 		s.shutSig.TriggerHasStopped()
 	}()
 	return s, nil
 }
 
-func (s *sqlCache) Get(ctx context.Context, key string) (value []byte, err error) {
+// cleanupLoop periodically deletes expired items until the cache is closed.
+func (s *sqlCache) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, done := s.shutSig.HardStopCtx(context.Background())
+	defer done()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		res, err := s.deleteBuilder.
+			Where(squirrel.LtOrEq{s.expiresColumn: time.Now().UTC()}).
+			RunWith(s.db).ExecContext(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Errorf("Failed to delete expired cache items: %v", err)
+			}
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			s.logger.Debugf("Deleted %v expired cache items", n)
+		}
+	}
+}
+
+// notExpired returns a condition matching rows that have not expired.
+func (s *sqlCache) notExpired() squirrel.Sqlizer {
+	return squirrel.Or{
+		squirrel.Eq{s.expiresColumn: nil},
+		squirrel.Gt{s.expiresColumn: time.Now().UTC()},
+	}
+}
+
+// rowValues returns the column values of a row for a cache item.
+func (s *sqlCache) rowValues(key string, value []byte, ttl *time.Duration) []any {
+	if s.expiresColumn == "" {
+		return []any{key, value}
+	}
+	if ttl == nil {
+		ttl = s.defaultTTL
+	}
+	if ttl == nil {
+		return []any{key, value, nil}
+	}
+	return []any{key, value, time.Now().Add(*ttl).UTC()}
+}
+
+// cacheGetBatch is a group of get operations performed with a single select
+// statement.
+type cacheGetBatch struct {
+	keys   []string
+	values map[string][]byte
+	err    error
+	done   chan struct{}
+}
+
+// Get reads an item, combining it with any other get operations issued whilst
+// one is in progress.
+func (s *sqlCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.driver == "gocosmos" {
+		return s.getSingle(ctx, key)
+	}
+
+	s.getMut.Lock()
+	b := s.getPending
+	if b == nil {
+		b = &cacheGetBatch{done: make(chan struct{})}
+		s.getPending = b
+	}
+	b.keys = append(b.keys, key)
+	if !s.getRunning {
+		s.getRunning = true
+		go s.getLoop()
+	}
+	s.getMut.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	value, exists := b.values[key]
+	if !exists {
+		return nil, service.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// getLoop performs pending get operations until there are none left.
+func (s *sqlCache) getLoop() {
+	ctx, done := s.shutSig.HardStopCtx(context.Background())
+	defer done()
+
+	for {
+		s.getMut.Lock()
+		b := s.getPending
+		s.getPending = nil
+		if b == nil {
+			s.getRunning = false
+			s.getMut.Unlock()
+			return
+		}
+		s.getMut.Unlock()
+
+		b.values, b.err = s.getMulti(ctx, b.keys)
+		close(b.done)
+	}
+}
+
+// getMulti reads the items of several keys with as few select statements as
+// possible, returning only the items that exist.
+//
+// Rows are matched to keys by the key returned from the database, which may not
+// be identical to the key requested, for example with case insensitive
+// collations or padded CHAR columns. When a row doesn't match a requested key
+// the keys left unmatched are read individually instead.
+func (s *sqlCache) getMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for len(keys) > 0 {
+		chunk := keys[:min(len(keys), cacheGetMultiMaxKeys)]
+		keys = keys[len(chunk):]
+
+		requested := make(map[string]struct{}, len(chunk))
+		for _, k := range chunk {
+			requested[k] = struct{}{}
+		}
+		var unmatched bool
+
+		var where squirrel.Sqlizer = squirrel.Eq{s.keyColumn: chunk}
+		if s.expiresColumn != "" {
+			where = squirrel.And{where, s.notExpired()}
+		}
+		rows, err := s.getMultiBuilder.Where(where).RunWith(s.db).QueryContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var value []byte
+			if err := rows.Scan(&key, &value); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			if _, exists := requested[key]; !exists {
+				unmatched = true
+				continue
+			}
+			values[key] = value
+		}
+		if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+			return nil, err
+		}
+
+		if !unmatched {
+			continue
+		}
+		for _, k := range chunk {
+			if _, exists := values[k]; exists {
+				continue
+			}
+			value, err := s.getSingle(ctx, k)
+			if errors.Is(err, service.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[k] = value
+		}
+	}
+	return values, nil
+}
+
+func (s *sqlCache) getSingle(ctx context.Context, key string) (value []byte, err error) {
+	var where squirrel.Sqlizer = squirrel.Eq{s.keyColumn: key}
+	if s.expiresColumn != "" {
+		where = squirrel.And{where, s.notExpired()}
+	}
 	err = s.selectBuilder.
-		Where(squirrel.Eq{s.keyColumn: key}).
+		Where(where).
 		RunWith(s.db).QueryRowContext(ctx).
 		Scan(&value)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
@@ -200,12 +461,62 @@ func (s *sqlCache) Get(ctx context.Context, key string) (value []byte, err error
 }
 
 func (s *sqlCache) Set(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
-	_, err := s.upsertBuilder.Values(key, value).RunWith(s.db).ExecContext(ctx)
+	_, err := s.upsertBuilder.Values(s.rowValues(key, value, ttl)...).RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
+// SetMulti sets multiple items with as few insert statements as possible.
+func (s *sqlCache) SetMulti(ctx context.Context, keyValues ...service.CacheItem) error {
+	if s.driver == "oracle" || s.driver == "gocosmos" {
+		for _, kv := range keyValues {
+			if err := s.Set(ctx, kv.Key, kv.Value, kv.TTL); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Upserts typically fail when a statement affects the same row twice, and
+	// therefore only the last item of each key is written.
+	indexes := make(map[string]int, len(keyValues))
+	items := make([]service.CacheItem, 0, len(keyValues))
+	for _, kv := range keyValues {
+		if i, exists := indexes[kv.Key]; exists {
+			items[i] = kv
+			continue
+		}
+		indexes[kv.Key] = len(items)
+		items = append(items, kv)
+	}
+
+	for len(items) > 0 {
+		chunk := items[:min(len(items), cacheSetMultiMaxRows)]
+		items = items[len(chunk):]
+
+		builder := s.upsertBuilder
+		for _, kv := range chunk {
+			builder = builder.Values(s.rowValues(kv.Key, kv.Value, kv.TTL)...)
+		}
+		if _, err := builder.RunWith(s.db).ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *sqlCache) Add(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
-	_, err := s.insertBuilder.Values(key, value).RunWith(s.db).ExecContext(ctx)
+	if s.expiresColumn != "" {
+		// An expired item that has not yet been deleted would otherwise cause
+		// the insert to fail.
+		if _, err := s.deleteBuilder.Where(squirrel.And{
+			squirrel.Eq{s.keyColumn: key},
+			squirrel.LtOrEq{s.expiresColumn: time.Now().UTC()},
+		}).RunWith(s.db).ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.insertBuilder.Values(s.rowValues(key, value, ttl)...).RunWith(s.db).ExecContext(ctx)
 	if err != nil {
 		// This is difficult, ideally we need to translate any error that
 		// indicates a collision into service.ErrKeyAlreadyExists, but this is
@@ -0,0 +1,240 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+func sqliteCacheFromYAML(t *testing.T, extra string) *sqlCache {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db") + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	conf, err := sqlCacheConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: cache
+key_column: k
+value_column: v
+set_suffix: 'ON CONFLICT (k) DO UPDATE SET v = excluded.v, expires_at = excluded.expires_at'
+init_statement: 'CREATE TABLE cache (k TEXT PRIMARY KEY, v BLOB, expires_at TIMESTAMP)'
+`+extra, nil)
+	require.NoError(t, err)
+
+	c, err := newSQLCacheFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, c.Close(context.Background()))
+	})
+	return c
+}
+
+func TestSQLCacheTTL(t *testing.T) {
+	c := sqliteCacheFromYAML(t, `
+expires_column: expires_at
+cleanup_interval: 0s
+`)
+	ctx := context.Background()
+
+	short, long := time.Millisecond*50, time.Hour
+	require.NoError(t, c.Set(ctx, "a", []byte("a1"), &short))
+	require.NoError(t, c.Set(ctx, "b", []byte("b1"), &long))
+	require.NoError(t, c.Set(ctx, "c", []byte("c1"), nil))
+
+	v, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", string(v))
+
+	time.Sleep(time.Millisecond * 100)
+
+	_, err = c.Get(ctx, "a")
+	assert.ErrorIs(t, err, service.ErrKeyNotFound)
+
+	v, err = c.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "b1", string(v))
+
+	v, err = c.Get(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", string(v))
+
+	// Adding an expired key succeeds, whereas adding a live key does not.
+	require.NoError(t, c.Add(ctx, "a", []byte("a2"), nil))
+	assert.Error(t, c.Add(ctx, "b", []byte("b2"), nil))
+
+	v, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", string(v))
+}
+
+func TestSQLCacheDefaultTTLCleanup(t *testing.T) {
+	c := sqliteCacheFromYAML(t, `
+expires_column: expires_at
+default_ttl: 10ms
+cleanup_interval: 20ms
+`)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("a1"), nil))
+
+	assert.Eventually(t, func() bool {
+		var n int
+		if err := c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM cache`).Scan(&n); err != nil {
+			return false
+		}
+		return n == 0
+	}, time.Second*5, time.Millisecond*20)
+}
+
+func TestSQLCacheSetMulti(t *testing.T) {
+	c := sqliteCacheFromYAML(t, ``)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("a0"), nil))
+
+	items := []service.CacheItem{
+		{Key: "a", Value: []byte("a1")},
+		{Key: "b", Value: []byte("b1")},
+		{Key: "a", Value: []byte("a2")},
+	}
+	for i := 0; i < cacheSetMultiMaxRows+10; i++ {
+		items = append(items, service.CacheItem{Key: fmt.Sprintf("key%v", i), Value: []byte("v")})
+	}
+	require.NoError(t, c.SetMulti(ctx, items...))
+
+	for k, exp := range map[string]string{"a": "a2", "b": "b1"} {
+		v, err := c.Get(ctx, k)
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(v), k)
+	}
+
+	var n int
+	require.NoError(t, c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM cache`).Scan(&n))
+	assert.Equal(t, cacheSetMultiMaxRows+12, n)
+}
+
+func TestSQLCacheGetConcurrent(t *testing.T) {
+	c := sqliteCacheFromYAML(t, `
+expires_column: expires_at
+cleanup_interval: 0s
+`)
+	ctx := context.Background()
+
+	var items []service.CacheItem
+	for i := 0; i < cacheGetMultiMaxKeys+10; i++ {
+		items = append(items, service.CacheItem{Key: fmt.Sprintf("k%v", i), Value: []byte(fmt.Sprintf("v%v", i))})
+	}
+	expired := -time.Minute
+	items = append(items, service.CacheItem{Key: "expired", Value: []byte("nope"), TTL: &expired})
+	require.NoError(t, c.SetMulti(ctx, items...))
+
+	// Keys spanning several select statements are read at once, excluding
+	// those that are missing or expired.
+	keys := []string{"missing", "expired"}
+	for _, item := range items[:len(items)-1] {
+		keys = append(keys, item.Key)
+	}
+	values, err := c.getMulti(ctx, keys)
+	require.NoError(t, err)
+	assert.Len(t, values, cacheGetMultiMaxKeys+10)
+	assert.Equal(t, "v0", string(values["k0"]))
+	assert.Equal(t, fmt.Sprintf("v%v", cacheGetMultiMaxKeys+9), string(values[fmt.Sprintf("k%v", cacheGetMultiMaxKeys+9)]))
+
+	// Concurrent gets each receive their own item.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(items)-1; j += 50 {
+				v, err := c.Get(ctx, fmt.Sprintf("k%v", j))
+				if assert.NoError(t, err) {
+					assert.Equal(t, fmt.Sprintf("v%v", j), string(v))
+				}
+			}
+			_, err := c.Get(ctx, "missing")
+			assert.ErrorIs(t, err, service.ErrKeyNotFound)
+			_, err = c.Get(ctx, "expired")
+			assert.ErrorIs(t, err, service.ErrKeyNotFound)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSQLCacheGetCaseInsensitiveKeys(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	conf, err := sqlCacheConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: cache
+key_column: k
+value_column: v
+init_statement: 'CREATE TABLE cache (k TEXT COLLATE NOCASE PRIMARY KEY, v BLOB)'
+`, nil)
+	require.NoError(t, err)
+
+	c, err := newSQLCacheFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, c.Close(context.Background()))
+	})
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "Foo", []byte("foo1"), nil))
+	require.NoError(t, c.Set(ctx, "bar", []byte("bar1"), nil))
+
+	// The database matches keys that differ from those stored, and returns the
+	// stored key rather than the one requested.
+	values, err := c.getMulti(ctx, []string{"FOO", "bar", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"FOO": []byte("foo1"),
+		"bar": []byte("bar1"),
+	}, values)
+
+	v, err := c.Get(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo1", string(v))
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, service.ErrKeyNotFound)
+}
+
+func TestSQLCacheDefaultTTLRequiresColumn(t *testing.T) {
+	conf, err := sqlCacheConfig().ParseYAML(`
+driver: sqlite
+dsn: 'file::memory:'
+table: cache
+key_column: k
+value_column: v
+default_ttl: 1m
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newSQLCacheFromConfig(conf, service.MockResources())
+	require.Error(t, err)
+}
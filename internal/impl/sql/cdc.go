@@ -0,0 +1,477 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/checkpoint"
+	"github.com/Jeffail/shutdown"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	cdcFieldDSN             = "dsn"
+	cdcFieldTables          = "tables"
+	cdcFieldStreamSnapshot  = "stream_snapshot"
+	cdcFieldPollInterval    = "poll_interval"
+	cdcFieldCheckpointCache = "checkpoint_cache"
+	cdcFieldCheckpointKey   = "checkpoint_key"
+	cdcFieldCheckpointLimit = "checkpoint_limit"
+	cdcFieldBatching        = "batching"
+
+	cdcShutdownTimeout = 5 * time.Second
+)
+
+const (
+	cdcModeSnapshot  = "snapshot"
+	cdcModeStreaming = "streaming"
+
+	cdcOpInsert = "insert"
+	cdcOpUpdate = "update"
+	cdcOpDelete = "delete"
+)
+
+// cdcMetadataDescription describes the metadata added to messages by inputs
+// that are built on cdcInput, given the name of the position metadata field.
+func cdcMetadataDescription(positionKey, positionDesc string) string {
+	return `
+== Metadata
+
+This input adds the following metadata fields to each message:
+
+- mode (Either "streaming" or "snapshot" indicating whether the message is part of a streaming operation or snapshot processing)
+- table (Name of the table that the message originated from)
+- operation (Type of operation that generated the message: "insert", "update", or "delete". Messages of a snapshot have the operation "insert")
+- ` + positionKey + ` (` + positionDesc + `, which is not set for messages of a snapshot)
+`
+}
+
+// cdcFields returns the config fields common to inputs built on cdcInput.
+func cdcFields(dsnExample, tableExample, defaultCheckpointKey string) []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewStringField(cdcFieldDSN).
+			Description("A Data Source Name to identify the target database.").
+			Example(dsnExample),
+		service.NewStringListField(cdcFieldTables).
+			Description("A list of tables, qualified with their schema, to stream changes from.").
+			Example([]string{tableExample}),
+		service.NewBoolField(cdcFieldStreamSnapshot).
+			Description("When set to true and no checkpoint has been stored yet the existing rows of each table are streamed before changes are streamed.").
+			Default(false),
+		service.NewDurationField(cdcFieldPollInterval).
+			Description("The interval at which the database is polled for new changes once all existing changes have been read.").
+			Default("1s"),
+		service.NewStringField(cdcFieldCheckpointCache).
+			Description("A xref:components:caches/about.adoc[cache resource] to store the position of the last acknowledged change in, so that streaming resumes from it after a restart."),
+		service.NewStringField(cdcFieldCheckpointKey).
+			Description("The key to store the checkpoint with within the `" + cdcFieldCheckpointCache + "`.").
+			Default(defaultCheckpointKey).
+			Advanced(),
+		service.NewIntField(cdcFieldCheckpointLimit).
+			Description("The maximum number of messages that can be processed at a given time. Increasing this limit enables parallel processing and batching at the output level. A position is not checkpointed until all messages before it have been delivered in order to preserve at least once delivery guarantees.").
+			Default(1024),
+		service.NewAutoRetryNacksToggleField(),
+		service.NewBatchPolicyField(cdcFieldBatching),
+	}
+}
+
+// cdcEvent is a single change read from a database.
+type cdcEvent struct {
+	table     string
+	operation string
+	position  string
+	data      map[string]any
+}
+
+// cdcSource reads the changes of a database from the change tables or logs
+// of a specific engine.
+type cdcSource interface {
+	// currentPosition returns the position of the most recent change, from
+	// which changes are streamed after a snapshot.
+	currentPosition(ctx context.Context) (string, error)
+
+	// snapshotQuery returns a query that selects the rows of a table as of a
+	// position returned by currentPosition.
+	snapshotQuery(table, position string) (string, []any, error)
+
+	// readChanges calls fn, in order, for each change after the position
+	// from, and returns the position up to which changes have been read.
+	readChanges(ctx context.Context, from string, fn func(cdcEvent) error) (string, error)
+}
+
+// cdcSnapshotConverter is implemented by a cdcSource that converts the values
+// of snapshot rows in order to match the values of its changes.
+type cdcSnapshotConverter interface {
+	convertSnapshotRow(ctx context.Context, table string, row map[string]any) error
+}
+
+// cdcInput streams an optional snapshot followed by the changes of a
+// cdcSource, checkpointing the position of acknowledged changes in a cache.
+type cdcInput struct {
+	driver      string
+	dsn         string
+	positionKey string
+	newSource   func(db *sql.DB, tables []string) cdcSource
+
+	tables          []string
+	streamSnapshot  bool
+	pollInterval    time.Duration
+	checkpointCache string
+	checkpointKey   string
+	checkpointLimit int
+	batching        service.BatchPolicy
+
+	msgChan chan cdcAsyncMessage
+
+	// Serialises the storing of checkpoints so that an older position never
+	// overwrites a newer one.
+	storeMut   sync.Mutex
+	lastStored string
+
+	mgr     *service.Resources
+	logger  *service.Logger
+	stopSig *shutdown.Signaller
+}
+
+type cdcAsyncMessage struct {
+	msg   service.MessageBatch
+	ackFn service.AckFunc
+}
+
+func cdcInputFromParsed(
+	conf *service.ParsedConfig,
+	mgr *service.Resources,
+	driver, positionKey string,
+	newSource func(db *sql.DB, tables []string) cdcSource,
+) (i *cdcInput, err error) {
+	i = &cdcInput{
+		driver:      driver,
+		positionKey: positionKey,
+		newSource:   newSource,
+		msgChan:     make(chan cdcAsyncMessage),
+		mgr:         mgr,
+		logger:      mgr.Logger(),
+		stopSig:     shutdown.NewSignaller(),
+	}
+
+	if i.dsn, err = conf.FieldString(cdcFieldDSN); err != nil {
+		return nil, err
+	}
+	if i.tables, err = conf.FieldStringList(cdcFieldTables); err != nil {
+		return nil, err
+	}
+	if len(i.tables) == 0 {
+		return nil, errors.New("at least one table must be specified")
+	}
+	for _, t := range i.tables {
+		if err := validateTableName(t); err != nil {
+			return nil, err
+		}
+		if schema, name, _ := strings.Cut(t, "."); schema == "" || name == "" || strings.Contains(name, ".") {
+			return nil, fmt.Errorf("table %v must be qualified with its schema", t)
+		}
+	}
+	if i.streamSnapshot, err = conf.FieldBool(cdcFieldStreamSnapshot); err != nil {
+		return nil, err
+	}
+	if i.pollInterval, err = conf.FieldDuration(cdcFieldPollInterval); err != nil {
+		return nil, err
+	}
+	if i.checkpointCache, err = conf.FieldString(cdcFieldCheckpointCache); err != nil {
+		return nil, err
+	}
+	if !mgr.HasCache(i.checkpointCache) {
+		return nil, fmt.Errorf("cache resource %v was not found", i.checkpointCache)
+	}
+	if i.checkpointKey, err = conf.FieldString(cdcFieldCheckpointKey); err != nil {
+		return nil, err
+	}
+	if i.checkpointLimit, err = conf.FieldInt(cdcFieldCheckpointLimit); err != nil {
+		return nil, err
+	}
+	if i.batching, err = conf.FieldBatchPolicy(cdcFieldBatching); err != nil {
+		return nil, err
+	} else if i.batching.IsNoop() {
+		i.batching.Count = 1
+	}
+
+	// Has stopped is how we notify that we're not connected. This will get
+	// reset at connection time.
+	i.stopSig.TriggerHasStopped()
+	return i, nil
+}
+
+func (i *cdcInput) Connect(ctx context.Context) error {
+	db, err := sqlOpenWithReworks(i.logger, i.driver, i.dsn)
+	if err != nil {
+		return err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	position, err := i.loadCheckpoint(ctx)
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	batcher, err := i.batching.NewBatcher(i.mgr)
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	// Reset our stop signal
+	i.stopSig = shutdown.NewSignaller()
+	go i.loop(db, i.newSource(db, i.tables), batcher, position)
+	return nil
+}
+
+func (i *cdcInput) loadCheckpoint(ctx context.Context) (string, error) {
+	var position []byte
+	var cacheErr error
+	if err := i.mgr.AccessCache(ctx, i.checkpointCache, func(c service.Cache) {
+		position, cacheErr = c.Get(ctx, i.checkpointKey)
+	}); err != nil {
+		return "", err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return "", nil
+	}
+	if cacheErr != nil {
+		return "", cacheErr
+	}
+	i.storeMut.Lock()
+	i.lastStored = string(position)
+	i.storeMut.Unlock()
+	return string(position), nil
+}
+
+func (i *cdcInput) storeCheckpoint(ctx context.Context, position string) error {
+	var setErr error
+	if err := i.mgr.AccessCache(ctx, i.checkpointCache, func(c service.Cache) {
+		setErr = c.Set(ctx, i.checkpointKey, []byte(position), nil)
+	}); err != nil {
+		return err
+	}
+	return setErr
+}
+
+func (i *cdcInput) loop(db *sql.DB, source cdcSource, batcher *service.Batcher, position string) {
+	ctx, done := i.stopSig.SoftStopCtx(context.Background())
+	defer done()
+
+	defer func() {
+		ctx, done := i.stopSig.HardStopCtx(context.Background())
+		defer done()
+		if err := batcher.Close(ctx); err != nil {
+			i.logger.Errorf("Unable to close batcher: %v", err)
+		}
+		_ = db.Close()
+		i.stopSig.TriggerHasStopped()
+	}()
+
+	// Every batch is tracked with the position that is safe to resume from
+	// once it and all prior batches are delivered. Batches of a snapshot are
+	// tracked with an empty position, apart from the last.
+	cp := checkpoint.NewCapped[string](int64(i.checkpointLimit))
+
+	if position == "" {
+		var err error
+		if position, err = source.currentPosition(ctx); err != nil {
+			i.logger.Errorf("Failed to read current position: %v", err)
+			i.stopSig.TriggerSoftStop()
+			return
+		}
+		if i.streamSnapshot {
+			if err := i.snapshot(ctx, db, source, batcher, cp, position); err != nil {
+				i.logger.Errorf("Failed to stream snapshot: %v", err)
+				i.stopSig.TriggerSoftStop()
+				return
+			}
+		}
+		if err := i.flush(ctx, batcher, cp, position); err != nil {
+			i.logger.Debugf("Failed to flush batch: %v", err)
+			return
+		}
+	}
+
+	for !i.stopSig.IsSoftStopSignalled() {
+		next, err := source.readChanges(ctx, position, func(e cdcEvent) error {
+			msg := service.NewMessage(nil)
+			msg.SetStructuredMut(e.data)
+			msg.MetaSetMut("mode", cdcModeStreaming)
+			msg.MetaSetMut("table", e.table)
+			msg.MetaSetMut("operation", e.operation)
+			msg.MetaSetMut(i.positionKey, e.position)
+			if batcher.Add(msg) {
+				// Changes of a partially read poll can only be resumed from
+				// the position the poll started from.
+				return i.flush(ctx, batcher, cp, position)
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				i.logger.Errorf("Failed to read changes: %v", err)
+				i.stopSig.TriggerSoftStop()
+			}
+			return
+		}
+		if err := i.flush(ctx, batcher, cp, next); err != nil {
+			i.logger.Debugf("Failed to flush batch: %v", err)
+			return
+		}
+		position = next
+
+		select {
+		case <-time.After(i.pollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (i *cdcInput) snapshot(
+	ctx context.Context,
+	db *sql.DB,
+	source cdcSource,
+	batcher *service.Batcher,
+	cp *checkpoint.Capped[string],
+	position string,
+) error {
+	for _, table := range i.tables {
+		query, args, err := source.snapshotQuery(table, position)
+		if err != nil {
+			return err
+		}
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query table %v: %w", table, err)
+		}
+		for rows.Next() {
+			obj, err := sqlRowToMap(rows)
+			if err != nil {
+				_ = rows.Close()
+				return err
+			}
+			if conv, ok := source.(cdcSnapshotConverter); ok {
+				if err := conv.convertSnapshotRow(ctx, table, obj); err != nil {
+					_ = rows.Close()
+					return err
+				}
+			}
+			msg := service.NewMessage(nil)
+			msg.SetStructuredMut(obj)
+			msg.MetaSetMut("mode", cdcModeSnapshot)
+			msg.MetaSetMut("table", table)
+			msg.MetaSetMut("operation", cdcOpInsert)
+			if batcher.Add(msg) {
+				if err := i.flush(ctx, batcher, cp, ""); err != nil {
+					_ = rows.Close()
+					return err
+				}
+			}
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush sends the batched messages, if any, tracked with the position that
+// may be checkpointed once they and all prior messages are delivered.
+func (i *cdcInput) flush(ctx context.Context, batcher *service.Batcher, cp *checkpoint.Capped[string], position string) error {
+	batch, err := batcher.Flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	release, err := cp.Track(ctx, position, int64(len(batch)))
+	if err != nil {
+		return err
+	}
+
+	ackFn := func(ctx context.Context, _ error) error {
+		// Nacks are handled by AutoRetryNacks, and so this is only called
+		// once the batch has been delivered.
+		i.storeMut.Lock()
+		defer i.storeMut.Unlock()
+
+		highest := release()
+		if highest == nil || *highest == "" || *highest == i.lastStored {
+			return nil
+		}
+		if err := i.storeCheckpoint(ctx, *highest); err != nil {
+			return fmt.Errorf("unable to store checkpoint: %w", err)
+		}
+		i.lastStored = *highest
+		return nil
+	}
+
+	if len(batch) == 0 {
+		// Nothing to deliver, but the position may still be checkpointed
+		// once all prior messages are delivered.
+		return ackFn(ctx, nil)
+	}
+
+	select {
+	case i.msgChan <- cdcAsyncMessage{msg: batch, ackFn: ackFn}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (i *cdcInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	select {
+	case m := <-i.msgChan:
+		return m.msg, m.ackFn, nil
+	case <-i.stopSig.HasStoppedChan():
+		return nil, nil, service.ErrNotConnected
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (i *cdcInput) Close(ctx context.Context) error {
+	i.stopSig.TriggerSoftStop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(cdcShutdownTimeout):
+	case <-i.stopSig.HasStoppedChan():
+	}
+	i.stopSig.TriggerHardStop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(cdcShutdownTimeout):
+	case <-i.stopSig.HasStoppedChan():
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+// fakeCDCSource emits changes that are added to it, where the position of
+// each change is its index plus one.
+type fakeCDCSource struct {
+	mut     sync.Mutex
+	changes []cdcEvent
+}
+
+func (f *fakeCDCSource) add(table, operation string, data map[string]any) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.changes = append(f.changes, cdcEvent{
+		table:     table,
+		operation: operation,
+		position:  strconv.Itoa(len(f.changes) + 1),
+		data:      data,
+	})
+}
+
+func (f *fakeCDCSource) currentPosition(context.Context) (string, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return strconv.Itoa(len(f.changes)), nil
+}
+
+func (f *fakeCDCSource) snapshotQuery(table, _ string) (string, []any, error) {
+	return fmt.Sprintf("SELECT * FROM %v ORDER BY id", table), nil, nil
+}
+
+func (f *fakeCDCSource) readChanges(_ context.Context, from string, fn func(cdcEvent) error) (string, error) {
+	f.mut.Lock()
+	changes := f.changes
+	f.mut.Unlock()
+
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range changes[start:] {
+		if err := fn(c); err != nil {
+			return "", err
+		}
+	}
+	return strconv.Itoa(len(changes)), nil
+}
+
+func testCDCInput(t *testing.T, dsn string, mgr *service.Resources, source cdcSource, snapshot bool) *cdcInput {
+	t.Helper()
+
+	conf, err := service.NewConfigSpec().Fields(cdcFields("", "", "fake_cdc")...).ParseYAML(fmt.Sprintf(`
+dsn: %v
+tables: [ main.foo ]
+stream_snapshot: %v
+poll_interval: 10ms
+checkpoint_cache: checkpoints
+`, dsn, snapshot), nil)
+	require.NoError(t, err)
+
+	i, err := cdcInputFromParsed(conf, mgr, "sqlite", "lsn", func(*sql.DB, []string) cdcSource {
+		return source
+	})
+	require.NoError(t, err)
+	return i
+}
+
+type cdcTestMessage struct {
+	mode, operation, position string
+	data                      any
+}
+
+func readCDCMessages(t *testing.T, i *cdcInput, n int) []cdcTestMessage {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+
+	var msgs []cdcTestMessage
+	for len(msgs) < n {
+		batch, ackFn, err := i.ReadBatch(ctx)
+		require.NoError(t, err)
+		for _, msg := range batch {
+			m := cdcTestMessage{}
+			m.mode, _ = msg.MetaGet("mode")
+			m.operation, _ = msg.MetaGet("operation")
+			m.position, _ = msg.MetaGet("lsn")
+			m.data, err = msg.AsStructured()
+			require.NoError(t, err)
+			msgs = append(msgs, m)
+		}
+		require.NoError(t, ackFn(ctx, nil))
+	}
+	return msgs
+}
+
+func TestCDCInputSnapshotAndCheckpoints(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(`CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO foo VALUES (1, 'a'), (2, 'b');`)
+	require.NoError(t, err)
+
+	mgr := service.MockResources(service.MockResourcesOptAddCache("checkpoints"))
+	source := &fakeCDCSource{}
+	source.add("main.foo", cdcOpInsert, map[string]any{"id": 1, "name": "a"})
+	source.add("main.foo", cdcOpInsert, map[string]any{"id": 2, "name": "b"})
+
+	ctx := context.Background()
+	i := testCDCInput(t, dsn, mgr, source, true)
+	require.NoError(t, i.Connect(ctx))
+
+	assert.Equal(t, []cdcTestMessage{
+		{mode: "snapshot", operation: "insert", data: map[string]any{"id": int64(1), "name": "a"}},
+		{mode: "snapshot", operation: "insert", data: map[string]any{"id": int64(2), "name": "b"}},
+	}, readCDCMessages(t, i, 2))
+
+	source.add("main.foo", cdcOpUpdate, map[string]any{"id": 2, "name": "c"})
+
+	assert.Equal(t, []cdcTestMessage{
+		{mode: "streaming", operation: "update", position: "3", data: map[string]any{"id": 2, "name": "c"}},
+	}, readCDCMessages(t, i, 1))
+
+	assert.Eventually(t, func() bool {
+		var position []byte
+		_ = mgr.AccessCache(ctx, "checkpoints", func(c service.Cache) {
+			position, _ = c.Get(ctx, "fake_cdc")
+		})
+		return string(position) == "3"
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, i.Close(ctx))
+
+	// Restarting resumes from the checkpoint without another snapshot.
+	source.add("main.foo", cdcOpDelete, map[string]any{"id": 1})
+
+	i = testCDCInput(t, dsn, mgr, source, true)
+	require.NoError(t, i.Connect(ctx))
+	t.Cleanup(func() { _ = i.Close(ctx) })
+
+	assert.Equal(t, []cdcTestMessage{
+		{mode: "streaming", operation: "delete", position: "4", data: map[string]any{"id": 1}},
+	}, readCDCMessages(t, i, 1))
+}
+
+func TestCDCInputWithoutSnapshot(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	mgr := service.MockResources(service.MockResourcesOptAddCache("checkpoints"))
+	source := &fakeCDCSource{}
+	source.add("main.foo", cdcOpInsert, map[string]any{"id": 1})
+
+	ctx := context.Background()
+	i := testCDCInput(t, dsn, mgr, source, false)
+	require.NoError(t, i.Connect(ctx))
+	t.Cleanup(func() { _ = i.Close(ctx) })
+
+	// The starting position is checkpointed straight away.
+	assert.Eventually(t, func() bool {
+		var position []byte
+		_ = mgr.AccessCache(ctx, "checkpoints", func(c service.Cache) {
+			position, _ = c.Get(ctx, "fake_cdc")
+		})
+		return string(position) == "1"
+	}, time.Second, 10*time.Millisecond)
+
+	source.add("main.foo", cdcOpInsert, map[string]any{"id": 2})
+
+	assert.Equal(t, []cdcTestMessage{
+		{mode: "streaming", operation: "insert", position: "2", data: map[string]any{"id": 2}},
+	}, readCDCMessages(t, i, 1))
+}
+
+func TestCDCInputUnqualifiedTable(t *testing.T) {
+	spec := service.NewConfigSpec().Fields(cdcFields("", "", "fake_cdc")...)
+	mgr := service.MockResources(service.MockResourcesOptAddCache("checkpoints"))
+
+	for _, table := range []string{"foo", "a.b.c", ".foo"} {
+		conf, err := spec.ParseYAML(fmt.Sprintf(`
+dsn: foo
+tables: [ %q ]
+checkpoint_cache: checkpoints
+`, table), nil)
+		require.NoError(t, err)
+
+		_, err = cdcInputFromParsed(conf, mgr, "sqlite", "lsn", func(*sql.DB, []string) cdcSource {
+			return &fakeCDCSource{}
+		})
+		require.Error(t, err, table)
+	}
+}
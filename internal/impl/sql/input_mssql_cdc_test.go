@@ -0,0 +1,248 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// testLSN returns a log sequence number in the ten byte binary form used by
+// SQL Server.
+func testLSN(n uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{0, 0}, n)
+}
+
+type fakeMSSQLChange struct {
+	lsn, seq uint64
+	op       int64
+	id       int64
+}
+
+// fakeMSSQLCDC is a database/sql connector that answers the queries of the
+// mssql_cdc input from fixed change tables, in the same way as the change
+// data capture functions of SQL Server.
+type fakeMSSQLCDC struct {
+	maxLSN  uint64
+	minLSNs map[string]uint64
+	changes map[string][]fakeMSSQLChange
+}
+
+func (f *fakeMSSQLCDC) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakeMSSQLCDC) Driver() driver.Driver                        { return nil }
+
+func (f *fakeMSSQLCDC) Prepare(query string) (driver.Stmt, error) {
+	return &fakeMSSQLStmt{f: f, query: query}, nil
+}
+func (f *fakeMSSQLCDC) Close() error              { return nil }
+func (f *fakeMSSQLCDC) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeMSSQLStmt struct {
+	f     *fakeMSSQLCDC
+	query string
+}
+
+func (s *fakeMSSQLStmt) Close() error  { return nil }
+func (s *fakeMSSQLStmt) NumInput() int { return -1 }
+
+func (s *fakeMSSQLStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *fakeMSSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	single := func(v driver.Value) (driver.Rows, error) {
+		return &fakeMSSQLRows{columns: []string{""}, values: [][]driver.Value{{v}}}, nil
+	}
+	switch {
+	case s.query == "SELECT sys.fn_cdc_get_max_lsn()":
+		return single(testLSN(s.f.maxLSN))
+	case strings.HasPrefix(s.query, "SELECT sys.fn_cdc_increment_lsn("):
+		return single(testLSN(binary.BigEndian.Uint64(args[0].([]byte)[2:]) + 1))
+	case strings.HasPrefix(s.query, "SELECT sys.fn_cdc_get_min_lsn("):
+		return single(testLSN(s.f.minLSNs[args[0].(string)]))
+	case strings.HasPrefix(s.query, "SELECT TOP 1 capture_instance FROM cdc.change_tables"):
+		return single(strings.ReplaceAll(args[0].(string), ".", "_"))
+	}
+
+	instance, isChanges := strings.CutPrefix(s.query, "SELECT * FROM cdc.fn_cdc_get_all_changes_")
+	if !isChanges {
+		return nil, fmt.Errorf("unexpected query: %v", s.query)
+	}
+	instance, _, _ = strings.Cut(instance, "(")
+
+	// SQL Server rejects ranges that begin before the minimum LSN of the
+	// capture instance or end after the maximum LSN.
+	lower, upper := args[0].([]byte), args[1].([]byte)
+	if bytes.Compare(lower, testLSN(s.f.minLSNs[instance])) < 0 || bytes.Compare(upper, testLSN(s.f.maxLSN)) > 0 {
+		return nil, errors.New("an insufficient number of arguments were supplied for the procedure or function cdc.fn_cdc_get_all_changes_")
+	}
+
+	rows := &fakeMSSQLRows{columns: []string{"__$start_lsn", "__$end_lsn", "__$seqval", "__$operation", "__$update_mask", "id"}}
+	for _, c := range s.f.changes[instance] {
+		lsn := testLSN(c.lsn)
+		if bytes.Compare(lsn, lower) < 0 || bytes.Compare(lsn, upper) > 0 {
+			continue
+		}
+		rows.values = append(rows.values, []driver.Value{lsn, nil, testLSN(c.seq), c.op, []byte{0x01}, c.id})
+	}
+	return rows, nil
+}
+
+type fakeMSSQLRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeMSSQLRows) Columns() []string { return r.columns }
+func (r *fakeMSSQLRows) Close() error      { return nil }
+
+func (r *fakeMSSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func testMSSQLCDCSource(t *testing.T, f *fakeMSSQLCDC, tables ...string) *mssqlCDCSource {
+	t.Helper()
+
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { _ = db.Close() })
+	return &mssqlCDCSource{db: db, tables: tables, logger: service.MockResources().Logger()}
+}
+
+func readMSSQLChanges(t *testing.T, m *mssqlCDCSource, from uint64) (events []cdcEvent, position string) {
+	t.Helper()
+
+	position, err := m.readChanges(context.Background(), hex.EncodeToString(testLSN(from)), func(e cdcEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	require.NoError(t, err)
+	return
+}
+
+func TestMSSQLChangesNext(t *testing.T) {
+	m := testMSSQLCDCSource(t, &fakeMSSQLCDC{
+		maxLSN: 10,
+		changes: map[string][]fakeMSSQLChange{
+			"dbo_foo": {
+				{lsn: 1, seq: 1, op: 2, id: 1},
+				{lsn: 2, seq: 1, op: 3, id: 1},
+				{lsn: 2, seq: 1, op: 4, id: 1},
+				{lsn: 3, seq: 1, op: 1, id: 1},
+			},
+		},
+	})
+
+	rows, err := m.db.Query("SELECT * FROM cdc.fn_cdc_get_all_changes_dbo_foo(?, ?, N'all')", testLSN(1), testLSN(10))
+	require.NoError(t, err)
+
+	c := &mssqlChanges{table: "dbo.foo", rows: rows}
+	var events []cdcEvent
+	for {
+		require.NoError(t, c.next())
+		if c.head == nil {
+			break
+		}
+		events = append(events, *c.head)
+	}
+
+	// Prior values of updates are skipped, and the change table columns are
+	// removed from the data.
+	assert.Equal(t, []cdcEvent{
+		{table: "dbo.foo", operation: cdcOpInsert, position: hex.EncodeToString(testLSN(1)), data: map[string]any{"id": int64(1)}},
+		{table: "dbo.foo", operation: cdcOpUpdate, position: hex.EncodeToString(testLSN(2)), data: map[string]any{"id": int64(1)}},
+		{table: "dbo.foo", operation: cdcOpDelete, position: hex.EncodeToString(testLSN(3)), data: map[string]any{"id": int64(1)}},
+	}, events)
+}
+
+func TestMSSQLCDCReadChangesMerge(t *testing.T) {
+	m := testMSSQLCDCSource(t, &fakeMSSQLCDC{
+		maxLSN: 10,
+		changes: map[string][]fakeMSSQLChange{
+			"dbo_foo": {
+				{lsn: 1, seq: 1, op: 2, id: 1},
+				{lsn: 3, seq: 2, op: 2, id: 3},
+				{lsn: 5, seq: 1, op: 2, id: 5},
+			},
+			"dbo_bar": {
+				{lsn: 2, seq: 1, op: 2, id: 2},
+				{lsn: 3, seq: 1, op: 2, id: 3},
+				{lsn: 4, seq: 1, op: 2, id: 4},
+			},
+		},
+	}, "dbo.foo", "dbo.bar")
+
+	// Changes are read after the position, and the changes of all tables are
+	// emitted in the order of their LSN and sequence value.
+	events, position := readMSSQLChanges(t, m, 1)
+	assert.Equal(t, hex.EncodeToString(testLSN(10)), position)
+
+	var order []string
+	for _, e := range events {
+		order = append(order, fmt.Sprintf("%v:%v", e.table, e.data["id"]))
+	}
+	assert.Equal(t, []string{"dbo.bar:2", "dbo.bar:3", "dbo.foo:3", "dbo.bar:4", "dbo.foo:5"}, order)
+
+	// Nothing is read when already at the maximum LSN.
+	events, position = readMSSQLChanges(t, m, 10)
+	assert.Empty(t, events)
+	assert.Equal(t, hex.EncodeToString(testLSN(10)), position)
+}
+
+func TestMSSQLCDCReadChangesMinLSN(t *testing.T) {
+	m := testMSSQLCDCSource(t, &fakeMSSQLCDC{
+		maxLSN: 10,
+		minLSNs: map[string]uint64{
+			"dbo_foo": 4,
+			"dbo_bar": 11,
+		},
+		changes: map[string][]fakeMSSQLChange{
+			"dbo_foo": {
+				{lsn: 4, seq: 1, op: 2, id: 4},
+				{lsn: 6, seq: 1, op: 2, id: 6},
+			},
+		},
+	}, "dbo.foo", "dbo.bar")
+
+	// Changes of dbo.foo prior to its minimum LSN have been cleaned up, and so
+	// reading resumes from it, whereas dbo.bar has no changes within the range
+	// at all.
+	events, position := readMSSQLChanges(t, m, 1)
+	assert.Equal(t, hex.EncodeToString(testLSN(10)), position)
+
+	var ids []any
+	for _, e := range events {
+		ids = append(ids, e.data["id"])
+	}
+	assert.Equal(t, []any{int64(4), int64(6)}, ids)
+}
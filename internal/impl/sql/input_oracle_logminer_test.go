@@ -0,0 +1,110 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedoSQL(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		stmt string
+		exp  map[string]any
+	}{
+		{
+			name: "insert",
+			stmt: `insert into "FOO"."BAR"("ID","NAME","NOTE","CREATED") values ('1','it''s',NULL,TO_DATE('2024-01-02 03:04:05', 'YYYY-MM-DD HH24:MI:SS'));`,
+			exp:  map[string]any{"ID": "1", "NAME": "it's", "NOTE": nil, "CREATED": "2024-01-02 03:04:05"},
+		},
+		{
+			name: "insert empty lob",
+			stmt: `insert into "FOO"."BAR"("ID","DOC") values ('1',EMPTY_CLOB());`,
+			exp:  map[string]any{"ID": "1", "DOC": ""},
+		},
+		{
+			name: "update",
+			stmt: `update "FOO"."BAR" set "NAME" = 'b', "NOTE" = NULL where "ID" = '1' and "NAME" = 'a' and "NOTE" = 'x' and ROWID = 'AAAR3sAAEAAAACXAAA';`,
+			exp:  map[string]any{"ID": "1", "NAME": "b", "NOTE": nil},
+		},
+		{
+			name: "delete",
+			stmt: `delete from "FOO"."BAR" where "ID" = '1' and "NAME" IS NULL and ROWID = 'AAAR3sAAEAAAACXAAA';`,
+			exp:  map[string]any{"ID": "1", "NAME": nil},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			obj, err := parseRedoSQL(test.stmt)
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, obj)
+		})
+	}
+
+	_, err := parseRedoSQL(`insert into "FOO"."BAR"("ID") values ('1`)
+	require.Error(t, err)
+
+	_, err = parseRedoSQL(`alter table "FOO"."BAR" add "BAZ" NUMBER;`)
+	require.Error(t, err)
+}
+
+func TestOraclePosition(t *testing.T) {
+	p, err := parseOraclePosition("1234:1200")
+	require.NoError(t, err)
+	assert.Equal(t, oraclePosition{commitSCN: 1234, startSCN: 1200}, p)
+	assert.Equal(t, "1234:1200", p.String())
+
+	_, err = parseOraclePosition("1234")
+	require.Error(t, err)
+}
+
+func TestOracleValue(t *testing.T) {
+	for _, test := range []struct {
+		dataType string
+		value    any
+		exp      any
+	}{
+		{dataType: "NUMBER", value: "42", exp: int64(42)},
+		{dataType: "NUMBER", value: "-1.5", exp: -1.5},
+		{dataType: "NUMBER", value: ".25", exp: 0.25},
+		{dataType: "NUMBER", value: "123456789012345678901234567890", exp: 1.2345678901234568e+29},
+		{dataType: "BINARY_DOUBLE", value: "1.5E+000", exp: 1.5},
+		{dataType: "NUMBER", value: nil, exp: nil},
+		{dataType: "BINARY_DOUBLE", value: 2.5, exp: 2.5},
+		{dataType: "DATE", value: "2024-01-02 03:04:05", exp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{dataType: "TIMESTAMP(6)", value: "2024-01-02 03:04:05.123000000", exp: time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)},
+		{dataType: "TIMESTAMP(6) WITH TIME ZONE", value: "2024-01-02 03:04:05.123000000 +01:00", exp: time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.FixedZone("", 3600))},
+		{dataType: "VARCHAR2", value: "42", exp: "42"},
+		{dataType: "", value: "42", exp: "42"},
+	} {
+		v, err := oracleValue(test.dataType, test.value)
+		require.NoError(t, err, test.dataType)
+		if exp, ok := test.exp.(time.Time); ok {
+			require.IsType(t, time.Time{}, v, test.dataType)
+			assert.True(t, exp.Equal(v.(time.Time)), "%v: %v", test.dataType, v)
+			continue
+		}
+		assert.Equal(t, test.exp, v, test.dataType)
+	}
+
+	_, err := oracleValue("NUMBER", "abc")
+	require.Error(t, err)
+
+	_, err = oracleValue("DATE", "02-JAN-24")
+	require.Error(t, err)
+}
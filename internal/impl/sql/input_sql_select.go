@@ -17,7 +17,9 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/Masterminds/squirrel"
@@ -33,7 +35,13 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 		Beta().
 		Categories("Services").
 		Summary("Executes a select query and creates a message for each row received.").
-		Description(`Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a xref:components:inputs/sequence.adoc[sequence] to execute).`).
+		Description(`Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a xref:components:inputs/sequence.adoc[sequence] to execute).
+
+== Polling
+
+When the ` + "`polling`" + ` field is set the input instead polls the table indefinitely for rows with a ` + "`cursor_column`" + ` value greater than that of the last row read, in the order of that column. The value of the last row acknowledged, where rows are acknowledged in order, is stored in a cache resource so that polling resumes from it after a restart.
+
+The cursor column should be monotonically increasing, such as an auto incremented ID or an update timestamp. When the cursor column is not unique a ` + "`key_column`" + `, such as the primary key, must also be set, otherwise rows that share a cursor value may be skipped when they are split across polls by the ` + "`limit`" + `. Rows are then ordered by both columns and the cursor tracks the values of both.`).
 		Field(driverField).
 		Field(dsnField).
 		Field(service.NewStringField("table").
@@ -60,7 +68,54 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 			Description("An optional suffix to append to the select query.").
 			Optional().
 			Advanced()).
-		Field(service.NewAutoRetryNacksToggleField())
+		Field(service.NewObjectField("polling",
+			service.NewStringField("cursor_column").
+				Description("The column to order rows by and track the progress of polling with.").
+				Example("id").
+				Example("updated_at"),
+			service.NewStringField("key_column").
+				Description("A unique column, such as the primary key, to order rows that share the same value of the `cursor_column` by. This must be set when the cursor column is not unique, so that no rows are skipped.").
+				Example("id").
+				Optional(),
+			service.NewDurationField("interval").
+				Description("The period of time to wait between polls once all available rows have been read.").
+				Default("10s"),
+			service.NewIntField("limit").
+				Description("The maximum number of rows to select in a single query.").
+				Default(1000).
+				Advanced(),
+			service.NewStringField("cache").
+				Description("A cache resource to store the cursor value of the last acknowledged row in."),
+			service.NewStringField("cache_key").
+				Description("The key to store the cursor value with. Defaults to the name of the table and cursor column.").
+				Optional().
+				Advanced(),
+		).
+			Description("Continuously polls the table for new rows instead of shutting down once the rows of a single query are exhausted.").
+			Optional().
+			Version("4.42.0")).
+		Field(service.NewObjectField("partitioning",
+			service.NewStringField("column").
+				Description("A numeric or timestamp column to split the table into ranges by. The bounds of the ranges are determined by selecting the minimum and maximum values of the column, and therefore the column should ideally be indexed.").
+				Example("id").
+				Example("created_at"),
+			service.NewIntField("partitions").
+				Description("The number of ranges to split the table into, each of which is read concurrently with its own connection.").
+				Default(4),
+			service.NewStringField("cache").
+				Description("An optional cache resource to store the bounds of the ranges, and which of them have been completely read and acknowledged, in. When set an interrupted export resumes by reading only the ranges that were not completed.").
+				Optional(),
+			service.NewStringField("cache_key").
+				Description("The key to store the state of the ranges with. Defaults to the name of the table and partition column.").
+				Optional().
+				Advanced(),
+		).
+			Description("Reads the table as a number of ranges concurrently, which is useful for exporting large tables. Rows are not read in any particular order, and once all ranges have been read the input shuts down. A range is only completed once all of its rows have been acknowledged successfully, and should the query of a range fail part way through then the range is read again from the start, in which case rows of it may be delivered more than once. When resuming from a cache the ranges of the interrupted export are reused, and therefore rows added since then beyond the maximum value are not read, and once all ranges are completed the key must be deleted from the cache in order to export the table again.").
+			Optional().
+			Advanced().
+			Version("4.42.0")).
+		Field(service.NewAutoRetryNacksToggleField()).
+		Field(validateOnConnectField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -68,6 +123,7 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 
 	spec = spec.
 		Version("3.59.0").
+		LintRule(`root = if this.exists("polling") && this.exists("partitioning") { [ "the fields polling and partitioning cannot be set simultaneously" ] }`).
 		Example("Consume a Table (PostgreSQL)",
 			`
 Here we define a pipeline that will consume all rows from a table created within the last hour by comparing the unix timestamp stored in the row column "created_at":`,
@@ -83,6 +139,27 @@ input:
       root = [
         now().ts_unix() - 3600
       ]
+`,
+		).
+		Example("Poll for New Rows (MySQL)",
+			`
+Here we continuously consume new rows of a table in the order of their auto incremented ID, storing the ID of the last row delivered in a Redis cache:`,
+			`
+input:
+  sql_select:
+    driver: mysql
+    dsn: foouser:foopassword@tcp(localhost:3306)/foodb
+    table: orders
+    columns: [ '*' ]
+    polling:
+      cursor_column: id
+      interval: 5s
+      cache: cursors
+
+cache_resources:
+  - label: cursors
+    redis:
+      url: tcp://localhost:6379
 `,
 		)
 	return spec
@@ -113,17 +190,25 @@ type sqlSelectInput struct {
 	builder squirrel.SelectBuilder
 	dbMut   sync.Mutex
 
+	table       string
 	where       string
+	suffix      string
 	argsMapping *bloblang.Executor
 
+	polling      *sqlSelectPolling
+	partitioning *sqlSelectPartitioning
+
+	validation   *connectValidation
 	connSettings *connSettings
 
+	mgr     *service.Resources
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
 }
 
 func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*sqlSelectInput, error) {
 	s := &sqlSelectInput{
+		mgr:     mgr,
 		logger:  mgr.Logger(),
 		shutSig: shutdown.NewSignaller(),
 	}
@@ -138,8 +223,7 @@ func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resour
 		return nil, err
 	}
 
-	tableStr, err := conf.FieldString("table")
-	if err != nil {
+	if s.table, err = conf.FieldString("table"); err != nil {
 		return nil, err
 	}
 
@@ -160,12 +244,7 @@ func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resour
 		}
 	}
 
-	s.builder = squirrel.Select(columns...).From(tableStr)
-	if s.driver == "postgres" || s.driver == "clickhouse" {
-		s.builder = s.builder.PlaceholderFormat(squirrel.Dollar)
-	} else if s.driver == "oracle" || s.driver == "gocosmos" {
-		s.builder = s.builder.PlaceholderFormat(squirrel.Colon)
-	}
+	s.builder = withPlaceholderFormat(s.driver, squirrel.Select(columns...).From(s.table))
 
 	if conf.Contains("prefix") {
 		prefixStr, err := conf.FieldString("prefix")
@@ -176,11 +255,48 @@ func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resour
 	}
 
 	if conf.Contains("suffix") {
-		suffixStr, err := conf.FieldString("suffix")
-		if err != nil {
+		if s.suffix, err = conf.FieldString("suffix"); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Contains("polling") {
+		if s.polling, err = sqlSelectPollingFromParsed(conf.Namespace("polling"), s.driver, s.table); err != nil {
 			return nil, err
 		}
-		s.builder = s.builder.Suffix(suffixStr)
+	}
+
+	if conf.Contains("partitioning") {
+		if s.polling != nil {
+			return nil, errors.New("the fields polling and partitioning cannot be set simultaneously")
+		}
+		if s.partitioning, err = sqlSelectPartitioningFromParsed(conf.Namespace("partitioning"), s.table); err != nil {
+			return nil, err
+		}
+	}
+
+	validateColumns := columns
+	if s.polling != nil {
+		validateColumns = append(slices.Clone(columns), s.polling.cursorColumn)
+		if s.polling.keyColumn != "" {
+			validateColumns = append(validateColumns, s.polling.keyColumn)
+		}
+	} else if s.partitioning != nil {
+		validateColumns = append(slices.Clone(columns), s.partitioning.column)
+	}
+	validateBuilder := s.builder
+	if s.where != "" {
+		validateBuilder = validateBuilder.Where(s.where)
+	}
+	if s.suffix != "" {
+		validateBuilder = validateBuilder.Suffix(s.suffix)
+	}
+	validateQuery, _, err := validateBuilder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if s.validation, err = connectValidationFromParsed(conf, s.table, validateColumns, validateQuery); err != nil {
+		return nil, err
 	}
 
 	if s.connSettings, err = connSettingsFromParsed(conf, mgr); err != nil {
@@ -209,37 +325,45 @@ func (s *sqlSelectInput) Connect(ctx context.Context) (err error) {
 
 	s.connSettings.apply(ctx, db, s.logger)
 
-	var args []any
-	if s.argsMapping != nil {
-		var iargs any
-		if iargs, err = s.argsMapping.Query(nil); err != nil {
+	if s.validation != nil {
+		if err = s.validation.check(ctx, db); err != nil {
 			return
 		}
+	}
 
-		var ok bool
-		if args, ok = iargs.([]any); !ok {
-			err = fmt.Errorf("mapping returned non-array result: %T", iargs)
+	if s.polling != nil {
+		// Rows are selected by Read, starting from the last acknowledged cursor.
+		if err = s.polling.loadCursor(ctx, s.mgr); err != nil {
+			return
+		}
+	} else if s.partitioning != nil {
+		if err = s.startPartitioned(ctx, db); err != nil {
+			return
+		}
+	} else {
+		var queryBuilder squirrel.SelectBuilder
+		if queryBuilder, err = s.queryBuilder(); err != nil {
 			return
 		}
-	}
 
-	queryBuilder := s.builder
-	if s.where != "" {
-		queryBuilder = queryBuilder.Where(s.where, args...)
-	}
-	var rows *sql.Rows
-	if rows, err = queryBuilder.RunWith(db).Query(); err != nil {
-		return
-	} else if err = rows.Err(); err != nil {
-		s.logger.With("err", err).Warn("unexpected error while execute raw select")
+		var rows *sql.Rows
+		if rows, err = queryBuilder.RunWith(db).Query(); err != nil {
+			return
+		} else if err = rows.Err(); err != nil {
+			s.logger.With("err", err).Warn("unexpected error while execute raw select")
+		}
+		s.rows = rows
 	}
 
 	s.db = db
-	s.rows = rows
 
 	go func() {
 		<-s.shutSig.HardStopChan()
 
+		if s.partitioning != nil {
+			s.partitioning.wg.Wait()
+		}
+
 		s.dbMut.Lock()
 		if s.rows != nil {
 			_ = s.rows.Close()
@@ -255,6 +379,56 @@ func (s *sqlSelectInput) Connect(ctx context.Context) (err error) {
 	return nil
 }
 
+// withPlaceholderFormat sets the placeholder format of a select query to the
+// one used by a driver.
+func withPlaceholderFormat(driver string, b squirrel.SelectBuilder) squirrel.SelectBuilder {
+	if driver == "postgres" || driver == "clickhouse" {
+		return b.PlaceholderFormat(squirrel.Dollar)
+	} else if driver == "oracle" || driver == "gocosmos" {
+		return b.PlaceholderFormat(squirrel.Colon)
+	}
+	return b
+}
+
+// whereArgs returns the arguments of the where clause.
+func (s *sqlSelectInput) whereArgs() ([]any, error) {
+	if s.argsMapping == nil {
+		return nil, nil
+	}
+
+	iargs, err := s.argsMapping.Query(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	args, ok := iargs.([]any)
+	if !ok {
+		return nil, fmt.Errorf("mapping returned non-array result: %T", iargs)
+	}
+	return args, nil
+}
+
+// queryBuilder returns the select query with the where clause and arguments
+// applied.
+func (s *sqlSelectInput) queryBuilder() (squirrel.SelectBuilder, error) {
+	args, err := s.whereArgs()
+	if err != nil {
+		return s.builder, err
+	}
+
+	queryBuilder := s.builder
+	if s.where != "" {
+		queryBuilder = queryBuilder.Where(s.where, args...)
+	}
+	if s.polling != nil {
+		queryBuilder = s.polling.apply(queryBuilder)
+	}
+	if s.suffix != "" {
+		queryBuilder = queryBuilder.Suffix(s.suffix)
+	}
+	return queryBuilder, nil
+}
+
 func (s *sqlSelectInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
 	s.dbMut.Lock()
 	defer s.dbMut.Unlock()
@@ -263,6 +437,13 @@ func (s *sqlSelectInput) Read(ctx context.Context) (*service.Message, service.Ac
 		return nil, nil, service.ErrNotConnected
 	}
 
+	if s.polling != nil {
+		return s.readPolling(ctx)
+	}
+	if s.partitioning != nil {
+		return s.readPartitioned(ctx)
+	}
+
 	if s.rows == nil {
 		return nil, nil, service.ErrEndOfInput
 	}
@@ -0,0 +1,461 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// partitionBounds describes the range of values of a partition column, which
+// are either all integers, floats or timestamps.
+type partitionBounds struct {
+	Kind       string          `json:"kind"`
+	Min        json.RawMessage `json:"min"`
+	Max        json.RawMessage `json:"max"`
+	Partitions int             `json:"partitions"`
+	Completed  []int           `json:"completed"`
+}
+
+// partitionRange is the range of values read by a single partition, where the
+// upper bound is inclusive for the last partition only.
+type partitionRange struct {
+	index          int
+	lower, upper   any
+	upperInclusive bool
+}
+
+// sqlSelectPartitioning reads the ranges of a table concurrently, and stores
+// the ranges that have been completely read and acknowledged in a cache.
+type sqlSelectPartitioning struct {
+	column     string
+	partitions int
+	cache      string
+	cacheKey   string
+
+	bounds *partitionBounds
+	mut    sync.Mutex
+
+	msgChan chan partitionedRow
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+}
+
+type partitionedRow struct {
+	msg   *service.Message
+	ackFn service.AckFunc
+}
+
+func sqlSelectPartitioningFromParsed(conf *service.ParsedConfig, table string) (p *sqlSelectPartitioning, err error) {
+	p = &sqlSelectPartitioning{}
+	if p.column, err = conf.FieldString("column"); err != nil {
+		return
+	}
+	if p.partitions, err = conf.FieldInt("partitions"); err != nil {
+		return
+	}
+	if p.partitions <= 0 {
+		return nil, errors.New("the number of partitions must be greater than zero")
+	}
+	if conf.Contains("cache") {
+		if p.cache, err = conf.FieldString("cache"); err != nil {
+			return
+		}
+	}
+	p.cacheKey = "sql_select_" + table + "_" + p.column + "_partitions"
+	if conf.Contains("cache_key") {
+		if p.cacheKey, err = conf.FieldString("cache_key"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// partitionValue converts a MIN or MAX value of a partition column into an
+// int64, float64 or time.Time.
+func partitionValue(v any) (any, error) {
+	switch t := v.(type) {
+	case int64, float64, time.Time:
+		return t, nil
+	case int:
+		return int64(t), nil
+	case int32:
+		return int64(t), nil
+	case uint32:
+		return int64(t), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return nil, fmt.Errorf("partition column value %v overflows int64", t)
+		}
+		return int64(t), nil
+	case float32:
+		return float64(t), nil
+	case []byte:
+		return partitionValue(string(t))
+	case string:
+		if i, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return f, nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if ts, err := time.Parse(layout, t); err == nil {
+				return ts, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("partition column values must be numbers or timestamps, got %T", v)
+}
+
+func newPartitionBounds(minV, maxV any, partitions int) (*partitionBounds, error) {
+	b := &partitionBounds{Partitions: partitions, Completed: []int{}}
+
+	var err error
+	if minV, err = partitionValue(minV); err != nil {
+		return nil, err
+	}
+	if maxV, err = partitionValue(maxV); err != nil {
+		return nil, err
+	}
+
+	switch minT := minV.(type) {
+	case int64:
+		if _, ok := maxV.(int64); !ok {
+			b.Kind = "float"
+			minV = float64(minT)
+		} else {
+			b.Kind = "int"
+		}
+	case float64:
+		b.Kind = "float"
+	case time.Time:
+		b.Kind = "time"
+	}
+	if b.Kind == "float" {
+		if maxI, ok := maxV.(int64); ok {
+			maxV = float64(maxI)
+		}
+	}
+
+	if b.Min, err = json.Marshal(minV); err != nil {
+		return nil, err
+	}
+	if b.Max, err = json.Marshal(maxV); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *partitionBounds) decode(raw json.RawMessage) (any, error) {
+	switch b.Kind {
+	case "int":
+		var i int64
+		err := json.Unmarshal(raw, &i)
+		return i, err
+	case "float":
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return f, err
+	case "time":
+		var t time.Time
+		err := json.Unmarshal(raw, &t)
+		return t, err
+	}
+	return nil, fmt.Errorf("unknown partition bounds kind: %v", b.Kind)
+}
+
+// ranges returns the ranges of each partition that hasn't yet been completed.
+func (b *partitionBounds) ranges() ([]partitionRange, error) {
+	minV, err := b.decode(b.Min)
+	if err != nil {
+		return nil, err
+	}
+	maxV, err := b.decode(b.Max)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := func(i int) any {
+		frac := float64(i) / float64(b.Partitions)
+		switch b.Kind {
+		case "int":
+			lo, hi := minV.(int64), maxV.(int64)
+			return lo + int64(math.Ceil(float64(hi-lo)*frac))
+		case "float":
+			lo, hi := minV.(float64), maxV.(float64)
+			return lo + (hi-lo)*frac
+		default:
+			lo, hi := minV.(time.Time), maxV.(time.Time)
+			return lo.Add(time.Duration(float64(hi.Sub(lo)) * frac))
+		}
+	}
+
+	var ranges []partitionRange
+	for i := 0; i < b.Partitions; i++ {
+		if b.isCompleted(i) {
+			continue
+		}
+		r := partitionRange{index: i, lower: bound(i), upper: bound(i + 1)}
+		if i == b.Partitions-1 {
+			r.upper, r.upperInclusive = maxV, true
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (b *partitionBounds) isCompleted(i int) bool {
+	for _, c := range b.Completed {
+		if c == i {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *sqlSelectPartitioning) loadBounds(ctx context.Context, mgr *service.Resources) (bool, error) {
+	if p.cache == "" {
+		return false, nil
+	}
+
+	var boundsBytes []byte
+	var cacheErr error
+	if err := mgr.AccessCache(ctx, p.cache, func(c service.Cache) {
+		boundsBytes, cacheErr = c.Get(ctx, p.cacheKey)
+	}); err != nil {
+		return false, err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return false, nil
+	}
+	if cacheErr != nil {
+		return false, cacheErr
+	}
+
+	var b partitionBounds
+	dec := json.NewDecoder(bytes.NewReader(boundsBytes))
+	if err := dec.Decode(&b); err != nil {
+		return false, fmt.Errorf("failed to parse stored partitions: %w", err)
+	}
+	p.bounds = &b
+	return true, nil
+}
+
+func (p *sqlSelectPartitioning) storeBounds(ctx context.Context, mgr *service.Resources) error {
+	if p.cache == "" {
+		return nil
+	}
+	boundsBytes, err := json.Marshal(p.bounds)
+	if err != nil {
+		return err
+	}
+	var setErr error
+	if err := mgr.AccessCache(ctx, p.cache, func(c service.Cache) {
+		setErr = c.Set(ctx, p.cacheKey, boundsBytes, nil)
+	}); err != nil {
+		return err
+	}
+	return setErr
+}
+
+// startPartitioned determines the ranges of each partition and begins reading
+// them concurrently.
+func (s *sqlSelectInput) startPartitioned(ctx context.Context, db *sql.DB) error {
+	p := s.partitioning
+
+	found, err := p.loadBounds(ctx, s.mgr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		var minV, maxV any
+		boundsBuilder := squirrel.Select(fmt.Sprintf("MIN(%v)", p.column), fmt.Sprintf("MAX(%v)", p.column)).From(s.table)
+		boundsBuilder = withPlaceholderFormat(s.driver, boundsBuilder)
+		if s.where != "" {
+			args, err := s.whereArgs()
+			if err != nil {
+				return err
+			}
+			boundsBuilder = boundsBuilder.Where(s.where, args...)
+		}
+		if err := boundsBuilder.RunWith(db).QueryRowContext(ctx).Scan(&minV, &maxV); err != nil {
+			return fmt.Errorf("failed to query partition bounds: %w", err)
+		}
+		if minV == nil || maxV == nil {
+			// There are no rows to read.
+			p.msgChan = make(chan partitionedRow)
+			close(p.msgChan)
+			return nil
+		}
+		if p.bounds, err = newPartitionBounds(minV, maxV, p.partitions); err != nil {
+			return err
+		}
+		if err := p.storeBounds(ctx, s.mgr); err != nil {
+			return err
+		}
+	}
+
+	ranges, err := p.bounds.ranges()
+	if err != nil {
+		return err
+	}
+
+	queryBuilder, err := s.queryBuilder()
+	if err != nil {
+		return err
+	}
+
+	var readCtx context.Context
+	readCtx, p.cancel = s.shutSig.HardStopCtx(context.Background())
+
+	p.msgChan = make(chan partitionedRow, len(ranges))
+	for _, r := range ranges {
+		rangeBuilder := queryBuilder.Where(squirrel.GtOrEq{p.column: r.lower})
+		if r.upperInclusive {
+			rangeBuilder = rangeBuilder.Where(squirrel.LtOrEq{p.column: r.upper})
+		} else {
+			rangeBuilder = rangeBuilder.Where(squirrel.Lt{p.column: r.upper})
+		}
+
+		p.wg.Add(1)
+		go func(r partitionRange, rangeBuilder squirrel.SelectBuilder) {
+			defer p.wg.Done()
+			s.readPartition(readCtx, db, r, rangeBuilder)
+		}(r, rangeBuilder)
+	}
+
+	go func() {
+		p.wg.Wait()
+		close(p.msgChan)
+	}()
+	return nil
+}
+
+// readPartition reads all rows of a partition range, retrying the range from
+// the start should the query fail, and marks the range as completed once every
+// row has been acknowledged successfully. Rows of a range that was retried may
+// therefore be delivered more than once.
+func (s *sqlSelectInput) readPartition(ctx context.Context, db *sql.DB, r partitionRange, rangeBuilder squirrel.SelectBuilder) {
+	p := s.partitioning
+
+	var pendingMut sync.Mutex
+	pending, finished, failed := 0, false, false
+	completeFn := func(ctx context.Context) error {
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		p.bounds.Completed = append(p.bounds.Completed, r.index)
+		s.logger.Debugf("Completed partition %v of table %v", r.index, s.table)
+		return p.storeBounds(ctx, s.mgr)
+	}
+
+	for {
+		err := s.readPartitionRows(ctx, db, rangeBuilder, func(msg *service.Message) bool {
+			pendingMut.Lock()
+			pending++
+			pendingMut.Unlock()
+
+			select {
+			case p.msgChan <- partitionedRow{
+				msg: msg,
+				ackFn: func(ctx context.Context, err error) error {
+					pendingMut.Lock()
+					pending--
+					if err != nil && !failed {
+						failed = true
+						s.logger.Warnf("Partition %v of table %v will not be marked as completed as a row was rejected: %v", r.index, s.table, err)
+					}
+					done := finished && pending == 0 && !failed
+					pendingMut.Unlock()
+					if done {
+						return completeFn(ctx)
+					}
+					return nil
+				},
+			}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Errorf("Failed to read partition %v of table %v, retrying: %v", r.index, s.table, err)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	pendingMut.Lock()
+	finished = true
+	done := pending == 0 && !failed
+	pendingMut.Unlock()
+	if done {
+		if err := completeFn(ctx); err != nil {
+			s.logger.Errorf("Failed to store completion of partition %v: %v", r.index, err)
+		}
+	}
+}
+
+func (s *sqlSelectInput) readPartitionRows(ctx context.Context, db *sql.DB, rangeBuilder squirrel.SelectBuilder, fn func(*service.Message) bool) error {
+	rows, err := rangeBuilder.RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		obj, err := sqlRowToMap(rows)
+		if err != nil {
+			return err
+		}
+		msg := service.NewMessage(nil)
+		msg.SetStructuredMut(obj)
+		if !fn(msg) {
+			return ctx.Err()
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlSelectInput) readPartitioned(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	select {
+	case row, open := <-s.partitioning.msgChan:
+		if !open {
+			return nil, nil, service.ErrEndOfInput
+		}
+		return row.msg, row.ackFn, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/checkpoint"
+	"github.com/Masterminds/squirrel"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// sqlSelectPolling tracks the cursor of a sql_select input that continuously
+// polls a table for new rows.
+type sqlSelectPolling struct {
+	driver       string
+	cursorColumn string
+	keyColumn    string
+	interval     time.Duration
+	limit        int
+	cache        string
+	cacheKey     string
+
+	checkpointer *checkpoint.Capped[polledCursor]
+
+	// Acknowledgements can arrive concurrently, storing the cursor is
+	// serialised so that an older cursor never overwrites a newer one.
+	storeMut   sync.Mutex
+	lastStored uint64
+	seq        uint64
+
+	// The cursor and key values of the last row read, which may not yet have
+	// been acknowledged.
+	cursor    any
+	key       any
+	hasCursor bool
+	rowsRead  int
+
+	warnedTies bool
+}
+
+// polledCursor is the cursor value of a row read along with the order in which
+// it was read.
+type polledCursor struct {
+	seq   uint64
+	value any
+}
+
+func sqlSelectPollingFromParsed(conf *service.ParsedConfig, driver, table string) (p *sqlSelectPolling, err error) {
+	p = &sqlSelectPolling{
+		driver:       driver,
+		checkpointer: checkpoint.NewCapped[polledCursor](1024),
+	}
+	if p.cursorColumn, err = conf.FieldString("cursor_column"); err != nil {
+		return
+	}
+	if conf.Contains("key_column") {
+		if p.keyColumn, err = conf.FieldString("key_column"); err != nil {
+			return
+		}
+	}
+	if p.interval, err = conf.FieldDuration("interval"); err != nil {
+		return
+	}
+	if p.limit, err = conf.FieldInt("limit"); err != nil {
+		return
+	}
+	if p.limit <= 0 {
+		return nil, errors.New("polling limit must be greater than zero")
+	}
+	if p.cache, err = conf.FieldString("cache"); err != nil {
+		return
+	}
+	p.cacheKey = "sql_select_" + table + "_" + p.cursorColumn
+	if conf.Contains("cache_key") {
+		if p.cacheKey, err = conf.FieldString("cache_key"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// apply adds the cursor condition, ordering and limit to a select query. With a
+// key column rows are ordered by both columns, and the condition is the
+// equivalent of (cursor_column, key_column) > (cursor, key), which is written
+// out as not all drivers support comparing row values.
+func (p *sqlSelectPolling) apply(b squirrel.SelectBuilder) squirrel.SelectBuilder {
+	if p.keyColumn == "" {
+		if p.hasCursor {
+			b = b.Where(squirrel.Gt{p.cursorColumn: p.cursor})
+		}
+		b = b.OrderBy(p.cursorColumn)
+	} else {
+		if p.hasCursor {
+			b = b.Where(squirrel.Or{
+				squirrel.Gt{p.cursorColumn: p.cursor},
+				squirrel.And{
+					squirrel.Eq{p.cursorColumn: p.cursor},
+					squirrel.Gt{p.keyColumn: p.key},
+				},
+			})
+		}
+		b = b.OrderBy(p.cursorColumn, p.keyColumn)
+	}
+	switch p.driver {
+	case "mssql":
+		b = b.Suffix(fmt.Sprintf("OFFSET 0 ROWS FETCH NEXT %v ROWS ONLY", p.limit))
+	case "oracle":
+		b = b.Suffix(fmt.Sprintf("FETCH FIRST %v ROWS ONLY", p.limit))
+	default:
+		b = b.Limit(uint64(p.limit))
+	}
+	return b
+}
+
+func (p *sqlSelectPolling) loadCursor(ctx context.Context, mgr *service.Resources) error {
+	var cursorBytes []byte
+	var cacheErr error
+	if err := mgr.AccessCache(ctx, p.cache, func(c service.Cache) {
+		cursorBytes, cacheErr = c.Get(ctx, p.cacheKey)
+	}); err != nil {
+		return err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return nil
+	}
+	if cacheErr != nil {
+		return cacheErr
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(cursorBytes))
+	dec.UseNumber()
+
+	var cursor any
+	if err := dec.Decode(&cursor); err != nil {
+		return fmt.Errorf("failed to parse stored cursor: %w", err)
+	}
+
+	// With a key column the cursor is stored as an array of the cursor and key
+	// values.
+	if p.keyColumn == "" {
+		cursor, err := storedCursorValue(cursor)
+		if err != nil {
+			return err
+		}
+		p.cursor = cursor
+	} else {
+		values, ok := cursor.([]any)
+		if !ok || len(values) != 2 {
+			return fmt.Errorf("failed to parse stored cursor: expected an array of the cursor and key values, got %s", cursorBytes)
+		}
+		var err error
+		if p.cursor, err = storedCursorValue(values[0]); err != nil {
+			return err
+		}
+		if p.key, err = storedCursorValue(values[1]); err != nil {
+			return err
+		}
+	}
+	p.hasCursor = true
+	return nil
+}
+
+func storedCursorValue(v any) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored cursor: %w", err)
+	}
+	return f, nil
+}
+
+func (p *sqlSelectPolling) storeCursor(ctx context.Context, mgr *service.Resources, cursor any) error {
+	cursorBytes, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	var setErr error
+	if err := mgr.AccessCache(ctx, p.cache, func(c service.Cache) {
+		setErr = c.Set(ctx, p.cacheKey, cursorBytes, nil)
+	}); err != nil {
+		return err
+	}
+	return setErr
+}
+
+func (s *sqlSelectInput) readPolling(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	p := s.polling
+	for {
+		if s.rows == nil {
+			queryBuilder, err := s.queryBuilder()
+			if err != nil {
+				return nil, nil, err
+			}
+			if s.rows, err = queryBuilder.RunWith(s.db).QueryContext(ctx); err != nil {
+				return nil, nil, err
+			}
+			p.rowsRead = 0
+		}
+
+		if s.rows.Next() {
+			break
+		}
+
+		err := s.rows.Err()
+		_ = s.rows.Close()
+		s.rows = nil
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Only wait for the next poll once we've caught up with the table.
+		if p.rowsRead < p.limit {
+			select {
+			case <-time.After(p.interval):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			case <-s.shutSig.HardStopChan():
+				return nil, nil, service.ErrEndOfInput
+			}
+		}
+	}
+
+	obj, err := sqlRowToMap(s.rows)
+	if err != nil {
+		_ = s.rows.Close()
+		s.rows = nil
+		return nil, nil, err
+	}
+
+	cursor, exists := obj[p.cursorColumn]
+	if !exists {
+		_ = s.rows.Close()
+		s.rows = nil
+		return nil, nil, fmt.Errorf("cursor column %v was not selected", p.cursorColumn)
+	}
+
+	stored := cursor
+	if p.keyColumn == "" {
+		if p.hasCursor && !p.warnedTies && reflect.DeepEqual(p.cursor, cursor) {
+			p.warnedTies = true
+			s.mgr.Logger().Warnf("Rows with the same value of cursor column %v were read, rows that share a value may be skipped unless a key_column is set", p.cursorColumn)
+		}
+	} else {
+		key, exists := obj[p.keyColumn]
+		if !exists {
+			_ = s.rows.Close()
+			s.rows = nil
+			return nil, nil, fmt.Errorf("key column %v was not selected", p.keyColumn)
+		}
+		p.key = key
+		stored = []any{cursor, key}
+	}
+	p.cursor, p.hasCursor = cursor, true
+	p.rowsRead++
+	p.seq++
+
+	release, err := p.checkpointer.Track(ctx, polledCursor{seq: p.seq, value: stored}, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg := service.NewMessage(nil)
+	msg.SetStructuredMut(obj)
+	return msg, func(ctx context.Context, err error) error {
+		// Nacks are handled by AutoRetryNacks, and the cursor is only stored
+		// once all prior rows have also been acknowledged.
+		p.storeMut.Lock()
+		defer p.storeMut.Unlock()
+
+		highest := release()
+		if highest == nil || highest.seq <= p.lastStored {
+			return nil
+		}
+		if err := p.storeCursor(ctx, s.mgr, highest.value); err != nil {
+			return err
+		}
+		p.lastStored = highest.seq
+		return nil
+	}, nil
+}
@@ -16,8 +16,15 @@ package sql
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -43,3 +50,315 @@ args_mapping: 'root = [ this.id ]'
 	require.NoError(t, err)
 	require.NoError(t, selectInput.Close(context.Background()))
 }
+
+func TestSQLSelectInputPolling(t *testing.T) {
+	// WAL mode allows rows to be inserted whilst the input has rows open.
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db") + "?_pragma=journal_mode(WAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec("CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+
+	insertRows := func(from, to int) {
+		t.Helper()
+		for i := from; i <= to; i++ {
+			_, err := db.Exec("INSERT INTO foo (id, name) VALUES (?, ?)", i, fmt.Sprintf("name%v", i))
+			require.NoError(t, err)
+		}
+	}
+	insertRows(1, 5)
+
+	conf, err := sqlSelectInputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, name ]
+where: name != ?
+args_mapping: 'root = [ "name3" ]'
+polling:
+  cursor_column: id
+  interval: 10ms
+  limit: 2
+  cache: cursors
+`, nil)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("cursors"))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	readIDs := func(in *sqlSelectInput, n int, ack bool) (ids []any) {
+		t.Helper()
+		for range n {
+			msg, ackFn, err := in.Read(ctx)
+			require.NoError(t, err)
+
+			v, err := msg.AsStructured()
+			require.NoError(t, err)
+			ids = append(ids, v.(map[string]any)["id"])
+
+			if ack {
+				require.NoError(t, ackFn(ctx, nil))
+			}
+		}
+		return
+	}
+
+	in, err := newSQLSelectInputFromConfig(conf, res)
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(ctx))
+
+	assert.Equal(t, []any{int64(1), int64(2), int64(4), int64(5)}, readIDs(in, 4, true))
+
+	insertRows(6, 7)
+	assert.Equal(t, []any{int64(6)}, readIDs(in, 1, true))
+	assert.Equal(t, []any{int64(7)}, readIDs(in, 1, false))
+	require.NoError(t, in.Close(ctx))
+
+	// Polling resumes after the last acknowledged row.
+	in, err = newSQLSelectInputFromConfig(conf, res)
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, in.Close(context.Background()))
+	})
+
+	assert.Equal(t, []any{int64(7)}, readIDs(in, 1, true))
+
+	var cursor []byte
+	require.NoError(t, res.AccessCache(ctx, "cursors", func(c service.Cache) {
+		cursor, err = c.Get(ctx, "sql_select_foo_id")
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "7", string(cursor))
+}
+
+func TestSQLSelectInputPollingConcurrentAcks(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec("CREATE TABLE foo (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+	for i := 1; i <= 100; i++ {
+		_, err := db.Exec("INSERT INTO foo (id) VALUES (?)", i)
+		require.NoError(t, err)
+	}
+
+	conf, err := sqlSelectInputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id ]
+polling:
+  cursor_column: id
+  interval: 10ms
+  limit: 100
+  cache: cursors
+`, nil)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("cursors"))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	in, err := newSQLSelectInputFromConfig(conf, res)
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, in.Close(context.Background()))
+	})
+
+	var ackFns []service.AckFunc
+	for range 100 {
+		_, ackFn, err := in.Read(ctx)
+		require.NoError(t, err)
+		ackFns = append(ackFns, ackFn)
+	}
+
+	// The last row is acknowledged first so that all other acknowledgements
+	// race to store the same or an older cursor.
+	var wg sync.WaitGroup
+	for i := len(ackFns) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(ackFn service.AckFunc) {
+			defer wg.Done()
+			assert.NoError(t, ackFn(ctx, nil))
+		}(ackFns[i])
+	}
+	wg.Wait()
+
+	var cursor []byte
+	require.NoError(t, res.AccessCache(ctx, "cursors", func(c service.Cache) {
+		cursor, err = c.Get(ctx, "sql_select_foo_id")
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "100", string(cursor))
+}
+
+func TestSQLSelectInputPollingKeyColumn(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec("CREATE TABLE foo (id INTEGER PRIMARY KEY, updated_at INTEGER)")
+	require.NoError(t, err)
+
+	// Several rows share an updated_at value, and are split across polls by
+	// the limit.
+	for id, updatedAt := range []int{10, 10, 10, 20, 20, 30} {
+		_, err := db.Exec("INSERT INTO foo (id, updated_at) VALUES (?, ?)", id+1, updatedAt)
+		require.NoError(t, err)
+	}
+
+	conf, err := sqlSelectInputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, updated_at ]
+polling:
+  cursor_column: updated_at
+  key_column: id
+  interval: 10ms
+  limit: 2
+  cache: cursors
+`, nil)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("cursors"))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	readIDs := func(in *sqlSelectInput, n int) (ids []any) {
+		t.Helper()
+		for range n {
+			msg, ackFn, err := in.Read(ctx)
+			require.NoError(t, err)
+
+			v, err := msg.AsStructured()
+			require.NoError(t, err)
+			ids = append(ids, v.(map[string]any)["id"])
+			require.NoError(t, ackFn(ctx, nil))
+		}
+		return
+	}
+
+	in, err := newSQLSelectInputFromConfig(conf, res)
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(ctx))
+
+	assert.Equal(t, []any{int64(1), int64(2), int64(3), int64(4)}, readIDs(in, 4))
+	require.NoError(t, in.Close(ctx))
+
+	var cursor []byte
+	require.NoError(t, res.AccessCache(ctx, "cursors", func(c service.Cache) {
+		cursor, err = c.Get(ctx, "sql_select_foo_updated_at")
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "[20,4]", string(cursor))
+
+	// Polling resumes from the stored cursor and key, including the remaining
+	// row that shares the cursor value of the last row read.
+	in, err = newSQLSelectInputFromConfig(conf, res)
+	require.NoError(t, err)
+	require.NoError(t, in.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, in.Close(context.Background()))
+	})
+
+	assert.Equal(t, []any{int64(5), int64(6)}, readIDs(in, 2))
+}
+
+func TestSQLSelectInputPartitioned(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec("CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+	for i := 1; i <= 100; i++ {
+		_, err := db.Exec("INSERT INTO foo (id, name) VALUES (?, ?)", i, fmt.Sprintf("name%v", i))
+		require.NoError(t, err)
+	}
+
+	conf, err := sqlSelectInputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, name ]
+partitioning:
+  column: id
+  partitions: 4
+  cache: partitions
+`, nil)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("partitions"))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	// Reads all rows, acknowledging those with an id within the limit and
+	// rejecting the rest, and returns the ids read.
+	readAll := func(ackLimit int64) map[int64]struct{} {
+		t.Helper()
+
+		in, err := newSQLSelectInputFromConfig(conf, res)
+		require.NoError(t, err)
+		require.NoError(t, in.Connect(ctx))
+
+		ids := map[int64]struct{}{}
+		for {
+			msg, ackFn, err := in.Read(ctx)
+			if errors.Is(err, service.ErrEndOfInput) {
+				break
+			}
+			require.NoError(t, err)
+
+			v, err := msg.AsStructured()
+			require.NoError(t, err)
+
+			id := v.(map[string]any)["id"].(int64)
+			ids[id] = struct{}{}
+			if id <= ackLimit {
+				require.NoError(t, ackFn(ctx, nil))
+			} else {
+				require.NoError(t, ackFn(ctx, errors.New("rejected")))
+			}
+		}
+		require.NoError(t, in.Close(ctx))
+		return ids
+	}
+
+	ids := readAll(50)
+	assert.Len(t, ids, 100)
+
+	// Only the two partitions above 50 were not completed.
+	ids = readAll(100)
+	assert.Len(t, ids, 50)
+	for id := range ids {
+		assert.Greater(t, id, int64(50))
+	}
+
+	assert.Empty(t, readAll(100))
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"testing"
@@ -544,15 +545,102 @@ processors:
 	})
 }
 
-func testSuite(t *testing.T, driver, dsn string, createTableFn func(string) (string, error)) {
-	for _, fn := range []testFn{
+func testUpsertOutput(t *testing.T, driver, dsn, table string) {
+	t.Run("upsert_output", func(t *testing.T) {
+		outputConf := strings.NewReplacer(
+			"$driver", driver,
+			"$dsn", dsn,
+			"$table", table,
+		).Replace(`
+sql_upsert:
+  driver: $driver
+  dsn: $dsn
+  table: $table
+  columns: [ '"foo"', '"bar"', '"baz"' ]
+  key_columns: [ '"foo"' ]
+  args_mapping: 'root = [ this.foo, this.bar.floor(), this.baz ]'
+  operation_mapping: 'root = meta("operation")'
+`)
+
+		streamInBuilder := service.NewStreamBuilder()
+		require.NoError(t, streamInBuilder.SetLoggerYAML(`level: OFF`))
+		require.NoError(t, streamInBuilder.AddOutputYAML(outputConf))
+
+		inFn, err := streamInBuilder.AddBatchProducerFunc()
+		require.NoError(t, err)
+
+		streamIn, err := streamInBuilder.Build()
+		require.NoError(t, err)
+
+		go func() {
+			assert.NoError(t, streamIn.Run(context.Background()))
+		}()
+
+		writeBatch := func(ops ...string) {
+			t.Helper()
+			var batch service.MessageBatch
+			for _, op := range ops {
+				var operation, foo string
+				var bar int
+				_, err := fmt.Sscanf(op, "%s %s %d", &operation, &foo, &bar)
+				require.NoError(t, err)
+
+				msg := service.NewMessage([]byte(fmt.Sprintf(`{"foo":%q,"bar":%d,"baz":"%v %v"}`, foo, bar, operation, bar)))
+				msg.MetaSetMut("operation", operation)
+				batch = append(batch, msg)
+			}
+			require.NoError(t, inFn(context.Background(), batch))
+		}
+
+		writeBatch("insert doc-0 0", "insert doc-1 1", "insert doc-2 2", "insert doc-3 3")
+		writeBatch(
+			"update doc-1 10",
+			"delete doc-2 0",
+			"read doc-4 4",
+			// A row inserted and updated within the same batch.
+			"insert doc-5 5",
+			"update doc-5 15",
+		)
+		// Deleting a missing row is not an error.
+		writeBatch("delete doc-6 0")
+		require.NoError(t, streamIn.StopWithin(15*time.Second))
+
+		db, err := sql.Open(driver, dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		rows, err := db.Query(fmt.Sprintf(`select "foo", "bar", "baz" from %s order by "bar" asc`, table))
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var results []string
+		for rows.Next() {
+			var foo, baz string
+			var bar int
+			require.NoError(t, rows.Scan(&foo, &bar, &baz))
+			results = append(results, fmt.Sprintf("%v %v %v", foo, bar, baz))
+		}
+		require.NoError(t, rows.Err())
+
+		assert.Equal(t, []string{
+			"doc-0 0 insert 0",
+			"doc-3 3 insert 3",
+			"doc-4 4 read 4",
+			"doc-1 10 update 10",
+			"doc-5 15 update 15",
+		}, results)
+	})
+}
+
+func testSuite(t *testing.T, driver, dsn string, createTableFn func(string) (string, error), extraFns ...testFn) {
+	for _, fn := range append([]testFn{
 		testBatchProcessorBasic,
 		testBatchProcessorParallel,
 		testBatchInputOutputBatch,
 		testBatchInputOutputRaw,
 		testRawProcessorsBasic,
 		testDeprecatedProcessorsBasic,
-	} {
+	}, extraFns...) {
 		tableName, err := gonanoid.Generate("abcdefghijklmnopqrstuvwxyz", 40)
 		require.NoError(t, err)
 
@@ -731,7 +819,7 @@ func TestIntegrationPostgres(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "postgres", dsn, createTable)
+	testSuite(t, "postgres", dsn, createTable, testUpsertOutput)
 }
 
 func TestIntegrationPostgresVector(t *testing.T) {
@@ -906,7 +994,7 @@ func TestIntegrationMySQL(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "mysql", dsn, createTable)
+	testSuite(t, "mysql", dsn, createTable, testUpsertOutput)
 }
 
 func TestIntegrationMSSQL(t *testing.T) {
@@ -967,7 +1055,7 @@ func TestIntegrationMSSQL(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "mssql", dsn, createTable)
+	testSuite(t, "mssql", dsn, createTable, testUpsertOutput)
 }
 
 func TestIntegrationSQLite(t *testing.T) {
@@ -1010,7 +1098,7 @@ func TestIntegrationSQLite(t *testing.T) {
 		return nil
 	}())
 
-	testSuite(t, "sqlite", dsn, createTable)
+	testSuite(t, "sqlite", dsn, createTable, testUpsertOutput)
 }
 
 func TestIntegrationOracle(t *testing.T) {
@@ -1072,7 +1160,38 @@ func TestIntegrationOracle(t *testing.T) {
 		return nil
 	}))
 
-	testSuite(t, "oracle", dsn, createTable)
+	testSuite(t, "oracle", dsn, createTable, testUpsertOutput)
+}
+
+func TestIntegrationSnowflakeUpsert(t *testing.T) {
+	integration.CheckSkip(t)
+
+	// Snowflake cannot be run as a container, and so an account must be
+	// provided in the form of a DSN.
+	dsn := os.Getenv("SNOWFLAKE_DSN")
+	if dsn == "" {
+		t.Skip("SNOWFLAKE_DSN is not set, skipping snowflake test")
+	}
+
+	db, err := sql.Open("snowflake", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	tableName, err := gonanoid.Generate("abcdefghijklmnopqrstuvwxyz", 40)
+	require.NoError(t, err)
+
+	_, err = db.Exec(fmt.Sprintf(`create table %s (
+  "foo" varchar(50) not null,
+  "bar" integer not null,
+  "baz" varchar(50) not null,
+  primary key ("foo")
+)`, tableName))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = db.Exec("drop table " + tableName)
+	})
+
+	testUpsertOutput(t, "snowflake", dsn, tableName)
 }
 
 func TestIntegrationTrino(t *testing.T) {
@@ -47,7 +47,8 @@ func sqlRawOutputConfig() *service.ConfigSpec {
 			Optional()).
 		Field(service.NewIntField("max_in_flight").
 			Description("The maximum number of inserts to run in parallel.").
-			Default(64))
+			Default(64)).
+		Field(validateOnConnectField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -108,6 +109,7 @@ type sqlRawOutput struct {
 	argsConverter argsConverter
 
 	connSettings *connSettings
+	validation   *connectValidation
 
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
@@ -157,7 +159,13 @@ func newSQLRawOutputFromConfig(conf *service.ParsedConfig, mgr *service.Resource
 		argsConverter = func(v []any) []any { return v }
 	}
 
-	return newSQLRawOutput(mgr.Logger(), driverStr, dsnStr, queryStatic, queryDyn, argsMapping, argsConverter, connSettings), nil
+	s := newSQLRawOutput(mgr.Logger(), driverStr, dsnStr, queryStatic, queryDyn, argsMapping, argsConverter, connSettings)
+	if queryDyn == nil {
+		if s.validation, err = connectValidationFromParsed(conf, "", nil, queryStatic); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func newSQLRawOutput(
@@ -190,12 +198,20 @@ func (s *sqlRawOutput) Connect(ctx context.Context) error {
 		return nil
 	}
 
-	var err error
-	if s.db, err = sqlOpenWithReworks(s.logger, s.driver, s.dsn); err != nil {
+	db, err := sqlOpenWithReworks(s.logger, s.driver, s.dsn)
+	if err != nil {
 		return err
 	}
 
-	s.connSettings.apply(ctx, s.db, s.logger)
+	s.connSettings.apply(ctx, db, s.logger)
+
+	if s.validation != nil {
+		if err := s.validation.check(ctx, db); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+	s.db = db
 
 	go func() {
 		<-s.shutSig.HardStopChan()
@@ -0,0 +1,129 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLUpsertStatements(t *testing.T) {
+	columns, keyColumns := []string{"id", "name", "age"}, []string{"id"}
+
+	for driver, exp := range map[string]string{
+		"postgres":  "INSERT INTO foo (id, name, age) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+		"sqlite":    "INSERT INTO foo (id, name, age) VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+		"mysql":     "INSERT INTO foo (id, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name), age = VALUES(age)",
+		"mssql":     "MERGE INTO foo WITH (HOLDLOCK) AS target USING (VALUES (?, ?, ?)) AS source (id, name, age) ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET target.name = source.name, target.age = source.age WHEN NOT MATCHED THEN INSERT (id, name, age) VALUES (source.id, source.name, source.age);",
+		"oracle":    "MERGE INTO foo target USING (SELECT :1 AS id, :2 AS name, :3 AS age FROM dual) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET target.name = source.name, target.age = source.age WHEN NOT MATCHED THEN INSERT (id, name, age) VALUES (source.id, source.name, source.age)",
+		"snowflake": "MERGE INTO foo AS target USING (SELECT ? AS id, ? AS name, ? AS age) AS source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET target.name = source.name, target.age = source.age WHEN NOT MATCHED THEN INSERT (id, name, age) VALUES (source.id, source.name, source.age)",
+	} {
+		t.Run(driver, func(t *testing.T) {
+			stmt, err := upsertStatement(driver, "foo", columns, keyColumns)
+			require.NoError(t, err)
+			assert.Equal(t, exp, stmt)
+		})
+	}
+
+	_, err := upsertStatement("clickhouse", "foo", columns, keyColumns)
+	require.Error(t, err)
+
+	stmt, err := upsertStatement("postgres", "foo", []string{"a", "b"}, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO foo (a, b) VALUES ($1, $2) ON CONFLICT (a, b) DO NOTHING", stmt)
+
+	assert.Equal(t, "DELETE FROM foo WHERE a = $1 AND b = $2", deleteStatement("postgres", "foo", []string{"a", "b"}))
+	assert.Equal(t, "DELETE FROM foo WHERE a = :1", deleteStatement("oracle", "foo", []string{"a"}))
+}
+
+func TestSQLUpsertOutputSQLite(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := sqlUpsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, name ]
+key_columns: [ id ]
+args_mapping: 'root = [ this.id, this.name ]'
+operation_mapping: 'root = this.op | "upsert"'
+init_statement: 'CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT)'
+`, nil)
+	require.NoError(t, err)
+
+	out, err := newSQLUpsertOutputFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, out.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, out.Close(ctx))
+	})
+
+	writeBatch := func(docs ...string) {
+		t.Helper()
+		var batch service.MessageBatch
+		for _, d := range docs {
+			batch = append(batch, service.NewMessage([]byte(d)))
+		}
+		require.NoError(t, out.WriteBatch(ctx, batch))
+	}
+
+	writeBatch(
+		`{"id":1,"name":"foo"}`,
+		`{"id":2,"name":"bar"}`,
+		`{"id":3,"name":"baz"}`,
+	)
+	writeBatch(
+		`{"id":1,"name":"foo2","op":"update"}`,
+		`{"id":2,"op":"delete"}`,
+		`{"id":4,"name":"buz","op":"insert"}`,
+	)
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	rows, err := db.Query("SELECT id, name FROM foo ORDER BY id")
+	require.NoError(t, err)
+	res, err := sqlRowsToArray(rows)
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "name": "foo2"},
+		map[string]any{"id": int64(3), "name": "baz"},
+		map[string]any{"id": int64(4), "name": "buz"},
+	}, res)
+
+	err = out.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":5,"name":"qux"}`)),
+		service.NewMessage([]byte(`{"id":6,"name":"quz","op":"nope"}`)),
+	})
+	require.ErrorContains(t, err, "unsupported operation")
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM foo WHERE id = 5").Scan(&count))
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLDescribeProcessor(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := DescribeProcessorConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: '${! meta("table") }'
+init_statement: 'CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT, score REAL)'
+`, nil)
+	require.NoError(t, err)
+
+	proc, err := newSQLDescribeProcessorFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = proc.Close(context.Background())
+	})
+
+	fooMsg := service.NewMessage(nil)
+	fooMsg.MetaSetMut("table", "foo")
+	missingMsg := service.NewMessage(nil)
+	missingMsg.MetaSetMut("table", "bar")
+	invalidMsg := service.NewMessage(nil)
+	invalidMsg.MetaSetMut("table", "foo; DROP TABLE foo")
+
+	batches, err := proc.ProcessBatch(context.Background(), service.MessageBatch{fooMsg, missingMsg, invalidMsg})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 3)
+
+	require.NoError(t, batches[0][0].GetError())
+	columns, err := batches[0][0].AsStructured()
+	require.NoError(t, err)
+	require.Len(t, columns, 3)
+
+	var names, types []string
+	for _, c := range columns.([]any) {
+		obj := c.(map[string]any)
+		names = append(names, obj["name"].(string))
+		types = append(types, obj["type"].(string))
+	}
+	assert.Equal(t, []string{"id", "name", "score"}, names)
+	assert.Equal(t, []string{"INTEGER", "TEXT", "REAL"}, types)
+
+	require.Error(t, batches[0][1].GetError())
+
+	require.Error(t, batches[0][2].GetError())
+	assert.Contains(t, batches[0][2].GetError().Error(), "invalid table name")
+}
@@ -61,7 +61,8 @@ If the insert fails to execute then the message will still remain unchanged and
 			Description("A list of keyword options to add before the INTO clause of the query.").
 			Optional().
 			Advanced().
-			Example([]string{"DELAYED", "IGNORE"}))
+			Example([]string{"DELAYED", "IGNORE"})).
+		Field(validateOnConnectField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -200,6 +201,19 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 		s.builder = s.builder.Options(options...)
 	}
 
+	validateBuilder := s.builder
+	if !s.useTxStmt {
+		validateBuilder = validateBuilder.Values(columnPlaceholderValues(columns)...)
+	}
+	validateQuery, _, err := validateBuilder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	validation, err := connectValidationFromParsed(conf, tableStr, columns, validateQuery)
+	if err != nil {
+		return nil, err
+	}
+
 	connSettings, err := connSettingsFromParsed(conf, mgr)
 	if err != nil {
 		return nil, err
@@ -211,6 +225,13 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 
 	connSettings.apply(context.Background(), s.db, s.logger)
 
+	if validation != nil {
+		if err := validation.check(context.Background(), s.db); err != nil {
+			_ = s.db.Close()
+			return nil, err
+		}
+	}
+
 	go func() {
 		<-s.shutSig.HardStopChan()
 
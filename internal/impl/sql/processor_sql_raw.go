@@ -50,7 +50,8 @@ If the query fails to execute then the message will remain unchanged and the err
 			Optional()).
 		Field(service.NewBoolField("exec_only").
 			Description("Whether the query result should be discarded. When set to `true` the message contents will remain unchanged, which is useful in cases where you are executing inserts, updates, etc.").
-			Default(false))
+			Default(false)).
+		Field(validateOnConnectField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -168,7 +169,24 @@ func NewSQLRawProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Resou
 		argsConverter = func(v []any) []any { return v }
 	}
 
-	return newSQLRawProcessor(mgr.Logger(), driverStr, dsnStr, queryStatic, queryDyn, onlyExec, argsMapping, argsConverter, connSettings)
+	var validation *connectValidation
+	if queryDyn == nil {
+		if validation, err = connectValidationFromParsed(conf, "", nil, queryStatic); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := newSQLRawProcessor(mgr.Logger(), driverStr, dsnStr, queryStatic, queryDyn, onlyExec, argsMapping, argsConverter, connSettings)
+	if err != nil {
+		return nil, err
+	}
+	if validation != nil {
+		if err := validation.check(context.Background(), s.db); err != nil {
+			s.shutSig.TriggerHardStop()
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func newSQLRawProcessor(
@@ -62,7 +62,8 @@ If the query fails to execute then the message will remain unchanged and the err
 		Field(service.NewStringField("suffix").
 			Description("An optional suffix to append to the select query.").
 			Optional().
-			Advanced())
+			Advanced()).
+		Field(validateOnConnectField())
 
 	for _, f := range connFields() {
 		spec = spec.Field(f)
@@ -180,6 +181,19 @@ func NewSQLSelectProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 		s.builder = s.builder.Suffix(suffixStr)
 	}
 
+	validateBuilder := s.builder
+	if s.where != "" {
+		validateBuilder = validateBuilder.Where(s.where)
+	}
+	validateQuery, _, err := validateBuilder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	validation, err := connectValidationFromParsed(conf, tableStr, columns, validateQuery)
+	if err != nil {
+		return nil, err
+	}
+
 	connSettings, err := connSettingsFromParsed(conf, mgr)
 	if err != nil {
 		return nil, err
@@ -190,6 +204,13 @@ func NewSQLSelectProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 	}
 	connSettings.apply(context.Background(), s.db, s.logger)
 
+	if validation != nil {
+		if err := validation.check(context.Background(), s.db); err != nil {
+			_ = s.db.Close()
+			return nil, err
+		}
+	}
+
 	go func() {
 		<-s.shutSig.HardStopChan()
 
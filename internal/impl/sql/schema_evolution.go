@@ -0,0 +1,325 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// columnTypes describes the column types created by the default new column
+// type mapping of a driver.
+type columnTypes struct {
+	str, bytes, integer, float, boolean, timestamp, structured string
+}
+
+var driverColumnTypes = map[string]columnTypes{
+	"postgres":   {"TEXT", "BYTEA", "BIGINT", "DOUBLE PRECISION", "BOOLEAN", "TIMESTAMPTZ", "JSONB"},
+	"mysql":      {"TEXT", "BLOB", "BIGINT", "DOUBLE", "BOOLEAN", "DATETIME(6)", "JSON"},
+	"sqlite":     {"TEXT", "BLOB", "INTEGER", "REAL", "BOOLEAN", "DATETIME", "TEXT"},
+	"mssql":      {"NVARCHAR(MAX)", "VARBINARY(MAX)", "BIGINT", "FLOAT", "BIT", "DATETIME2", "NVARCHAR(MAX)"},
+	"oracle":     {"VARCHAR2(4000)", "BLOB", "NUMBER(19)", "BINARY_DOUBLE", "NUMBER(1)", "TIMESTAMP", "CLOB"},
+	"snowflake":  {"STRING", "BINARY", "NUMBER", "DOUBLE", "BOOLEAN", "TIMESTAMP", "VARIANT"},
+	"clickhouse": {"Nullable(String)", "Nullable(String)", "Nullable(Int64)", "Nullable(Float64)", "Nullable(Bool)", "Nullable(DateTime64(6))", "Nullable(String)"},
+	"trino":      {"VARCHAR", "VARBINARY", "BIGINT", "DOUBLE", "BOOLEAN", "TIMESTAMP(6)", "JSON"},
+}
+
+func defaultNewColumnTypeMapping(t columnTypes) string {
+	return fmt.Sprintf(`root = match {
+  this.value.type() == "string" => %q
+  this.value.type() == "bytes" => %q
+  this.value.type() == "number" && this.value.round() == this.value => %q
+  this.value.type() == "number" => %q
+  this.value.type() == "bool" => %q
+  this.value.type() == "timestamp" => %q
+  this.value.type() == "object" || this.value.type() == "array" => %q
+  _ => %q
+}`, t.str, t.bytes, t.integer, t.float, t.boolean, t.timestamp, t.structured, t.str)
+}
+
+func schemaEvolutionDocs() string {
+	var b strings.Builder
+	b.WriteString(`
+The default mapping for new column types depends on the driver:
+
+|===
+| Driver | String | Bytes | Integer | Float | Bool | Timestamp | Object or array
+
+`)
+	for _, driver := range []string{"postgres", "mysql", "sqlite", "mssql", "oracle", "snowflake", "clickhouse", "trino"} {
+		t := driverColumnTypes[driver]
+		fmt.Fprintf(&b, "| `%v` | `%v` | `%v` | `%v` | `%v` | `%v` | `%v` | `%v`\n", driver, t.str, t.bytes, t.integer, t.float, t.boolean, t.timestamp, t.structured)
+	}
+	b.WriteString("|===\n\nValues that are `null` for every row of a batch create a column of the string type.")
+	return b.String()
+}
+
+func schemaEvolutionField() *service.ConfigField {
+	return service.NewObjectField("schema_evolution",
+		service.NewBoolField("enabled").
+			Description("Whether to create the table when it does not exist and add columns that are missing from it.").
+			Default(false),
+		service.NewBloblangField("new_column_type_mapping").
+			Description(`
+An optional mapping from a value to the type of a new column, which should result in the `+"`root`"+` being assigned a string containing the data type of the new column. When not specified a default mapping of the driver is used.
+
+The input to this mapping is an object with the value and the name of the new column, for example: `+"`"+`{"value": 42.3, "name":"new_data_field"}`+"`"+`.`+schemaEvolutionDocs()).
+			Optional(),
+	).
+		Description("Options for creating the target table from the first batch written to it, inferring column types from its values, and adding columns with `ALTER TABLE` as they appear. This is supported by all drivers except `gocosmos` and `spanner`.").
+		Advanced().
+		Optional().
+		Version("4.42.0")
+}
+
+var (
+	validIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	validColumnTypeRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*(\([A-Za-z0-9_, ()]*\))?$`)
+)
+
+// schemaEvolver creates a table and adds columns to it in order to fit the rows
+// about to be written.
+type schemaEvolver struct {
+	driver     string
+	table      string
+	keyColumns []string
+	mapping    *bloblang.Executor
+	logger     *service.Logger
+
+	mut     sync.Mutex
+	columns map[string]struct{}
+}
+
+func schemaEvolverFromParsed(conf *service.ParsedConfig, driver, table string, keyColumns []string, logger *service.Logger) (*schemaEvolver, error) {
+	if !conf.Contains("schema_evolution") {
+		return nil, nil
+	}
+	conf = conf.Namespace("schema_evolution")
+
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	types, exists := driverColumnTypes[driver]
+	if !exists {
+		return nil, fmt.Errorf("schema evolution is not supported by driver %v", driver)
+	}
+
+	e := &schemaEvolver{
+		driver:     driver,
+		table:      table,
+		keyColumns: keyColumns,
+		logger:     logger,
+	}
+	if conf.Contains("new_column_type_mapping") {
+		if e.mapping, err = conf.FieldBloblang("new_column_type_mapping"); err != nil {
+			return nil, err
+		}
+	} else if e.mapping, err = bloblang.Parse(defaultNewColumnTypeMapping(types)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// evolve ensures that the table exists and has all of the given columns, where
+// rows contains the values of each column in the order of columns.
+func (e *schemaEvolver) evolve(ctx context.Context, db *sql.DB, columns []string, rows [][]any) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if e.columns == nil {
+		existing, exists, err := e.existingColumns(ctx, db)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return e.createTable(ctx, db, columns, rows)
+		}
+		e.columns = existing
+	}
+
+	for i, c := range columns {
+		if _, exists := e.columns[strings.ToLower(c)]; exists {
+			continue
+		}
+		if err := e.addColumn(ctx, db, c, columnValue(rows, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableNotFoundErrors contains, for each driver, a substring of the error
+// returned when querying a table that does not exist.
+var tableNotFoundErrors = map[string]string{
+	"postgres":   "does not exist",
+	"mysql":      "Error 1146",
+	"sqlite":     "no such table",
+	"mssql":      "Invalid object name",
+	"oracle":     "ORA-00942",
+	"snowflake":  "002003",
+	"clickhouse": "code: 60,",
+	"trino":      "TABLE_NOT_FOUND",
+}
+
+// existingColumns returns the lowercased names of the columns of the table, or
+// false if the table does not exist. Errors other than the table not existing,
+// such as a lost connection or missing permissions, are returned.
+func (e *schemaEvolver) existingColumns(ctx context.Context, db *sql.DB) (map[string]struct{}, bool, error) {
+	columns, err := tableColumns(ctx, db, e.table)
+	if err != nil {
+		if strings.Contains(err.Error(), tableNotFoundErrors[e.driver]) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read columns of table %v: %w", e.table, err)
+	}
+	return columns, true, nil
+}
+
+// jsonEncodeStructured returns the values of a row with objects and arrays
+// encoded as JSON, which is how they are stored in the columns created for
+// them, as drivers are unable to bind them as they are.
+func jsonEncodeStructured(row []any) ([]any, error) {
+	var encoded []any
+	for i, v := range row {
+		switch v.(type) {
+		case map[string]any, []any:
+		default:
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if encoded == nil {
+			encoded = slices.Clone(row)
+		}
+		encoded[i] = string(b)
+	}
+	if encoded == nil {
+		return row, nil
+	}
+	return encoded, nil
+}
+
+// columnValue returns the first non-null value of a column within rows.
+func columnValue(rows [][]any, i int) any {
+	for _, r := range rows {
+		if i < len(r) && r[i] != nil {
+			return r[i]
+		}
+	}
+	return nil
+}
+
+func (e *schemaEvolver) columnType(name string, value any) (string, error) {
+	if !validIdentifierRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid column name for schema evolution: %v", name)
+	}
+
+	msg := service.NewMessage(nil)
+	msg.SetStructuredMut(map[string]any{
+		"name":  name,
+		"value": value,
+	})
+	out, err := msg.BloblangQuery(e.mapping)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute new column type for %v: %w", name, err)
+	}
+	v, err := out.AsBytes()
+	if err != nil {
+		return "", fmt.Errorf("unable to extract result from new column type mapping for %v: %w", name, err)
+	}
+	columnType := string(v)
+	if !validColumnTypeRegex.MatchString(columnType) {
+		return "", fmt.Errorf("invalid column data type for %v: %v", name, columnType)
+	}
+	return columnType, nil
+}
+
+func (e *schemaEvolver) createTable(ctx context.Context, db *sql.DB, columns []string, rows [][]any) error {
+	defs := make([]string, len(columns))
+	created := make(map[string]struct{}, len(columns))
+	for i, c := range columns {
+		colType, err := e.columnType(c, columnValue(rows, i))
+		if err != nil {
+			return err
+		}
+		defs[i] = c + " " + colType
+		created[strings.ToLower(c)] = struct{}{}
+	}
+	if len(e.keyColumns) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%v)", strings.Join(e.keyColumns, ", ")))
+	}
+
+	// This is prone to SQL injection and therefore column names and types are
+	// validated before being used.
+	stmt := fmt.Sprintf("CREATE TABLE %v (%v)", e.table, strings.Join(defs, ", "))
+	if e.driver == "clickhouse" {
+		stmt += " ENGINE = MergeTree ORDER BY tuple()"
+	}
+
+	e.logger.Infof("Identified write to non-existing table, attempting to create table: %v", e.table)
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		// The table may have been created by another writer in the meantime.
+		if existing, exists, _ := e.existingColumns(ctx, db); exists {
+			e.columns = existing
+			return nil
+		}
+		return fmt.Errorf("failed to create table %v: %w", e.table, err)
+	}
+	e.columns = created
+	return nil
+}
+
+func (e *schemaEvolver) addColumn(ctx context.Context, db *sql.DB, name string, value any) error {
+	colType, err := e.columnType(name, value)
+	if err != nil {
+		return err
+	}
+
+	var stmt string
+	switch e.driver {
+	case "mssql":
+		stmt = fmt.Sprintf("ALTER TABLE %v ADD %v %v", e.table, name, colType)
+	case "oracle":
+		stmt = fmt.Sprintf("ALTER TABLE %v ADD (%v %v)", e.table, name, colType)
+	default:
+		stmt = fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", e.table, name, colType)
+	}
+
+	e.logger.Infof("Identified new schema, attempting to alter table to add column: %v %v", name, colType)
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		// The column may have been added by another writer in the meantime.
+		existing, exists, _ := e.existingColumns(ctx, db)
+		if _, added := existing[strings.ToLower(name)]; !exists || !added {
+			return fmt.Errorf("failed to add column %v to table %v: %w", name, e.table, err)
+		}
+		e.columns = existing
+		return nil
+	}
+	e.columns[strings.ToLower(name)] = struct{}{}
+	return nil
+}
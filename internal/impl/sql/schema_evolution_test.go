@@ -0,0 +1,285 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestSchemaEvolutionDefaultColumnTypes(t *testing.T) {
+	conf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: postgres
+dsn: foo
+table: bar
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	e, err := schemaEvolverFromParsed(conf, "postgres", "bar", nil, service.MockResources().Logger())
+	require.NoError(t, err)
+	require.NotNil(t, e)
+
+	for _, test := range []struct {
+		value any
+		exp   string
+	}{
+		{value: "foo", exp: "TEXT"},
+		{value: []byte("foo"), exp: "BYTEA"},
+		{value: int64(10), exp: "BIGINT"},
+		{value: 10.5, exp: "DOUBLE PRECISION"},
+		{value: true, exp: "BOOLEAN"},
+		{value: time.Unix(10, 0), exp: "TIMESTAMPTZ"},
+		{value: map[string]any{"foo": "bar"}, exp: "JSONB"},
+		{value: []any{"foo"}, exp: "JSONB"},
+		{value: nil, exp: "TEXT"},
+	} {
+		colType, err := e.columnType("foo", test.value)
+		require.NoError(t, err)
+		assert.Equal(t, test.exp, colType, "%v", test.value)
+	}
+
+	_, err = e.columnType("foo; DROP TABLE bar", "foo")
+	require.Error(t, err)
+
+	conf, err = sqlInsertOutputConfig().ParseYAML(`
+driver: postgres
+dsn: foo
+table: bar
+schema_evolution:
+  enabled: true
+  new_column_type_mapping: 'root = "TEXT; DROP TABLE bar"'
+`, nil)
+	require.NoError(t, err)
+
+	e, err = schemaEvolverFromParsed(conf, "postgres", "bar", nil, service.MockResources().Logger())
+	require.NoError(t, err)
+
+	_, err = e.columnType("foo", "bar")
+	require.Error(t, err)
+}
+
+func TestSchemaEvolutionRequiresColumns(t *testing.T) {
+	conf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: postgres
+dsn: foo
+table: bar
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newSQLInsertOutputFromConfig(conf, service.MockResources())
+	require.ErrorContains(t, err, "columns must be specified")
+}
+
+func querySQLiteRows(t *testing.T, dsn, query string) []any {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	res, err := sqlRowsToArray(rows)
+	require.NoError(t, err)
+	return res
+}
+
+func TestSchemaEvolutionInsertSQLite(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	out, err := newSQLInsertOutputFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, out.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, out.Close(ctx))
+	})
+
+	require.NoError(t, out.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"name":"foo"}`)),
+		service.NewMessage([]byte(`{"id":2}`)),
+	}))
+	require.NoError(t, out.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":3,"name":"bar","score":1.5}`)),
+	}))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "name": "foo", "score": nil},
+		map[string]any{"id": int64(2), "name": nil, "score": nil},
+		map[string]any{"id": int64(3), "name": "bar", "score": 1.5},
+	}, querySQLiteRows(t, dsn, "SELECT id, name, score FROM foo ORDER BY id"))
+
+	// A new output discovers the columns of the existing table.
+	out2, err := newSQLInsertOutputFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, out2.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, out2.Close(ctx))
+	})
+
+	require.NoError(t, out2.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":4,"name":"baz","active":true}`)),
+	}))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(4), "name": "baz", "active": int64(1)},
+	}, querySQLiteRows(t, dsn, "SELECT id, name, active FROM foo WHERE id = 4"))
+}
+
+func TestSchemaEvolutionUpsertSQLite(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := sqlUpsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, name ]
+key_columns: [ id ]
+args_mapping: 'root = [ this.id, this.name ]'
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	out, err := newSQLUpsertOutputFromConfig(conf, service.MockResources())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, out.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, out.Close(ctx))
+	})
+
+	require.NoError(t, out.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"name":"foo"}`)),
+		service.NewMessage([]byte(`{"id":1,"name":"bar"}`)),
+	}))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "name": "bar"},
+	}, querySQLiteRows(t, dsn, "SELECT id, name FROM foo"))
+}
+
+func TestSchemaEvolutionNestedValuesSQLite(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	ctx := context.Background()
+
+	insertConf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	insertOut, err := newSQLInsertOutputFromConfig(insertConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, insertOut.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, insertOut.Close(ctx))
+	})
+
+	require.NoError(t, insertOut.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"meta":{"tags":["a","b"]}}`)),
+		service.NewMessage([]byte(`{"id":2,"list":[1,{"c":true}]}`)),
+	}))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "meta": `{"tags":["a","b"]}`, "list": nil},
+		map[string]any{"id": int64(2), "meta": nil, "list": `[1,{"c":true}]`},
+	}, querySQLiteRows(t, dsn, "SELECT id, meta, list FROM foo ORDER BY id"))
+
+	upsertConf, err := sqlUpsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: bar
+columns: [ id, meta ]
+key_columns: [ id ]
+args_mapping: 'root = [ this.id, this.meta ]'
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	upsertOut, err := newSQLUpsertOutputFromConfig(upsertConf, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, upsertOut.Connect(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, upsertOut.Close(ctx))
+	})
+
+	require.NoError(t, upsertOut.WriteBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"meta":{"a":1}}`)),
+		service.NewMessage([]byte(`{"id":1,"meta":{"a":2}}`)),
+	}))
+
+	assert.Equal(t, []any{
+		map[string]any{"id": int64(1), "meta": `{"a":2}`},
+	}, querySQLiteRows(t, dsn, "SELECT id, meta FROM bar"))
+}
+
+func TestSchemaEvolutionExistingColumnsErrors(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+schema_evolution:
+  enabled: true
+`, nil)
+	require.NoError(t, err)
+
+	e, err := schemaEvolverFromParsed(conf, "sqlite", "foo", nil, service.MockResources().Logger())
+	require.NoError(t, err)
+
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, exists, err := e.existingColumns(ctx, db)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Errors other than the table not existing must not be mistaken for a
+	// missing table, which would otherwise attempt to create it.
+	require.NoError(t, db.Close())
+	_, _, err = e.existingColumns(ctx, db)
+	require.ErrorContains(t, err, "failed to read columns of table foo")
+
+	err = e.evolve(ctx, db, []string{"id"}, [][]any{{int64(1)}})
+	require.ErrorContains(t, err, "failed to read columns of table foo")
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const validateOnConnectFieldName = "validate_on_connect"
+
+func validateOnConnectField() *service.ConfigField {
+	return service.NewBoolField(validateOnConnectFieldName).
+		Description("Whether to check that the table and columns referenced by this component exist, and that its statements can be prepared by the database, upon connecting. When a check fails the component does not connect and the error is logged, which catches mistakes such as misspelled columns before any messages are processed. Columns that are not plain identifiers, such as `*` or expressions, are not checked, and some drivers do not validate statements until they are executed. Checks are skipped when `schema_evolution` is enabled, as the table is then created and altered as messages are written.").
+		Default(false).
+		Advanced().
+		Version("4.42.0")
+}
+
+// connectValidation describes the table, columns and statements of a component
+// that are checked when it connects to a database.
+type connectValidation struct {
+	table      string
+	columns    []string
+	statements []string
+}
+
+// connectValidationFromParsed returns a connectValidation when enabled by the
+// config, or nil otherwise.
+func connectValidationFromParsed(conf *service.ParsedConfig, table string, columns []string, statements ...string) (*connectValidation, error) {
+	enabled, err := conf.FieldBool(validateOnConnectFieldName)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return &connectValidation{
+		table:      table,
+		columns:    columns,
+		statements: statements,
+	}, nil
+}
+
+// validateTableName returns an error unless a table name, which may be
+// qualified with a schema name, only contains plain identifiers.
+func validateTableName(table string) error {
+	for _, part := range strings.Split(table, ".") {
+		if !validIdentifierRegex.MatchString(part) {
+			return fmt.Errorf("invalid table name: %v", table)
+		}
+	}
+	return nil
+}
+
+// tableColumns returns the lowercased names of the columns of a table.
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]struct{}, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %v WHERE 1 = 0", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		columns[strings.ToLower(n)] = struct{}{}
+	}
+	return columns, nil
+}
+
+func (v *connectValidation) check(ctx context.Context, db *sql.DB) error {
+	if v.table != "" {
+		existing, err := tableColumns(ctx, db, v.table)
+		if err != nil {
+			return fmt.Errorf("failed to read columns of table %v: %w", v.table, err)
+		}
+		for _, c := range v.columns {
+			if !validIdentifierRegex.MatchString(c) {
+				continue
+			}
+			if _, exists := existing[strings.ToLower(c)]; !exists {
+				return fmt.Errorf("column %v does not exist in table %v", c, v.table)
+			}
+		}
+	}
+
+	for _, s := range v.statements {
+		stmt, err := db.PrepareContext(ctx, s)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement '%v': %w", s, err)
+		}
+		_ = stmt.Close()
+	}
+	return nil
+}
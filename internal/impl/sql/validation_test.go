@@ -0,0 +1,89 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	_ "modernc.org/sqlite"
+)
+
+const validationInitStatement = `CREATE TABLE IF NOT EXISTS foo (id INTEGER PRIMARY KEY, name TEXT)`
+
+func TestSQLInsertOutputValidateOnConnect(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	for _, test := range []struct {
+		name        string
+		columns     string
+		errContains string
+	}{
+		{name: "valid", columns: "[ id, name ]"},
+		{name: "misspelled column", columns: "[ id, nmae ]", errContains: "column nmae does not exist in table foo"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: `+test.columns+`
+args_mapping: 'root = [ this.id, this.name ]'
+init_statement: '`+validationInitStatement+`'
+validate_on_connect: true
+`, nil)
+			require.NoError(t, err)
+
+			out, err := newSQLInsertOutputFromConfig(conf, service.MockResources())
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = out.Close(context.Background())
+			})
+
+			err = out.Connect(context.Background())
+			if test.errContains == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			}
+		})
+	}
+}
+
+func TestSQLSelectProcessorValidateOnConnect(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+
+	conf, err := SelectProcessorConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: foo
+columns: [ id, nmae ]
+where: id = ?
+init_statement: '`+validationInitStatement+`'
+validate_on_connect: true
+`, nil)
+	require.NoError(t, err)
+
+	_, err = NewSQLSelectProcessorFromConfig(conf, service.MockResources())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "column nmae does not exist in table foo")
+}
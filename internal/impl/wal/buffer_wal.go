@@ -0,0 +1,599 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/batchcodec"
+)
+
+const (
+	wbFieldPath             = "path"
+	wbFieldSegmentSizeBytes = "segment_size_bytes"
+	wbFieldMaxSizeBytes     = "max_size_bytes"
+	wbFieldSyncPolicy       = "sync_policy"
+	wbFieldSyncInterval     = "sync_interval"
+
+	syncPolicyAlways   = "always"
+	syncPolicyInterval = "interval"
+	syncPolicyNone     = "none"
+)
+
+// BufferConfig returns a config spec for a WAL buffer.
+func BufferConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Utility").
+		Version("4.42.0").
+		Summary(`Stores messages in an append-only write-ahead log of segment files on disk until they are acknowledged by the output.`).
+		Description(`
+Batches written to this buffer are appended to the active segment file within the `+"`path`"+` directory, which is rolled over to a new segment once it reaches `+"`segment_size_bytes`"+`. Batches are read concurrently by each processing thread of the pipeline, and acknowledgements are tracked per segment in an accompanying ack file. Once every batch of a segment has been acknowledged the segment and its ack file are deleted in order to reclaim space.
+
+Batches that are rejected by the output are redelivered, and the ordering of batches is therefore not preserved. Messages are delivered at least once, and batches that were not acknowledged before a crash or restart are delivered again once the buffer is opened.
+
+== Durability
+
+The `+"`sync_policy`"+` determines when writes are flushed to disk with fsync. With the `+"`always`"+` policy a batch is only acknowledged to the input once it has been flushed, which survives a crash of the machine but limits throughput to the rate at which the disk can flush. The `+"`interval`"+` and `+"`none`"+` policies acknowledge batches once they are written to the operating system, which survives a crash of the process but may lose the most recent writes when the machine crashes. Acknowledgements are flushed according to the same policy, and unless the policy is `+"`none`"+` the directory is also flushed whenever a segment is created or deleted.
+
+When the buffer is opened all segments are scanned and each record is validated with a checksum. A segment containing an incomplete or corrupt record, which is the result of a crash during a write, is truncated at that record.
+
+== Limits
+
+When `+"`max_size_bytes`"+` is set writes are blocked once the segments on disk reach that size, which applies back pressure to the input until enough batches have been acknowledged. This buffer is intended for absorbing bursts of ingestion and outages of outputs that last longer than an in-memory buffer is able to cover, and therefore the limit should be based on the disk space available.
+
+== Metrics
+
+This buffer emits the gauges `+"`buffer_wal_bytes` and `buffer_wal_segments`"+`, which are the total size and number of segments stored on disk.
+`).
+		Field(service.NewStringField(wbFieldPath).
+			Description("The path of a directory in which segment files are stored, which is created if it does not exist.").
+			Example("/var/lib/redpanda-connect/wal")).
+		Field(service.NewIntField(wbFieldSegmentSizeBytes).
+			Description("The size in bytes at which the active segment is closed and a new one is created. A segment is only deleted once all of its batches have been acknowledged, and therefore smaller segments reclaim space sooner at the cost of more files.").
+			Default(64*1024*1024).
+			Advanced()).
+		Field(service.NewIntField(wbFieldMaxSizeBytes).
+			Description("The maximum total size in bytes of segments stored on disk, after which writes are blocked, where `0` means no limit. A batch is always accepted when the buffer is empty, even when it exceeds the limit by itself.").
+			Default(0)).
+		Field(service.NewStringAnnotatedEnumField(wbFieldSyncPolicy, map[string]string{
+			syncPolicyAlways:   "Flush every write to disk before acknowledging it to the input.",
+			syncPolicyInterval: "Flush writes to disk periodically according to `sync_interval`.",
+			syncPolicyNone:     "Never explicitly flush writes, leaving it to the operating system.",
+		}).
+			Description("When writes are flushed to disk with fsync.").
+			Default(syncPolicyInterval)).
+		Field(service.NewDurationField(wbFieldSyncInterval).
+			Description("How often writes are flushed to disk when the `sync_policy` is `interval`.").
+			Default("1s").
+			Advanced()).
+		Example("Absorb Output Outages", "Batches of messages consumed from Kafka are stored on disk so that the input can keep consuming when the output is unavailable, up to 100GB.", `
+input:
+  kafka_franz:
+    seed_brokers: [ localhost:9092 ]
+    topics: [ events ]
+    consumer_group: events_archive
+    batching:
+      count: 1000
+      period: 1s
+
+buffer:
+  wal:
+    path: /var/lib/redpanda-connect/wal
+    max_size_bytes: 100000000000
+
+output:
+  http_client:
+    url: https://example.com/events
+    verb: POST
+`)
+}
+
+func init() {
+	err := service.RegisterBatchBuffer(
+		"wal", BufferConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchBuffer, error) {
+			return NewBufferFromConfig(conf, mgr)
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type bufferOptions struct {
+	segmentSize  int64
+	maxSize      int64
+	syncPolicy   string
+	syncInterval time.Duration
+}
+
+// NewBufferFromConfig creates a new WAL buffer from a parsed config.
+func NewBufferFromConfig(conf *service.ParsedConfig, res *service.Resources) (*Buffer, error) {
+	path, err := conf.FieldString(wbFieldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts bufferOptions
+	segmentSize, err := conf.FieldInt(wbFieldSegmentSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	if segmentSize <= 0 {
+		return nil, fmt.Errorf("%v must be greater than zero", wbFieldSegmentSizeBytes)
+	}
+	opts.segmentSize = int64(segmentSize)
+
+	maxSize, err := conf.FieldInt(wbFieldMaxSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	opts.maxSize = int64(maxSize)
+
+	if opts.syncPolicy, err = conf.FieldString(wbFieldSyncPolicy); err != nil {
+		return nil, err
+	}
+	if opts.syncInterval, err = conf.FieldDuration(wbFieldSyncInterval); err != nil {
+		return nil, err
+	}
+
+	return newBuffer(path, opts, res.Logger(), res.Metrics())
+}
+
+//------------------------------------------------------------------------------
+
+// recordRef identifies a record within a segment.
+type recordRef struct {
+	seg    *segment
+	offset int64
+}
+
+// Buffer stores batches of messages in segment files on disk.
+type Buffer struct {
+	dir  string
+	opts bufferOptions
+	log  *service.Logger
+
+	cond *sync.Cond
+
+	// Segments in the order that they were written, the last of which is the
+	// active segment.
+	segments []*segment
+	nextSeq  uint64
+	size     int64
+
+	retries        []recordRef
+	inFlight       int
+	blockedWriters int
+	dirty          bool
+	endOfInput     bool
+	closed         bool
+
+	mBytes    *service.MetricGauge
+	mSegments *service.MetricGauge
+
+	closeChan chan struct{}
+	syncWG    sync.WaitGroup
+}
+
+func newBuffer(dir string, opts bufferOptions, log *service.Logger, metrics *service.Metrics) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	b := &Buffer{
+		dir:       dir,
+		opts:      opts,
+		log:       log,
+		cond:      sync.NewCond(&sync.Mutex{}),
+		mBytes:    metrics.NewGauge("buffer_wal_bytes"),
+		mSegments: metrics.NewGauge("buffer_wal_segments"),
+		closeChan: make(chan struct{}),
+	}
+
+	var err error
+	if b.segments, err = recoverSegments(dir, b.durable(), log); err != nil {
+		return nil, err
+	}
+	for _, seg := range b.segments {
+		b.size += seg.size
+		b.nextSeq = seg.seq + 1
+	}
+
+	active, err := createSegment(dir, b.nextSeq, b.durable())
+	if err != nil {
+		b.closeSegments()
+		return nil, err
+	}
+	b.nextSeq++
+	b.segments = append(b.segments, active)
+	b.updateMetrics()
+
+	if opts.syncPolicy == syncPolicyInterval {
+		b.syncWG.Add(1)
+		go b.syncLoop()
+	}
+	return b, nil
+}
+
+// durable returns whether writes are explicitly flushed to disk, in which case
+// the directory is also flushed when segments are created or deleted.
+func (b *Buffer) durable() bool {
+	return b.opts.syncPolicy != syncPolicyNone
+}
+
+func (b *Buffer) active() *segment {
+	return b.segments[len(b.segments)-1]
+}
+
+func (b *Buffer) updateMetrics() {
+	b.mBytes.Set(b.size)
+	b.mSegments.Set(int64(len(b.segments)))
+}
+
+func (b *Buffer) syncLoop() {
+	defer b.syncWG.Done()
+
+	ticker := time.NewTicker(b.opts.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.closeChan:
+			return
+		}
+
+		b.cond.L.Lock()
+		if !b.closed {
+			b.syncSegments()
+		}
+		b.cond.L.Unlock()
+	}
+}
+
+// syncSegments flushes the active segment if it has been written to, and the
+// ack files of every segment that has been acknowledged since it was last
+// flushed. Must be called whilst holding the lock.
+func (b *Buffer) syncSegments() {
+	for _, seg := range b.segments[:len(b.segments)-1] {
+		if err := seg.syncAcks(); err != nil {
+			b.log.Errorf("Failed to sync acknowledgements of segment %v: %v", seg.seq, err)
+		}
+	}
+	active := b.active()
+	if b.dirty {
+		if err := active.sync(); err != nil {
+			b.log.Errorf("Failed to sync segment: %v", err)
+		} else {
+			b.dirty = false
+		}
+	} else if err := active.syncAcks(); err != nil {
+		b.log.Errorf("Failed to sync acknowledgements of segment %v: %v", active.seq, err)
+	}
+}
+
+// roll seals the active segment and creates a new one. Must be called whilst
+// holding the lock.
+func (b *Buffer) roll() error {
+	active := b.active()
+	if err := active.sync(); err != nil {
+		return err
+	}
+	seg, err := createSegment(b.dir, b.nextSeq, b.durable())
+	if err != nil {
+		return err
+	}
+	b.nextSeq++
+	active.sealed = true
+	b.segments = append(b.segments, seg)
+	b.dirty = false
+
+	// The sealed segment may have already been fully acknowledged.
+	b.reclaim(active)
+	b.updateMetrics()
+	return nil
+}
+
+// reclaim deletes a segment once it is sealed and all of its records have been
+// acknowledged. Must be called whilst holding the lock.
+func (b *Buffer) reclaim(seg *segment) {
+	if !seg.sealed || seg.acked < seg.records {
+		return
+	}
+	for i, s := range b.segments {
+		if s != seg {
+			continue
+		}
+		b.segments = append(b.segments[:i], b.segments[i+1:]...)
+		b.size -= seg.size
+		if err := seg.remove(); err != nil {
+			b.log.Errorf("Failed to delete segment %v: %v", seg.seq, err)
+		}
+		b.updateMetrics()
+		b.cond.Broadcast()
+		return
+	}
+}
+
+// nextRecord returns the next record to be delivered, or false if there are
+// none. Must be called whilst holding the lock.
+func (b *Buffer) nextRecord() (recordRef, uint32, bool, error) {
+	if len(b.retries) > 0 {
+		ref := b.retries[0]
+		b.retries = b.retries[1:]
+		length, err := ref.seg.readHeader(ref.offset)
+		return ref, length, true, err
+	}
+	for _, seg := range b.segments {
+		for seg.readOffset < seg.size {
+			offset := seg.readOffset
+			length, err := seg.readHeader(offset)
+			if err != nil {
+				return recordRef{}, 0, false, err
+			}
+			seg.readOffset += recordHeaderLen + int64(length)
+			if _, acked := seg.ackedOffsets[offset]; acked {
+				continue
+			}
+			return recordRef{seg: seg, offset: offset}, length, true, nil
+		}
+	}
+	return recordRef{}, 0, false, nil
+}
+
+// ReadBatch reads the next batch from the log.
+func (b *Buffer) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	ctx, done := context.WithCancel(ctx)
+	defer done()
+
+	go func() {
+		<-ctx.Done()
+		b.cond.Broadcast()
+	}()
+
+	for {
+		b.cond.L.Lock()
+		var ref recordRef
+		var length uint32
+		for {
+			if b.closed {
+				b.cond.L.Unlock()
+				return nil, nil, service.ErrEndOfBuffer
+			}
+			if ctx.Err() != nil {
+				b.cond.L.Unlock()
+				return nil, nil, ctx.Err()
+			}
+
+			var ok bool
+			var err error
+			if ref, length, ok, err = b.nextRecord(); err != nil {
+				b.cond.L.Unlock()
+				return nil, nil, err
+			}
+			if ok {
+				break
+			}
+			if b.endOfInput && b.inFlight == 0 {
+				b.cond.L.Unlock()
+				return nil, nil, service.ErrEndOfBuffer
+			}
+			b.cond.Wait()
+		}
+		b.inFlight++
+		b.cond.L.Unlock()
+
+		// Reading and decoding the payload is done without the lock so that
+		// readers are able to do so in parallel. The segment cannot be deleted
+		// in the meantime as the record is not yet acknowledged.
+		payload, err := ref.seg.readPayload(ref.offset, length)
+		var batch service.MessageBatch
+		if err == nil {
+			batch, _, err = batchcodec.Read(payload)
+		}
+		if err != nil {
+			b.cond.L.Lock()
+			closed := b.closed
+			b.cond.L.Unlock()
+			if closed {
+				return nil, nil, service.ErrEndOfBuffer
+			}
+			b.log.Errorf("Dropping corrupt record at offset %v of segment %v: %v", ref.offset, ref.seg.seq, err)
+			b.ack(ref)
+			continue
+		}
+
+		var acked int32
+		return batch, func(ctx context.Context, err error) error {
+			if !atomic.CompareAndSwapInt32(&acked, 0, 1) {
+				return nil
+			}
+			if err != nil {
+				b.nack(ref)
+				return nil
+			}
+			return b.ack(ref)
+		}, nil
+	}
+}
+
+func (b *Buffer) nack(ref recordRef) {
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+
+	b.inFlight--
+	b.retries = append(b.retries, ref)
+	b.cond.Broadcast()
+}
+
+func (b *Buffer) ack(ref recordRef) error {
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+
+	b.inFlight--
+	b.cond.Broadcast()
+	if b.closed {
+		return service.ErrEndOfBuffer
+	}
+
+	seg := ref.seg
+	if err := seg.ack(ref.offset, b.opts.syncPolicy == syncPolicyAlways); err != nil {
+		return err
+	}
+
+	// Writers blocked on the size limit are only able to continue once space
+	// is reclaimed, which requires the active segment to be sealed.
+	if seg == b.active() && b.blockedWriters > 0 && seg.acked == seg.records {
+		if err := b.roll(); err != nil {
+			return err
+		}
+	}
+	b.reclaim(seg)
+	return nil
+}
+
+// WriteBatch appends a batch to the active segment.
+func (b *Buffer) WriteBatch(ctx context.Context, msgBatch service.MessageBatch, aFn service.AckFunc) error {
+	payload, err := batchcodec.Append(nil, msgBatch)
+	if err != nil {
+		return err
+	}
+	record := appendRecord(make([]byte, 0, recordHeaderLen+len(payload)), payload)
+
+	if err := b.write(ctx, record); err != nil {
+		return err
+	}
+	return aFn(ctx, nil)
+}
+
+func (b *Buffer) write(ctx context.Context, record []byte) error {
+	ctx, done := context.WithCancel(ctx)
+	defer done()
+
+	go func() {
+		<-ctx.Done()
+		b.cond.Broadcast()
+	}()
+
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+
+	recordLen := int64(len(record))
+	for b.opts.maxSize > 0 && b.size > 0 && b.size+recordLen > b.opts.maxSize {
+		if b.closed {
+			return service.ErrEndOfBuffer
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// The active segment may hold nothing but acknowledged records, in
+		// which case it needs to be sealed in order to reclaim it.
+		if active := b.active(); active.records > 0 && active.acked == active.records {
+			if err := b.roll(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		b.blockedWriters++
+		b.cond.Wait()
+		b.blockedWriters--
+	}
+	if b.closed {
+		return service.ErrEndOfBuffer
+	}
+
+	if active := b.active(); active.size > 0 && active.size+recordLen > b.opts.segmentSize {
+		if err := b.roll(); err != nil {
+			return err
+		}
+	}
+
+	if err := b.active().append(record); err != nil {
+		return err
+	}
+	b.size += recordLen
+	b.dirty = true
+	b.updateMetrics()
+
+	if b.opts.syncPolicy == syncPolicyAlways {
+		if err := b.active().sync(); err != nil {
+			return err
+		}
+		b.dirty = false
+	}
+
+	b.cond.Broadcast()
+	return nil
+}
+
+// EndOfInput signals to the buffer that the input is finished and therefore
+// once the log is drained it should close.
+func (b *Buffer) EndOfInput() {
+	go func() {
+		b.cond.L.Lock()
+		defer b.cond.L.Unlock()
+
+		b.endOfInput = true
+		b.cond.Broadcast()
+	}()
+}
+
+func (b *Buffer) closeSegments() error {
+	var errs []error
+	for _, seg := range b.segments {
+		if err := seg.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes and closes the segment files.
+func (b *Buffer) Close(ctx context.Context) error {
+	b.cond.L.Lock()
+	if b.closed {
+		b.cond.L.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.closeChan)
+	b.cond.Broadcast()
+	b.cond.L.Unlock()
+
+	b.syncWG.Wait()
+
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+
+	var errs []error
+	if b.durable() {
+		for _, seg := range b.segments[:len(b.segments)-1] {
+			errs = append(errs, seg.syncAcks())
+		}
+		errs = append(errs, b.active().sync())
+	}
+	return errors.Join(append(errs, b.closeSegments())...)
+}
@@ -0,0 +1,426 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func walBufFromConf(t testing.TB, conf string) *Buffer {
+	t.Helper()
+
+	parsedConf, err := BufferConfig().ParseYAML(conf, nil)
+	require.NoError(t, err)
+
+	buf, err := NewBufferFromConfig(parsedConf, service.MockResources())
+	require.NoError(t, err)
+
+	return buf
+}
+
+func noopAck(context.Context, error) error { return nil }
+
+func writeStrs(t testing.TB, buf *Buffer, strs ...string) {
+	t.Helper()
+
+	for _, s := range strs {
+		require.NoError(t, buf.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(s)),
+		}, noopAck))
+	}
+}
+
+func readStr(t testing.TB, buf *Buffer) (string, service.AckFunc) {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	b, aFn, err := buf.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, b, 1)
+
+	mBytes, err := b[0].AsBytes()
+	require.NoError(t, err)
+	return string(mBytes), aFn
+}
+
+func segmentFiles(t testing.TB, dir string) []string {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestBufferWALBasic(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+`, dir))
+	defer buf.Close(ctx)
+
+	msg := service.NewMessage([]byte("hello world"))
+	msg.MetaSetMut("foo", "bar")
+	require.NoError(t, buf.WriteBatch(ctx, service.MessageBatch{msg, service.NewMessage([]byte("second"))}, noopAck))
+
+	b, aFn, err := buf.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, b, 2)
+
+	mBytes, err := b[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(mBytes))
+
+	v, _ := b[0].MetaGetMut("foo")
+	assert.Equal(t, "bar", v)
+
+	mBytes, err = b[1].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(mBytes))
+
+	require.NoError(t, aFn(ctx, nil))
+
+	buf.EndOfInput()
+	_, _, err = buf.ReadBatch(ctx)
+	assert.Equal(t, service.ErrEndOfBuffer, err)
+}
+
+func TestBufferWALNack(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+`, dir))
+	defer buf.Close(ctx)
+
+	writeStrs(t, buf, "1", "2")
+
+	v, aFn := readStr(t, buf)
+	assert.Equal(t, "1", v)
+	require.NoError(t, aFn(ctx, errors.New("nope")))
+
+	v, aFn = readStr(t, buf)
+	assert.Equal(t, "1", v)
+	require.NoError(t, aFn(ctx, nil))
+
+	v, aFn = readStr(t, buf)
+	assert.Equal(t, "2", v)
+	require.NoError(t, aFn(ctx, nil))
+}
+
+func TestBufferWALSegmentReclaim(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+segment_size_bytes: 100
+`, dir))
+	defer buf.Close(ctx)
+
+	for i := 0; i < 20; i++ {
+		writeStrs(t, buf, fmt.Sprintf("message %v", i))
+	}
+	assert.Greater(t, len(segmentFiles(t, dir)), 2)
+
+	for i := 0; i < 20; i++ {
+		v, aFn := readStr(t, buf)
+		assert.Equal(t, fmt.Sprintf("message %v", i), v)
+		require.NoError(t, aFn(ctx, nil))
+	}
+
+	// Only the active segment remains.
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
+
+func TestBufferWALSyncSealedAcks(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+segment_size_bytes: 100
+sync_policy: interval
+sync_interval: 10ms
+`, dir))
+	defer buf.Close(ctx)
+
+	for i := 0; i < 20; i++ {
+		writeStrs(t, buf, fmt.Sprintf("message %v", i))
+	}
+
+	// Acknowledging the first record of a sealed segment leaves its ack file
+	// to be flushed by the sync loop, even though the active segment has not
+	// been written to since.
+	_, aFn := readStr(t, buf)
+	require.NoError(t, aFn(ctx, nil))
+
+	buf.cond.L.Lock()
+	sealed := buf.segments[0]
+	require.True(t, sealed.sealed)
+	buf.cond.L.Unlock()
+
+	assert.Eventually(t, func() bool {
+		buf.cond.L.Lock()
+		defer buf.cond.L.Unlock()
+		return !sealed.ackDirty
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestBufferWALRecovery(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	conf := fmt.Sprintf(`
+path: %v
+segment_size_bytes: 100
+`, dir)
+
+	buf := walBufFromConf(t, conf)
+	for i := 0; i < 10; i++ {
+		writeStrs(t, buf, fmt.Sprintf("message %v", i))
+	}
+
+	var acks []service.AckFunc
+	for i := 0; i < 10; i++ {
+		v, aFn := readStr(t, buf)
+		assert.Equal(t, fmt.Sprintf("message %v", i), v)
+		acks = append(acks, aFn)
+	}
+	for i, aFn := range acks {
+		if i%2 == 0 {
+			require.NoError(t, aFn(ctx, nil))
+		}
+	}
+	require.NoError(t, buf.Close(ctx))
+
+	// Simulate a crash during a write by appending an incomplete record to the
+	// last segment.
+	segs := segmentFiles(t, dir)
+	sort.Strings(segs)
+	f, err := os.OpenFile(segs[len(segs)-1], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write(appendRecord(nil, []byte("incomplete"))[:12])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	buf = walBufFromConf(t, conf)
+	defer buf.Close(ctx)
+
+	var remaining []string
+	for i := 0; i < 5; i++ {
+		v, aFn := readStr(t, buf)
+		remaining = append(remaining, v)
+		require.NoError(t, aFn(ctx, nil))
+	}
+	assert.Equal(t, []string{"message 1", "message 3", "message 5", "message 7", "message 9"}, remaining)
+
+	buf.EndOfInput()
+	_, _, err = buf.ReadBatch(ctx)
+	assert.Equal(t, service.ErrEndOfBuffer, err)
+}
+
+func TestBufferWALMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+max_size_bytes: 100
+sync_policy: always
+`, dir))
+	defer buf.Close(ctx)
+
+	payload := string(make([]byte, 60))
+	writeStrs(t, buf, payload)
+
+	tCtx, done := context.WithTimeout(ctx, time.Millisecond*50)
+	err := buf.WriteBatch(tCtx, service.MessageBatch{service.NewMessage([]byte(payload))}, noopAck)
+	done()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- buf.WriteBatch(ctx, service.MessageBatch{service.NewMessage([]byte(payload))}, noopAck)
+	}()
+
+	select {
+	case err := <-writeErr:
+		t.Fatalf("write should be blocked: %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	_, aFn := readStr(t, buf)
+	require.NoError(t, aFn(ctx, nil))
+
+	select {
+	case err := <-writeErr:
+		require.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for blocked write")
+	}
+
+	v, aFn := readStr(t, buf)
+	assert.Equal(t, payload, v)
+	require.NoError(t, aFn(ctx, nil))
+}
+
+func TestBufferWALParallelReaders(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	buf := walBufFromConf(t, fmt.Sprintf(`
+path: %v
+segment_size_bytes: 1000
+`, dir))
+	defer buf.Close(ctx)
+
+	n := 500
+	go func() {
+		for i := 0; i < n; i++ {
+			writeStrs(t, buf, fmt.Sprintf("message %v", i))
+		}
+		buf.EndOfInput()
+	}()
+
+	var mut sync.Mutex
+	seen := map[string]int{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				b, aFn, err := buf.ReadBatch(ctx)
+				if err != nil {
+					assert.Equal(t, service.ErrEndOfBuffer, err)
+					return
+				}
+				mBytes, err := b[0].AsBytes()
+				assert.NoError(t, err)
+
+				// Reject every fifth delivery in order to exercise retries.
+				if j%5 == 0 {
+					assert.NoError(t, aFn(ctx, errors.New("nope")))
+					continue
+				}
+
+				mut.Lock()
+				seen[string(mBytes)]++
+				mut.Unlock()
+				assert.NoError(t, aFn(ctx, nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, seen, n)
+	for k, v := range seen {
+		assert.Equal(t, 1, v, k)
+	}
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
+
+func TestSegmentRecoverTornAck(t *testing.T) {
+	dir := t.TempDir()
+	log := service.MockResources().Logger()
+
+	seg, err := createSegment(dir, 1, true)
+	require.NoError(t, err)
+	var offsets []int64
+	for i := 0; i < 3; i++ {
+		offsets = append(offsets, seg.size)
+		require.NoError(t, seg.append(appendRecord(nil, []byte(fmt.Sprintf("message %v", i)))))
+	}
+	require.NoError(t, seg.ack(offsets[0], true))
+	require.NoError(t, seg.close())
+
+	// Simulate a crash during an ack by appending a partial ack.
+	_, ackPath := segmentPaths(dir, 1)
+	f, err := os.OpenFile(ackPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	seg, err = recoverSegment(dir, 1, true, log)
+	require.NoError(t, err)
+	assert.Equal(t, 1, seg.acked)
+
+	fi, err := os.Stat(ackPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), fi.Size())
+
+	// Acks written after recovery are read by subsequent recoveries.
+	require.NoError(t, seg.ack(offsets[2], true))
+	require.NoError(t, seg.close())
+
+	seg, err = recoverSegment(dir, 1, true, log)
+	require.NoError(t, err)
+	defer seg.close()
+	assert.Equal(t, 2, seg.acked)
+	assert.Equal(t, map[int64]struct{}{offsets[0]: {}, offsets[2]: {}}, seg.ackedOffsets)
+}
+
+func TestSegmentRecoverOversizedLength(t *testing.T) {
+	dir := t.TempDir()
+
+	seg, err := createSegment(dir, 1, false)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, seg.append(appendRecord(nil, []byte(fmt.Sprintf("message %v", i)))))
+	}
+	size := seg.size
+
+	// A torn header with a length far beyond the end of the file.
+	require.NoError(t, seg.append([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 'a'}))
+	require.NoError(t, seg.close())
+
+	segPath, _ := segmentPaths(dir, 1)
+	f, err := os.Open(segPath)
+	require.NoError(t, err)
+	var scanned int64
+	_, err = scanRecords(f, &scanned)
+	require.NoError(t, f.Close())
+	require.ErrorIs(t, err, errCorruptRecord)
+	assert.Equal(t, size, scanned)
+
+	seg, err = recoverSegment(dir, 1, false, service.MockResources().Logger())
+	require.NoError(t, err)
+	defer seg.close()
+	assert.Equal(t, 2, seg.records)
+	assert.Equal(t, size, seg.size)
+
+	fi, err := os.Stat(segPath)
+	require.NoError(t, err)
+	assert.Equal(t, size, fi.Size())
+}
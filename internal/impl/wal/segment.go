@@ -0,0 +1,353 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Each record within a segment consists of a header containing the length of
+// the payload and its checksum, followed by the payload.
+const recordHeaderLen = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptRecord = errors.New("record checksum does not match")
+
+func appendRecord(buffer, payload []byte) []byte {
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(len(payload)))
+	buffer = binary.BigEndian.AppendUint32(buffer, crc32.Checksum(payload, crcTable))
+	return append(buffer, payload...)
+}
+
+// segment is a file of records, accompanied by an ack file containing the
+// offsets of records that have been acknowledged.
+type segment struct {
+	seq     uint64
+	file    *os.File
+	ackFile *os.File
+
+	size       int64
+	readOffset int64
+	records    int
+	acked      int
+	sealed     bool
+
+	// Whether the directory is flushed when the segment is created or removed,
+	// and whether acknowledgements have been written since the ack file was
+	// last flushed.
+	durable  bool
+	ackDirty bool
+
+	// Offsets of records that were acknowledged before the segment was
+	// recovered, which are skipped when reading.
+	ackedOffsets map[int64]struct{}
+}
+
+func segmentPaths(dir string, seq uint64) (string, string) {
+	name := fmt.Sprintf("%020d", seq)
+	return filepath.Join(dir, name+".wal"), filepath.Join(dir, name+".ack")
+}
+
+// syncDir flushes a directory to disk so that the files created or deleted
+// within it survive a crash of the machine.
+func syncDir(dir string) error {
+	// Directories cannot be flushed on Windows, where file metadata is written
+	// through instead.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.Sync(), d.Close())
+}
+
+func createSegment(dir string, seq uint64, durable bool) (*segment, error) {
+	segPath, ackPath := segmentPaths(dir, seq)
+	file, err := os.OpenFile(segPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	ackFile, err := os.OpenFile(ackPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	seg := &segment{seq: seq, file: file, ackFile: ackFile, durable: durable}
+	if durable {
+		if err := syncDir(dir); err != nil {
+			_ = seg.close()
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// recoverSegments opens the segments within a directory in the order that
+// they were written, truncating incomplete or corrupt records and deleting
+// segments that were fully acknowledged.
+func recoverSegments(dir string, durable bool, log *service.Logger) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []uint64
+	for _, e := range entries {
+		name, isSegment := strings.CutSuffix(e.Name(), ".wal")
+		if !isSegment || e.IsDir() {
+			continue
+		}
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+
+	var segments []*segment
+	for _, seq := range seqs {
+		seg, err := recoverSegment(dir, seq, durable, log)
+		if err != nil {
+			for _, s := range segments {
+				_ = s.close()
+			}
+			return nil, fmt.Errorf("failed to recover segment %v: %w", seq, err)
+		}
+		if seg.acked == seg.records {
+			if err := seg.remove(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+func recoverSegment(dir string, seq uint64, durable bool, log *service.Logger) (seg *segment, err error) {
+	segPath, ackPath := segmentPaths(dir, seq)
+
+	seg = &segment{seq: seq, sealed: true, durable: durable, ackedOffsets: map[int64]struct{}{}}
+	if seg.file, err = os.OpenFile(segPath, os.O_RDWR, 0o644); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = seg.close()
+		}
+	}()
+
+	var offsets map[int64]struct{}
+	if offsets, err = scanRecords(seg.file, &seg.size); err != nil {
+		if !errors.Is(err, errCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		log.Warnf("Truncating segment %v at offset %v: %v", seq, seg.size, err)
+		if err = seg.file.Truncate(seg.size); err != nil {
+			return nil, err
+		}
+		if durable {
+			if err = seg.file.Sync(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	seg.records = len(offsets)
+
+	ackBytes, err := os.ReadFile(ackPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// A torn trailing ack is removed so that subsequent acks are appended at
+	// an aligned offset.
+	tornAck := len(ackBytes)%8 != 0
+	if tornAck {
+		log.Warnf("Truncating ack file of segment %v at offset %v", seq, len(ackBytes)&^7)
+		if err = os.Truncate(ackPath, int64(len(ackBytes)&^7)); err != nil {
+			return nil, err
+		}
+	}
+	for len(ackBytes) >= 8 {
+		offset := int64(binary.BigEndian.Uint64(ackBytes))
+		ackBytes = ackBytes[8:]
+		if _, exists := offsets[offset]; !exists {
+			continue
+		}
+		if _, exists := seg.ackedOffsets[offset]; !exists {
+			seg.ackedOffsets[offset] = struct{}{}
+			seg.acked++
+		}
+	}
+
+	if seg.ackFile, err = os.OpenFile(ackPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	if tornAck && durable {
+		if err = seg.ackFile.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// scanRecords validates each record of a segment file and returns their
+// offsets, setting size to the end of the last valid record.
+func scanRecords(file *os.File, size *int64) (map[int64]struct{}, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := map[int64]struct{}{}
+	r := bufio.NewReaderSize(file, 1024*1024)
+
+	header := make([]byte, recordHeaderLen)
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offsets, nil
+			}
+			return offsets, err
+		}
+		// The length of a torn header may exceed the remainder of the file,
+		// in which case the record is corrupt.
+		length := binary.BigEndian.Uint32(header)
+		if int64(length) > fi.Size()-*size-recordHeaderLen {
+			return offsets, errCorruptRecord
+		}
+		if cap(payload) < int(length) {
+			payload = make([]byte, length)
+		}
+		payload = payload[:length]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return offsets, err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			return offsets, errCorruptRecord
+		}
+		offsets[*size] = struct{}{}
+		*size += recordHeaderLen + int64(length)
+	}
+}
+
+func (s *segment) append(record []byte) error {
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return err
+	}
+	s.size += int64(len(record))
+	s.records++
+	return nil
+}
+
+func (s *segment) readHeader(offset int64) (uint32, error) {
+	header := make([]byte, recordHeaderLen)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(header), nil
+}
+
+func (s *segment) readPayload(offset int64, length uint32) ([]byte, error) {
+	record := make([]byte, recordHeaderLen+int(length))
+	if _, err := s.file.ReadAt(record, offset); err != nil {
+		return nil, err
+	}
+	payload := record[recordHeaderLen:]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(record[4:]) {
+		return nil, errCorruptRecord
+	}
+	return payload, nil
+}
+
+func (s *segment) ack(offset int64, sync bool) error {
+	if _, err := s.ackFile.Write(binary.BigEndian.AppendUint64(nil, uint64(offset))); err != nil {
+		return err
+	}
+	s.acked++
+	if sync {
+		return s.ackFile.Sync()
+	}
+	s.ackDirty = true
+	return nil
+}
+
+// syncAcks flushes the ack file if acknowledgements have been written since it
+// was last flushed.
+func (s *segment) syncAcks() error {
+	if !s.ackDirty {
+		return nil
+	}
+	if err := s.ackFile.Sync(); err != nil {
+		return err
+	}
+	s.ackDirty = false
+	return nil
+}
+
+func (s *segment) sync() error {
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	if err := s.ackFile.Sync(); err != nil {
+		return err
+	}
+	s.ackDirty = false
+	return nil
+}
+
+func (s *segment) close() error {
+	var errs []error
+	if s.file != nil {
+		errs = append(errs, s.file.Close())
+	}
+	if s.ackFile != nil {
+		errs = append(errs, s.ackFile.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *segment) remove() error {
+	dir := filepath.Dir(s.file.Name())
+	segPath, ackPath := segmentPaths(dir, s.seq)
+	if err := errors.Join(s.close(), os.Remove(segPath), os.Remove(ackPath)); err != nil {
+		return err
+	}
+	if s.durable {
+		return syncDir(dir)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	// FieldCache is the name of the response cache config field.
+	FieldCache         = "cache"
+	fieldCacheResource = "resource"
+	fieldCacheTTL      = "ttl"
+)
+
+// CacheField returns the config field used to configure a response cache for
+// an LLM processor.
+func CacheField() *service.ConfigField {
+	return service.NewObjectField(FieldCache,
+		service.NewStringField(fieldCacheResource).
+			Description("The name of the xref:components:caches/about.adoc[cache resource] to store responses in."),
+		service.NewDurationField(fieldCacheTTL).
+			Description("An optional TTL to set for cached responses, if supported by the cache resource.").
+			Example("24h").
+			Optional(),
+	).
+		Description("Cache model responses in a cache resource, keyed by a hash of the model and the fully rendered request. Identical requests are then served from the cache without calling the model.").
+		Optional().
+		Advanced()
+}
+
+// ResponseCache stores model responses within a cache resource. A nil
+// ResponseCache is valid and never stores or returns anything.
+type ResponseCache struct {
+	mgr      *service.Resources
+	resource string
+	ttl      *time.Duration
+
+	hits   *service.MetricCounter
+	misses *service.MetricCounter
+}
+
+// NewResponseCacheFromParsed creates a ResponseCache from a parsed config
+// containing a CacheField. If the field is not set then nil is returned.
+func NewResponseCacheFromParsed(conf *service.ParsedConfig, mgr *service.Resources) (*ResponseCache, error) {
+	if !conf.Contains(FieldCache) {
+		return nil, nil
+	}
+	conf = conf.Namespace(FieldCache)
+	resource, err := conf.FieldString(fieldCacheResource)
+	if err != nil {
+		return nil, err
+	}
+	if !mgr.HasCache(resource) {
+		return nil, fmt.Errorf("cache resource '%v' was not found", resource)
+	}
+	c := &ResponseCache{
+		mgr:      mgr,
+		resource: resource,
+		hits:     mgr.Metrics().NewCounter("llm_cache_hits"),
+		misses:   mgr.Metrics().NewCounter("llm_cache_misses"),
+	}
+	if conf.Contains(fieldCacheTTL) {
+		ttl, err := conf.FieldDuration(fieldCacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		c.ttl = &ttl
+	}
+	return c, nil
+}
+
+// CacheKey returns the key used to cache the response to the given request,
+// which is a hash of its JSON serialization. The request should contain the
+// model along with everything that was rendered for the message.
+func CacheKey(req any) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Lookup attempts to find a cached response for a request, returning the key
+// that the response should be stored under with Store on a cache miss.
+//
+// Errors from the cache are logged and treated as a miss so that a flaky
+// cache never fails a message that could be served by the model.
+func (c *ResponseCache) Lookup(ctx context.Context, req any) (key string, value []byte, ok bool) {
+	if c == nil {
+		return
+	}
+	var err error
+	if key, err = CacheKey(req); err != nil {
+		c.mgr.Logger().With("error", err).Warn("Failed to compute LLM response cache key")
+		return "", nil, false
+	}
+	if aerr := c.mgr.AccessCache(ctx, c.resource, func(cache service.Cache) {
+		value, err = cache.Get(ctx, key)
+	}); aerr != nil {
+		err = aerr
+	}
+	if err != nil {
+		if !errors.Is(err, service.ErrKeyNotFound) {
+			c.mgr.Logger().With("error", err).Warn("Failed to read from LLM response cache")
+		}
+		c.misses.Incr(1)
+		return key, nil, false
+	}
+	c.hits.Incr(1)
+	return key, value, true
+}
+
+// Store saves a response under a key obtained from Lookup.
+func (c *ResponseCache) Store(ctx context.Context, key string, value []byte) {
+	if c == nil || key == "" {
+		return
+	}
+	var err error
+	if aerr := c.mgr.AccessCache(ctx, c.resource, func(cache service.Cache) {
+		err = cache.Set(ctx, key, value, c.ttl)
+	}); aerr != nil {
+		err = aerr
+	}
+	if err != nil {
+		c.mgr.Logger().With("error", err).Warn("Failed to write to LLM response cache")
+	}
+}
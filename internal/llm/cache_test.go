@@ -0,0 +1,67 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+func TestResponseCache(t *testing.T) {
+	spec := service.NewConfigSpec().Field(CacheField())
+	conf, err := spec.ParseYAML(`
+cache:
+  resource: llm_cache
+`, nil)
+	require.NoError(t, err)
+	res := service.MockResources(service.MockResourcesOptAddCache("llm_cache"))
+	c, err := NewResponseCacheFromParsed(conf, res)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	ctx := context.Background()
+	key, _, ok := c.Lookup(ctx, testRequest{"foo", "hello"})
+	require.False(t, ok)
+	c.Store(ctx, key, []byte("world"))
+
+	_, v, ok := c.Lookup(ctx, testRequest{"foo", "hello"})
+	require.True(t, ok)
+	assert.Equal(t, "world", string(v))
+
+	// Different models must not share responses
+	_, _, ok = c.Lookup(ctx, testRequest{"bar", "hello"})
+	require.False(t, ok)
+}
+
+func TestResponseCacheMissingResource(t *testing.T) {
+	spec := service.NewConfigSpec().Field(CacheField())
+	conf, err := spec.ParseYAML(`
+cache:
+  resource: nope
+`, nil)
+	require.NoError(t, err)
+	_, err = NewResponseCacheFromParsed(conf, service.MockResources())
+	require.Error(t, err)
+}
+
+func TestNilResponseCache(t *testing.T) {
+	var c *ResponseCache
+	key, _, ok := c.Lookup(context.Background(), testRequest{"foo", "hello"})
+	require.False(t, ok)
+	c.Store(context.Background(), key, []byte("world"))
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	// FieldSchemaValidation is the name of the schema validation config field.
+	FieldSchemaValidation           = "schema_validation"
+	fieldSchemaValidationEnabled    = "enabled"
+	fieldSchemaValidationMaxRetries = "max_retries"
+)
+
+// SchemaValidationField returns the config field used to enable validating
+// structured output against the JSON schema given to the model.
+func SchemaValidationField() *service.ConfigField {
+	return service.NewObjectField(FieldSchemaValidation,
+		service.NewBoolField(fieldSchemaValidationEnabled).
+			Description("Whether to validate responses against the JSON schema.").
+			Default(false),
+		service.NewIntField(fieldSchemaValidationMaxRetries).
+			Description("The maximum number of times to re-prompt the model with the validation errors of a response before failing the message.").
+			Default(2).
+			LintRule(`root = if this < 0 { [ "field must be greater than or equal to 0" ] }`),
+	).
+		Description("Validate that responses in `json_schema` format actually match the schema. When a response is invalid the model is re-prompted with the validation errors, and if the response is still invalid once the retries are exhausted then the message fails with an error describing why.").
+		Optional().
+		Advanced()
+}
+
+// Role is the role of a participant in a conversation with a model.
+type Role string
+
+// The roles of participants in a conversation.
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Turn is a single message in a conversation with a model.
+type Turn struct {
+	Role    Role
+	Content string
+}
+
+// CompleteFn requests a completion from a model. The follow up turns are to be
+// added to the end of the original conversation.
+type CompleteFn func(ctx context.Context, followUp []Turn) (string, error)
+
+// ExtractionError is returned when a model fails to produce output that
+// matches a schema.
+type ExtractionError struct {
+	Attempts int
+	Errors   []string
+}
+
+func (e *ExtractionError) Error() string {
+	return fmt.Sprintf("response did not match the JSON schema after %d attempt(s): %s", e.Attempts, strings.Join(e.Errors, "; "))
+}
+
+// Extractor requests structured output from a model, validating it against a
+// JSON schema and re-prompting the model when it's invalid.
+type Extractor struct {
+	maxRetries int
+
+	mu     sync.Mutex
+	raw    string
+	schema *gojsonschema.Schema
+}
+
+// NewExtractorFromParsed creates an Extractor from a parsed config containing
+// a SchemaValidationField. If validation is not enabled then nil is returned.
+func NewExtractorFromParsed(conf *service.ParsedConfig) (*Extractor, error) {
+	conf = conf.Namespace(FieldSchemaValidation)
+	if enabled, err := conf.FieldBool(fieldSchemaValidationEnabled); err != nil || !enabled {
+		return nil, err
+	}
+	maxRetries, err := conf.FieldInt(fieldSchemaValidationMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	return NewExtractor(maxRetries), nil
+}
+
+// NewExtractor creates an Extractor that re-prompts a model up to maxRetries
+// times.
+func NewExtractor(maxRetries int) *Extractor {
+	return &Extractor{maxRetries: maxRetries}
+}
+
+// compile returns the compiled form of a schema, which is cached as it's
+// almost always the same schema between calls.
+func (e *Extractor) compile(schema []byte) (*gojsonschema.Schema, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.schema != nil && e.raw == string(schema) {
+		return e.schema, nil
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile JSON schema: %w", err)
+	}
+	e.raw, e.schema = string(schema), s
+	return s, nil
+}
+
+// Validate returns the reasons that a completion doesn't match a schema, if
+// any.
+func (e *Extractor) Validate(schema []byte, completion string) ([]string, error) {
+	s, err := e.compile(schema)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Validate(gojsonschema.NewStringLoader(completion))
+	if err != nil {
+		// The completion isn't even valid JSON
+		return []string{err.Error()}, nil
+	}
+	var errs []string
+	for _, re := range res.Errors() {
+		errs = append(errs, re.String())
+	}
+	return errs, nil
+}
+
+// Extract requests a completion that matches the schema, re-prompting the
+// model with the validation errors of each invalid completion.
+func (e *Extractor) Extract(ctx context.Context, schema []byte, complete CompleteFn) (string, error) {
+	var followUp []Turn
+	for attempt := 1; ; attempt++ {
+		completion, err := complete(ctx, followUp)
+		if err != nil {
+			return "", err
+		}
+		errs, err := e.Validate(schema, completion)
+		if err != nil {
+			return "", err
+		}
+		if len(errs) == 0 {
+			return completion, nil
+		}
+		if attempt > e.maxRetries {
+			return "", &ExtractionError{Attempts: attempt, Errors: errs}
+		}
+		followUp = append(followUp,
+			Turn{Role: RoleAssistant, Content: completion},
+			Turn{Role: RoleUser, Content: retryPrompt(errs)},
+		)
+	}
+}
+
+func retryPrompt(errs []string) string {
+	var sb strings.Builder
+	sb.WriteString("Your previous response did not match the required JSON schema for the following reasons:\n")
+	for _, err := range errs {
+		sb.WriteString("- ")
+		sb.WriteString(err)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("Respond again with only JSON that matches the schema.")
+	return sb.String()
+}
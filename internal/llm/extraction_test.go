@@ -0,0 +1,73 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+  "type": "object",
+  "properties": {"name": {"type": "string"}, "age": {"type": "integer"}},
+  "required": ["name", "age"]
+}`
+
+func TestExtractorValidate(t *testing.T) {
+	e := NewExtractor(0)
+
+	errs, err := e.Validate([]byte(testSchema), `{"name":"bob","age":42}`)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	errs, err = e.Validate([]byte(testSchema), `{"name":"bob"}`)
+	require.NoError(t, err)
+	assert.Len(t, errs, 1)
+
+	errs, err = e.Validate([]byte(testSchema), `not json`)
+	require.NoError(t, err)
+	assert.Len(t, errs, 1)
+
+	_, err = e.Validate([]byte(`{"type": 5}`), `{}`)
+	assert.Error(t, err)
+}
+
+func TestExtractorRetries(t *testing.T) {
+	responses := []string{`{"name":"bob"}`, `{"name":"bob","age":42}`}
+	var calls [][]Turn
+	out, err := NewExtractor(2).Extract(context.Background(), []byte(testSchema), func(_ context.Context, followUp []Turn) (string, error) {
+		calls = append(calls, followUp)
+		return responses[len(calls)-1], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"bob","age":42}`, out)
+
+	require.Len(t, calls, 2)
+	assert.Empty(t, calls[0])
+	require.Len(t, calls[1], 2)
+	assert.Equal(t, Turn{Role: RoleAssistant, Content: `{"name":"bob"}`}, calls[1][0])
+	assert.Equal(t, RoleUser, calls[1][1].Role)
+	assert.Contains(t, calls[1][1].Content, "age is required")
+}
+
+func TestExtractorExhaustsRetries(t *testing.T) {
+	attempts := 0
+	_, err := NewExtractor(1).Extract(context.Background(), []byte(testSchema), func(context.Context, []Turn) (string, error) {
+		attempts++
+		return `{"age":"old"}`, nil
+	})
+	var extractErr *ExtractionError
+	require.ErrorAs(t, err, &extractErr)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, extractErr.Attempts)
+	assert.Len(t, extractErr.Errors, 2)
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	// FieldRateLimit is the name of the token rate limit config field.
+	FieldRateLimit                = "rate_limit"
+	fieldRateLimitTokensPerMinute = "tokens_per_minute"
+)
+
+// RateLimitField returns the config field used to configure a token aware
+// rate limit for an LLM processor.
+func RateLimitField() *service.ConfigField {
+	return service.NewObjectField(FieldRateLimit,
+		service.NewIntField(fieldRateLimitTokensPerMinute).
+			Description("The maximum number of tokens (prompt and completion) to consume per minute.").
+			Example(90000).
+			LintRule(`root = if this <= 0 { [ "field must be greater than 0" ] }`),
+	).
+		Description("Pace requests against a token budget. Prompt sizes are estimated before each request and corrected with the token usage reported by the model afterwards. When the provider reports via `x-ratelimit-*` response headers that the budget is exhausted, requests are paused until it resets.").
+		Optional().
+		Advanced()
+}
+
+// TokenLimiter paces requests against a budget of tokens per window. A nil
+// TokenLimiter is valid and never blocks.
+type TokenLimiter struct {
+	budget int
+	window time.Duration
+	now    func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	used        int
+	pausedUntil time.Time
+}
+
+// NewTokenLimiterFromParsed creates a TokenLimiter from a parsed config
+// containing a RateLimitField. If the field is not set then nil is returned.
+func NewTokenLimiterFromParsed(conf *service.ParsedConfig) (*TokenLimiter, error) {
+	if !conf.Contains(FieldRateLimit) {
+		return nil, nil
+	}
+	tpm, err := conf.FieldInt(FieldRateLimit, fieldRateLimitTokensPerMinute)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenLimiter(tpm, time.Minute), nil
+}
+
+// NewTokenLimiter creates a TokenLimiter that allows budget tokens to be
+// consumed every window.
+func NewTokenLimiter(budget int, window time.Duration) *TokenLimiter {
+	return &TokenLimiter{
+		budget: budget,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// EstimateTokens returns a rough estimate of the number of tokens within some
+// text, for use before the real usage is known.
+func EstimateTokens(text string) int {
+	// Most tokenizers average about four bytes per token for English text.
+	return (len(text) + 3) / 4
+}
+
+// Acquire blocks until the estimated number of tokens can be consumed within
+// the budget, or the context is cancelled. A request is always allowed when
+// nothing has been consumed yet in the current window, so that estimates
+// larger than the budget don't block forever.
+func (l *TokenLimiter) Acquire(ctx context.Context, estimate int) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		wait := l.tryAcquire(estimate)
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *TokenLimiter) tryAcquire(estimate int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		l.used = 0
+	}
+	if l.used > 0 && l.used+estimate > l.budget {
+		return l.windowStart.Add(l.window).Sub(now)
+	}
+	l.used += estimate
+	return 0
+}
+
+// Release corrects a previously acquired estimate with the number of tokens
+// that were actually consumed by the request.
+func (l *TokenLimiter) Release(estimate, actual int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used = max(l.used+actual-estimate, 0)
+}
+
+// ObserveHeaders pauses all requests when the `x-ratelimit-*` headers of a
+// response indicate that the token budget of the provider is exhausted.
+func (l *TokenLimiter) ObserveHeaders(h http.Header) {
+	if l == nil || h == nil {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get("x-ratelimit-remaining-tokens"))
+	if err != nil || remaining > 0 {
+		return
+	}
+	reset, err := time.ParseDuration(h.Get("x-ratelimit-reset-tokens"))
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(reset); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// UsageMetrics reports the number of tokens consumed by a model. A nil
+// UsageMetrics is valid and reports nothing.
+type UsageMetrics struct {
+	promptTokens     *service.MetricCounter
+	completionTokens *service.MetricCounter
+}
+
+// NewUsageMetrics creates metrics for reporting token usage labelled by model.
+func NewUsageMetrics(mgr *service.Resources) *UsageMetrics {
+	return &UsageMetrics{
+		promptTokens:     mgr.Metrics().NewCounter("llm_prompt_tokens", "model"),
+		completionTokens: mgr.Metrics().NewCounter("llm_completion_tokens", "model"),
+	}
+}
+
+// Record reports the tokens consumed by a single request to a model.
+func (u *UsageMetrics) Record(model string, prompt, completion int) {
+	if u == nil {
+		return
+	}
+	u.promptTokens.Incr(int64(prompt), model)
+	u.completionTokens.Incr(int64(completion), model)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenLimiterBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewTokenLimiter(100, time.Minute)
+	l.now = func() time.Time { return now }
+
+	assert.Zero(t, l.tryAcquire(60))
+	assert.Equal(t, time.Minute, l.tryAcquire(60))
+
+	// The real usage was lower than the estimate, which frees up budget.
+	l.Release(60, 30)
+	assert.Zero(t, l.tryAcquire(60))
+
+	now = now.Add(time.Minute)
+	assert.Zero(t, l.tryAcquire(100))
+}
+
+func TestTokenLimiterOversizedEstimate(t *testing.T) {
+	l := NewTokenLimiter(10, time.Minute)
+	require.NoError(t, l.Acquire(context.Background(), 1000))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Acquire(ctx, 1), context.DeadlineExceeded)
+}
+
+func TestTokenLimiterHeaders(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewTokenLimiter(100, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.ObserveHeaders(http.Header{
+		"X-Ratelimit-Remaining-Tokens": []string{"10"},
+		"X-Ratelimit-Reset-Tokens":     []string{"5s"},
+	})
+	assert.Zero(t, l.tryAcquire(1))
+
+	l.ObserveHeaders(http.Header{
+		"X-Ratelimit-Remaining-Tokens": []string{"0"},
+		"X-Ratelimit-Reset-Tokens":     []string{"5s"},
+	})
+	assert.Equal(t, 5*time.Second, l.tryAcquire(1))
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *TokenLimiter
+	require.NoError(t, l.Acquire(context.Background(), 1000))
+	l.Release(1000, 10)
+	l.ObserveHeaders(nil)
+}
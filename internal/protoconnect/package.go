@@ -12,6 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:generate protoc -I=../../proto/redpanda/api/connect/v1alpha1 --go_out=../.. status.proto
+//go:generate protoc -I=../../proto/redpanda/api/connect/v1alpha1 --go_out=../.. --go-grpc_out=../.. status.proto status_service.proto log_event.proto
 
 package protoconnect
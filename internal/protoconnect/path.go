@@ -0,0 +1,36 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protoconnect
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SliceToDotPath converts the path of a component to the dot path of the
+// status messages, following
+// https://docs.redpanda.com/redpanda-connect/configuration/field_paths/
+func SliceToDotPath(path []string) string {
+	var b bytes.Buffer
+	for i, s := range path {
+		s = strings.ReplaceAll(s, "~", "~0")
+		s = strings.ReplaceAll(s, ".", "~1")
+		b.WriteString(s)
+		if i < len(path)-1 {
+			b.WriteRune('.')
+		}
+	}
+	return b.String()
+}
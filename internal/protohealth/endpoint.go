@@ -23,15 +23,19 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+
+	"github.com/redpanda-data/connect/v4/internal/protoconnect"
 )
 
-// Endpoint hosts a grpc health endpoint at the specified port.
+// Endpoint hosts a grpc health endpoint at the specified port, alongside a
+// status service describing the running stream.
 // No TLS is wrapped around this; it's for k8s consumption.
 type Endpoint struct {
 	port    int16
 	srv     *grpc.Server
 	running atomic.Bool
 	signal  chan struct{}
+	status  *Status
 	grpc_health_v1.UnimplementedHealthServer
 }
 
@@ -43,12 +47,20 @@ func NewEndpoint(port int16) *Endpoint {
 		port:   port,
 		srv:    srv,
 		signal: make(chan struct{}),
+		status: NewStatus(),
 	}
 	grpc_health_v1.RegisterHealthServer(srv, e)
+	protoconnect.RegisterStatusServiceServer(srv, e.status)
 
 	return e
 }
 
+// Status returns the Status served by the endpoint, which should be updated
+// as the stream runs.
+func (e *Endpoint) Status() *Status {
+	return e.status
+}
+
 // Run listens on the supplied GRPC health endpoint for unencrypted connections
 func (e *Endpoint) Run(ctx context.Context) error {
 	e.running.Store(true)
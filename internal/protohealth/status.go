@@ -0,0 +1,278 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protohealth
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/redpanda-data/connect/v4/internal/protoconnect"
+)
+
+const (
+	maxRecentErrors    = 50
+	statusPollInterval = time.Second
+)
+
+// Status tracks the state of the running stream and serves it over the grpc
+// StatusService. All methods are safe to call on a nil Status, in which case
+// they do nothing.
+type Status struct {
+	protoconnect.UnimplementedStatusServiceServer
+
+	mut          sync.Mutex
+	instanceID   string
+	pipelineID   string
+	exiting      bool
+	exitErr      *protoconnect.ExitError
+	summary      *service.RunningStreamSummary
+	recentErrors []*protoconnect.RecentError
+	changed      chan struct{}
+}
+
+// NewStatus constructs a Status for an instance that has not yet parsed a
+// config.
+func NewStatus() *Status {
+	return &Status{
+		changed: make(chan struct{}),
+	}
+}
+
+// notifyLocked wakes all watchers, the mutex must be held.
+func (s *Status) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// SetInstanceID sets the unique identifier of the connect instance.
+func (s *Status) SetInstanceID(id string) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.instanceID = id
+	s.notifyLocked()
+}
+
+// SetConfigParsed marks the instance as initializing a newly parsed config,
+// resetting the state of any stream previously run.
+func (s *Status) SetConfigParsed(pipelineID string) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.pipelineID = pipelineID
+	s.summary = nil
+	s.exiting = false
+	s.exitErr = nil
+	s.notifyLocked()
+}
+
+// SetStreamSummary sets the summary of the running stream, from which the
+// connection state of each input and output is read.
+func (s *Status) SetStreamSummary(summary *service.RunningStreamSummary) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.summary = summary
+	s.notifyLocked()
+}
+
+// SetStopped marks the instance as exiting, either by intention or due to an
+// issue described in the provided error.
+func (s *Status) SetStopped(err error) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.exiting = true
+	s.exitErr = nil
+	if err != nil {
+		s.exitErr = &protoconnect.ExitError{Message: err.Error()}
+	}
+	s.notifyLocked()
+}
+
+func (s *Status) recordError(e *protoconnect.RecentError) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if len(s.recentErrors) >= maxRecentErrors {
+		s.recentErrors = s.recentErrors[1:]
+	}
+	s.recentErrors = append(s.recentErrors, e)
+	s.notifyLocked()
+}
+
+// snapshot returns the current status along with a channel that is closed the
+// next time the status changes.
+func (s *Status) snapshot() (*protoconnect.InstanceStatus, <-chan struct{}) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	st := &protoconnect.InstanceStatus{
+		Type:         protoconnect.StatusEvent_TYPE_INITIALIZING,
+		PipelineId:   s.pipelineID,
+		InstanceId:   s.instanceID,
+		Timestamp:    time.Now().Unix(),
+		RecentErrors: append([]*protoconnect.RecentError(nil), s.recentErrors...),
+		ExitError:    s.exitErr,
+	}
+
+	if s.summary != nil {
+		st.Type = protoconnect.StatusEvent_TYPE_CONNECTION_HEALTHY
+		for _, c := range s.summary.ConnectionStatuses() {
+			cs := &protoconnect.ComponentStatus{
+				Path:      protoconnect.SliceToDotPath(c.Path()),
+				Connected: c.Active(),
+			}
+			if l := c.Label(); l != "" {
+				cs.Label = &l
+			}
+			if err := c.Err(); err != nil {
+				msg := err.Error()
+				cs.Error = &msg
+			}
+			if !cs.Connected {
+				st.Type = protoconnect.StatusEvent_TYPE_CONNECTION_ERROR
+			}
+			st.Components = append(st.Components, cs)
+		}
+	}
+	if s.exiting {
+		st.Type = protoconnect.StatusEvent_TYPE_EXITING
+	}
+	return st, s.changed
+}
+
+// GetStatus returns the current status of the instance.
+func (s *Status) GetStatus(context.Context, *protoconnect.GetStatusRequest) (*protoconnect.GetStatusResponse, error) {
+	st, _ := s.snapshot()
+	return &protoconnect.GetStatusResponse{Status: st}, nil
+}
+
+// WatchStatus sends the current status of the instance and then sends it again
+// each time it changes. Connection states are not pushed by the stream and are
+// therefore polled periodically.
+func (s *Status) WatchStatus(_ *protoconnect.WatchStatusRequest, server protoconnect.StatusService_WatchStatusServer) error {
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	var last *protoconnect.InstanceStatus
+	for {
+		st, changed := s.snapshot()
+		if last == nil || !statusEqual(last, st) {
+			if err := server.Send(&protoconnect.WatchStatusResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-server.Context().Done():
+			return server.Context().Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// statusEqual compares two statuses whilst ignoring the time they were
+// captured.
+func statusEqual(a, b *protoconnect.InstanceStatus) bool {
+	a = proto.Clone(a).(*protoconnect.InstanceStatus)
+	b = proto.Clone(b).(*protoconnect.InstanceStatus)
+	a.Timestamp, b.Timestamp = 0, 0
+	return proto.Equal(a, b)
+}
+
+// LogHandler returns a slog.Handler that records error level logs as recent
+// errors before passing all logs to next, which may be nil.
+func (s *Status) LogHandler(next slog.Handler) slog.Handler {
+	if s == nil {
+		return next
+	}
+	return &errorRecorder{s: s, next: next}
+}
+
+type errorRecorder struct {
+	s     *Status
+	next  slog.Handler
+	attrs []slog.Attr
+}
+
+func (e *errorRecorder) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelError || (e.next != nil && e.next.Enabled(ctx, level))
+}
+
+func (e *errorRecorder) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		re := &protoconnect.RecentError{
+			Message:   r.Message,
+			Timestamp: r.Time.Unix(),
+		}
+		setAttr := func(a slog.Attr) bool {
+			switch a.Key {
+			case "path":
+				v := a.Value.String()
+				re.Path = &v
+			case "label":
+				if v := a.Value.String(); v != "" {
+					re.Label = &v
+				}
+			case "error", "err":
+				re.Message += ": " + a.Value.String()
+			}
+			return true
+		}
+		for _, a := range e.attrs {
+			setAttr(a)
+		}
+		r.Attrs(setAttr)
+		e.s.recordError(re)
+	}
+	if e.next != nil && e.next.Enabled(ctx, r.Level) {
+		return e.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (e *errorRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var next slog.Handler
+	if e.next != nil {
+		next = e.next.WithAttrs(attrs)
+	}
+	return &errorRecorder{
+		s:     e.s,
+		next:  next,
+		attrs: append(append([]slog.Attr(nil), e.attrs...), attrs...),
+	}
+}
+
+func (e *errorRecorder) WithGroup(name string) slog.Handler {
+	var next slog.Handler
+	if e.next != nil {
+		next = e.next.WithGroup(name)
+	}
+	return &errorRecorder{s: e.s, next: next, attrs: e.attrs}
+}
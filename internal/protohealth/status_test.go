@@ -0,0 +1,132 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protohealth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/redpanda-data/connect/v4/internal/protoconnect"
+)
+
+func testStatusClient(t *testing.T, s *Status) protoconnect.StatusServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	protoconnect.RegisterStatusServiceServer(srv, s)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return protoconnect.NewStatusServiceClient(conn)
+}
+
+func TestStatusGet(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+
+	s := NewStatus()
+	s.SetInstanceID("foo")
+	s.SetConfigParsed("bar")
+
+	client := testStatusClient(t, s)
+
+	res, err := client.GetStatus(ctx, &protoconnect.GetStatusRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, protoconnect.StatusEvent_TYPE_INITIALIZING, res.Status.Type)
+	assert.Equal(t, "foo", res.Status.InstanceId)
+	assert.Equal(t, "bar", res.Status.PipelineId)
+	assert.Empty(t, res.Status.RecentErrors)
+	assert.Nil(t, res.Status.ExitError)
+
+	logger := slog.New(s.LogHandler(nil))
+	logger.With("path", "root.input", "label", "meow").Error("failed to connect", "error", "nope")
+	logger.Warn("not recorded")
+
+	s.SetStopped(errors.New("buh"))
+
+	res, err = client.GetStatus(ctx, &protoconnect.GetStatusRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, protoconnect.StatusEvent_TYPE_EXITING, res.Status.Type)
+	require.NotNil(t, res.Status.ExitError)
+	assert.Equal(t, "buh", res.Status.ExitError.Message)
+
+	require.Len(t, res.Status.RecentErrors, 1)
+	rErr := res.Status.RecentErrors[0]
+	assert.Equal(t, "failed to connect: nope", rErr.Message)
+	assert.Equal(t, "root.input", rErr.GetPath())
+	assert.Equal(t, "meow", rErr.GetLabel())
+}
+
+func TestStatusRecentErrorsCapped(t *testing.T) {
+	s := NewStatus()
+	logger := slog.New(s.LogHandler(nil))
+	for i := 0; i < maxRecentErrors+10; i++ {
+		logger.Error("meow")
+	}
+
+	st, _ := s.snapshot()
+	assert.Len(t, st.RecentErrors, maxRecentErrors)
+}
+
+func TestStatusWatch(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+
+	s := NewStatus()
+	client := testStatusClient(t, s)
+
+	stream, err := client.WatchStatus(ctx, &protoconnect.WatchStatusRequest{})
+	require.NoError(t, err)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, protoconnect.StatusEvent_TYPE_INITIALIZING, res.Status.Type)
+	assert.Equal(t, "", res.Status.PipelineId)
+
+	s.SetConfigParsed("bar")
+
+	res, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", res.Status.PipelineId)
+
+	s.SetStopped(nil)
+
+	res, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, protoconnect.StatusEvent_TYPE_EXITING, res.Status.Type)
+	assert.Nil(t, res.Status.ExitError)
+}
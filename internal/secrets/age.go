@@ -0,0 +1,147 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	// AgeKeyEnv is the environment variable that may contain age identities,
+	// which matches the variable read by SOPS.
+	AgeKeyEnv = "SOPS_AGE_KEY"
+
+	// AgeKeyFileEnv is the environment variable that may contain the path of a
+	// file of age identities, which matches the variable read by SOPS.
+	AgeKeyFileEnv = "SOPS_AGE_KEY_FILE"
+
+	// AgePrefix is the prefix of age encrypted strings, which are followed by
+	// the base64 encoded ciphertext.
+	AgePrefix = "age:"
+)
+
+// LoadAgeIdentities reads age identities from a file when a path is given,
+// otherwise from the SOPS_AGE_KEY and SOPS_AGE_KEY_FILE environment variables.
+func LoadAgeIdentities(path string) ([]age.Identity, error) {
+	if path == "" {
+		if keys := os.Getenv(AgeKeyEnv); keys != "" {
+			return age.ParseIdentities(strings.NewReader(keys))
+		}
+		if path = os.Getenv(AgeKeyFileEnv); path == "" {
+			return nil, fmt.Errorf("no age identities found, set either %v or %v", AgeKeyEnv, AgeKeyFileEnv)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return age.ParseIdentities(f)
+}
+
+// ParseAgeRecipients parses a list of age recipients, each of which is either
+// an `age1...` public key or the path of a file containing public keys.
+func ParseAgeRecipients(recipients []string) ([]age.Recipient, error) {
+	var parsed []age.Recipient
+	for _, r := range recipients {
+		if strings.HasPrefix(r, "age1") {
+			rcp, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, rcp)
+			continue
+		}
+		f, err := os.Open(r)
+		if err != nil {
+			return nil, err
+		}
+		rcps, err := age.ParseRecipients(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", r, err)
+		}
+		parsed = append(parsed, rcps...)
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("at least one age recipient must be specified")
+	}
+	return parsed, nil
+}
+
+// EncryptAgeString encrypts a value for a set of recipients, returning it as an
+// `age:` prefixed string that can be embedded within a config.
+func EncryptAgeString(value []byte, recipients ...age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(value); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return AgePrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecryptAgeString decrypts an `age:` prefixed string with the first of a set
+// of identities that it was encrypted for.
+func DecryptAgeString(value string, identities ...age.Identity) ([]byte, error) {
+	b64, ok := strings.CutPrefix(value, AgePrefix)
+	if !ok {
+		return nil, fmt.Errorf("expected an %q prefixed value", AgePrefix)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode age ciphertext: %w", err)
+	}
+	return ageDecrypt(bytes.NewReader(ciphertext), identities)
+}
+
+func ageDecrypt(r io.Reader, identities []age.Identity) ([]byte, error) {
+	pr, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(pr)
+}
+
+func ageEncryptArmored(value []byte, recipient age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, recipient)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(value); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := aw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func ageDecryptArmored(value string, identities []age.Identity) ([]byte, error) {
+	return ageDecrypt(armor.NewReader(strings.NewReader(value)), identities)
+}
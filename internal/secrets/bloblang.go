@@ -0,0 +1,47 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+)
+
+func init() {
+	secretSpec := bloblang.NewPluginSpec().
+		Category("Environment").
+		Description("Decrypts a secret that has been encrypted with https://age-encryption.org[age^] and embedded within a config as an `age:` prefixed string, which can be created with the `secrets encrypt` subcommand. The secret is decrypted once when the mapping is parsed, using the age identities found in the file at the path of the environment variable `SOPS_AGE_KEY_FILE`, or within the environment variable `SOPS_AGE_KEY`.").
+		Version("4.42.0").
+		Param(bloblang.NewStringParam("value").Description("An `age:` prefixed encrypted string.")).
+		ExampleNotTested("Secrets can be encrypted for one or more age recipients with `redpanda-connect secrets encrypt -r <recipient> <value>`, and the result used within any mapping of a config.", `root.password = secret("age:YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBRcGpQ...")`).
+		ExampleNotTested("The decrypted secret is a string that can be combined with other values like any other.", `root = "%s:%s".format(env("DB_USER"), secret("age:YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBRcGpQ..."))`)
+
+	if err := bloblang.RegisterFunctionV2(
+		"secret", secretSpec,
+		func(args *bloblang.ParsedParams) (bloblang.Function, error) {
+			value, err := args.GetString("value")
+			if err != nil {
+				return nil, err
+			}
+			identities, err := LoadAgeIdentities("")
+			if err != nil {
+				return nil, err
+			}
+			plaintext, err := DecryptAgeString(value, identities...)
+			if err != nil {
+				return nil, err
+			}
+			secret := string(plaintext)
+			return func() (any, error) {
+				return secret, nil
+			}, nil
+		},
+	); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileSecretsClient reads secrets from a directory where each file is a
+// secret named after the file, which is how Kubernetes and Docker mount
+// secrets into containers.
+type fileSecretsClient struct {
+	logger *slog.Logger
+	dir    string
+}
+
+func newFileSecretsLookup(logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	dir := u.Host + u.Path
+	if dir == "" {
+		return nil, errors.New("a directory must be specified for file secrets, e.g. file:///etc/secrets")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("file secrets path %v is not a directory", dir)
+	}
+	f := &fileSecretsClient{logger: logger, dir: dir}
+	return f.lookup, nil
+}
+
+// read returns the contents of the file for a secret with a single trailing
+// newline removed, as editors and `echo` commonly add one.
+func (f *fileSecretsClient) read(name string) (string, bool) {
+	// Secrets are only ever read from the directory itself, never from
+	// subdirectories or a parent.
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	b, err := os.ReadFile(filepath.Join(f.dir, name))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			f.logger.With("error", err, "key", name).Error("Failed to look up secret")
+		}
+		return "", false
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	return strings.TrimSuffix(s, "\r"), true
+}
+
+// lookup reads the file named by the key. Fields of JSON contents are selected
+// with keys of the form `name.field` by the lookup returned from
+// ParseLookupURNs.
+func (f *fileSecretsClient) lookup(_ context.Context, key string) (string, bool) {
+	return f.read(key)
+}
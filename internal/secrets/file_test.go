@@ -0,0 +1,72 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api_key"), []byte("hunter2\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db"), []byte(`{"user":"admin","password":"s3cret","port":5432,"opts":{"ssl":true}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("CERT"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(t.TempDir(), "outside"), []byte("nope"), 0o600))
+
+	lookup, err := ParseLookupURNs(context.Background(), slog.Default(), "file://"+dir)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		key    string
+		value  string
+		exists bool
+	}{
+		{key: "api_key", value: "hunter2", exists: true},
+		{key: "tls.crt", value: "CERT", exists: true},
+		{key: "db.password", value: "s3cret", exists: true},
+		{key: "db.port", value: "5432", exists: true},
+		{key: "db.opts", value: `{"ssl":true}`, exists: true},
+		{key: "db.opts.ssl", value: "true", exists: true},
+		{key: "db.missing"},
+		{key: "api_key.field"},
+		{key: "missing"},
+		{key: "../outside"},
+		{key: "sub/db"},
+	} {
+		v, exists := lookup(context.Background(), test.key)
+		assert.Equal(t, test.exists, exists, test.key)
+		assert.Equal(t, test.value, v, test.key)
+	}
+}
+
+func TestFileSecretsNotADirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	_, err := ParseLookupURNs(context.Background(), slog.Default(), "file://"+path)
+	require.Error(t, err)
+}
+
+func TestSelectorAcrossTiers(t *testing.T) {
+	t.Setenv("TEST_SECRETS_SELECTOR", `{"nested":{"value":"foo"}}`)
+
+	lookup, err := ParseLookupURNs(context.Background(), slog.Default(), "file://"+t.TempDir(), "env:")
+	require.NoError(t, err)
+
+	v, exists := lookup(context.Background(), "TEST_SECRETS_SELECTOR.nested.value")
+	assert.True(t, exists)
+	assert.Equal(t, "foo", v)
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultKubernetesCAPath        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	defaultKubernetesNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// k8sSecret is the subset of a Kubernetes Secret object that we read, where
+// data values are base64 encoded and therefore decoded as bytes.
+type k8sSecret struct {
+	Data map[string][]byte `json:"data"`
+}
+
+// k8sSecretsClient reads Secret objects from a namespace through the
+// Kubernetes API, authenticating with a service account token.
+type k8sSecretsClient struct {
+	logger    *slog.Logger
+	http      *http.Client
+	api       string
+	namespace string
+	tokenPath string
+}
+
+func newK8sSecretsLookup(_ context.Context, logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	q := u.Query()
+
+	namespace := u.Host
+	if namespace == "" {
+		b, err := os.ReadFile(defaultKubernetesNamespacePath)
+		if err != nil {
+			return nil, fmt.Errorf("a namespace must be specified when not running within a Kubernetes pod: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+
+	api := q.Get("api")
+	if api == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("the api parameter must be specified when not running within a Kubernetes pod")
+		}
+		api = "https://" + net.JoinHostPort(host, port)
+	}
+
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+	caPath := q.Get("ca_path")
+	if caPath == "" {
+		caPath = defaultKubernetesCAPath
+	}
+	if caPEM, err := os.ReadFile(caPath); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("failed to parse CA certificate from %v", caPath)
+		}
+		tlsConf.RootCAs = pool
+	} else if q.Get("ca_path") != "" {
+		return nil, err
+	}
+
+	tokenPath := q.Get("token_path")
+	if tokenPath == "" {
+		tokenPath = defaultKubernetesTokenPath
+	}
+
+	k := &k8sSecretsClient{
+		logger: logger,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConf},
+		},
+		api:       strings.TrimSuffix(api, "/"),
+		namespace: namespace,
+		tokenPath: tokenPath,
+	}
+	return k.lookup, nil
+}
+
+func (k *k8sSecretsClient) get(ctx context.Context, name string) (*k8sSecret, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%v/api/v1/namespaces/%v/secrets/%v", k.api, url.PathEscape(k.namespace), url.PathEscape(name)), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	// Service account tokens are rotated by the kubelet, so the token is read
+	// for each request rather than once.
+	if token, err := os.ReadFile(k.tokenPath); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("status code %v: %s", resp.StatusCode, body)
+	}
+
+	var secret k8sSecret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+	return &secret, nil
+}
+
+// lookup obtains a Secret object, where a key of the form `name.dataKey`
+// returns a single decoded data value and a key without a dot returns all data
+// values of the Secret as a JSON object.
+func (k *k8sSecretsClient) lookup(ctx context.Context, key string) (string, bool) {
+	name, dataKey, hasDataKey := strings.Cut(key, ".")
+
+	secret, err := k.get(ctx, name)
+	if err != nil {
+		k.logger.With("error", err, "key", key).Error("Failed to look up secret")
+		return "", false
+	}
+	if secret == nil {
+		return "", false
+	}
+
+	if hasDataKey {
+		v, exists := secret.Data[dataKey]
+		return string(v), exists
+	}
+
+	values := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		values[k] = string(v)
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
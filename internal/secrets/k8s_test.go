@@ -0,0 +1,64 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK8sSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/connect/secrets/db":
+			// user=admin, config={"password":"s3cret"}, tls.crt=CERT
+			_, _ = w.Write([]byte(`{"kind":"Secret","data":{"user":"YWRtaW4=","config":"eyJwYXNzd29yZCI6InMzY3JldCJ9","tls.crt":"Q0VSVA=="}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("sa-token\n"), 0o600))
+
+	lookup, err := ParseLookupURNs(context.Background(), slog.Default(), "k8s://connect?api="+srv.URL+"&token_path="+tokenPath)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		key    string
+		value  string
+		exists bool
+	}{
+		{key: "db.user", value: "admin", exists: true},
+		{key: "db.tls.crt", value: "CERT", exists: true},
+		{key: "db.missing"},
+		{key: "missing.user"},
+	} {
+		v, exists := lookup(context.Background(), test.key)
+		assert.Equal(t, test.exists, exists, test.key)
+		assert.Equal(t, test.value, v, test.key)
+	}
+
+	v, exists := lookup(context.Background(), "db")
+	require.True(t, exists)
+	assert.JSONEq(t, `{"user":"admin","config":"{\"password\":\"s3cret\"}","tls.crt":"CERT"}`, v)
+}
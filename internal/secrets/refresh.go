@@ -0,0 +1,122 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+type cachedSecret struct {
+	value  string
+	exists bool
+	at     time.Time
+}
+
+// Refresher caches the secrets obtained from a LookupFn for a TTL and polls
+// them for changes, so that rotated secrets can be picked up by reloading the
+// components that reference them.
+type Refresher struct {
+	lookup LookupFn
+	ttl    time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	secrets map[string]cachedSecret
+}
+
+// NewRefresher wraps a LookupFn with a cache of secrets that expire after a
+// TTL.
+func NewRefresher(logger *slog.Logger, lookup LookupFn, ttl time.Duration) *Refresher {
+	return &Refresher{
+		lookup:  lookup,
+		ttl:     ttl,
+		logger:  logger,
+		now:     time.Now,
+		secrets: map[string]cachedSecret{},
+	}
+}
+
+// Lookup returns a secret, which is served from the cache until its TTL has
+// passed.
+func (r *Refresher) Lookup(ctx context.Context, key string) (string, bool) {
+	r.mu.Lock()
+	s, cached := r.secrets[key]
+	r.mu.Unlock()
+	if cached && r.now().Sub(s.at) < r.ttl {
+		return s.value, s.exists
+	}
+
+	value, exists := r.lookup(ctx, key)
+	r.mu.Lock()
+	r.secrets[key] = cachedSecret{value: value, exists: exists, at: r.now()}
+	r.mu.Unlock()
+	return value, exists
+}
+
+// Poll looks up every secret that has been requested so far, returning the
+// keys of any that have changed since they were last looked up. Changes are
+// recorded in an audit log entry that contains the key but never the value.
+//
+// A secret that can no longer be found keeps its previous value, as lookups
+// also fail when the secrets backend is temporarily unavailable.
+func (r *Refresher) Poll(ctx context.Context) (changed []string) {
+	r.mu.Lock()
+	keys := make([]string, 0, len(r.secrets))
+	for k := range r.secrets {
+		keys = append(keys, k)
+	}
+	r.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, exists := r.lookup(ctx, key)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		r.mu.Lock()
+		prev := r.secrets[key]
+		switch {
+		case !exists && prev.exists:
+			r.logger.With("key", key).Warn("Secret could not be refreshed, keeping its previous value")
+			prev.at = r.now()
+			r.secrets[key] = prev
+		case exists != prev.exists || value != prev.value:
+			r.logger.With("key", key, "audit", true).Info("Secret value has changed")
+			changed = append(changed, key)
+			fallthrough
+		default:
+			r.secrets[key] = cachedSecret{value: value, exists: exists, at: r.now()}
+		}
+		r.mu.Unlock()
+	}
+	return changed
+}
+
+// Run polls for changes to secrets every TTL until the context is cancelled,
+// calling onChange with the keys of the secrets that changed.
+func (r *Refresher) Run(ctx context.Context, onChange func(changed []string)) {
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if changed := r.Poll(ctx); len(changed) > 0 {
+			onChange(changed)
+		}
+	}
+}
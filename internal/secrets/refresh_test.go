@@ -0,0 +1,117 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapLookup struct {
+	mu      sync.Mutex
+	values  map[string]string
+	lookups int
+}
+
+func (m *mapLookup) set(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value == "" {
+		delete(m.values, key)
+		return
+	}
+	m.values[key] = value
+}
+
+func (m *mapLookup) lookup(_ context.Context, key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	v, ok := m.values[key]
+	return v, ok
+}
+
+func TestRefresherCachesUntilTTL(t *testing.T) {
+	backend := &mapLookup{values: map[string]string{"DB_PASSWORD": "first"}}
+	now := time.Unix(1000, 0)
+	r := NewRefresher(slog.Default(), backend.lookup, time.Minute)
+	r.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	v, ok := r.Lookup(ctx, "DB_PASSWORD")
+	require.True(t, ok)
+	assert.Equal(t, "first", v)
+
+	backend.set("DB_PASSWORD", "second")
+	v, _ = r.Lookup(ctx, "DB_PASSWORD")
+	assert.Equal(t, "first", v)
+	assert.Equal(t, 1, backend.lookups)
+
+	now = now.Add(time.Minute)
+	v, _ = r.Lookup(ctx, "DB_PASSWORD")
+	assert.Equal(t, "second", v)
+}
+
+func TestRefresherPollAuditsChanges(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	backend := &mapLookup{values: map[string]string{"A": "hunter2", "B": "b1"}}
+	r := NewRefresher(logger, backend.lookup, time.Minute)
+
+	ctx := context.Background()
+	r.Lookup(ctx, "A")
+	r.Lookup(ctx, "B")
+	r.Lookup(ctx, "C")
+
+	assert.Empty(t, r.Poll(ctx))
+
+	backend.set("A", "correct-horse")
+	backend.set("B", "")
+	backend.set("C", "c1")
+	assert.Equal(t, []string{"A", "C"}, r.Poll(ctx))
+
+	// A secret that disappears keeps its previous value.
+	v, ok := r.Lookup(ctx, "B")
+	assert.True(t, ok)
+	assert.Equal(t, "b1", v)
+
+	assert.Contains(t, logs.String(), "key=A")
+	assert.NotContains(t, logs.String(), "hunter2")
+	assert.NotContains(t, logs.String(), "correct-horse")
+}
+
+func TestRefresherRun(t *testing.T) {
+	backend := &mapLookup{values: map[string]string{"A": "a1"}}
+	r := NewRefresher(slog.Default(), backend.lookup, 10*time.Millisecond)
+
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	r.Lookup(ctx, "A")
+	changes := make(chan []string, 1)
+	go r.Run(ctx, func(changed []string) {
+		changes <- changed
+	})
+
+	backend.set("A", "a2")
+	select {
+	case changed := <-changes:
+		assert.Equal(t, []string{"A"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
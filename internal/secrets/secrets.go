@@ -17,9 +17,12 @@ import (
 	"strings"
 
 	"github.com/redpanda-data/common-go/secrets"
+	"github.com/tidwall/gjson"
 )
 
-const trimPrefixParam = "trimPrefix"
+const (
+	trimPrefixParam = "trimPrefix"
+)
 
 // LookupFn defines the common closure that a secrets management client provides
 // and is then fed into a Redpanda Connect cli constructor.
@@ -58,7 +61,44 @@ func ParseLookupURNs(ctx context.Context, logger *slog.Logger, secretsMgmtUrns .
 		tiers = append(tiers, tier)
 	}
 
-	return tiers.Lookup, nil
+	return withSelector(tiers.Lookup), nil
+}
+
+// withSelector wraps a LookupFn so that, when no secret exists under a key of
+// the form `name.field`, the secret under `name` is obtained and the field of
+// its JSON value at the path `field` is returned, regardless of which backend
+// the secret was obtained from.
+func withSelector(fn LookupFn) LookupFn {
+	return func(ctx context.Context, key string) (string, bool) {
+		if v, ok := fn(ctx, key); ok {
+			return v, true
+		}
+		name, path, ok := strings.Cut(key, ".")
+		if !ok || ctx.Err() != nil {
+			return "", false
+		}
+		v, ok := fn(ctx, name)
+		if !ok {
+			return "", false
+		}
+		return selectField(v, path)
+	}
+}
+
+// selectField returns the field of a JSON document at a gjson path, where
+// string fields are returned unquoted and all others as raw JSON.
+func selectField(doc, path string) (string, bool) {
+	if !gjson.Valid(doc) {
+		return "", false
+	}
+	res := gjson.Get(doc, path)
+	if !res.Exists() {
+		return "", false
+	}
+	if res.Type == gjson.String {
+		return res.Str, true
+	}
+	return res.Raw, true
 }
 
 func parseSecretsLookupURN(ctx context.Context, logger *slog.Logger, urn string) (LookupFn, error) {
@@ -75,6 +115,14 @@ func parseSecretsLookupURN(ctx context.Context, logger *slog.Logger, urn string)
 		}, nil
 	case "redis":
 		return newRedisSecretsLookup(ctx, logger, u)
+	case "vault", "openbao":
+		return newVaultSecretsLookup(ctx, logger, u)
+	case "file":
+		return newFileSecretsLookup(logger, u)
+	case "k8s":
+		return newK8sSecretsLookup(ctx, logger, u)
+	case "sops":
+		return newSOPSSecretsLookup(logger, u)
 	case "env":
 		return func(ctx context.Context, key string) (string, bool) {
 			return os.LookupEnv(key)
@@ -0,0 +1,544 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// The subset of the SOPS file format (https://github.com/getsops/sops) that is
+// supported here is YAML (or JSON) documents with values encrypted using
+// AES256_GCM under a data key that is encrypted for age recipients.
+const (
+	sopsMetadataKey       = "sops"
+	sopsVersion           = "3.9.0"
+	sopsUnencryptedSuffix = "_unencrypted"
+	sopsNonceSize         = 32
+)
+
+// ErrNotSOPSDocument is returned when attempting to decrypt or re-key a
+// document that does not contain SOPS metadata.
+var ErrNotSOPSDocument = errors.New("document is not SOPS encrypted")
+
+var sopsEncRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+type sopsAgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+type sopsMetadata struct {
+	Age                     []sopsAgeKey `yaml:"age"`
+	LastModified            string       `yaml:"lastmodified"`
+	MAC                     string       `yaml:"mac"`
+	UnencryptedSuffix       string       `yaml:"unencrypted_suffix,omitempty"`
+	EncryptedSuffix         string       `yaml:"encrypted_suffix,omitempty"`
+	UnencryptedRegex        string       `yaml:"unencrypted_regex,omitempty"`
+	EncryptedRegex          string       `yaml:"encrypted_regex,omitempty"`
+	UnencryptedCommentRegex string       `yaml:"unencrypted_comment_regex,omitempty"`
+	EncryptedCommentRegex   string       `yaml:"encrypted_comment_regex,omitempty"`
+	MACOnlyEncrypted        bool         `yaml:"mac_only_encrypted,omitempty"`
+	Version                 string       `yaml:"version"`
+}
+
+// sopsMACOnlyEncryptedInit is written to the MAC of documents with
+// mac_only_encrypted set before any values, as it is by SOPS.
+var sopsMACOnlyEncryptedInit = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// sopsRules determines which values of a document are encrypted, from the
+// rules within its metadata.
+type sopsRules struct {
+	unencryptedSuffix string
+	encryptedSuffix   string
+	unencryptedRegex  *regexp.Regexp
+	encryptedRegex    *regexp.Regexp
+	macOnlyEncrypted  bool
+}
+
+func (m *sopsMetadata) rules() (*sopsRules, error) {
+	if m.UnencryptedCommentRegex != "" || m.EncryptedCommentRegex != "" {
+		return nil, errors.New("documents encrypted with unencrypted_comment_regex or encrypted_comment_regex are not supported")
+	}
+	set := 0
+	for _, v := range []string{m.UnencryptedSuffix, m.EncryptedSuffix, m.UnencryptedRegex, m.EncryptedRegex} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("only one of unencrypted_suffix, encrypted_suffix, unencrypted_regex and encrypted_regex can be set")
+	}
+
+	r := &sopsRules{
+		unencryptedSuffix: m.UnencryptedSuffix,
+		encryptedSuffix:   m.EncryptedSuffix,
+		macOnlyEncrypted:  m.MACOnlyEncrypted,
+	}
+	if set == 0 {
+		r.unencryptedSuffix = sopsUnencryptedSuffix
+	}
+	var err error
+	if m.UnencryptedRegex != "" {
+		if r.unencryptedRegex, err = regexp.Compile(m.UnencryptedRegex); err != nil {
+			return nil, fmt.Errorf("failed to parse unencrypted_regex: %w", err)
+		}
+	}
+	if m.EncryptedRegex != "" {
+		if r.encryptedRegex, err = regexp.Compile(m.EncryptedRegex); err != nil {
+			return nil, fmt.Errorf("failed to parse encrypted_regex: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// encrypted returns whether the value at a path is encrypted, where any key of
+// the path can match a rule.
+func (r *sopsRules) encrypted(path []string) bool {
+	switch {
+	case r.encryptedSuffix != "":
+		return slices.ContainsFunc(path, func(k string) bool { return strings.HasSuffix(k, r.encryptedSuffix) })
+	case r.encryptedRegex != nil:
+		return slices.ContainsFunc(path, r.encryptedRegex.MatchString)
+	case r.unencryptedSuffix != "":
+		return !slices.ContainsFunc(path, func(k string) bool { return strings.HasSuffix(k, r.unencryptedSuffix) })
+	case r.unencryptedRegex != nil:
+		return !slices.ContainsFunc(path, r.unencryptedRegex.MatchString)
+	}
+	return true
+}
+
+// newMAC returns the hash that the MAC of a document is computed with.
+func (r *sopsRules) newMAC() hash.Hash {
+	h := sha512.New()
+	if r.macOnlyEncrypted {
+		_, _ = h.Write(sopsMACOnlyEncryptedInit)
+	}
+	return h
+}
+
+// addToMAC adds a scalar to the MAC of a document, which includes either all
+// values or only those that are encrypted.
+func (r *sopsRules) addToMAC(h hash.Hash, n *yaml.Node, encrypted bool) {
+	if encrypted || !r.macOnlyEncrypted {
+		b, _ := sopsPlaintext(n)
+		_, _ = h.Write(b)
+	}
+}
+
+// sopsDocument is a parsed SOPS file, where root is the top level mapping that
+// still contains the metadata under the key `sops`.
+type sopsDocument struct {
+	root     *yaml.Node
+	metaNode *yaml.Node
+	meta     sopsMetadata
+}
+
+func parseSOPSDocument(data []byte) (*sopsDocument, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSOPSDocument, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, ErrNotSOPSDocument
+	}
+	d := &sopsDocument{root: doc.Content[0]}
+	for i := 0; i < len(d.root.Content)-1; i += 2 {
+		if d.root.Content[i].Value == sopsMetadataKey {
+			d.metaNode = d.root.Content[i+1]
+		}
+	}
+	if d.metaNode == nil {
+		return nil, ErrNotSOPSDocument
+	}
+	if err := d.metaNode.Decode(&d.meta); err != nil {
+		return nil, fmt.Errorf("failed to parse SOPS metadata: %w", err)
+	}
+	return d, nil
+}
+
+// dataKey obtains the key that values are encrypted with by decrypting it with
+// the first identity that matches an age recipient of the document.
+func (d *sopsDocument) dataKey(identities []age.Identity) ([]byte, error) {
+	if len(d.meta.Age) == 0 {
+		return nil, errors.New("document has no age recipients, other SOPS key types are not supported")
+	}
+	var errs []error
+	for _, k := range d.meta.Age {
+		key, err := ageDecryptArmored(k.Enc, identities)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, fmt.Errorf("%v: %w", k.Recipient, err))
+	}
+	return nil, fmt.Errorf("failed to decrypt the data key: %w", errors.Join(errs...))
+}
+
+// walkSOPSValues calls fn for each scalar value of a document, excluding the
+// SOPS metadata, along with the path of mapping keys leading to it.
+func walkSOPSValues(n *yaml.Node, path []string, fn func(n *yaml.Node, path []string) error) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(n.Content)-1; i += 2 {
+			k := n.Content[i].Value
+			if len(path) == 0 && k == sopsMetadataKey {
+				continue
+			}
+			if err := walkSOPSValues(n.Content[i+1], append(path[:len(path):len(path)], k), fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		// Elements of a sequence share the path of the sequence itself.
+		for _, c := range n.Content {
+			if err := walkSOPSValues(c, path, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil
+		}
+		return fn(n, path)
+	case yaml.AliasNode:
+		return errors.New("YAML aliases are not supported in encrypted documents")
+	}
+	return nil
+}
+
+// sopsPlaintext returns the bytes of a scalar that are encrypted and added to
+// the MAC along with the SOPS type of the scalar, where booleans are
+// capitalised as they are by SOPS.
+func sopsPlaintext(n *yaml.Node) ([]byte, string) {
+	switch n.ShortTag() {
+	case "!!int":
+		return []byte(n.Value), "int"
+	case "!!float":
+		if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+			return []byte(strconv.FormatFloat(f, 'f', -1, 64)), "float"
+		}
+	case "!!bool":
+		if b, err := strconv.ParseBool(n.Value); err == nil {
+			if b {
+				return []byte("True"), "bool"
+			}
+			return []byte("False"), "bool"
+		}
+	}
+	return []byte(n.Value), "str"
+}
+
+func sopsEncrypt(key, plaintext []byte, typ, additionalData string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, sopsNonceSize)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, sopsNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	out := gcm.Seal(nil, iv, plaintext, []byte(additionalData))
+	tagStart := len(out) - gcm.Overhead()
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(out[:tagStart]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[tagStart:]),
+		typ,
+	), nil
+}
+
+func sopsDecrypt(key []byte, value, additionalData string) (plaintext []byte, typ string, err error) {
+	matches := sopsEncRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, "", errors.New("value is not in the SOPS encrypted format")
+	}
+	var data, iv, tag []byte
+	for i, v := range []*[]byte{&data, &iv, &tag} {
+		if *v, err = base64.StdEncoding.DecodeString(matches[i+1]); err != nil {
+			return nil, "", err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, "", err
+	}
+	if plaintext, err = gcm.Open(nil, iv, append(data, tag...), []byte(additionalData)); err != nil {
+		return nil, "", err
+	}
+	return plaintext, matches[4], nil
+}
+
+func sopsAdditionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// DecryptSOPS decrypts a SOPS document with age identities, verifying its
+// message authentication code, and returns the plaintext document without the
+// SOPS metadata.
+func DecryptSOPS(data []byte, identities ...age.Identity) (*yaml.Node, error) {
+	d, err := parseSOPSDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := d.dataKey(identities)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := d.meta.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := rules.newMAC()
+	if err := walkSOPSValues(d.root, nil, func(n *yaml.Node, path []string) error {
+		encrypted := rules.encrypted(path)
+		if encrypted && sopsEncRegex.MatchString(n.Value) {
+			plaintext, typ, err := sopsDecrypt(key, n.Value, sopsAdditionalData(path))
+			if err != nil {
+				return fmt.Errorf("failed to decrypt value at %v: %w", strings.Join(path, "."), err)
+			}
+			n.Value, n.Tag, n.Style = string(plaintext), "!!"+typ, 0
+			switch typ {
+			case "str":
+				// Ensure that strings resembling other types are quoted.
+				n.Style = yaml.DoubleQuotedStyle
+			case "bool":
+				n.Value = strings.ToLower(n.Value)
+			}
+		}
+		rules.addToMAC(mac, n, encrypted)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	expected, _, err := sopsDecrypt(key, d.meta.MAC, d.meta.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the document MAC: %w", err)
+	}
+	if !strings.EqualFold(string(expected), fmt.Sprintf("%X", mac.Sum(nil))) {
+		return nil, errors.New("document MAC does not match, it may have been tampered with")
+	}
+
+	plain := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i < len(d.root.Content)-1; i += 2 {
+		if d.root.Content[i].Value != sopsMetadataKey {
+			plain.Content = append(plain.Content, d.root.Content[i], d.root.Content[i+1])
+		}
+	}
+	return plain, nil
+}
+
+// EncryptSOPS encrypts the values of a plaintext YAML document for a set of
+// age recipients, where values under keys ending with `_unencrypted` are left
+// as plaintext.
+func EncryptSOPS(data []byte, recipients ...age.Recipient) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("expected a document with a mapping at the top level")
+	}
+	root := doc.Content[0]
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == sopsMetadataKey {
+			return nil, errors.New("document is already encrypted")
+		}
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	rules := &sopsRules{unencryptedSuffix: sopsUnencryptedSuffix}
+	mac := rules.newMAC()
+	if err := walkSOPSValues(root, nil, func(n *yaml.Node, path []string) error {
+		encrypted := rules.encrypted(path)
+		rules.addToMAC(mac, n, encrypted)
+		// Empty strings are left as they are by SOPS.
+		if !encrypted || (n.ShortTag() == "!!str" && n.Value == "") {
+			return nil
+		}
+		plaintext, typ := sopsPlaintext(n)
+		enc, err := sopsEncrypt(key, plaintext, typ, sopsAdditionalData(path))
+		if err != nil {
+			return err
+		}
+		n.Value, n.Tag, n.Style = enc, "!!str", 0
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	meta := sopsMetadata{
+		LastModified:      time.Now().UTC().Format(time.RFC3339),
+		UnencryptedSuffix: sopsUnencryptedSuffix,
+		Version:           sopsVersion,
+	}
+	var err error
+	if meta.Age, err = sopsWrapKey(key, recipients); err != nil {
+		return nil, err
+	}
+	if meta.MAC, err = sopsEncrypt(key, []byte(fmt.Sprintf("%X", mac.Sum(nil))), "str", meta.LastModified); err != nil {
+		return nil, err
+	}
+
+	var metaNode yaml.Node
+	if err := metaNode.Encode(meta); err != nil {
+		return nil, err
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: sopsMetadataKey}, &metaNode)
+	return yaml.Marshal(&doc)
+}
+
+// RekeySOPS re-encrypts the data key of a SOPS document for a new set of age
+// recipients, leaving the encrypted values untouched.
+func RekeySOPS(data []byte, identities []age.Identity, recipients []age.Recipient) ([]byte, error) {
+	d, err := parseSOPSDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := d.dataKey(identities)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := sopsWrapKey(key, recipients)
+	if err != nil {
+		return nil, err
+	}
+	var ageNode yaml.Node
+	if err := ageNode.Encode(keys); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(d.metaNode.Content)-1; i += 2 {
+		if d.metaNode.Content[i].Value == "age" {
+			d.metaNode.Content[i+1] = &ageNode
+		}
+	}
+	return yaml.Marshal(d.root)
+}
+
+func sopsWrapKey(key []byte, recipients []age.Recipient) ([]sopsAgeKey, error) {
+	keys := make([]sopsAgeKey, 0, len(recipients))
+	for _, r := range recipients {
+		s, ok := r.(fmt.Stringer)
+		if !ok {
+			return nil, fmt.Errorf("recipient of type %T is not supported", r)
+		}
+		enc, err := ageEncryptArmored(key, r)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, sopsAgeKey{Recipient: s.String(), Enc: enc})
+	}
+	return keys, nil
+}
+
+// sopsSecretsClient serves secrets from a SOPS encrypted file, which is
+// decrypted again whenever the file is modified.
+type sopsSecretsClient struct {
+	logger     *slog.Logger
+	path       string
+	identities []age.Identity
+
+	mu      sync.Mutex
+	modTime time.Time
+	doc     string
+}
+
+func newSOPSSecretsLookup(logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	identities, err := LoadAgeIdentities(u.Query().Get("identity_file"))
+	if err != nil {
+		return nil, err
+	}
+	s := &sopsSecretsClient{
+		logger:     logger,
+		path:       u.Host + u.Path,
+		identities: identities,
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("%v: %w", s.path, err)
+	}
+	return s.lookup, nil
+}
+
+func (s *sopsSecretsClient) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	plain, err := DecryptSOPS(data, s.identities...)
+	if err != nil {
+		return err
+	}
+	var v any
+	if err := plain.Decode(&v); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.doc, s.modTime = string(b), info.ModTime()
+	return nil
+}
+
+// lookup returns the value at a dot separated path of the decrypted document.
+func (s *sopsSecretsClient) lookup(_ context.Context, key string) (string, bool) {
+	if err := s.load(); err != nil {
+		// The previously decrypted document continues to be served.
+		s.logger.With("error", err, "path", s.path).Error("Failed to decrypt secrets file")
+	}
+	s.mu.Lock()
+	doc := s.doc
+	s.mu.Unlock()
+	return selectField(doc, key)
+}
@@ -0,0 +1,258 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const sopsTestDoc = `db:
+  user: admin
+  password: s3cret
+  port: 5432
+  tls: true
+hosts:
+  - a.example.com
+  - b.example.com
+region_unencrypted: eu-west-1
+`
+
+func TestSOPSRoundTrip(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	enc, err := EncryptSOPS([]byte(sopsTestDoc), id.Recipient())
+	require.NoError(t, err)
+	assert.NotContains(t, string(enc), "s3cret")
+	assert.NotContains(t, string(enc), "a.example.com")
+	assert.Contains(t, string(enc), "region_unencrypted: eu-west-1")
+	assert.Contains(t, string(enc), id.Recipient().String())
+
+	plain, err := DecryptSOPS(enc, id)
+	require.NoError(t, err)
+	var v map[string]any
+	require.NoError(t, plain.Decode(&v))
+	assert.Equal(t, map[string]any{
+		"db": map[string]any{
+			"user":     "admin",
+			"password": "s3cret",
+			"port":     5432,
+			"tls":      true,
+		},
+		"hosts":              []any{"a.example.com", "b.example.com"},
+		"region_unencrypted": "eu-west-1",
+	}, v)
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	_, err = DecryptSOPS(enc, other)
+	require.Error(t, err)
+}
+
+func TestSOPSTamperedValue(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	enc, err := EncryptSOPS([]byte(sopsTestDoc), id.Recipient())
+	require.NoError(t, err)
+
+	tampered := strings.Replace(string(enc), "eu-west-1", "us-east-1", 1)
+	_, err = DecryptSOPS([]byte(tampered), id)
+	require.ErrorContains(t, err, "MAC")
+}
+
+func TestSOPSRekey(t *testing.T) {
+	oldID, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	newID, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	enc, err := EncryptSOPS([]byte(sopsTestDoc), oldID.Recipient())
+	require.NoError(t, err)
+
+	rekeyed, err := RekeySOPS(enc, []age.Identity{oldID}, []age.Recipient{newID.Recipient()})
+	require.NoError(t, err)
+
+	_, err = DecryptSOPS(rekeyed, oldID)
+	require.Error(t, err)
+	plain, err := DecryptSOPS(rekeyed, newID)
+	require.NoError(t, err)
+	b, err := yaml.Marshal(plain)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "s3cret")
+
+	_, err = RekeySOPS([]byte(sopsTestDoc), []age.Identity{oldID}, []age.Recipient{newID.Recipient()})
+	require.ErrorIs(t, err, ErrNotSOPSDocument)
+}
+
+func TestSOPSSecretsLookup(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	enc, err := EncryptSOPS([]byte(sopsTestDoc), id.Recipient())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc.yaml")
+	require.NoError(t, os.WriteFile(path, enc, 0o600))
+	t.Setenv(AgeKeyEnv, id.String())
+
+	lookup, err := ParseLookupURNs(context.Background(), slog.Default(), "sops://"+path)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		key    string
+		value  string
+		exists bool
+	}{
+		{key: "db.password", value: "s3cret", exists: true},
+		{key: "db.port", value: "5432", exists: true},
+		{key: "hosts.1", value: "b.example.com", exists: true},
+		{key: "db.user", value: "admin", exists: true},
+		{key: "db.missing"},
+	} {
+		v, exists := lookup(context.Background(), test.key)
+		assert.Equal(t, test.exists, exists, test.key)
+		assert.Equal(t, test.value, v, test.key)
+	}
+}
+
+func TestSecretBloblangFunction(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyPath, []byte(id.String()+"\n"), 0o600))
+	t.Setenv(AgeKeyFileEnv, keyPath)
+
+	enc, err := EncryptAgeString([]byte("hunter2"), id.Recipient())
+	require.NoError(t, err)
+
+	exec, err := bloblang.Parse(`root = secret("` + enc + `")`)
+	require.NoError(t, err)
+	v, err := exec.Query(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", v)
+
+	_, err = bloblang.Parse(`root = secret("age:bm9wZQ==")`)
+	require.Error(t, err)
+}
+
+// The documents within testdata/sops were encrypted for the identity within
+// testdata/sops/keys.txt by the encryption code of SOPS 3.9.0.
+func TestSOPSFixtures(t *testing.T) {
+	identities, err := LoadAgeIdentities("testdata/sops/keys.txt")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		file     string
+		expected map[string]any
+	}{
+		{
+			file: "default.enc.yaml",
+			expected: map[string]any{
+				"db": map[string]any{
+					"user":     "admin",
+					"password": "s3cret",
+					"port":     5432,
+					"ratio":    0.5,
+					"tls":      true,
+					"empty":    "",
+				},
+				"hosts":              []any{"a.example.com", "b.example.com"},
+				"region_unencrypted": "eu-west-1",
+			},
+		},
+		{
+			file: "encrypted_regex.enc.yaml",
+			expected: map[string]any{
+				"db":  map[string]any{"user": "admin", "password": "s3cret", "port": 5432},
+				"api": map[string]any{"token": "t0ken", "url": "https://example.com"},
+			},
+		},
+		{
+			file: "unencrypted_regex_mac_only.enc.yaml",
+			expected: map[string]any{
+				"db":  map[string]any{"user": "admin", "password": "s3cret", "port": 5432},
+				"api": map[string]any{"token": "t0ken", "url": "https://example.com"},
+			},
+		},
+	} {
+		t.Run(test.file, func(t *testing.T) {
+			enc, err := os.ReadFile(filepath.Join("testdata/sops", test.file))
+			require.NoError(t, err)
+
+			plain, err := DecryptSOPS(enc, identities...)
+			require.NoError(t, err)
+			var v map[string]any
+			require.NoError(t, plain.Decode(&v))
+			assert.Equal(t, test.expected, v)
+
+			rekeyed, err := RekeySOPS(enc, identities, []age.Recipient{identities[0].(*age.X25519Identity).Recipient()})
+			require.NoError(t, err)
+			_, err = DecryptSOPS(rekeyed, identities...)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSOPSFixtureTampered(t *testing.T) {
+	identities, err := LoadAgeIdentities("testdata/sops/keys.txt")
+	require.NoError(t, err)
+
+	for file, replace := range map[string][2]string{
+		// Values that are not encrypted are covered by the MAC.
+		"encrypted_regex.enc.yaml": {"url: https://example.com", "url: https://evil.example.com"},
+		// Unless the MAC only covers encrypted values.
+		"unencrypted_regex_mac_only.enc.yaml": {"user: admin", "user: root"},
+	} {
+		enc, err := os.ReadFile(filepath.Join("testdata/sops", file))
+		require.NoError(t, err)
+		tampered := strings.Replace(string(enc), replace[0], replace[1], 1)
+		require.NotEqual(t, string(enc), tampered)
+
+		_, err = DecryptSOPS([]byte(tampered), identities...)
+		if file == "encrypted_regex.enc.yaml" {
+			require.ErrorContains(t, err, "MAC", file)
+		} else {
+			require.NoError(t, err, file)
+		}
+	}
+}
+
+func TestSOPSUnsupportedRules(t *testing.T) {
+	identities, err := LoadAgeIdentities("testdata/sops/keys.txt")
+	require.NoError(t, err)
+	enc, err := os.ReadFile("testdata/sops/default.enc.yaml")
+	require.NoError(t, err)
+
+	for _, rule := range []string{
+		"encrypted_comment_regex: sops:enc",
+		"unencrypted_comment_regex: sops:plain",
+	} {
+		doc := strings.Replace(string(enc), "    version: 3.9.0", "    "+rule+"\n    version: 3.9.0", 1)
+		_, err = DecryptSOPS([]byte(doc), identities...)
+		require.ErrorContains(t, err, "are not supported", rule)
+	}
+
+	doc := strings.Replace(string(enc), "    version: 3.9.0", "    encrypted_regex: ^password$\n    version: 3.9.0", 1)
+	_, err = DecryptSOPS([]byte(doc), identities...)
+	require.ErrorContains(t, err, "only one of", "conflicting rules")
+}
@@ -0,0 +1,380 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/common-go/secrets"
+)
+
+const (
+	vaultEngineKVv1     = "kv-v1"
+	vaultEngineKVv2     = "kv-v2"
+	vaultEngineDatabase = "database"
+
+	vaultAuthToken      = "token"
+	vaultAuthAppRole    = "approle"
+	vaultAuthKubernetes = "kubernetes"
+
+	defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+var errVaultNotFound = errors.New("not found")
+
+// vaultResponse is the common envelope of Vault API responses.
+type vaultResponse struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// vaultLease is a set of dynamic credentials that are kept alive by renewing
+// their lease until it can no longer be renewed.
+type vaultLease struct {
+	id    string
+	value string
+}
+
+// vaultSecretsClient reads secrets from HashiCorp Vault or OpenBao, which
+// share the same HTTP API.
+type vaultSecretsClient struct {
+	logger    *slog.Logger
+	http      *http.Client
+	addr      string
+	namespace string
+	mount     string
+	engine    string
+	login     func(ctx context.Context) (*vaultResponse, error)
+
+	// The context that background renewals run under, which lives for as
+	// long as the lookup is in use.
+	ctx context.Context
+
+	mu    sync.Mutex
+	token string
+
+	leasesMu sync.Mutex
+	leases   map[string]*vaultLease
+}
+
+// newVaultSecretsLookup creates a lookup for a URN of the form
+// vault://host:port/mount/prefix?engine=kv-v2&auth=approle, where keys are
+// appended to the prefix and the JSON fields of a secret are selected with a
+// `.field` suffix.
+func newVaultSecretsLookup(ctx context.Context, logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	q := u.Query()
+	mount, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if mount == "" {
+		return nil, errors.New("vault secrets URN must specify a mount path")
+	}
+
+	scheme := "https"
+	if q.Get("tls") == "false" {
+		scheme = "http"
+	}
+	v := &vaultSecretsClient{
+		logger:    logger,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		addr:      scheme + "://" + u.Host,
+		namespace: q.Get("namespace"),
+		mount:     mount,
+		engine:    q.Get("engine"),
+		ctx:       ctx,
+		leases:    map[string]*vaultLease{},
+	}
+	switch v.engine {
+	case "":
+		v.engine = vaultEngineKVv2
+	case vaultEngineKVv1, vaultEngineKVv2, vaultEngineDatabase:
+	default:
+		return nil, fmt.Errorf("vault secrets engine %v not recognized", v.engine)
+	}
+
+	auth := q.Get("auth")
+	if auth == "" {
+		auth = vaultAuthToken
+	}
+	authMount := q.Get("auth_mount")
+	if authMount == "" {
+		authMount = auth
+	}
+	switch auth {
+	case vaultAuthToken:
+		v.token = paramOrEnv(q, "token", "VAULT_TOKEN")
+		if v.token == "" {
+			return nil, errors.New("vault token auth requires a token parameter or the VAULT_TOKEN environment variable")
+		}
+	case vaultAuthAppRole:
+		body := map[string]string{
+			"role_id":   paramOrEnv(q, "role_id", "VAULT_ROLE_ID"),
+			"secret_id": paramOrEnv(q, "secret_id", "VAULT_SECRET_ID"),
+		}
+		v.login = func(ctx context.Context) (*vaultResponse, error) {
+			return v.request(ctx, http.MethodPost, "auth/"+authMount+"/login", body)
+		}
+	case vaultAuthKubernetes:
+		tokenPath := q.Get("jwt_path")
+		if tokenPath == "" {
+			tokenPath = defaultKubernetesTokenPath
+		}
+		role := q.Get("role")
+		v.login = func(ctx context.Context) (*vaultResponse, error) {
+			// The service account token is read on every login as it's rotated
+			// by the kubelet.
+			jwt, err := os.ReadFile(tokenPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read service account token: %w", err)
+			}
+			return v.request(ctx, http.MethodPost, "auth/"+authMount+"/login", map[string]string{
+				"role": role,
+				"jwt":  strings.TrimSpace(string(jwt)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("vault auth method %v not recognized", auth)
+	}
+
+	if v.login != nil {
+		ttl, renewable, err := v.authenticate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("vault login failed: %w", err)
+		}
+		go v.renewToken(ctx, ttl, renewable)
+	}
+	return lookupFn(secrets.NewSecretProvider, v, prefix, q.Get(trimPrefixParam))
+}
+
+func paramOrEnv(q url.Values, param, env string) string {
+	if v := q.Get(param); v != "" {
+		return v
+	}
+	return os.Getenv(env)
+}
+
+func (v *vaultSecretsClient) request(ctx context.Context, method, path string, body any) (*vaultResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	token := v.token
+	v.mu.Unlock()
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var vresp vaultResponse
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(&vresp); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errVaultNotFound
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("status code %v: %v", resp.StatusCode, strings.Join(vresp.Errors, ", "))
+	}
+	return &vresp, nil
+}
+
+// authenticate logs in and stores the resulting token, returning how long the
+// token is valid for and whether it can be renewed.
+func (v *vaultSecretsClient) authenticate(ctx context.Context) (ttl time.Duration, renewable bool, err error) {
+	resp, err := v.login(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return 0, false, errors.New("login response did not contain a token")
+	}
+	v.mu.Lock()
+	v.token = resp.Auth.ClientToken
+	v.mu.Unlock()
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, resp.Auth.Renewable, nil
+}
+
+// renewToken keeps the token obtained by logging in alive, logging in again
+// when it can no longer be renewed.
+func (v *vaultSecretsClient) renewToken(ctx context.Context, ttl time.Duration, renewable bool) {
+	for {
+		if ttl <= 0 {
+			// The token never expires.
+			return
+		}
+		select {
+		case <-time.After(renewAfter(ttl)):
+		case <-ctx.Done():
+			return
+		}
+		if renewable {
+			resp, err := v.request(ctx, http.MethodPost, "auth/token/renew-self", map[string]any{})
+			if err == nil && resp.Auth != nil {
+				ttl, renewable = time.Duration(resp.Auth.LeaseDuration)*time.Second, resp.Auth.Renewable
+				continue
+			}
+			if err != nil {
+				v.logger.With("error", err).Warn("Failed to renew vault token, logging in again")
+			}
+		}
+		var err error
+		if ttl, renewable, err = v.authenticate(ctx); err != nil {
+			v.logger.With("error", err).Error("Failed to log in to vault")
+			ttl, renewable = time.Minute, false
+		}
+	}
+}
+
+// renewLease keeps the lease of dynamic credentials alive until it can no
+// longer be renewed, at which point the credentials are evicted so that the
+// next lookup obtains new ones.
+func (v *vaultSecretsClient) renewLease(ctx context.Context, role string, lease *vaultLease, ttl time.Duration, renewable bool) {
+	defer func() {
+		v.leasesMu.Lock()
+		if v.leases[role] == lease {
+			delete(v.leases, role)
+		}
+		v.leasesMu.Unlock()
+	}()
+	for {
+		select {
+		case <-time.After(renewAfter(ttl)):
+		case <-ctx.Done():
+			return
+		}
+		if !renewable {
+			return
+		}
+		resp, err := v.request(ctx, http.MethodPut, "sys/leases/renew", map[string]string{"lease_id": lease.id})
+		if err != nil {
+			v.logger.With("error", err, "role", role).Warn("Failed to renew vault lease, new credentials will be issued on the next lookup")
+			return
+		}
+		if resp.LeaseDuration <= 0 {
+			v.logger.With("role", role).Info("Vault lease reached its maximum TTL, new credentials will be issued on the next lookup")
+			return
+		}
+		ttl, renewable = time.Duration(resp.LeaseDuration)*time.Second, resp.Renewable
+	}
+}
+
+// renewAfter returns how long to wait before renewing something with a TTL,
+// leaving a margin for the renewal to complete.
+func renewAfter(ttl time.Duration) time.Duration {
+	return ttl * 2 / 3
+}
+
+func (v *vaultSecretsClient) secretPath(name string) string {
+	switch v.engine {
+	case vaultEngineKVv2:
+		return v.mount + "/data/" + name
+	case vaultEngineDatabase:
+		return v.mount + "/creds/" + name
+	}
+	return v.mount + "/" + name
+}
+
+func (v *vaultSecretsClient) read(ctx context.Context, name string) (string, error) {
+	if v.engine == vaultEngineDatabase {
+		return v.readCredentials(ctx, name)
+	}
+	resp, err := v.request(ctx, http.MethodGet, v.secretPath(name), nil)
+	if err != nil {
+		return "", err
+	}
+	data := resp.Data
+	if v.engine == vaultEngineKVv2 {
+		var kv struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &kv); err != nil {
+			return "", err
+		}
+		data = kv.Data
+	}
+	if len(data) == 0 || string(data) == "null" {
+		// Deleted versions of a KV v2 secret have no data.
+		return "", errVaultNotFound
+	}
+	return string(data), nil
+}
+
+// readCredentials returns the dynamic credentials of a role, which are issued
+// once and shared by all lookups so that fields such as the username and
+// password of a database role match.
+func (v *vaultSecretsClient) readCredentials(ctx context.Context, role string) (string, error) {
+	v.leasesMu.Lock()
+	defer v.leasesMu.Unlock()
+	if lease, exists := v.leases[role]; exists {
+		return lease.value, nil
+	}
+
+	resp, err := v.request(ctx, http.MethodGet, v.secretPath(role), nil)
+	if err != nil {
+		return "", err
+	}
+	lease := &vaultLease{id: resp.LeaseID, value: string(resp.Data)}
+	if resp.LeaseDuration > 0 {
+		v.leases[role] = lease
+		go v.renewLease(v.ctx, role, lease, time.Duration(resp.LeaseDuration)*time.Second, resp.Renewable && lease.id != "")
+	}
+	return lease.value, nil
+}
+
+func (v *vaultSecretsClient) GetSecretValue(ctx context.Context, name string) (string, bool) {
+	value, err := v.read(ctx, name)
+	if err != nil {
+		if !errors.Is(err, errVaultNotFound) {
+			v.logger.With("error", err, "key", name).Error("Failed to look up secret")
+		}
+		return "", false
+	}
+	return value, true
+}
+
+func (v *vaultSecretsClient) CheckSecretExists(ctx context.Context, name string) bool {
+	_, exists := v.GetSecretValue(ctx, name)
+	return exists
+}
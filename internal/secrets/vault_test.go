@@ -0,0 +1,191 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service/integration"
+)
+
+// mockVault implements the parts of the Vault HTTP API used by the secrets
+// lookup: AppRole login, KV v2 reads and dynamic database credentials.
+type mockVault struct {
+	mu     sync.Mutex
+	issued int
+	renews int
+}
+
+func (m *mockVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			http.Error(w, `{"errors":["invalid role or secret ID"]}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"auth":{"client_token":"approle-token","lease_duration":3600,"renewable":true}}`)
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != "approle-token" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/secret/data/myapp/db":
+		fmt.Fprint(w, `{"data":{"data":{"username":"app","password":"hunter2"},"metadata":{"version":3}}}`)
+	case "/v1/database/creds/readonly":
+		m.issued++
+		fmt.Fprintf(w, `{"lease_id":"database/creds/readonly/%d","lease_duration":1,"renewable":true,"data":{"username":"v-user-%d","password":"pass-%d"}}`, m.issued, m.issued, m.issued)
+	case "/v1/sys/leases/renew":
+		m.renews++
+		fmt.Fprint(w, `{"lease_duration":1,"renewable":true}`)
+	default:
+		http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+	}
+}
+
+func TestVaultKVv2AppRole(t *testing.T) {
+	srv := httptest.NewServer(&mockVault{})
+	t.Cleanup(srv.Close)
+
+	ctx, done := context.WithCancel(context.Background())
+	t.Cleanup(done)
+
+	urn := fmt.Sprintf("vault://%v/secret/myapp/?tls=false&auth=approle&role_id=role&secret_id=secret", strings.TrimPrefix(srv.URL, "http://"))
+	lookup, err := parseSecretsLookupURN(ctx, slog.Default(), urn)
+	require.NoError(t, err)
+
+	v, exists := lookup(ctx, "db.password")
+	assert.True(t, exists)
+	assert.Equal(t, "hunter2", v)
+
+	v, exists = lookup(ctx, "db")
+	assert.True(t, exists)
+	assert.JSONEq(t, `{"username":"app","password":"hunter2"}`, v)
+
+	_, exists = lookup(ctx, "missing")
+	assert.False(t, exists)
+
+	_, err = parseSecretsLookupURN(ctx, slog.Default(), strings.Replace(urn, "secret_id=secret", "secret_id=wrong", 1))
+	assert.ErrorContains(t, err, "invalid role or secret ID")
+}
+
+func TestVaultDatabaseCredentials(t *testing.T) {
+	mock := &mockVault{}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	ctx, done := context.WithCancel(context.Background())
+	t.Cleanup(done)
+
+	urn := fmt.Sprintf("vault://%v/database?tls=false&engine=database&auth=approle&role_id=role&secret_id=secret", strings.TrimPrefix(srv.URL, "http://"))
+	lookup, err := parseSecretsLookupURN(ctx, slog.Default(), urn)
+	require.NoError(t, err)
+
+	// The username and password must come from the same set of credentials.
+	user, exists := lookup(ctx, "readonly.username")
+	require.True(t, exists)
+	pass, exists := lookup(ctx, "readonly.password")
+	require.True(t, exists)
+	assert.Equal(t, "v-user-1", user)
+	assert.Equal(t, "pass-1", pass)
+
+	assert.Eventually(t, func() bool {
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		return mock.renews > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	user, _ = lookup(ctx, "readonly.username")
+	assert.Equal(t, "v-user-1", user)
+}
+
+func TestVaultURNErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, urn := range []string{
+		"vault://localhost:8200?token=foo",
+		"vault://localhost:8200/secret?token=foo&engine=nope",
+		"vault://localhost:8200/secret?token=foo&auth=nope",
+		"vault://localhost:8200/secret?auth=token",
+	} {
+		t.Setenv("VAULT_TOKEN", "")
+		_, err := parseSecretsLookupURN(ctx, slog.Default(), urn)
+		assert.Error(t, err, urn)
+	}
+}
+
+func TestIntegrationVault(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	pool.MaxWait = time.Second * 30
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "hashicorp/vault",
+		Tag:        "latest",
+		Env:        []string{"VAULT_DEV_ROOT_TOKEN_ID=root"},
+		CapAdd:     []string{"IPC_LOCK"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+	_ = resource.Expire(900)
+
+	addr := "localhost:" + resource.GetPort("8200/tcp")
+	writeSecret := func() error {
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/v1/secret/data/myapp/db", bytes.NewReader([]byte(`{"data":{"password":"meow"}}`)))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Vault-Token", "root")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("status code %v", resp.StatusCode)
+		}
+		return nil
+	}
+	require.NoError(t, pool.Retry(writeSecret))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Minute)
+	defer done()
+
+	lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "vault://"+addr+"/secret/myapp/?tls=false&token=root")
+	require.NoError(t, err)
+
+	v, exists := lookup(ctx, "db.password")
+	assert.True(t, exists)
+	assert.Equal(t, "meow", v)
+
+	_, exists = lookup(ctx, "nope")
+	assert.False(t, exists)
+}
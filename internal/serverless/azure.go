@@ -0,0 +1,202 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// azureInvokeRequest is the body of requests sent by the Azure Functions host
+// to a custom handler, see
+// https://learn.microsoft.com/en-us/azure/azure-functions/functions-custom-handlers
+type azureInvokeRequest struct {
+	Data     map[string]json.RawMessage `json:"Data"`
+	Metadata map[string]any             `json:"Metadata"`
+}
+
+// azureHTTPRequest is the data of a HTTP trigger.
+type azureHTTPRequest struct {
+	URL     string              `json:"Url"`
+	Method  string              `json:"Method"`
+	Query   map[string]string   `json:"Query"`
+	Headers map[string][]string `json:"Headers"`
+	Params  map[string]string   `json:"Params"`
+	Body    json.RawMessage     `json:"Body"`
+}
+
+type azureHTTPResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Body       string            `json:"body"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+type azureInvokeResponse struct {
+	Outputs     map[string]any `json:"Outputs"`
+	Logs        []string       `json:"Logs"`
+	ReturnValue any            `json:"ReturnValue"`
+}
+
+// AzureFunctionsHandler returns an http.Handler that implements an Azure
+// Functions custom handler. The data of the trigger binding is injected into
+// the pipeline as a message, where an empty trigger name selects the only
+// binding of the invocation. HTTP triggers are mapped in the same way as
+// HTTPHandler, and scalar invocation metadata is added as message metadata.
+//
+// The sync_response results are set as the return value of the invocation,
+// and for HTTP triggers are also written to the HTTP output binding.
+func (h *Handler) AzureFunctionsHandler(trigger, httpOutput string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req azureInvokeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes)).Decode(&req); err != nil {
+			http.Error(w, "failed to parse invocation request: "+err.Error(), readBodyStatus(err))
+			return
+		}
+
+		data, err := req.triggerData(trigger)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var httpReq *azureHTTPRequest
+		if tmp := (azureHTTPRequest{}); json.Unmarshal(data, &tmp) == nil && tmp.Method != "" && tmp.URL != "" {
+			httpReq = &tmp
+		}
+
+		var msg *service.Message
+		if httpReq != nil {
+			msg = service.NewMessage(rawJSONBytes(httpReq.Body))
+			path := httpReq.URL
+			if u, err := url.Parse(httpReq.URL); err == nil {
+				path = u.Path
+			}
+			query := map[string][]string{}
+			for k, v := range httpReq.Query {
+				query[k] = []string{v}
+			}
+			setHTTPMetadata(msg, httpReq.Method, path, httpReq.Headers, query)
+			for k, v := range httpReq.Params {
+				msg.MetaSetMut(k, v)
+			}
+		} else {
+			msg = service.NewMessage(rawJSONBytes(data))
+		}
+		for k, v := range req.Metadata {
+			switch t := v.(type) {
+			case string, float64, bool:
+				msg.MetaSetMut(k, t)
+			case map[string]any:
+				if k == "sys" {
+					if name, ok := t["MethodName"].(string); ok {
+						msg.MetaSetMut("azure_function_name", name)
+					}
+				}
+			}
+		}
+
+		results, err := h.HandleMessage(r.Context(), msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res := azureInvokeResponse{Outputs: map[string]any{}}
+		if res.ReturnValue, err = resultValues(results); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if httpReq != nil && httpOutput != "" {
+			if res.Outputs[httpOutput], err = azureHTTPResult(results); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+func (r *azureInvokeRequest) triggerData(trigger string) (json.RawMessage, error) {
+	if trigger != "" {
+		data, exists := r.Data[trigger]
+		if !exists {
+			return nil, fmt.Errorf("invocation request does not contain trigger binding %q", trigger)
+		}
+		return data, nil
+	}
+	if len(r.Data) != 1 {
+		return nil, fmt.Errorf("expected a single binding within invocation request, found %v", len(r.Data))
+	}
+	for _, data := range r.Data {
+		return data, nil
+	}
+	return nil, nil
+}
+
+// rawJSONBytes returns the contents of JSON strings and the raw bytes of any
+// other JSON value.
+func rawJSONBytes(raw json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+func azureHTTPResult(batches []service.MessageBatch) (*azureHTTPResponse, error) {
+	var parts []*service.Message
+	for _, b := range batches {
+		parts = append(parts, b...)
+	}
+
+	res := &azureHTTPResponse{StatusCode: http.StatusOK}
+	switch {
+	case len(parts) == 0:
+		res.StatusCode = http.StatusNoContent
+	case len(batches) == 1 && len(parts) == 1:
+		body, err := parts[0].AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = string(body)
+		if contentType, exists := parts[0].MetaGet(contentTypeMetaKey); exists {
+			res.Headers = map[string]string{"Content-Type": contentType}
+		}
+		if statusStr, exists := parts[0].MetaGet(statusCodeMetaKey); exists {
+			if s, err := strconv.Atoi(statusStr); err == nil {
+				res.StatusCode = s
+			}
+		}
+	default:
+		v, err := resultValues(batches)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		res.Body = string(body)
+		res.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+	return res, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerlessAzureFunctionsHTTPTrigger(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: |
+        root = "%v %v %v %v %v".format(@http_server_verb, @http_server_request_path, @name, @azure_function_name, content().string().uppercase())
+        meta http_status_code = "202"
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.AzureFunctionsHandler("", "res"))
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL+"/HttpTrigger", "application/json", strings.NewReader(`{
+  "Data": {
+    "req": {
+      "Url": "http://localhost:7071/api/HttpTrigger?name=meow",
+      "Method": "POST",
+      "Query": { "name": "meow" },
+      "Headers": { "Content-Type": [ "text/plain" ] },
+      "Params": {},
+      "Body": "hello world"
+    }
+  },
+  "Metadata": {
+    "sys": { "MethodName": "HttpTrigger" }
+  }
+}`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{
+  "Outputs": {
+    "res": {
+      "statusCode": 202,
+      "body": "POST /api/HttpTrigger meow HttpTrigger HELLO WORLD"
+    }
+  },
+  "Logs": null,
+  "ReturnValue": "POST /api/HttpTrigger meow HttpTrigger HELLO WORLD"
+}`, string(body))
+}
+
+func TestServerlessAzureFunctionsQueueTrigger(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: |
+        root.doc = this
+        root.count = @DequeueCount
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.AzureFunctionsHandler("item", "res"))
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL+"/QueueTrigger", "application/json", strings.NewReader(`{
+  "Data": {
+    "item": "{\"foo\":\"bar\"}",
+    "other": "ignored"
+  },
+  "Metadata": {
+    "DequeueCount": 1
+  }
+}`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{
+  "Outputs": {},
+  "Logs": null,
+  "ReturnValue": { "doc": { "foo": "bar" }, "count": 1 }
+}`, string(body))
+}
+
+func TestServerlessAzureFunctionsBadRequests(t *testing.T) {
+	h := testHandler(t, `
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.AzureFunctionsHandler("", "res"))
+	t.Cleanup(srv.Close)
+
+	for _, body := range []string{
+		`not json`,
+		`{"Data":{"a":"1","b":"2"}}`,
+		`{"Data":{}}`,
+	} {
+		res, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+	}
+}
@@ -16,6 +16,7 @@ package serverless
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -24,23 +25,16 @@ import (
 // Handler provides a mechanism for controlling the lifetime of a serverless
 // handler runtime of Redpanda Connect.
 type Handler struct {
-	prodFn service.MessageHandlerFunc
-	strm   *service.Stream
+	prodFn        service.MessageBatchHandlerFunc
+	strm          *service.Stream
+	returnsErrors bool
+	maxBodyBytes  int64
 }
 
 // NewHandler creates a new serverless stream handler, where the provided config
 // is used in order to determine the behaviour of the pipeline.
 func NewHandler(confYAML string) (*Handler, error) {
-	env := service.GlobalEnvironment()
-	schema := env.FullConfigSchema("", "")
-	schema.SetFieldDefault(map[string]any{
-		"none": map[string]any{},
-	}, "metrics")
-	schema.SetFieldDefault("json", "logger", "format")
-	schema.SetFieldDefault(map[string]any{
-		"inproc": "____ignored",
-	}, "input")
-	schema.SetFieldDefault(map[string]any{
+	return newHandler(confYAML, map[string]any{
 		"switch": map[string]any{
 			"retry_until_success": false,
 			"cases": []any{
@@ -57,7 +51,49 @@ func NewHandler(confYAML string) (*Handler, error) {
 				},
 			},
 		},
-	}, "output")
+	}, false)
+}
+
+// NewBatchHandler creates a new serverless stream handler where, by default,
+// messages that fail processing are written to the sync response along with
+// successful ones, rather than being rejected. This allows the caller of
+// HandleBatch to report failures of individual messages within a batch.
+//
+// Failures can only be reported for messages that reach the sync response, and
+// therefore a config that sets an output must include a sync_response output.
+func NewBatchHandler(confYAML string) (*Handler, error) {
+	var hasOutput, hasSyncResponse bool
+	if err := service.GlobalEnvironment().FullConfigSchema("", "").NewStreamConfigWalker().WalkComponentsYAML([]byte(confYAML), func(w *service.WalkedComponent) error {
+		if w.ComponentType == "output" {
+			hasOutput = true
+			if w.Name == "sync_response" {
+				hasSyncResponse = true
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if hasOutput && !hasSyncResponse {
+		return nil, errors.New("the output must be or include a sync_response output in order to report the messages of a batch that failed processing")
+	}
+
+	return newHandler(confYAML, map[string]any{
+		"sync_response": map[string]any{},
+	}, true)
+}
+
+func newHandler(confYAML string, defaultOutput any, returnsErrors bool) (*Handler, error) {
+	env := service.GlobalEnvironment()
+	schema := env.FullConfigSchema("", "")
+	schema.SetFieldDefault(map[string]any{
+		"none": map[string]any{},
+	}, "metrics")
+	schema.SetFieldDefault("json", "logger", "format")
+	schema.SetFieldDefault(map[string]any{
+		"inproc": "____ignored",
+	}, "input")
+	schema.SetFieldDefault(defaultOutput, "output")
 
 	strmBuilder := env.NewStreamBuilder()
 	strmBuilder.SetSchema(schema)
@@ -66,7 +102,7 @@ func NewHandler(confYAML string) (*Handler, error) {
 		return nil, err
 	}
 
-	prod, err := strmBuilder.AddProducerFunc()
+	prod, err := strmBuilder.AddBatchProducerFunc()
 	if err != nil {
 		return nil, err
 	}
@@ -81,8 +117,10 @@ func NewHandler(confYAML string) (*Handler, error) {
 	}()
 
 	return &Handler{
-		prodFn: prod,
-		strm:   strm,
+		prodFn:        prod,
+		strm:          strm,
+		returnsErrors: returnsErrors,
+		maxBodyBytes:  DefaultMaxBodyBytes,
 	}, nil
 }
 
@@ -97,14 +135,26 @@ func (h *Handler) Handle(ctx context.Context, v any) (any, error) {
 	msg := service.NewMessage(nil)
 	msg.SetStructured(v)
 
-	msg, store := msg.WithSyncResponseStore()
-
-	if err := h.prodFn(ctx, msg); err != nil {
+	resultBatches, err := h.HandleMessage(ctx, msg)
+	if err != nil {
 		return nil, err
 	}
+	if h.returnsErrors {
+		for _, batch := range resultBatches {
+			for _, p := range batch {
+				if perr := p.GetError(); perr != nil {
+					return nil, fmt.Errorf("processing failed due to: %w", perr)
+				}
+			}
+		}
+	}
+	return StructuredResults(resultBatches)
+}
 
-	resultBatches := store.Read()
-
+// StructuredResults converts the result batches of a handler into a single
+// structured value, where a single result is returned as is, the results of a
+// single batch as an array, and otherwise an array of batch arrays.
+func StructuredResults(resultBatches []service.MessageBatch) (any, error) {
 	anyResults := make([][]any, len(resultBatches))
 	for i, batch := range resultBatches {
 		batchResults := make([]any, len(batch))
@@ -116,17 +166,51 @@ func (h *Handler) Handle(ctx context.Context, v any) (any, error) {
 		}
 		anyResults[i] = batchResults
 	}
+	return flattenResults(anyResults), nil
+}
+
+// HandleMessage injects a message into the underlying Benthos pipeline and
+// returns the batches of messages written to sync_response outputs.
+func (h *Handler) HandleMessage(ctx context.Context, msg *service.Message) ([]service.MessageBatch, error) {
+	return h.HandleBatch(ctx, service.MessageBatch{msg})
+}
+
+// HandleBatch injects a batch of messages into the underlying Benthos pipeline
+// and returns the batches of messages written to sync_response outputs.
+func (h *Handler) HandleBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	first, store := batch[0].WithSyncResponseStore()
+
+	// All messages of the batch share the same response store.
+	storeCtx := first.Context()
+	inBatch := make(service.MessageBatch, len(batch))
+	inBatch[0] = first
+	for i := 1; i < len(batch); i++ {
+		inBatch[i] = batch[i].WithContext(storeCtx)
+	}
+
+	if err := h.prodFn(ctx, inBatch); err != nil {
+		return nil, err
+	}
+	return store.Read(), nil
+}
 
+// flattenResults returns a single result as is, the results of a single batch
+// as an array, and otherwise an array of batch arrays.
+func flattenResults(anyResults [][]any) any {
 	if len(anyResults) == 1 {
 		if len(anyResults[0]) == 1 {
-			return anyResults[0][0], nil
+			return anyResults[0][0]
 		}
-		return anyResults[0], nil
+		return anyResults[0]
 	}
 
 	genBatchOfBatches := make([]any, len(anyResults))
 	for i, b := range anyResults {
 		genBatchOfBatches[i] = b
 	}
-	return genBatchOfBatches, nil
+	return genBatchOfBatches
 }
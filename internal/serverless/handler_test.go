@@ -22,6 +22,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/redpanda-data/benthos/v4/public/service"
+
 	"github.com/redpanda-data/connect/v4/internal/serverless"
 
 	_ "github.com/redpanda-data/connect/v4/public/components/pure"
@@ -47,3 +49,80 @@ logger:
 
 	require.NoError(t, h.Close(ctx))
 }
+
+func TestServerlessBatchHandlerErrors(t *testing.T) {
+	h, err := serverless.NewBatchHandler(`
+pipeline:
+  processors:
+    - mapping: |
+        root = if content().string().contains("bad") { throw("nope") } else { content().string().uppercase() }
+logger:
+  level: NONE
+`)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	results, err := h.HandleBatch(ctx, service.MessageBatch{
+		service.NewMessage([]byte("foo")),
+		service.NewMessage([]byte("bad")),
+		service.NewMessage([]byte("bar")),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Len(t, results[0], 3)
+
+	for i, exp := range []string{"FOO", "", "BAR"} {
+		if exp == "" {
+			assert.Error(t, results[0][i].GetError(), i)
+			continue
+		}
+		require.NoError(t, results[0][i].GetError(), i)
+		b, err := results[0][i].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(b), i)
+	}
+
+	_, err = h.Handle(ctx, "bad")
+	require.ErrorContains(t, err, "nope")
+
+	res, err := h.Handle(ctx, "good")
+	require.NoError(t, err)
+	assert.Equal(t, "GOOD", res)
+
+	require.NoError(t, h.Close(ctx))
+}
+
+func TestServerlessBatchHandlerRequiresSyncResponse(t *testing.T) {
+	_, err := serverless.NewBatchHandler(`
+output:
+  drop: {}
+logger:
+  level: NONE
+`)
+	require.ErrorContains(t, err, "must be or include a sync_response output")
+
+	h, err := serverless.NewBatchHandler(`
+output:
+  switch:
+    cases:
+      - check: errored()
+        output:
+          drop: {}
+      - output:
+          sync_response: {}
+logger:
+  level: NONE
+`)
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	res, err := h.Handle(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", res)
+
+	require.NoError(t, h.Close(ctx))
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Metadata keys that can be set on a sync_response result in order to
+// customise the status code and content type of the HTTP response.
+const (
+	statusCodeMetaKey  = "http_status_code"
+	contentTypeMetaKey = "http_content_type"
+)
+
+// DefaultMaxBodyBytes is the default limit on the size of request bodies read
+// by the HTTP handlers, which matches the request size limit of Cloud Run.
+const DefaultMaxBodyBytes = 32 << 20
+
+// SetMaxBodyBytes sets the limit on the size of request bodies read by the HTTP
+// handlers, where requests that exceed it receive a 413 response.
+func (h *Handler) SetMaxBodyBytes(n int64) {
+	h.maxBodyBytes = n
+}
+
+// readBodyStatus returns the status code of a response to a request body that
+// could not be read.
+func readBodyStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// HTTPHandler returns an http.Handler for platforms that invoke functions
+// with plain HTTP requests, such as Cloud Run and Knative. The body of each
+// request is injected into the pipeline as a message, with headers, query
+// parameters and request details added as metadata following the conventions
+// of the http_server input. The sync_response results are written back as the
+// response, where a single result can set the metadata keys http_status_code
+// and http_content_type to customise it.
+func (h *Handler) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+		if err != nil {
+			http.Error(w, "failed to read request body: "+err.Error(), readBodyStatus(err))
+			return
+		}
+
+		msg := service.NewMessage(body)
+		setHTTPMetadata(msg, r.Method, r.URL.Path, r.Header, r.URL.Query())
+		msg.MetaSetMut("http_server_user_agent", r.UserAgent())
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			msg.MetaSetMut("http_server_remote_ip", host)
+		}
+
+		results, err := h.HandleMessage(r.Context(), msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeHTTPResults(w, results)
+	})
+}
+
+func setHTTPMetadata(msg *service.Message, method, path string, headers http.Header, query map[string][]string) {
+	msg.MetaSetMut("http_server_request_path", path)
+	msg.MetaSetMut("http_server_verb", method)
+	for k, v := range headers {
+		if len(v) > 0 {
+			msg.MetaSetMut(k, v[0])
+		}
+	}
+	for k, v := range query {
+		if len(v) > 0 {
+			msg.MetaSetMut(k, v[0])
+		}
+	}
+}
+
+// writeHTTPResults writes a single sync_response result as the raw response
+// body, and otherwise a JSON array of results in the same shape as Handle.
+func writeHTTPResults(w http.ResponseWriter, batches []service.MessageBatch) {
+	var parts []*service.Message
+	for _, b := range batches {
+		parts = append(parts, b...)
+	}
+
+	switch {
+	case len(parts) == 0:
+		w.WriteHeader(http.StatusNoContent)
+	case len(batches) == 1 && len(parts) == 1:
+		body, err := parts[0].AsBytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if contentType, exists := parts[0].MetaGet(contentTypeMetaKey); exists {
+			w.Header().Set("Content-Type", contentType)
+		}
+		status := http.StatusOK
+		if statusStr, exists := parts[0].MetaGet(statusCodeMetaKey); exists {
+			if s, err := strconv.Atoi(statusStr); err == nil {
+				status = s
+			}
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	default:
+		v, err := resultValues(batches)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// resultValues converts sync_response results into values that can be
+// marshalled as JSON, where results that aren't valid JSON are strings.
+func resultValues(batches []service.MessageBatch) (any, error) {
+	anyResults := make([][]any, len(batches))
+	for i, batch := range batches {
+		batchResults := make([]any, len(batch))
+		for j, p := range batch {
+			if v, err := p.AsStructured(); err == nil {
+				batchResults[j] = v
+				continue
+			}
+			b, err := p.AsBytes()
+			if err != nil {
+				return nil, err
+			}
+			batchResults[j] = string(b)
+		}
+		anyResults[i] = batchResults
+	}
+	return flattenResults(anyResults), nil
+}
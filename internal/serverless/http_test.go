@@ -0,0 +1,157 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/connect/v4/internal/serverless"
+
+	_ "github.com/redpanda-data/connect/v4/public/components/pure"
+)
+
+func testHandler(t *testing.T, confYAML string) *serverless.Handler {
+	t.Helper()
+
+	h, err := serverless.NewHandler(confYAML)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+		require.NoError(t, h.Close(ctx))
+	})
+	return h
+}
+
+func TestServerlessHTTPHandler(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: |
+        root = "%v %v %v %v".format(@http_server_verb, @http_server_request_path, @Foo, content().string().uppercase())
+        meta http_content_type = "text/plain"
+        meta http_status_code = "201"
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.HTTPHandler())
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/meow", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	req.Header.Set("Foo", "bar")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "text/plain", res.Header.Get("Content-Type"))
+	assert.Equal(t, "POST /meow bar HELLO WORLD", string(body))
+}
+
+func TestServerlessHTTPHandlerBatch(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: 'root = [ content().string(), content().string().uppercase() ]'
+    - unarchive:
+        format: json_array
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.HTTPHandler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.JSONEq(t, `["hello","HELLO"]`, string(body))
+}
+
+func TestServerlessHTTPHandlerError(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: 'root = throw("nope")'
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.HTTPHandler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Contains(t, string(body), "nope")
+}
+
+func TestServerlessHTTPHandlerMaxBodyBytes(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: 'root = content().uppercase()'
+logger:
+  level: NONE
+`)
+	h.SetMaxBodyBytes(5)
+
+	srv := httptest.NewServer(h.HTTPHandler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = http.Post(srv.URL, "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+	pubSubSrv := httptest.NewServer(h.PubSubPushHandler())
+	t.Cleanup(pubSubSrv.Close)
+
+	res, err = http.Post(pubSubSrv.URL, "application/json", strings.NewReader(`{"message":{"data":"aGVsbG8="}}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// pubSubPushEnvelope is the body of requests sent by Pub/Sub push
+// subscriptions, see https://cloud.google.com/pubsub/docs/push
+type pubSubPushEnvelope struct {
+	Message struct {
+		Data        []byte            `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		MessageID   string            `json:"messageId"`
+		PublishTime time.Time         `json:"publishTime"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"message"`
+	Subscription    string `json:"subscription"`
+	DeliveryAttempt *int   `json:"deliveryAttempt"`
+}
+
+// PubSubPushHandler returns an http.Handler that accepts messages from Pub/Sub
+// push subscriptions, which is how Pub/Sub triggered Cloud Run functions are
+// invoked. The data of each message is injected into the pipeline with its
+// attributes added as metadata, following the conventions of the gcp_pubsub
+// input.
+//
+// A successful response acknowledges the message and contains the
+// sync_response results, whereas pipeline errors result in a 500 response so
+// that the message is redelivered.
+func (h *Handler) PubSubPushHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env pubSubPushEnvelope
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes)).Decode(&env); err != nil {
+			http.Error(w, "failed to parse Pub/Sub push message: "+err.Error(), readBodyStatus(err))
+			return
+		}
+
+		msg := service.NewMessage(env.Message.Data)
+		for k, v := range env.Message.Attributes {
+			msg.MetaSetMut(k, v)
+		}
+		msg.MetaSetMut("gcp_pubsub_message_id", env.Message.MessageID)
+		msg.MetaSetMut("gcp_pubsub_subscription", env.Subscription)
+		if !env.Message.PublishTime.IsZero() {
+			msg.MetaSetMut("gcp_pubsub_publish_time_unix", env.Message.PublishTime.Unix())
+		}
+		if env.Message.OrderingKey != "" {
+			msg.MetaSetMut("gcp_pubsub_ordering_key", env.Message.OrderingKey)
+		}
+		if env.DeliveryAttempt != nil {
+			msg.MetaSetMut("gcp_pubsub_delivery_attempt", *env.DeliveryAttempt)
+		}
+
+		results, err := h.HandleMessage(r.Context(), msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeHTTPResults(w, results)
+	})
+}
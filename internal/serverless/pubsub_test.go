@@ -0,0 +1,91 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerlessPubSubPushHandler(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: |
+        root.content = content().string()
+        root.foo = @foo
+        root.id = @gcp_pubsub_message_id
+        root.sub = @gcp_pubsub_subscription
+        root.published = @gcp_pubsub_publish_time_unix
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.PubSubPushHandler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "application/json", strings.NewReader(`{
+  "message": {
+    "data": "aGVsbG8gd29ybGQ=",
+    "attributes": { "foo": "bar" },
+    "messageId": "123",
+    "publishTime": "2024-01-02T03:04:05Z"
+  },
+  "subscription": "projects/foo/subscriptions/bar"
+}`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{
+  "content": "hello world",
+  "foo": "bar",
+  "id": "123",
+  "sub": "projects/foo/subscriptions/bar",
+  "published": 1704164645
+}`, string(body))
+}
+
+func TestServerlessPubSubPushHandlerErrors(t *testing.T) {
+	h := testHandler(t, `
+pipeline:
+  processors:
+    - mapping: 'root = throw("nope")'
+logger:
+  level: NONE
+`)
+
+	srv := httptest.NewServer(h.PubSubPushHandler())
+	t.Cleanup(srv.Close)
+
+	res, err := http.Post(srv.URL, "application/json", strings.NewReader(`not json`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"message":{"data":"aGVsbG8="}}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serverless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// PubSubPushPath is the path at which RunHTTP serves Pub/Sub push requests.
+const PubSubPushPath = "/pubsub/push"
+
+// RunHTTP executes Redpanda Connect as a HTTP function, configured in the same
+// way as RunLambda.
+//
+// When the environment variable FUNCTIONS_CUSTOMHANDLER_PORT is set the
+// function runs as an Azure Functions custom handler, where the trigger and
+// HTTP output bindings can be set with CONNECT_AZURE_TRIGGER and
+// CONNECT_AZURE_HTTP_OUTPUT. Otherwise requests are served on the port PORT
+// (defaulting to 8080) as used by Cloud Run and Knative, with Pub/Sub push
+// requests accepted at PubSubPushPath.
+//
+// Request bodies are limited to DefaultMaxBodyBytes, which can be changed with
+// CONNECT_HTTP_MAX_BODY_BYTES.
+func RunHTTP() {
+	handler, err := NewHandler(ReadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Initialisation error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if maxBodyStr := os.Getenv("CONNECT_HTTP_MAX_BODY_BYTES"); maxBodyStr != "" {
+		maxBody, err := strconv.ParseInt(maxBodyStr, 10, 64)
+		if err != nil || maxBody <= 0 {
+			fmt.Fprintf(os.Stderr, "Initialisation error: CONNECT_HTTP_MAX_BODY_BYTES must be a positive number of bytes, got %q\n", maxBodyStr)
+			os.Exit(1)
+		}
+		handler.SetMaxBodyBytes(maxBody)
+	}
+
+	mux := http.NewServeMux()
+	port := os.Getenv("FUNCTIONS_CUSTOMHANDLER_PORT")
+	if port != "" {
+		httpOutput := os.Getenv("CONNECT_AZURE_HTTP_OUTPUT")
+		if httpOutput == "" {
+			httpOutput = "res"
+		}
+		mux.Handle("/", handler.AzureFunctionsHandler(os.Getenv("CONNECT_AZURE_TRIGGER"), httpOutput))
+	} else {
+		if port = os.Getenv("PORT"); port == "" {
+			port = "8080"
+		}
+		mux.Handle(PubSubPushPath, handler.PubSubPushHandler())
+		mux.Handle("/", handler.HTTPHandler())
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errC:
+	case <-sigCtx.Done():
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+	defer done()
+
+	_ = srv.Shutdown(ctx)
+	if err = handler.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Shut down error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// dumpExporter writes each payload as a line of JSON to a local file, or to
+// stdout, so that operators can inspect exactly what is being exported.
+type dumpExporter struct {
+	logger *service.Logger
+	path   string
+
+	mut sync.Mutex
+}
+
+func (d *dumpExporter) export(p *payload) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		d.logger.With("error", err).Warn("Failed to marshal telemetry payload")
+		return
+	}
+	b = append(b, '\n')
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	var w io.Writer = os.Stdout
+	if d.path != "-" {
+		f, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			d.logger.With("error", err).Warn("Failed to open telemetry dump file")
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := w.Write(b); err != nil {
+		d.logger.With("error", err).Warn("Failed to write telemetry payload")
+	}
+}
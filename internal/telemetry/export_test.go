@@ -0,0 +1,135 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testPayload() *payload {
+	return &payload{
+		ID:     "foo",
+		Uptime: 10,
+		Components: []componentInfo{
+			{Type: "input", Name: "generate"},
+			{Type: "processor", Name: "mapping"},
+			{Type: "processor", Name: "mapping"},
+			{Type: "output", Name: "aws_s3"},
+		},
+	}
+}
+
+func TestDumpExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+
+	d := &dumpExporter{logger: service.MockResources().Logger(), path: path}
+	d.export(testPayload())
+	d.export(&payload{ID: "bar", Uptime: 20})
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+
+	var p payload
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &p))
+	assert.Equal(t, *testPayload(), p)
+
+	assert.JSONEq(t, `{"id":"bar","uptime":20,"components":null}`, lines[1])
+}
+
+func TestOTLPExporter(t *testing.T) {
+	reqC := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer meow", r.Header.Get("Authorization"))
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var req colmetricspb.ExportMetricsServiceRequest
+		require.NoError(t, proto.Unmarshal(b, &req))
+		reqC <- &req
+	}))
+	t.Cleanup(srv.Close)
+
+	o := newOTLPExporter(service.MockResources().Logger(), "1.2.3", srv.URL, map[string]string{
+		"Authorization": "Bearer meow",
+	})
+	o.export(testPayload())
+
+	var req *colmetricspb.ExportMetricsServiceRequest
+	select {
+	case req = <-reqC:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for request")
+	}
+
+	require.Len(t, req.ResourceMetrics, 1)
+	rm := req.ResourceMetrics[0]
+
+	resAttrs := map[string]string{}
+	for _, kv := range rm.Resource.Attributes {
+		resAttrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, map[string]string{
+		"service.name":        "redpanda-connect",
+		"service.instance.id": "foo",
+		"service.version":     "1.2.3",
+	}, resAttrs)
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := rm.ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "redpanda_connect.uptime", metrics[0].Name)
+	assert.Equal(t, int64(10), metrics[0].GetGauge().DataPoints[0].GetAsInt())
+
+	assert.Equal(t, "redpanda_connect.components", metrics[1].Name)
+	counts := map[string]int64{}
+	for _, dp := range metrics[1].GetGauge().DataPoints {
+		var typ, name string
+		for _, kv := range dp.Attributes {
+			switch kv.Key {
+			case "component.type":
+				typ = kv.Value.GetStringValue()
+			case "component.name":
+				name = kv.Value.GetStringValue()
+			}
+		}
+		counts[typ+"/"+name] = dp.GetAsInt()
+	}
+	assert.Equal(t, map[string]int64{
+		"input/generate":    1,
+		"processor/mapping": 2,
+		"output/aws_s3":     1,
+	}, counts)
+}
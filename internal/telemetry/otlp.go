@@ -0,0 +1,133 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpExporter sends payloads to an OTLP/HTTP collector as gauges, which allows
+// the component usage of a fleet of instances to be tracked by its operators.
+type otlpExporter struct {
+	logger  *service.Logger
+	version string
+	resty   *resty.Client
+}
+
+func newOTLPExporter(logger *service.Logger, version, endpoint string, headers map[string]string) *otlpExporter {
+	return &otlpExporter{
+		logger:  logger,
+		version: version,
+		resty: resty.New().
+			SetHeader("User-Agent", "RedpandaConnect/"+version).
+			SetHeader("Content-Type", "application/x-protobuf").
+			SetHeaders(headers).
+			SetBaseURL(endpoint).
+			SetTimeout(10 * time.Second).
+			SetLogger(&logWrapper{l: logger}).
+			SetRetryCount(3),
+	}
+}
+
+func stringKV(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   k,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func intGauge(name, description, unit string, points ...*metricspb.NumberDataPoint) *metricspb.Metric {
+	return &metricspb.Metric{
+		Name:        name,
+		Description: description,
+		Unit:        unit,
+		Data:        &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}},
+	}
+}
+
+// otlpRequest converts a payload into an OTLP metrics export request, where
+// each distinct component type and name is a data point of a count gauge.
+func otlpRequest(p *payload, version string, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	ts := uint64(now.UnixNano())
+
+	type componentKey struct{ typ, name string }
+	var keys []componentKey
+	counts := map[componentKey]int64{}
+	for _, c := range p.Components {
+		k := componentKey{typ: c.Type, name: c.Name}
+		if _, exists := counts[k]; !exists {
+			keys = append(keys, k)
+		}
+		counts[k]++
+	}
+
+	componentPoints := make([]*metricspb.NumberDataPoint, 0, len(keys))
+	for _, k := range keys {
+		componentPoints = append(componentPoints, &metricspb.NumberDataPoint{
+			Attributes:   []*commonpb.KeyValue{stringKV("component.type", k.typ), stringKV("component.name", k.name)},
+			TimeUnixNano: ts,
+			Value:        &metricspb.NumberDataPoint_AsInt{AsInt: counts[k]},
+		})
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{
+					stringKV("service.name", "redpanda-connect"),
+					stringKV("service.instance.id", p.ID),
+					stringKV("service.version", version),
+				},
+			},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "github.com/redpanda-data/connect/v4/internal/telemetry"},
+				Metrics: []*metricspb.Metric{
+					intGauge("redpanda_connect.uptime", "The duration for which the config has been running.", "s", &metricspb.NumberDataPoint{
+						TimeUnixNano: ts,
+						Value:        &metricspb.NumberDataPoint_AsInt{AsInt: p.Uptime},
+					}),
+					intGauge("redpanda_connect.components", "The number of each component within the running config.", "{component}", componentPoints...),
+				},
+			}},
+		}},
+	}
+}
+
+func (o *otlpExporter) export(p *payload) {
+	body, err := proto.Marshal(otlpRequest(p, o.version, time.Now()))
+	if err != nil {
+		o.logger.With("error", err).Warn("Failed to marshal telemetry payload")
+		return
+	}
+
+	response, err := o.resty.NewRequest().
+		SetBody(body).
+		Post("/v1/metrics")
+	if err != nil {
+		o.logger.With("error", err).Warn("Failed to send telemetry payload to OTLP collector")
+		return
+	}
+	if response.IsError() {
+		o.logger.With("status_code", response.StatusCode()).Warn("Failed to send telemetry payload to OTLP collector")
+	}
+}
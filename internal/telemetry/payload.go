@@ -68,7 +68,7 @@ func extractPayload(identifier string, logger *service.Logger, schema *service.C
 
 // This function runs asynchronously and is solely where telemetry data is
 // exported.
-func exporterLoop(p *payload, exportDelay, exportPeriod time.Duration, exporter *telemetryExporter) {
+func exporterLoop(p *payload, exportDelay, exportPeriod time.Duration, exporters []payloadExporter) {
 	started := time.Now()
 
 	// First, wait until after the export delay has passed.
@@ -76,7 +76,9 @@ func exporterLoop(p *payload, exportDelay, exportPeriod time.Duration, exporter
 
 	for {
 		p.Uptime = int64(time.Since(started) / time.Second)
-		exporter.export(p)
+		for _, e := range exporters {
+			e.export(p)
+		}
 
 		// Now wait for the next export.
 		time.Sleep(exportPeriod)
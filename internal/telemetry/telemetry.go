@@ -82,30 +82,57 @@ func ParseRSAPrivateKeyFromPEM(key []byte) (*rsa.PrivateKey, error) {
 	return pkey, nil
 }
 
+// ExportOptions customises where telemetry payloads are delivered in addition
+// to, or instead of, Redpanda.
+type ExportOptions struct {
+	// DisableRedpanda prevents payloads from being sent to Redpanda.
+	DisableRedpanda bool
+
+	// DumpPath is the path of a file, or `-` for stdout, that each payload is
+	// written to as a line of JSON.
+	DumpPath string
+
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector that each payload
+	// is sent to as metrics.
+	OTLPEndpoint string
+
+	// OTLPHeaders are added to each request sent to the OTLP collector.
+	OTLPHeaders map[string]string
+}
+
+// payloadExporter delivers a telemetry payload to a single destination.
+type payloadExporter interface {
+	export(p *payload)
+}
+
 // ActivateExporter runs the telemetry exporter asynchronously, provided all
 // conditions for telemetry are satisfied.
-func ActivateExporter(identifier, version string, logger *service.Logger, schema *service.ConfigSchema, conf *service.ParsedConfig) {
+func ActivateExporter(identifier, version string, logger *service.Logger, schema *service.ConfigSchema, conf *service.ParsedConfig, opts ExportOptions) {
+	var exporters []payloadExporter
+
 	// If TLS information isn't present in the build then we do not send
-	// telemetry data.
-	if privateKey == "" {
-		return
+	// telemetry data to Redpanda.
+	if !opts.DisableRedpanda && privateKey != "" {
+		if tExporter := newTelemetryExporter(version, logger); tExporter != nil {
+			exporters = append(exporters, tExporter)
+		}
+	}
+	if opts.OTLPEndpoint != "" {
+		exporters = append(exporters, newOTLPExporter(logger, version, opts.OTLPEndpoint, opts.OTLPHeaders))
 	}
 
-	// Parse private key for signing the JWT payload before sending it to our telemetry endpoint.
-	rsaPrivateKey, err := ParseRSAPrivateKeyFromPEM([]byte(privateKey))
-	if err != nil {
-		logger.With("error", err).Debug("Failed to parse private key")
-		return
+	var dump *dumpExporter
+	if opts.DumpPath != "" {
+		dump = &dumpExporter{logger: logger, path: opts.DumpPath}
+		exporters = append(exporters, dump)
 	}
-	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: rsaPrivateKey},
-		(&jose.SignerOptions{}).WithHeader("key_generation", 1))
-	if err != nil {
-		logger.With("error", err).Debug("Failed to create JWT signer")
+	if len(exporters) == 0 {
 		return
 	}
 
 	// Parse export delay and periods.
 	exportDelay, exportPeriod := defaultExportDelay, defaultExportPeriod
+	var err error
 	if ExportDelay != "" {
 		if exportDelay, err = time.ParseDuration(ExportDelay); err != nil {
 			logger.With("error", err).Debug("Failed to parse export delay")
@@ -119,12 +146,41 @@ func ActivateExporter(identifier, version string, logger *service.Logger, schema
 		}
 	}
 
+	payload, err := extractPayload(identifier, logger, schema, conf)
+	if err != nil {
+		logger.With("error", err).Debug("Failed to create telemetry payload")
+		return
+	}
+
+	// The payload is dumped straight away so that it can be inspected without
+	// waiting for the export delay.
+	if dump != nil {
+		dump.export(payload)
+	}
+
+	go exporterLoop(payload, exportDelay, exportPeriod, exporters)
+}
+
+func newTelemetryExporter(version string, logger *service.Logger) *telemetryExporter {
+	// Parse private key for signing the JWT payload before sending it to our telemetry endpoint.
+	rsaPrivateKey, err := ParseRSAPrivateKeyFromPEM([]byte(privateKey))
+	if err != nil {
+		logger.With("error", err).Debug("Failed to parse private key")
+		return nil
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: rsaPrivateKey},
+		(&jose.SignerOptions{}).WithHeader("key_generation", 1))
+	if err != nil {
+		logger.With("error", err).Debug("Failed to create JWT signer")
+		return nil
+	}
+
 	exportHost := defaultExportHost
 	if ExportHost != "" {
 		exportHost = ExportHost
 	}
 
-	tExporter := &telemetryExporter{
+	return &telemetryExporter{
 		logger: logger,
 		Resty: resty.New().
 			SetHeader("User-Agent", "RedpandaConnect/"+version).
@@ -137,14 +193,6 @@ func ActivateExporter(identifier, version string, logger *service.Logger, schema
 			SetRetryCount(3),
 		JWTBuilder: josejwt.Signed(signer),
 	}
-
-	payload, err := extractPayload(identifier, logger, schema, conf)
-	if err != nil {
-		logger.With("error", err).Debug("Failed to create telemetry payload")
-		return
-	}
-
-	go exporterLoop(payload, exportDelay, exportPeriod, tExporter)
 }
 
 type telemetryExporter struct {
@@ -17,6 +17,7 @@ import (
 	_ "github.com/redpanda-data/connect/v4/public/components/community"
 
 	// Import all enterprise components.
+	_ "github.com/redpanda-data/connect/v4/public/components/anthropic"
 	_ "github.com/redpanda-data/connect/v4/public/components/aws/enterprise"
 	_ "github.com/redpanda-data/connect/v4/public/components/cohere"
 	_ "github.com/redpanda-data/connect/v4/public/components/gcp/enterprise"
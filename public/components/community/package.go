@@ -27,6 +27,7 @@ import (
 	_ "github.com/redpanda-data/connect/v4/public/components/beanstalkd"
 	_ "github.com/redpanda-data/connect/v4/public/components/cassandra"
 	_ "github.com/redpanda-data/connect/v4/public/components/changelog"
+	_ "github.com/redpanda-data/connect/v4/public/components/clickhouse"
 	_ "github.com/redpanda-data/connect/v4/public/components/cockroachdb"
 	_ "github.com/redpanda-data/connect/v4/public/components/confluent"
 	_ "github.com/redpanda-data/connect/v4/public/components/couchbase"
@@ -70,6 +71,7 @@ import (
 	_ "github.com/redpanda-data/connect/v4/public/components/statsd"
 	_ "github.com/redpanda-data/connect/v4/public/components/timeplus"
 	_ "github.com/redpanda-data/connect/v4/public/components/twitter"
+	_ "github.com/redpanda-data/connect/v4/public/components/wal"
 	_ "github.com/redpanda-data/connect/v4/public/components/wasm"
 	_ "github.com/redpanda-data/connect/v4/public/components/zeromq"
 )